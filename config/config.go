@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
@@ -24,6 +25,10 @@ type Config struct {
 	WechatAPIURL                     string
 	WechatMchAPIURL                  string // 微信商户API地址
 
+	// 微信会话配置
+	WechatSessionSecret string // session_key 加密存储密钥（非开发环境必须配置）
+	WechatSessionTTL    string // session_key 服务端有效期
+
 	// 微信支付证书配置
 	WechatMchPrivateKeyPath string // 商户API证书私钥文件路径
 	WechatPayPublicKeyPath  string // 微信支付公钥文件路径
@@ -36,7 +41,13 @@ type Config struct {
 	OBSURL              string
 
 	// 安全配置
-	UserIDSecretKey string // 用户标识符加密密钥
+	UserIDSecretKey       string // 用户标识符加密密钥
+	RejectRawOpenIDParams bool   // 是否拒绝路径参数中直接传入原始openID
+
+	// 敏感数据加密配置
+	PIIEncryptionKeys   []string // 敏感字段主密钥列表，格式 "版本:密钥"，支持多版本用于轮换
+	PIIActiveKeyVersion string   // 当前用于加密的主密钥版本
+	PIIBlindIndexKey    string   // 盲索引密钥（用于手机号等字段的等值查询，轮换需重建索引）
 
 	// 时区配置
 	AppTimeZone string
@@ -48,8 +59,42 @@ type Config struct {
 	HTTPClientTimeout string // HTTP客户端超时时间
 
 	// 开发配置
-	EnableDevLogin bool   // 是否启用开发登录接口
-	LogLevel       string // 日志级别
+	EnableDevLogin      bool     // 是否启用开发登录接口（生产环境强制关闭）
+	TestIdentitySecret  string   // 测试身份签名密钥
+	TestIdentityOpenIDs []string // 允许使用测试身份登录的openID白名单
+	TestIdentityListen  string   // 测试身份登录接口的内部监听地址（只能是本机回环地址，不经过对外端口和反向代理）
+	LogLevel            string   // 日志级别
+
+	// 代理提现配置
+	RequireVerifiedPhoneForWithdraw bool // 代理提现前是否要求已验证手机号
+
+	// 账号注销配置
+	AccountDeletionCoolingOff string // 注销冷静期时长
+
+	// 离线学习包配置
+	StudyPackSecret   string // 离线学习包签名密钥（非开发环境必须配置）
+	StudyPackValidity string // 离线学习包凭证有效期（期间离线作答可上传）
+
+	// 测验配置
+	QuizAudioAllowedHosts []string // 听音题可下载发音的外部域名（BASE_API_URL 的域名始终允许）
+
+	// 对象存储配置
+	StorageBackend       string // 存储后端：local、s3 或 obs
+	StorageLocalDir      string // 本地存储根目录
+	StoragePublicURL     string // 对象公开访问地址前缀（本地存储默认使用 BaseAPIURL，OBS 默认使用 OBSURL）
+	StorageSigningSecret string // 本地存储签名地址密钥（非开发环境使用本地存储时必须配置）
+	StorageSignedURLTTL  string // 头像、小程序码等私有文件签名地址有效期
+	S3Endpoint           string // S3 兼容存储服务地址
+	S3Region             string
+	S3Bucket             string
+	S3AccessKey          string
+	S3SecretKey          string
+	S3PathStyle          bool   // 是否使用路径风格访问存储桶
+	OBSEndpoint          string // 华为云 OBS 服务地址，如 https://obs.cn-south-1.myhuaweicloud.com
+	OBSBucket            string
+	OBSAccessKey         string
+	OBSSecretKey         string
+	LegacyFileRoot       string // 旧版头像文件所在的网站根目录（迁移前的数据）
 
 	// 小程序码配置
 	QRCodeEnvVersion string // 小程序码环境版本 (release/trial/develop)
@@ -71,11 +116,12 @@ type Config struct {
 
 // GetConfig 获取应用配置
 func GetConfig() *Config {
+	environment := getEnv("ENVIRONMENT", "development")
 	return &Config{
 		// 服务器配置
 		ServerPort:  getEnv("SERVER_PORT", "8080"),
 		BaseAPIURL:  getEnv("BASE_API_URL", "https://backend.edmounds.top"),
-		Environment: getEnv("ENVIRONMENT", "development"),
+		Environment: environment,
 
 		// 微信小程序配置
 		WechatMchID:                      getEnv("WECHAT_MCH_ID", ""),
@@ -86,6 +132,10 @@ func GetConfig() *Config {
 		WechatAPIURL:                     getEnv("WECHAT_API_URL", "https://api.weixin.qq.com"),
 		WechatMchAPIURL:                  getEnv("WECHAT_MCH_API_URL", "https://api.mch.weixin.qq.com"),
 
+		// 微信会话配置
+		WechatSessionSecret: getSecretEnv("WECHAT_SESSION_SECRET", environment),
+		WechatSessionTTL:    getEnv("WECHAT_SESSION_TTL", "72h"),
+
 		// 微信支付证书配置
 		WechatMchPrivateKeyPath: getEnv("WECHAT_MCH_PRIVATE_KEY_PATH", "cert/apiclient_key.pem"),
 		WechatPayPublicKeyPath:  getEnv("WECHAT_PAY_PUBLIC_KEY_PATH", "cert/pub_key.pem"),
@@ -98,7 +148,13 @@ func GetConfig() *Config {
 		OBSURL:              getEnv("OBS_URL", "https://mini-app-89d7.obs.cn-south-1.myhuaweicloud.com"),
 
 		// 安全配置
-		UserIDSecretKey: getEnv("USER_ID_SECRET_KEY", "Chenqichen666"),
+		UserIDSecretKey:       getEnv("USER_ID_SECRET_KEY", "Chenqichen666"),
+		RejectRawOpenIDParams: getEnv("REJECT_RAW_OPENID_PARAMS", "false") == "true",
+
+		// 敏感数据加密配置
+		PIIEncryptionKeys:   getVersionedSecretEnv("PII_ENCRYPTION_KEYS", getEnv("PII_ACTIVE_KEY_VERSION", "v1"), environment),
+		PIIActiveKeyVersion: getEnv("PII_ACTIVE_KEY_VERSION", "v1"),
+		PIIBlindIndexKey:    getSecretEnv("PII_BLIND_INDEX_KEY", environment),
 
 		// 时区配置
 		AppTimeZone: getEnv("APP_TIMEZONE", "UTC"),
@@ -110,8 +166,42 @@ func GetConfig() *Config {
 		HTTPClientTimeout: getEnv("HTTP_CLIENT_TIMEOUT", "30s"),
 
 		// 开发配置
-		EnableDevLogin: getEnv("ENABLE_DEV_LOGIN", "true") == "true",
-		LogLevel:       getEnv("LOG_LEVEL", "info"),
+		EnableDevLogin:      getEnv("ENABLE_DEV_LOGIN", "false") == "true",
+		TestIdentitySecret:  getEnv("TEST_IDENTITY_SECRET", ""),
+		TestIdentityOpenIDs: getEnvList("TEST_IDENTITY_OPENIDS"),
+		TestIdentityListen:  getEnv("TEST_IDENTITY_LISTEN", "127.0.0.1:8081"),
+		LogLevel:            getEnv("LOG_LEVEL", "info"),
+
+		// 代理提现配置
+		RequireVerifiedPhoneForWithdraw: getEnv("REQUIRE_VERIFIED_PHONE_FOR_WITHDRAW", "false") == "true",
+
+		// 账号注销配置
+		AccountDeletionCoolingOff: getEnv("ACCOUNT_DELETION_COOLING_OFF", "168h"),
+
+		// 离线学习包配置
+		StudyPackSecret:   getSecretEnv("STUDY_PACK_SECRET", environment),
+		StudyPackValidity: getEnv("STUDY_PACK_VALIDITY", "720h"),
+
+		// 测验配置
+		QuizAudioAllowedHosts: getEnvList("QUIZ_AUDIO_ALLOWED_HOSTS"),
+
+		// 对象存储配置
+		StorageBackend:       getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalDir:      getEnv("STORAGE_LOCAL_DIR", "/www/wwwroot/miniprogram/storage"),
+		StoragePublicURL:     getEnv("STORAGE_PUBLIC_URL", ""),
+		StorageSigningSecret: getSecretEnv("STORAGE_SIGNING_SECRET", environment),
+		StorageSignedURLTTL:  getEnv("STORAGE_SIGNED_URL_TTL", "1h"),
+		S3Endpoint:           getEnv("S3_ENDPOINT", ""),
+		S3Region:             getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:             getEnv("S3_BUCKET", ""),
+		S3AccessKey:          getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:          getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:          getEnv("S3_PATH_STYLE", "false") == "true",
+		OBSEndpoint:          getEnv("OBS_ENDPOINT", "https://obs.cn-south-1.myhuaweicloud.com"),
+		OBSBucket:            getEnv("OBS_BUCKET", ""),
+		OBSAccessKey:         getEnv("OBS_ACCESS_KEY", ""),
+		OBSSecretKey:         getEnv("OBS_SECRET_KEY", ""),
+		LegacyFileRoot:       getEnv("LEGACY_FILE_ROOT", "/www/wwwroot/miniprogram"),
 
 		// 小程序码配置
 		QRCodeEnvVersion: getEnv("QRCODE_ENV_VERSION", "develop"),
@@ -128,6 +218,34 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getSecretEnv 获取签名、加密密钥：每个密钥使用单独的环境变量，不回退到其他密钥
+// 只有开发环境在未配置时使用按变量名区分的开发密钥，其他环境未配置时为空，由 ValidateSecrets 阻止启动
+func getSecretEnv(key, environment string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if isDevelopmentEnvironment(environment) {
+		return "dev-only-" + strings.ToLower(key)
+	}
+	return ""
+}
+
+// getVersionedSecretEnv 获取 "版本:密钥" 列表形式的密钥，开发环境未配置时使用 activeVersion 版本的开发密钥
+func getVersionedSecretEnv(key, activeVersion, environment string) []string {
+	if list := getEnvList(key); len(list) > 0 {
+		return list
+	}
+	if secret := getSecretEnv(key, environment); secret != "" {
+		return []string{activeVersion + ":" + secret}
+	}
+	return nil
+}
+
+// isDevelopmentEnvironment 是否为开发环境
+func isDevelopmentEnvironment(environment string) bool {
+	return strings.EqualFold(environment, "development") || strings.EqualFold(environment, "dev")
+}
+
 // getEnvInt 获取整数类型的环境变量，如果不存在或无法解析则返回默认值
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -138,6 +256,72 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvList 获取逗号分隔的列表类型环境变量，忽略空项
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// IsProduction 是否为生产环境
+func (c *Config) IsProduction() bool {
+	return strings.EqualFold(c.Environment, "production")
+}
+
+// IsDevelopment 是否为开发环境
+func (c *Config) IsDevelopment() bool {
+	return isDevelopmentEnvironment(c.Environment)
+}
+
+// ValidateSecrets 检查签名、加密密钥是否已单独配置（非开发环境未配置时不能启动）
+func (c *Config) ValidateSecrets() error {
+	type secret struct {
+		env   string
+		value string
+	}
+	secrets := []secret{
+		{"WECHAT_SESSION_SECRET", c.WechatSessionSecret},
+		{"STUDY_PACK_SECRET", c.StudyPackSecret},
+		{"PII_ENCRYPTION_KEYS", strings.Join(c.PIIEncryptionKeys, ",")},
+		{"PII_BLIND_INDEX_KEY", c.PIIBlindIndexKey},
+	}
+	// 只有本地存储使用签名地址密钥，S3、OBS 由存储服务签名
+	if c.StorageBackend == "local" || c.StorageBackend == "" {
+		secrets = append(secrets, secret{"STORAGE_SIGNING_SECRET", c.StorageSigningSecret})
+	}
+
+	var missing []string
+	for _, secret := range secrets {
+		if secret.value == "" {
+			missing = append(missing, secret.env)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s 环境必须配置以下密钥: %s", c.Environment, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// DevLoginAllowed 测试身份登录是否可用
+// 生产环境下无论 ENABLE_DEV_LOGIN 如何配置都不可用，且必须配置签名密钥和本机回环的内部监听地址
+func (c *Config) DevLoginAllowed() bool {
+	return c.EnableDevLogin && !c.IsProduction() && c.TestIdentitySecret != "" && c.TestIdentityListenIsLoopback()
+}
+
+// TestIdentityListenIsLoopback 测试身份内部监听地址是否为本机回环地址（如 127.0.0.1:8081）
+func (c *Config) TestIdentityListenIsLoopback() bool {
+	host, _, err := net.SplitHostPort(c.TestIdentityListen)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // ValidateWechatPayNotifyURL 验证微信支付回调URL是否符合要求
 func ValidateWechatPayNotifyURL(baseURL string) error {
 	// 1. 检查URL是否为空
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"fmt"
+	"miniprogram/middlewares"
+	"miniprogram/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// requireAccountOwner 校验当前登录用户是否为 :user_id 本人（账号导出、注销以及学习数据只允许本人操作）
+func requireAccountOwner(c *gin.Context) (string, bool) {
+	openID := c.Param("user_id")
+
+	claims, ok := middlewares.GetUserFromGinContext(c)
+	if !ok {
+		UnauthorizedResponse(c, "用户未认证", nil)
+		return "", false
+	}
+	if claims.UserId != openID {
+		ForbiddenResponse(c, "只能操作本人账号", nil)
+		return "", false
+	}
+	return openID, true
+}
+
+// ExportUserDataHandler 导出个人数据处理器（format=json 或 zip）
+func ExportUserDataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "zip" {
+			BadRequestResponse(c, "不支持的导出格式，仅支持 json 或 zip", nil)
+			return
+		}
+
+		accountService := GetAccountService()
+		export, err := accountService.ExportUserData(openID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+				return
+			}
+			InternalServerErrorResponse(c, "导出个人数据失败", err)
+			return
+		}
+
+		if format == "json" {
+			SuccessResponse(c, "导出个人数据成功", export)
+			return
+		}
+
+		content, err := accountService.BuildExportZip(export)
+		if err != nil {
+			InternalServerErrorResponse(c, "生成导出文件失败", err)
+			return
+		}
+
+		filename := fmt.Sprintf("user_data_%s.zip", export.ExportedAt.Format("20060102150405"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "application/zip", content)
+	}
+}
+
+// RequestAccountDeletionHandler 申请注销账号处理器（进入冷静期，冷静期结束后执行注销）
+func RequestAccountDeletionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.AccountDeletionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+		if !req.Confirm {
+			BadRequestResponse(c, "请确认注销账号", nil)
+			return
+		}
+
+		deletion, err := GetAccountService().RequestDeletion(openID, req.Reason)
+		if err != nil {
+			switch err {
+			case ErrAccountDeletionPending:
+				ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+			case mongo.ErrNoDocuments:
+				NotFoundResponse(c, "用户不存在", err)
+			default:
+				InternalServerErrorResponse(c, "申请注销账号失败", err)
+			}
+			return
+		}
+
+		CreatedResponse(c, "注销申请已提交，冷静期结束后将注销账号", deletion)
+	}
+}
+
+// GetAccountDeletionHandler 查询注销申请状态处理器
+func GetAccountDeletionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		deletion, err := GetAccountService().GetPendingDeletion(openID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "没有进行中的注销申请", nil)
+				return
+			}
+			InternalServerErrorResponse(c, "查询注销申请失败", err)
+			return
+		}
+
+		SuccessResponse(c, "查询注销申请成功", deletion)
+	}
+}
+
+// CancelAccountDeletionHandler 撤销注销申请处理器（仅冷静期内可撤销）
+func CancelAccountDeletionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		deletion, err := GetAccountService().CancelDeletion(openID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "没有进行中的注销申请", nil)
+				return
+			}
+			InternalServerErrorResponse(c, "撤销注销申请失败", err)
+			return
+		}
+
+		SuccessResponse(c, "注销申请已撤销", deletion)
+	}
+}
+
+// ProcessAccountDeletionsHandler 立即执行冷静期已结束的注销申请处理器（管理员）
+func ProcessAccountDeletionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := GetAccountService().ProcessDueDeletions()
+		if err != nil {
+			InternalServerErrorResponse(c, "执行账号注销失败", err)
+			return
+		}
+
+		SuccessResponse(c, "账号注销处理完成", result)
+	}
+}
@@ -0,0 +1,522 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 账号数据服务层（数据导出与账号注销） =====
+
+// ErrAccountDeletionPending 已存在冷静期中的注销申请
+var ErrAccountDeletionPending = errors.New("账号已在注销冷静期中")
+
+// deletedUserName 注销后保留记录中显示的用户名
+const deletedUserName = "已注销用户"
+
+// userOwnedCollections 注销时直接删除的用户私有数据集合（按 user_openid 关联）
+var userOwnedCollections = []string{
+	"carts",
+	"wechat_sessions",
+	"test_identity_logs",
+	"word_reviews",
+	"learning_progress",
+	"progress_applied_changes",
+	"quizzes",
+	"mistake_words",
+	"study_sessions",
+	"study_daily_stats",
+	"leaderboard_entries",
+	"offline_answers",
+	"search_history",
+}
+
+// AccountService 账号数据服务
+type AccountService struct{}
+
+// GetAccountService 获取账号数据服务实例
+func GetAccountService() *AccountService {
+	return &AccountService{}
+}
+
+// getCoolingOffPeriod 获取注销冷静期
+func (s *AccountService) getCoolingOffPeriod() time.Duration {
+	period, err := time.ParseDuration(config.GetConfig().AccountDeletionCoolingOff)
+	if err != nil || period < 0 {
+		return 7 * 24 * time.Hour
+	}
+	return period
+}
+
+// ===== 数据导出 =====
+
+// ExportUserData 汇总用户的全部个人数据
+func (s *AccountService) ExportUserData(openID string) (*models.UserDataExport, error) {
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	export := &models.UserDataExport{
+		ExportedAt:     utils.GetCurrentUTCTime(),
+		Profile:        *user,
+		Addresses:      user.Addresses,
+		Orders:         []models.Order{},
+		Progress:       user.Progress,
+		CollectedCards: user.CollectedCards,
+		UnlockedBooks:  user.UnlockedBooks,
+		Commissions:    []models.Commission{},
+		Withdrawals:    []models.WithdrawRecord{},
+		Refunds:        []models.RefundRecord{},
+		WordReviews:    []models.WordReview{},
+		UnitProgress:   []models.UnitProgress{},
+		Quizzes:        []models.Quiz{},
+		MistakeWords:   []models.MistakeWord{},
+		StudySessions:  []models.StudySession{},
+		StudyDays:      []models.StudyDailyStat{},
+		Classrooms:     []models.Classroom{},
+		FamilyLinks:    []models.FamilyLink{},
+		Activations:    []models.ActivationCode{},
+		OfflineAnswers: []models.OfflineAnswer{},
+	}
+
+	sortByCreated := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	filter := bson.M{"user_openid": openID}
+
+	if err := s.findAll(ctx, "orders", filter, sortByCreated, &export.Orders); err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	if err := s.findAll(ctx, "commissions", filter, sortByCreated, &export.Commissions); err != nil {
+		return nil, fmt.Errorf("查询佣金记录失败: %w", err)
+	}
+	if err := s.findAll(ctx, "withdrawals", filter, sortByCreated, &export.Withdrawals); err != nil {
+		return nil, fmt.Errorf("查询提现记录失败: %w", err)
+	}
+	if err := s.findAll(ctx, "refunds", filter, sortByCreated, &export.Refunds); err != nil {
+		return nil, fmt.Errorf("查询退款记录失败: %w", err)
+	}
+	if err := s.findAll(ctx, "word_reviews", filter, sortByCreated, &export.WordReviews); err != nil {
+		return nil, fmt.Errorf("查询复习记录失败: %w", err)
+	}
+	if err := s.findAll(ctx, "learning_progress", filter, sortByCreated, &export.UnitProgress); err != nil {
+		return nil, fmt.Errorf("查询单元学习进度失败: %w", err)
+	}
+	if err := s.findAll(ctx, "quizzes", filter, sortByCreated, &export.Quizzes); err != nil {
+		return nil, fmt.Errorf("查询测验记录失败: %w", err)
+	}
+	if err := s.findAll(ctx, "mistake_words", filter, sortByCreated, &export.MistakeWords); err != nil {
+		return nil, fmt.Errorf("查询错题本失败: %w", err)
+	}
+	if err := s.findAll(ctx, "study_sessions", filter, sortByCreated, &export.StudySessions); err != nil {
+		return nil, fmt.Errorf("查询学习会话失败: %w", err)
+	}
+	if err := s.findAll(ctx, "offline_answers", filter, sortByCreated, &export.OfflineAnswers); err != nil {
+		return nil, fmt.Errorf("查询离线作答记录失败: %w", err)
+	}
+	sortBySearched := options.Find().SetSort(bson.D{{Key: "last_searched_at", Value: -1}})
+	if err := s.findAll(ctx, "search_history", filter, sortBySearched, &export.SearchHistory); err != nil {
+		return nil, fmt.Errorf("查询搜索历史失败: %w", err)
+	}
+	sortByDate := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
+	if err := s.findAll(ctx, "study_daily_stats", filter, sortByDate, &export.StudyDays); err != nil {
+		return nil, fmt.Errorf("查询每日学习统计失败: %w", err)
+	}
+	// 作为学生加入的班级不导出其他同学的信息
+	classroomFilter := bson.M{"$or": []bson.M{{"teacher_openid": openID}, {"students.student_openid": openID}}}
+	if err := s.findAll(ctx, "classrooms", classroomFilter, sortByCreated, &export.Classrooms); err != nil {
+		return nil, fmt.Errorf("查询班级失败: %w", err)
+	}
+	for i := range export.Classrooms {
+		if export.Classrooms[i].TeacherOpenID == openID {
+			continue
+		}
+		for _, student := range export.Classrooms[i].Students {
+			if student.StudentOpenID == openID {
+				export.Classrooms[i].Students = []models.ClassroomStudent{student}
+				break
+			}
+		}
+	}
+
+	familyFilter := bson.M{"$or": []bson.M{{"owner_openid": openID}, {"member_openid": openID}}}
+	if err := s.findAll(ctx, "family_links", familyFilter, sortByCreated, &export.FamilyLinks); err != nil {
+		return nil, fmt.Errorf("查询家庭共享失败: %w", err)
+	}
+
+	redeemedFilter := bson.M{"redeemed_by_openid": openID}
+	sortByRedeemed := options.Find().SetSort(bson.D{{Key: "redeemed_at", Value: -1}})
+	if err := s.findAll(ctx, "activation_codes", redeemedFilter, sortByRedeemed, &export.Activations); err != nil {
+		return nil, fmt.Errorf("查询激活码兑换记录失败: %w", err)
+	}
+
+	var referral models.Referral
+	err = GetCollection("referrals").FindOne(ctx, filter).Decode(&referral)
+	if err == nil {
+		export.Referral = &referral
+	} else if err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("查询推荐记录失败: %w", err)
+	}
+
+	return export, nil
+}
+
+// findAll 查询集合中的全部匹配文档
+func (s *AccountService) findAll(ctx context.Context, collectionName string, filter bson.M, opts *options.FindOptions, results interface{}) error {
+	cursor, err := GetCollection(collectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
+
+// BuildExportZip 将导出数据打包为ZIP（每类数据一个JSON文件，附带头像文件）
+func (s *AccountService) BuildExportZip(export *models.UserDataExport) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"addresses.json", export.Addresses},
+		{"orders.json", export.Orders},
+		{"progress.json", export.Progress},
+		{"collected_cards.json", export.CollectedCards},
+		{"unlocked_books.json", export.UnlockedBooks},
+		{"referral.json", export.Referral},
+		{"commissions.json", export.Commissions},
+		{"withdrawals.json", export.Withdrawals},
+		{"refunds.json", export.Refunds},
+		{"word_reviews.json", export.WordReviews},
+		{"unit_progress.json", export.UnitProgress},
+		{"quizzes.json", export.Quizzes},
+		{"mistake_words.json", export.MistakeWords},
+		{"study_sessions.json", export.StudySessions},
+		{"study_days.json", export.StudyDays},
+		{"classrooms.json", export.Classrooms},
+		{"family_links.json", export.FamilyLinks},
+		{"activations.json", export.Activations},
+		{"offline_answers.json", export.OfflineAnswers},
+		{"search_history.json", export.SearchHistory},
+	}
+
+	for _, file := range files {
+		content, err := json.MarshalIndent(scrubOpenIDs(file.data), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("序列化%s失败: %w", file.name, err)
+		}
+		if err := s.writeZipFile(writer, file.name, content, export.ExportedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	// 附带头像文件
+	if content, ext, err := GetUserFileService().ReadAvatar(&export.Profile); err == nil {
+		if err := s.writeZipFile(writer, "avatar"+ext, content, export.ExportedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("生成ZIP失败: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeZipFile 向ZIP中写入单个文件
+func (s *AccountService) writeZipFile(writer *zip.Writer, name string, content []byte, modified time.Time) error {
+	fileWriter, err := writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("创建ZIP文件%s失败: %w", name, err)
+	}
+	if _, err := fileWriter.Write(content); err != nil {
+		return fmt.Errorf("写入ZIP文件%s失败: %w", name, err)
+	}
+	return nil
+}
+
+// ===== 账号注销 =====
+
+// RequestDeletion 申请注销账号，进入冷静期
+func (s *AccountService) RequestDeletion(openID, reason string) (*models.AccountDeletion, error) {
+	if _, err := GetUserService().FindUserByOpenID(openID); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.GetPendingDeletion(openID); err == nil && existing != nil {
+		return existing, ErrAccountDeletionPending
+	} else if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	collection := GetCollection("account_deletions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	deletion := models.AccountDeletion{
+		ID:          primitive.NewObjectID(),
+		UserOpenID:  openID,
+		Status:      models.AccountDeletionStatusPending,
+		Reason:      reason,
+		RequestedAt: now,
+		ScheduledAt: now.Add(s.getCoolingOffPeriod()),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if _, err := collection.InsertOne(ctx, deletion); err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// GetPendingDeletion 获取用户冷静期中的注销申请
+func (s *AccountService) GetPendingDeletion(openID string) (*models.AccountDeletion, error) {
+	collection := GetCollection("account_deletions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var deletion models.AccountDeletion
+	err := collection.FindOne(ctx, bson.M{
+		"user_openid": openID,
+		"status":      models.AccountDeletionStatusPending,
+	}).Decode(&deletion)
+	if err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// CancelDeletion 在冷静期内撤销注销申请
+func (s *AccountService) CancelDeletion(openID string) (*models.AccountDeletion, error) {
+	deletion, err := s.GetPendingDeletion(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := GetCollection("account_deletions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": deletion.ID}, bson.M{
+		"$set": bson.M{
+			"status":       models.AccountDeletionStatusCancelled,
+			"cancelled_at": now,
+			"updated_at":   now,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deletion.Status = models.AccountDeletionStatusCancelled
+	deletion.CancelledAt = now
+	deletion.UpdatedAt = now
+	return deletion, nil
+}
+
+// ProcessDueDeletions 执行所有冷静期已结束的注销申请
+func (s *AccountService) ProcessDueDeletions() (*models.AccountDeletionResult, error) {
+	collection := GetCollection("account_deletions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"status":       models.AccountDeletionStatusPending,
+		"scheduled_at": bson.M{"$lte": utils.GetCurrentUTCTime()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var deletions []models.AccountDeletion
+	if err := cursor.All(ctx, &deletions); err != nil {
+		return nil, err
+	}
+
+	result := &models.AccountDeletionResult{}
+	for _, deletion := range deletions {
+		// 提现未到终态时匿名化会丢失打款和回调所需的账户信息，保留申请待下次执行
+		pending, err := NewAgentWithdrawService().CheckPendingWithdrawRecord(deletion.UserOpenID)
+		if err != nil {
+			log.Printf("[账号注销] 查询提现记录失败: id=%s, err=%v", deletion.ID.Hex(), err)
+			result.Failed++
+			continue
+		}
+		if pending != nil {
+			log.Printf("[账号注销] 存在未完成的提现，延后执行: id=%s", deletion.ID.Hex())
+			result.Deferred++
+			continue
+		}
+
+		if err := s.executeDeletion(deletion); err != nil {
+			log.Printf("[账号注销] 执行失败: id=%s, err=%v", deletion.ID.Hex(), err)
+			result.Failed++
+			continue
+		}
+		result.Processed++
+	}
+
+	return result, nil
+}
+
+// anonymizeStep 注销时匿名化一类记录：匹配 filter 的文档设置为 set
+type anonymizeStep struct {
+	collection string
+	filter     bson.M
+	set        bson.M
+}
+
+// anonymizeSteps 注销时需要匿名化的记录（保留用于财务对账），openID 替换为 anonymousID
+func anonymizeSteps(openID, anonymousID string) []anonymizeStep {
+	return []anonymizeStep{
+		{"orders", bson.M{"user_openid": openID}, bson.M{"user_openid": anonymousID, "address_id": ""}},
+		{"orders", bson.M{"referrer_openid": openID}, bson.M{"referrer_openid": anonymousID}},
+		{"commissions", bson.M{"user_openid": openID}, bson.M{"user_openid": anonymousID}},
+		{"commissions", bson.M{"referred_user_openid": openID}, bson.M{"referred_user_openid": anonymousID, "referred_user_name": deletedUserName}},
+		{"withdrawals", bson.M{"user_openid": openID}, bson.M{"user_openid": anonymousID, "account_info": bson.M{}}},
+		{"refunds", bson.M{"user_openid": openID}, bson.M{"user_openid": anonymousID}},
+		{"activation_codes", bson.M{"redeemed_by_openid": openID}, bson.M{"redeemed_by_openid": anonymousID}},
+		{"activation_batches", bson.M{"agent_openid": openID}, bson.M{"agent_openid": anonymousID}},
+	}
+}
+
+// executeDeletion 执行单个注销申请：匿名化需保留的财务记录，删除其他个人数据和头像文件
+func (s *AccountService) executeDeletion(deletion models.AccountDeletion) error {
+	openID := deletion.UserOpenID
+	anonymousID := utils.AnonymousUserID(openID)
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	// 注销涉及多个集合，使用较长的超时时间
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// 1. 匿名化需要保留用于财务对账的记录
+	for _, item := range anonymizeSteps(openID, anonymousID) {
+		if _, err := GetCollection(item.collection).UpdateMany(ctx, item.filter, bson.M{"$set": item.set}); err != nil {
+			return fmt.Errorf("匿名化%s失败: %w", item.collection, err)
+		}
+	}
+
+	// 2. 匿名化其他用户推荐码中的使用记录
+	_, err = GetCollection("referrals").UpdateMany(ctx,
+		bson.M{"used_by.user_openid": openID},
+		bson.M{"$set": bson.M{
+			"used_by.$[usage].user_openid": anonymousID,
+			"used_by.$[usage].user_name":   deletedUserName,
+		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"usage.user_openid": openID}},
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("匿名化推荐使用记录失败: %w", err)
+	}
+
+	// 3. 删除用户自己的推荐码和私有数据
+	if _, err := GetCollection("referrals").DeleteMany(ctx, bson.M{"user_openid": openID}); err != nil {
+		return fmt.Errorf("删除推荐码失败: %w", err)
+	}
+	for _, collectionName := range userOwnedCollections {
+		if _, err := GetCollection(collectionName).DeleteMany(ctx, bson.M{"user_openid": openID}); err != nil {
+			return fmt.Errorf("删除%s失败: %w", collectionName, err)
+		}
+	}
+
+	// 4. 解散用户创建的班级和家庭共享，并从加入的班级和家庭中移除
+	if _, err := GetCollection("classrooms").DeleteMany(ctx, bson.M{"teacher_openid": openID}); err != nil {
+		return fmt.Errorf("删除班级失败: %w", err)
+	}
+	_, err = GetCollection("classrooms").UpdateMany(ctx,
+		bson.M{"students.student_openid": openID},
+		bson.M{"$pull": bson.M{"students": bson.M{"student_openid": openID}}},
+	)
+	if err != nil {
+		return fmt.Errorf("退出班级失败: %w", err)
+	}
+	_, err = GetCollection("family_links").DeleteMany(ctx, bson.M{
+		"$or": []bson.M{{"owner_openid": openID}, {"member_openid": openID}},
+	})
+	if err != nil {
+		return fmt.Errorf("删除家庭共享失败: %w", err)
+	}
+
+	// 5. 删除头像和小程序码文件
+	GetUserFileService().DeleteUserFiles(openID, user)
+
+	// 6. 删除用户主记录
+	if _, err := GetCollection("users").DeleteOne(ctx, bson.M{"openID": openID}); err != nil {
+		return fmt.Errorf("删除用户失败: %w", err)
+	}
+
+	// 7. 标记注销完成，注销记录中也不再保留openID
+	now := utils.GetCurrentUTCTime()
+	_, err = GetCollection("account_deletions").UpdateMany(ctx, bson.M{"user_openid": openID}, bson.M{
+		"$set": bson.M{
+			"user_openid": anonymousID,
+			"updated_at":  now,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("更新注销记录失败: %w", err)
+	}
+	_, err = GetCollection("account_deletions").UpdateOne(ctx, bson.M{"_id": deletion.ID}, bson.M{
+		"$set": bson.M{
+			"status":       models.AccountDeletionStatusCompleted,
+			"completed_at": now,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("更新注销状态失败: %w", err)
+	}
+
+	log.Printf("[账号注销] 注销完成: id=%s", deletion.ID.Hex())
+	return nil
+}
+
+// StartAccountDeletionWorker 启动后台任务，定期执行冷静期结束的注销申请
+func StartAccountDeletionWorker(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			result, err := GetAccountService().ProcessDueDeletions()
+			if err != nil {
+				log.Printf("[账号注销] 处理到期注销申请失败: %v", err)
+				continue
+			}
+			if result.Processed > 0 || result.Failed > 0 {
+				log.Printf("[账号注销] 处理到期注销申请: 完成=%d, 失败=%d", result.Processed, result.Failed)
+			}
+		}
+	}()
+}
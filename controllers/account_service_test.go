@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"miniprogram/models"
+	"miniprogram/utils"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// accountDataModels 注销涉及的集合及其文档模型
+var accountDataModels = map[string]interface{}{
+	"carts":                    models.Cart{},
+	"wechat_sessions":          models.WechatSession{},
+	"test_identity_logs":       models.TestIdentityLog{},
+	"word_reviews":             models.WordReview{},
+	"learning_progress":        models.UnitProgress{},
+	"progress_applied_changes": models.AppliedProgressChange{},
+	"quizzes":                  models.Quiz{},
+	"mistake_words":            models.MistakeWord{},
+	"study_sessions":           models.StudySession{},
+	"study_daily_stats":        models.StudyDailyStat{},
+	"leaderboard_entries":      models.LeaderboardEntry{},
+	"offline_answers":          models.OfflineAnswer{},
+	"search_history":           models.SearchHistory{},
+	"orders":                   models.Order{},
+	"commissions":              models.Commission{},
+	"withdrawals":              models.WithdrawRecord{},
+	"refunds":                  models.RefundRecord{},
+	"activation_codes":         models.ActivationCode{},
+	"activation_batches":       models.ActivationCodeBatch{},
+}
+
+// openIDFields 模型中保存用户 openID 的字段（bson 名称），created_by_openid 为管理员操作记录，不属于用户数据
+func openIDFields(model interface{}) []string {
+	var fields []string
+	modelType := reflect.TypeOf(model)
+	for i := 0; i < modelType.NumField(); i++ {
+		name, _, _ := strings.Cut(modelType.Field(i).Tag.Get("bson"), ",")
+		if strings.HasSuffix(name, "openid") && name != "created_by_openid" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func TestAccountDeletionLeavesNoOpenID(t *testing.T) {
+	const openID = "o_deleted_user"
+	anonymousID := utils.AnonymousUserID(openID)
+
+	// 每个集合放一条所有 openID 字段都是该用户的文档
+	data := make(map[string][]bson.M)
+	for collection, model := range accountDataModels {
+		doc := bson.M{}
+		for _, field := range openIDFields(model) {
+			doc[field] = openID
+		}
+		if len(doc) == 0 {
+			t.Fatalf("%s: model has no openID field", collection)
+		}
+		data[collection] = []bson.M{doc}
+	}
+	for _, collection := range userOwnedCollections {
+		if _, ok := accountDataModels[collection]; !ok {
+			t.Fatalf("userOwnedCollections contains %s without a model in accountDataModels", collection)
+		}
+	}
+
+	// 按 executeDeletion 的顺序匿名化、删除（过滤条件都是单个字段的等值匹配）
+	for _, step := range anonymizeSteps(openID, anonymousID) {
+		docs, ok := data[step.collection]
+		if !ok {
+			t.Fatalf("anonymizeSteps uses %s without a model in accountDataModels", step.collection)
+		}
+		for _, doc := range docs {
+			matched := true
+			for field, value := range step.filter {
+				matched = matched && doc[field] == value
+			}
+			if matched {
+				for field, value := range step.set {
+					doc[field] = value
+				}
+			}
+		}
+	}
+	for _, collection := range userOwnedCollections {
+		var kept []bson.M
+		for _, doc := range data[collection] {
+			if doc["user_openid"] != openID {
+				kept = append(kept, doc)
+			}
+		}
+		data[collection] = kept
+	}
+
+	for collection, docs := range data {
+		for _, doc := range docs {
+			for field, value := range doc {
+				if value == openID {
+					t.Errorf("%s.%s still holds the deleted user's openID", collection, field)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"miniprogram/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondActivationError 统一处理激活码相关错误
+func respondActivationError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidActivationRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrInvalidActivationCode):
+		NotFoundResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrActivationCodeUsed), errors.Is(err, ErrBookAlreadyUnlocked):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, ErrActivationCodeRevoked), errors.Is(err, ErrActivationCodeExpired):
+		ForbiddenResponse(c, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, "批次或书籍不存在", err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// RedeemActivationCodeHandler 用户兑换激活码处理器（解锁对应书籍权限）
+func RedeemActivationCodeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.RedeemActivationCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		result, err := GetActivationService().Redeem(openID, req.Code)
+		if err != nil {
+			respondActivationError(c, "兑换激活码失败", err)
+			return
+		}
+
+		SuccessResponse(c, "激活成功", result)
+	}
+}
+
+// CreateActivationBatchHandler 管理员生成激活码批次处理器
+func CreateActivationBatchHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminOpenID, _ := c.Get("user_openid")
+
+		var req models.CreateActivationBatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		batch, err := GetActivationService().CreateBatch(adminOpenID.(string), req)
+		if err != nil {
+			respondActivationError(c, "生成激活码失败", err)
+			return
+		}
+
+		CreatedResponse(c, "激活码批次生成成功", batch)
+	}
+}
+
+// GetActivationBatchesHandler 管理员获取激活码批次列表处理器
+func GetActivationBatchesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		batches, total, err := GetActivationService().ListBatches(c.Query("book_id"), c.Query("status"), page, limit)
+		if err != nil {
+			respondActivationError(c, "获取激活码批次失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取激活码批次成功", gin.H{
+			"batches": batches,
+			"pagination": models.Pagination{
+				Page:       page,
+				Limit:      limit,
+				Total:      int(total),
+				TotalPages: int((total + int64(limit) - 1) / int64(limit)),
+			},
+		})
+	}
+}
+
+// GetActivationBatchHandler 管理员获取激活码批次详情处理器
+func GetActivationBatchHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batch, err := GetActivationService().GetBatch(c.Param("batch_id"))
+		if err != nil {
+			respondActivationError(c, "获取激活码批次失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取激活码批次成功", batch)
+	}
+}
+
+// ExportActivationCodesHandler 管理员导出批次激活码处理器（format=csv 用于印刷，status 可筛选）
+func ExportActivationCodesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "csv")
+		if format != "json" && format != "csv" {
+			BadRequestResponse(c, "不支持的导出格式，仅支持 json 或 csv", nil)
+			return
+		}
+
+		service := GetActivationService()
+		batch, err := service.GetBatch(c.Param("batch_id"))
+		if err != nil {
+			respondActivationError(c, "获取激活码批次失败", err)
+			return
+		}
+
+		codes, err := service.ListBatchCodes(batch.ID, c.Query("status"))
+		if err != nil {
+			respondActivationError(c, "获取激活码失败", err)
+			return
+		}
+
+		if format == "json" {
+			SuccessResponse(c, "获取激活码成功", gin.H{
+				"batch": batch,
+				"codes": codes,
+			})
+			return
+		}
+
+		content, err := service.BuildCodesCSV(batch, codes)
+		if err != nil {
+			InternalServerErrorResponse(c, "生成激活码文件失败", err)
+			return
+		}
+
+		filename := fmt.Sprintf("activation_codes_%s.csv", batch.BatchNo)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", content)
+	}
+}
+
+// RevokeActivationBatchHandler 管理员作废整个批次处理器（未兑换的激活码全部作废）
+func RevokeActivationBatchHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := GetActivationService().RevokeBatch(c.Param("batch_id"))
+		if err != nil {
+			respondActivationError(c, "作废激活码批次失败", err)
+			return
+		}
+
+		SuccessResponse(c, "激活码批次已作废", result)
+	}
+}
+
+// RevokeActivationCodeHandler 管理员作废单个激活码处理器
+func RevokeActivationCodeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code, err := GetActivationService().RevokeCode(c.Param("code"))
+		if err != nil {
+			respondActivationError(c, "作废激活码失败", err)
+			return
+		}
+
+		SuccessResponse(c, "激活码已作废", code)
+	}
+}
@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 激活码服务层（线下实体卡兑换书籍权限） =====
+
+var (
+	// ErrInvalidActivationRequest 激活码批次参数错误
+	ErrInvalidActivationRequest = errors.New("激活码批次参数错误")
+	// ErrInvalidActivationCode 激活码不存在
+	ErrInvalidActivationCode = errors.New("激活码无效，请检查后重新输入")
+	// ErrActivationCodeUsed 激活码已被兑换
+	ErrActivationCodeUsed = errors.New("激活码已被使用")
+	// ErrActivationCodeRevoked 激活码或所属批次已作废
+	ErrActivationCodeRevoked = errors.New("激活码已作废")
+	// ErrActivationCodeExpired 已超过批次的兑换截止日期
+	ErrActivationCodeExpired = errors.New("激活码已过兑换期限")
+	// ErrBookAlreadyUnlocked 已拥有该书籍的永久权限，兑换不会带来变化
+	ErrBookAlreadyUnlocked = errors.New("您已拥有该书籍的永久权限，无需兑换")
+)
+
+const (
+	activationBatchesCollection = "activation_batches"
+	activationCodesCollection   = "activation_codes"
+	activationCodeLength        = 16   // 印刷时按 4 位一组显示
+	activationInsertChunk       = 1000 // 每次批量写入的激活码数量
+	activationGenerateTries     = 3
+)
+
+// ActivationService 激活码服务
+type ActivationService struct{}
+
+// GetActivationService 获取激活码服务实例
+func GetActivationService() *ActivationService {
+	return &ActivationService{}
+}
+
+// normalizeActivationCode 去掉用户输入中的分隔符和空白并转为大写
+func normalizeActivationCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// formatActivationCode 将激活码按 4 位一组格式化，便于印刷和输入
+func formatActivationCode(code string) string {
+	groups := make([]string, 0, len(code)/4+1)
+	for i := 0; i < len(code); i += 4 {
+		end := i + 4
+		if end > len(code) {
+			end = len(code)
+		}
+		groups = append(groups, code[i:end])
+	}
+	return strings.Join(groups, "-")
+}
+
+// ===== 管理员：批次生成、查询、导出与作废 =====
+
+// CreateBatch 生成激活码批次，按批次写入指定数量的一次性激活码
+func (s *ActivationService) CreateBatch(adminOpenID string, req models.CreateActivationBatchRequest) (*models.ActivationCodeBatch, error) {
+	bookID, err := primitive.ObjectIDFromHex(req.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的书籍ID", ErrInvalidActivationRequest)
+	}
+	accessType := req.AccessType
+	if accessType == "" {
+		accessType = "physical"
+	}
+	if accessType != "physical" && accessType != "digital" {
+		return nil, fmt.Errorf("%w: 权限类型只能是 physical 或 digital", ErrInvalidActivationRequest)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: 批次名称不能为空", ErrInvalidActivationRequest)
+	}
+
+	now := utils.GetCurrentUTCTime()
+	batch := &models.ActivationCodeBatch{
+		ID:         primitive.NewObjectID(),
+		BatchNo:    "ACB" + now.Format("20060102150405") + GenerateRandomString(4),
+		Name:       name,
+		BookID:     bookID,
+		AccessType: accessType,
+		AccessDays: req.AccessDays,
+		Quantity:   req.Quantity,
+		UnitPrice:  req.UnitPrice,
+		Status:     models.ActivationBatchActive,
+		CreatedBy:  adminOpenID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if req.RedeemDeadline != "" {
+		date, err := utils.ParseAppDate(req.RedeemDeadline)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 兑换截止日期格式应为 YYYY-MM-DD", ErrInvalidActivationRequest)
+		}
+		deadline := date.AddDate(0, 0, 1).UTC() // 截止日期当天仍可兑换
+		if !deadline.After(now) {
+			return nil, fmt.Errorf("%w: 兑换截止日期不能早于今天", ErrInvalidActivationRequest)
+		}
+		batch.RedeemDeadline = &deadline
+	}
+
+	if req.AgentID != "" {
+		agentOpenID, err := utils.DecodeSafeIDToOpenID(req.AgentID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的代理标识符", ErrInvalidActivationRequest)
+		}
+		if _, err := NewAgentUserService().IsValidAgent(agentOpenID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, fmt.Errorf("%w: 归属用户不是代理", ErrInvalidActivationRequest)
+			}
+			return nil, err
+		}
+		batch.AgentOpenID = agentOpenID
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		return nil, err
+	}
+	batch.BookName = book.BookName
+
+	if _, err := GetCollection(activationBatchesCollection).InsertOne(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	if err := s.generateCodes(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// generateCodes 为批次写入激活码；极少数编码冲突时补足差额
+func (s *ActivationService) generateCodes(batch *models.ActivationCodeBatch) error {
+	collection := GetCollection(activationCodesCollection)
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := CreateDBContext()
+		existing, err := collection.CountDocuments(ctx, bson.M{"batch_id": batch.ID})
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		remaining := batch.Quantity - int(existing)
+		if remaining <= 0 {
+			return nil
+		}
+		if attempt >= activationGenerateTries {
+			return errors.New("生成激活码失败，请重试")
+		}
+
+		for remaining > 0 {
+			size := remaining
+			if size > activationInsertChunk {
+				size = activationInsertChunk
+			}
+
+			docs := make([]interface{}, 0, size)
+			for i := 0; i < size; i++ {
+				code, err := GenerateReadableCode(activationCodeLength)
+				if err != nil {
+					return err
+				}
+				docs = append(docs, models.ActivationCode{
+					Code:      code,
+					BatchID:   batch.ID,
+					BookID:    batch.BookID,
+					Status:    models.ActivationCodeUnused,
+					CreatedAt: batch.CreatedAt,
+				})
+			}
+
+			ctx, cancel := CreateDBContext()
+			_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+			cancel()
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return err
+			}
+			remaining -= size
+		}
+	}
+}
+
+// ListBatches 分页获取激活码批次，可按书籍和状态筛选
+func (s *ActivationService) ListBatches(bookID, status string, page, limit int) ([]models.ActivationCodeBatch, int64, error) {
+	filter := bson.M{}
+	if bookID != "" {
+		id, err := primitive.ObjectIDFromHex(bookID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: 无效的书籍ID", ErrInvalidActivationRequest)
+		}
+		filter["book_id"] = id
+	}
+	if status != "" {
+		if status != models.ActivationBatchActive && status != models.ActivationBatchRevoked {
+			return nil, 0, fmt.Errorf("%w: 无效的批次状态", ErrInvalidActivationRequest)
+		}
+		filter["status"] = status
+	}
+
+	collection := GetCollection(activationBatchesCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	batches := []models.ActivationCodeBatch{}
+	if err := cursor.All(ctx, &batches); err != nil {
+		return nil, 0, err
+	}
+	return batches, total, nil
+}
+
+// GetBatch 获取激活码批次
+func (s *ActivationService) GetBatch(batchIDHex string) (*models.ActivationCodeBatch, error) {
+	batchID, err := primitive.ObjectIDFromHex(batchIDHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的批次ID", ErrInvalidActivationRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var batch models.ActivationCodeBatch
+	if err := GetCollection(activationBatchesCollection).FindOne(ctx, bson.M{"_id": batchID}).Decode(&batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListBatchCodes 获取批次中的激活码（按生成顺序），status 为空时返回全部
+func (s *ActivationService) ListBatchCodes(batchID primitive.ObjectID, status string) ([]models.ActivationCode, error) {
+	filter := bson.M{"batch_id": batchID}
+	if status != "" {
+		switch status {
+		case models.ActivationCodeUnused, models.ActivationCodeRedeemed, models.ActivationCodeRevoked:
+			filter["status"] = status
+		default:
+			return nil, fmt.Errorf("%w: 无效的激活码状态", ErrInvalidActivationRequest)
+		}
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection(activationCodesCollection).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	codes := []models.ActivationCode{}
+	if err := cursor.All(ctx, &codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// BuildCodesCSV 将批次激活码导出为印刷用CSV（带 UTF-8 BOM，便于 Excel 直接打开）
+func (s *ActivationService) BuildCodesCSV(batch *models.ActivationCodeBatch, codes []models.ActivationCode) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("\xEF\xBB\xBF")
+
+	writer := csv.NewWriter(buffer)
+	if err := writer.Write([]string{"序号", "批次号", "激活码", "书籍", "权限类型", "有效天数", "状态", "兑换时间"}); err != nil {
+		return nil, err
+	}
+
+	accessDays := "永久"
+	if batch.AccessDays > 0 {
+		accessDays = strconv.Itoa(batch.AccessDays)
+	}
+	for i, code := range codes {
+		redeemedAt := ""
+		if code.RedeemedAt != nil {
+			redeemedAt = utils.FormatTimeForResponse(*code.RedeemedAt)
+		}
+		record := []string{
+			strconv.Itoa(i + 1),
+			batch.BatchNo,
+			formatActivationCode(code.Code),
+			batch.BookName,
+			batch.AccessType,
+			accessDays,
+			code.Status,
+			redeemedAt,
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// RevokeBatch 作废整个批次：未兑换的激活码全部作废，已兑换的不受影响
+func (s *ActivationService) RevokeBatch(batchIDHex string) (*models.ActivationRevokeResult, error) {
+	batch, err := s.GetBatch(batchIDHex)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	result, err := GetCollection(activationCodesCollection).UpdateMany(ctx,
+		bson.M{"batch_id": batch.ID, "status": models.ActivationCodeUnused},
+		bson.M{"$set": bson.M{"status": models.ActivationCodeRevoked, "revoked_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = GetCollection(activationBatchesCollection).UpdateOne(ctx, bson.M{"_id": batch.ID}, bson.M{
+		"$set": bson.M{"status": models.ActivationBatchRevoked, "updated_at": now},
+		"$inc": bson.M{"revoked_count": result.ModifiedCount},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ActivationRevokeResult{RevokedCount: int(result.ModifiedCount)}, nil
+}
+
+// RevokeCode 作废单个未兑换的激活码（如卡片遗失）
+func (s *ActivationService) RevokeCode(rawCode string) (*models.ActivationCode, error) {
+	code := normalizeActivationCode(rawCode)
+
+	collection := GetCollection(activationCodesCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var activation models.ActivationCode
+	if err := collection.FindOne(ctx, bson.M{"code": code}).Decode(&activation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidActivationCode
+		}
+		return nil, err
+	}
+	switch activation.Status {
+	case models.ActivationCodeRedeemed:
+		return nil, ErrActivationCodeUsed
+	case models.ActivationCodeRevoked:
+		return nil, ErrActivationCodeRevoked
+	}
+
+	now := utils.GetCurrentUTCTime()
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": activation.ID, "status": models.ActivationCodeUnused},
+		bson.M{"$set": bson.M{"status": models.ActivationCodeRevoked, "revoked_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, ErrActivationCodeUsed
+	}
+
+	_, err = GetCollection(activationBatchesCollection).UpdateOne(ctx, bson.M{"_id": activation.BatchID}, bson.M{
+		"$inc": bson.M{"revoked_count": 1},
+		"$set": bson.M{"updated_at": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	activation.Status = models.ActivationCodeRevoked
+	activation.RevokedAt = &now
+	return &activation, nil
+}
+
+// ===== 用户兑换 =====
+
+// Redeem 兑换激活码并为用户创建书籍权限；批次归属代理时为其计算提成
+func (s *ActivationService) Redeem(openID, rawCode string) (*models.RedeemActivationCodeResult, error) {
+	code := normalizeActivationCode(rawCode)
+	if len(code) != activationCodeLength {
+		return nil, ErrInvalidActivationCode
+	}
+
+	codeCollection := GetCollection(activationCodesCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var activation models.ActivationCode
+	if err := codeCollection.FindOne(ctx, bson.M{"code": code}).Decode(&activation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidActivationCode
+		}
+		return nil, err
+	}
+	switch activation.Status {
+	case models.ActivationCodeRedeemed:
+		return nil, ErrActivationCodeUsed
+	case models.ActivationCodeRevoked:
+		return nil, ErrActivationCodeRevoked
+	}
+
+	var batch models.ActivationCodeBatch
+	if err := GetCollection(activationBatchesCollection).FindOne(ctx, bson.M{"_id": activation.BatchID}).Decode(&batch); err != nil {
+		return nil, err
+	}
+	now := utils.GetCurrentUTCTime()
+	if batch.Status == models.ActivationBatchRevoked {
+		return nil, ErrActivationCodeRevoked
+	}
+	if batch.RedeemDeadline != nil && !now.Before(*batch.RedeemDeadline) {
+		return nil, ErrActivationCodeExpired
+	}
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := models.BookPermission{
+		BookID:     batch.BookID,
+		BookName:   batch.BookName,
+		AccessType: batch.AccessType,
+		Source:     models.BookAccessSourceCode,
+	}
+	current := append([]models.BookPermission(nil), user.UnlockedBooks...)
+	permissions, changed := mergeBookPermission(current, granted, batch.AccessDays, now)
+	if !changed {
+		return nil, ErrBookAlreadyUnlocked
+	}
+
+	// 先占用激活码，并发兑换同一个码时只有一个请求成功
+	result, err := codeCollection.UpdateOne(ctx,
+		bson.M{"_id": activation.ID, "status": models.ActivationCodeUnused},
+		bson.M{"$set": bson.M{
+			"status":             models.ActivationCodeRedeemed,
+			"redeemed_by_openid": openID,
+			"redeemed_at":        now,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, ErrActivationCodeUsed
+	}
+
+	_, err = GetCollection("users").UpdateOne(ctx, bson.M{"openID": openID}, bson.M{
+		"$set": bson.M{
+			"unlocked_books": permissions,
+			"updated_at":     now,
+		},
+	})
+	if err != nil {
+		// 权限写入失败时释放激活码，用户可以重新兑换
+		_, rollbackErr := codeCollection.UpdateOne(ctx, bson.M{"_id": activation.ID}, bson.M{
+			"$set":   bson.M{"status": models.ActivationCodeUnused},
+			"$unset": bson.M{"redeemed_by_openid": "", "redeemed_at": ""},
+		})
+		if rollbackErr != nil {
+			log.Printf("[激活码] 释放激活码失败: code_id=%s, err=%v", activation.ID.Hex(), rollbackErr)
+		}
+		return nil, err
+	}
+
+	if _, err := GetCollection(activationBatchesCollection).UpdateOne(ctx, bson.M{"_id": batch.ID}, bson.M{
+		"$inc": bson.M{"redeemed_count": 1},
+		"$set": bson.M{"updated_at": now},
+	}); err != nil {
+		log.Printf("[激活码] 更新批次兑换数量失败: batch=%s, err=%v", batch.BatchNo, err)
+	}
+
+	if batch.AgentOpenID != "" && batch.UnitPrice > 0 {
+		description := fmt.Sprintf("激活码兑换提成 - %s", batch.Name)
+		err := NewAgentTieredCommissionService().ProcessAttributedAgentCommission(
+			batch.AgentOpenID, user, batch.UnitPrice, activation.ID.Hex(), description)
+		if err != nil {
+			// 提成失败不影响兑换结果
+			log.Printf("[激活码] 处理代理提成失败: batch=%s, err=%v", batch.BatchNo, err)
+		}
+	}
+
+	redeemed := &models.RedeemActivationCodeResult{
+		Code:     formatActivationCode(code),
+		BookID:   batch.BookID.Hex(),
+		BookName: batch.BookName,
+	}
+	for _, permission := range permissions {
+		if permission.BookID == batch.BookID {
+			redeemed.Permission = permission
+			break
+		}
+	}
+	return redeemed, nil
+}
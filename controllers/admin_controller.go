@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"miniprogram/middlewares"
 	"miniprogram/models"
 	"miniprogram/utils"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // ===== HTTP 处理器 =====
@@ -59,6 +61,14 @@ func GetAllUsersHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 未被授予 view_pii 权限的管理员只能看到脱敏数据
+		piiService := GetPIIService()
+		if !piiService.HasViewPIIPermission(c) {
+			for i := range response.Users {
+				piiService.MaskUser(&response.Users[i])
+			}
+		}
+
 		SuccessResponse(c, "获取用户列表成功", response)
 	}
 }
@@ -79,6 +89,11 @@ func GetUserDetailHandler() gin.HandlerFunc {
 			return
 		}
 
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, openID) {
+			piiService.MaskUser(user)
+		}
+
 		SuccessResponse(c, "获取用户详情成功", user)
 	}
 }
@@ -108,6 +123,112 @@ func UpdateUserAdminStatusHandler() gin.HandlerFunc {
 	}
 }
 
+// UpdateUserPermissionsHandler 更新用户细粒度权限处理器（如 view_pii 查看完整敏感信息）
+func UpdateUserPermissionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID := c.Param("user_id")
+
+		var req models.UpdateUserPermissionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		piiService := GetPIIService()
+		if err := piiService.ValidatePermissions(req.Permissions); err != nil {
+			BadRequestResponse(c, "权限参数错误", err)
+			return
+		}
+
+		user, err := piiService.UpdateUserPermissions(openID, req.Permissions)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+				return
+			}
+			InternalServerErrorResponse(c, "更新用户权限失败", err)
+			return
+		}
+
+		SuccessResponse(c, "用户权限更新成功", gin.H{
+			"user_id":     utils.EncodeOpenIDToSafeID(openID),
+			"permissions": user.Permissions,
+		})
+	}
+}
+
+// GetUserStatusHandler 获取用户账号状态处理器（封禁/暂停及冻结情况）
+func GetUserStatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID := c.Param("user_id")
+
+		result, err := GetUserStatusService().GetUserStatus(openID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+				return
+			}
+			InternalServerErrorResponse(c, "获取用户状态失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取用户状态成功", result)
+	}
+}
+
+// UpdateUserStatusHandler 更新用户账号状态处理器（正常、暂停至指定时间、封禁）
+func UpdateUserStatusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID := c.Param("user_id")
+
+		var req models.UpdateUserStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		claims, ok := middlewares.GetUserFromGinContext(c)
+		if !ok {
+			UnauthorizedResponse(c, "用户未认证", nil)
+			return
+		}
+		if claims.UserId == openID {
+			ForbiddenResponse(c, "不能修改自己的账号状态", nil)
+			return
+		}
+
+		if req.Status == models.AccountStatusSuspended && !req.SuspendedUntil.After(utils.GetCurrentUTCTime()) {
+			BadRequestResponse(c, "暂停截止时间必须晚于当前时间", nil)
+			return
+		}
+
+		result, err := GetUserStatusService().UpdateUserStatus(openID, req, claims.UserId)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+				return
+			}
+			InternalServerErrorResponse(c, "更新用户状态失败", err)
+			return
+		}
+
+		SuccessResponse(c, "用户状态更新成功", result)
+	}
+}
+
+// ReencryptPIIHandler 使用当前密钥版本重新加密敏感字段处理器（密钥轮换、历史明文迁移）
+func ReencryptPIIHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := GetPIIService().ReencryptAll()
+		if err != nil {
+			InternalServerErrorResponse(c, "重新加密敏感数据失败", err)
+			return
+		}
+
+		SuccessResponse(c, "敏感数据重新加密完成", result)
+	}
+}
+
 // GetUserOrdersHandler 获取指定用户订单列表处理器
 func GetUserOrdersHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
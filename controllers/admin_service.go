@@ -5,6 +5,7 @@ import (
 	"math"
 	"miniprogram/models"
 	"miniprogram/utils"
+	"regexp"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -55,7 +56,7 @@ func (s *AdminService) GetAllUsers(req models.AdminUserListRequest) (*models.Adm
 
 	// 学校筛选
 	if req.School != "" {
-		filter["school"] = bson.M{"$regex": req.School, "$options": "i"}
+		filter["school"] = bson.M{"$regex": regexp.QuoteMeta(req.School), "$options": "i"}
 	}
 
 	// 代理筛选
@@ -68,12 +69,15 @@ func (s *AdminService) GetAllUsers(req models.AdminUserListRequest) (*models.Adm
 		filter["is_admin"] = *req.IsAdmin
 	}
 
-	// 关键词搜索（用户名、手机号）
+	// 关键词搜索（用户名模糊匹配；手机号加密存储，通过盲索引精确匹配完整号码）
 	if req.Keyword != "" {
-		filter["$or"] = []bson.M{
-			{"user_name": bson.M{"$regex": req.Keyword, "$options": "i"}},
-			{"phone": bson.M{"$regex": req.Keyword, "$options": "i"}},
+		conditions := []bson.M{
+			{"user_name": bson.M{"$regex": regexp.QuoteMeta(req.Keyword), "$options": "i"}},
 		}
+		if phoneIndex := utils.PhoneBlindIndex(req.Keyword); phoneIndex != "" {
+			conditions = append(conditions, bson.M{"phone_bidx": phoneIndex})
+		}
+		filter["$or"] = conditions
 	}
 
 	// 计算分页
@@ -386,6 +390,7 @@ func (s *AdminService) CreateProduct(req models.CreateProductRequest) (*models.P
 		ProductType:    req.ProductType,
 		ProductVersion: req.ProductVersion,
 		BookID:         bookID,
+		AccessDays:     req.AccessDays,
 		Images:         req.Images,
 		CreatedAt:      utils.GetCurrentUTCTime(),
 		UpdatedAt:      utils.GetCurrentUTCTime(),
@@ -443,6 +448,12 @@ func (s *AdminService) UpdateProduct(productID string, req models.UpdateProductR
 	if req.Images != nil {
 		updates["images"] = *req.Images
 	}
+	if req.AccessDays != nil {
+		if *req.AccessDays < 0 {
+			return nil, errors.New("权限有效天数不能为负数")
+		}
+		updates["access_days"] = *req.AccessDays
+	}
 
 	// 更新商品
 	filter := bson.M{"product_id": productID}
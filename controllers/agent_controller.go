@@ -3,6 +3,7 @@ package controllers
 import (
 	"fmt"
 	"log"
+	"miniprogram/config"
 	"miniprogram/models"
 	"miniprogram/utils"
 	"net/http"
@@ -39,10 +40,16 @@ func GetAgentUsersHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 3. 为每个用户添加订单统计信息和推荐码使用详情
+		// 3. 为每个用户添加订单统计信息和推荐码使用详情（手机号默认脱敏）
 		referralService := NewReferralCodeService()
+		showFullPII := GetPIIService().HasViewPIIPermission(c)
 		var usersData []gin.H
 		for _, user := range managedUsers {
+			phone := user.Phone.String()
+			if !showFullPII {
+				phone = utils.MaskPhone(phone)
+			}
+
 			totalOrders, totalSpent, err := userService.GetUserOrderStats(user.OpenID)
 			if err != nil {
 				totalOrders = 0
@@ -72,7 +79,7 @@ func GetAgentUsersHandler() gin.HandlerFunc {
 				"school":      user.School,
 				"city":        user.City,
 				"age":         user.Age,
-				"phone":       user.Phone,
+				"phone":       phone,
 				"agent_level": user.AgentLevel,
 
 				"referral_code":          user.ReferralCode,
@@ -235,6 +242,18 @@ func WithdrawCommissionHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 封禁或暂停的代理佣金已冻结，不能提现
+		if agent.EffectiveAccountStatus(utils.GetCurrentUTCTime()) != models.AccountStatusActive {
+			ForbiddenResponse(c, "账号已被冻结，暂不能提现", nil)
+			return
+		}
+
+		// 按配置要求代理先完成手机号验证
+		if config.GetConfig().RequireVerifiedPhoneForWithdraw && !agent.PhoneVerified {
+			ForbiddenResponse(c, "提现前请先绑定并验证手机号", nil)
+			return
+		}
+
 		// 微信支付企业转账只支持微信
 		if req.Amount <= 0 {
 			BadRequestResponse(c, "提取金额必须大于0", nil)
@@ -682,7 +682,7 @@ func (s *AgentSalesService) buildSalesResponse(totalSales float64, totalOrders i
 	var topPerformers []map[string]interface{}
 	for userID, data := range userSales {
 		topPerformers = append(topPerformers, map[string]interface{}{
-			"user_id":      userID,
+			"user_id":      utils.EncodeOpenIDToSafeID(userID),
 			"user_name":    data.UserName,
 			"total_spent":  data.TotalSpent,
 			"orders_count": data.OrdersCount,
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"miniprogram/middlewares"
 	"miniprogram/models"
+	"miniprogram/utils"
+	"net"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +30,10 @@ func WechatAuthHandler() gin.HandlerFunc {
 		// 执行微信认证
 		result, err := authService.AuthenticateWithWechat(req.Code, req.ReferralCode)
 		if err != nil {
+			if errors.Is(err, ErrAccountBanned) || errors.Is(err, ErrAccountSuspended) {
+				ForbiddenResponse(c, err.Error(), nil)
+				return
+			}
 			InternalServerErrorResponse(c, "认证失败", err)
 			return
 		}
@@ -39,7 +47,8 @@ func WechatAuthHandler() gin.HandlerFunc {
 	}
 }
 
-// DevLoginHandler 开发环境登录处理器 - 仅用于测试
+// DevLoginHandler 测试身份登录处理器 - 仅用于测试
+// 只接受签名的测试身份夹具，且限制openID白名单和本机回环地址，生产环境不可用
 func DevLoginHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req models.DevLoginRequest
@@ -50,6 +59,20 @@ func DevLoginHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 校验测试身份并记录本次使用
+		identityService := GetTestIdentityService()
+		remoteIP := c.RemoteIP()
+		localAddr := ""
+		if addr, ok := c.Request.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			localAddr = addr.String()
+		}
+		authErr := identityService.Authorize(req, remoteIP, localAddr)
+		identityService.RecordUse(req.OpenID, remoteIP, c.Request.UserAgent(), authErr)
+		if authErr != nil {
+			ForbiddenResponse(c, "测试身份校验失败", authErr)
+			return
+		}
+
 		// 初始化服务
 		authService := GetAuthService()
 
@@ -74,6 +97,12 @@ func DevLoginHandler() gin.HandlerFunc {
 			}
 		}
 
+		// 检查账号状态
+		if err := GetUserStatusService().CheckAccountStatus(user); err != nil {
+			ForbiddenResponse(c, err.Error(), nil)
+			return
+		}
+
 		// 生成JWT token - 使用原有的middlewares方法
 		tokenUser := middlewares.User{
 			UserName:     user.UserName,
@@ -89,10 +118,71 @@ func DevLoginHandler() gin.HandlerFunc {
 		}
 
 		// 返回结果
-		SuccessResponse(c, "开发环境登录成功", gin.H{
+		SuccessResponse(c, "测试身份登录成功", gin.H{
 			"token":       token,
 			"user":        user,
 			"is_new_user": isNewUser,
 		})
 	}
 }
+
+// DecryptWechatDataHandler 解密微信加密数据处理器
+// 使用当前登录用户服务端保存的 session_key 解密 encryptedData/iv，并校验水印appid
+func DecryptWechatDataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := middlewares.GetUserFromGinContext(c)
+		if !ok {
+			UnauthorizedResponse(c, "用户未认证", nil)
+			return
+		}
+
+		var req models.DecryptWechatDataRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		data, err := GetWechatSessionService().DecryptUserData(claims.UserId, req.EncryptedData, req.IV)
+		if err != nil {
+			if err == ErrWechatSessionExpired {
+				UnauthorizedResponse(c, err.Error(), nil)
+				return
+			}
+			BadRequestResponse(c, "解密微信数据失败", err)
+			return
+		}
+
+		SuccessResponse(c, "解密成功", data)
+	}
+}
+
+// CheckWechatSessionHandler 检查微信会话状态处理器
+// 客户端据此判断是否需要重新调用 wx.login
+func CheckWechatSessionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := middlewares.GetUserFromGinContext(c)
+		if !ok {
+			UnauthorizedResponse(c, "用户未认证", nil)
+			return
+		}
+
+		session, err := GetWechatSessionService().CheckSession(claims.UserId)
+		if err != nil {
+			if err == ErrWechatSessionExpired {
+				SuccessResponse(c, "微信会话已过期", gin.H{
+					"valid":        false,
+					"need_relogin": true,
+				})
+				return
+			}
+			InternalServerErrorResponse(c, "检查微信会话失败", err)
+			return
+		}
+
+		SuccessResponse(c, "微信会话有效", gin.H{
+			"valid":        true,
+			"need_relogin": false,
+			"expires_at":   utils.FormatTimeForResponse(session.ExpiresAt),
+		})
+	}
+}
@@ -1,10 +1,10 @@
 package controllers
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"miniprogram/config"
 	"miniprogram/middlewares"
 	"miniprogram/models"
@@ -66,7 +66,17 @@ func (s *AuthService) AuthenticateWithWechat(code string, referralCode string) (
 		}
 	}
 
-	// 4. 生成Token
+	// 4. 检查账号状态，封禁或暂停的账号不允许登录
+	if err := GetUserStatusService().CheckAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	// 5. 加密保存session_key，用于后续解密小程序加密数据
+	if err := GetWechatSessionService().SaveSession(user.OpenID, wechatData.SessionKey, wechatData.UnionID); err != nil {
+		log.Printf("[微信会话] 保存session_key失败: openID=%s, err=%v", user.OpenID, err)
+	}
+
+	// 6. 生成Token
 	token, err := s.generateUserToken(user)
 	if err != nil {
 		return nil, fmt.Errorf("生成token失败: %w", err)
@@ -169,12 +179,14 @@ func (s *AuthService) createNewUserWithReferral(openID string, referralCode stri
 	if newUser.ReferralCode != "" {
 		qrCode, err := s.generateUserQRCode(newUser.ReferralCode)
 		if err == nil {
-			// 更新用户二维码
-			userService := GetUserService()
-			userService.UpdateUserQRCode(newUser.OpenID, qrCode)
-			newUser.QRCode = qrCode
+			// 保存到对象存储，用户记录只保存对象键
+			if key, err := GetUserFileService().SaveQRCode(newUser.OpenID, qrCode); err == nil {
+				newUser.QRCodeKey = key
+			} else {
+				log.Printf("保存用户小程序码失败: %v", err)
+			}
 		}
-		// 二维码生成失败不影响用户创建
+		// 二维码生成失败不影响用户创建（访问时重新生成）
 	}
 
 	return newUser, nil
@@ -271,6 +283,15 @@ func (s *WechatAccessTokenService) GetAccessToken() (string, error) {
 	return token, nil
 }
 
+// InvalidateAccessToken 使缓存的访问令牌失效（微信返回令牌过期或无效时调用）
+func (s *WechatAccessTokenService) InvalidateAccessToken() {
+	accessTokenCache.mu.Lock()
+	defer accessTokenCache.mu.Unlock()
+
+	accessTokenCache.token = ""
+	accessTokenCache.expireAt = time.Time{}
+}
+
 // fetchAccessToken 从微信服务器获取访问令牌
 func (s *WechatAccessTokenService) fetchAccessToken() (string, time.Time, error) {
 	cfg := config.GetConfig()
@@ -313,15 +334,65 @@ func (s *WechatAccessTokenService) fetchAccessToken() (string, time.Time, error)
 	return data.AccessToken, expireAt, nil
 }
 
+// GetWechatPhoneNumber 使用小程序 getPhoneNumber 返回的 code 换取用户手机号
+func (s *AuthService) GetWechatPhoneNumber(code string) (*models.WechatPhoneInfo, error) {
+	// 1. 获取微信访问令牌
+	tokenService := GetWechatAccessTokenService()
+	accessToken, err := tokenService.GetAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("获取微信访问令牌失败: %w", err)
+	}
+
+	// 2. 构建请求参数
+	jsonData, err := json.Marshal(map[string]string{"code": code})
+	if err != nil {
+		return nil, fmt.Errorf("构建请求参数失败: %w", err)
+	}
+
+	// 3. 调用微信API换取手机号
+	cfg := config.GetConfig()
+	apiURL := fmt.Sprintf("%s/wxa/business/getuserphonenumber?access_token=%s", cfg.WechatAPIURL, accessToken)
+
+	response, err := http.Post(apiURL, "application/json", strings.NewReader(string(jsonData)))
+	if err != nil {
+		return nil, fmt.Errorf("调用微信API失败: %w", err)
+	}
+	defer response.Body.Close()
+
+	var responseData models.WechatPhoneNumberResponse
+	if err := json.NewDecoder(response.Body).Decode(&responseData); err != nil {
+		return nil, fmt.Errorf("解析微信API响应失败: %w", err)
+	}
+
+	// 4. 检查错误（令牌失效时清除缓存，下次请求重新获取）
+	if responseData.ErrCode != 0 {
+		if responseData.ErrCode == 40001 || responseData.ErrCode == 42001 {
+			tokenService.InvalidateAccessToken()
+		}
+		return nil, fmt.Errorf("微信API错误: %d - %s", responseData.ErrCode, responseData.ErrMsg)
+	}
+
+	// 5. 校验数据水印，确保手机号属于当前小程序
+	phoneInfo := responseData.PhoneInfo
+	if phoneInfo.Watermark.AppID != cfg.WechatAppID {
+		return nil, fmt.Errorf("手机号数据水印appid不匹配")
+	}
+	if phoneInfo.PurePhoneNumber == "" {
+		return nil, fmt.Errorf("微信API响应数据不完整: 手机号为空")
+	}
+
+	return &phoneInfo, nil
+}
+
 // ===== 向后兼容函数 =====
 
-// generateUserQRCode 生成用户推荐二维码
-func (s *AuthService) generateUserQRCode(referralCode string) (string, error) {
+// generateUserQRCode 生成用户推荐二维码，返回图片内容
+func (s *AuthService) generateUserQRCode(referralCode string) ([]byte, error) {
 	// 获取微信访问令牌
 	tokenService := GetWechatAccessTokenService()
 	accessToken, err := tokenService.GetAccessToken()
 	if err != nil {
-		return "", fmt.Errorf("获取微信访问令牌失败: %w", err)
+		return nil, fmt.Errorf("获取微信访问令牌失败: %w", err)
 	}
 
 	// 获取配置
@@ -338,7 +409,7 @@ func (s *AuthService) generateUserQRCode(referralCode string) (string, error) {
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestData)
 	if err != nil {
-		return "", fmt.Errorf("构建请求参数失败: %w", err)
+		return nil, fmt.Errorf("构建请求参数失败: %w", err)
 	}
 
 	// 调用微信API生成小程序码
@@ -346,29 +417,25 @@ func (s *AuthService) generateUserQRCode(referralCode string) (string, error) {
 
 	response, err := http.Post(apiURL, "application/json", strings.NewReader(string(jsonData)))
 	if err != nil {
-		return "", fmt.Errorf("调用微信API失败: %w", err)
+		return nil, fmt.Errorf("调用微信API失败: %w", err)
 	}
 	defer response.Body.Close()
 
 	// 读取响应数据
 	responseData, err := io.ReadAll(response.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应数据失败: %w", err)
+		return nil, fmt.Errorf("读取响应数据失败: %w", err)
 	}
 
 	// 检查是否是错误响应（JSON格式）
 	var errorResp map[string]interface{}
 	if json.Unmarshal(responseData, &errorResp) == nil {
 		if errCode, exists := errorResp["errcode"]; exists {
-			return "", fmt.Errorf("微信API错误: %v - %v", errCode, errorResp["errmsg"])
+			return nil, fmt.Errorf("微信API错误: %v - %v", errCode, errorResp["errmsg"])
 		}
 	}
 
-	// 将图片数据转换为base64
-	base64Image := base64.StdEncoding.EncodeToString(responseData)
-	qrCodeData := "data:image/jpeg;base64," + base64Image
-
-	return qrCodeData, nil
+	return responseData, nil
 }
 
 // GetCachedAccessToken 获取已缓存的访问令牌 (向后兼容)
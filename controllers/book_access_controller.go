@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondFamilyError 统一处理家庭共享相关错误
+func respondFamilyError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidFamilyRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrNoShareableBooks):
+		ForbiddenResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrAlreadyInFamily), errors.Is(err, ErrFamilyFull):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, "用户或家庭成员不存在", err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// GetBookAccessHandler 获取用户对某本书的访问权限处理器（到期时间、来源、试读单元）
+func GetBookAccessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		bookID, err := primitive.ObjectIDFromHex(c.Param("book_id"))
+		if err != nil {
+			BadRequestResponse(c, "无效的书籍ID格式", err)
+			return
+		}
+
+		access, err := GetBookAccessService().GetBookAccess(openID, bookID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+				return
+			}
+			InternalServerErrorResponse(c, "获取书籍访问权限失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取书籍访问权限成功", access)
+	}
+}
+
+// GetFamilyHandler 获取家庭共享信息处理器
+func GetFamilyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		family, err := GetBookAccessService().GetFamily(openID)
+		if err != nil {
+			InternalServerErrorResponse(c, "获取家庭共享信息失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取家庭共享信息成功", family)
+	}
+}
+
+// AddFamilyMemberHandler 购买者添加家庭共享成员处理器
+func AddFamilyMemberHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.AddFamilyMemberRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		memberOpenID, err := utils.DecodeSafeIDToOpenID(req.MemberID)
+		if err != nil {
+			BadRequestResponse(c, "无效的成员标识符", nil)
+			return
+		}
+
+		link, err := GetBookAccessService().AddFamilyMember(openID, memberOpenID)
+		if err != nil {
+			respondFamilyError(c, "添加家庭成员失败", err)
+			return
+		}
+
+		CreatedResponse(c, "家庭成员添加成功", link)
+	}
+}
+
+// RemoveFamilyMemberHandler 购买者移除家庭共享成员处理器（:member_id 为成员的安全用户标识符）
+func RemoveFamilyMemberHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		memberOpenID, err := utils.DecodeSafeIDToOpenID(c.Param("member_id"))
+		if err != nil {
+			BadRequestResponse(c, "无效的成员标识符", nil)
+			return
+		}
+
+		if err := GetBookAccessService().RemoveFamilyMember(openID, memberOpenID); err != nil {
+			respondFamilyError(c, "移除家庭成员失败", err)
+			return
+		}
+
+		SuccessResponse(c, "家庭成员已移除", nil)
+	}
+}
+
+// LeaveFamilyHandler 成员退出家庭共享处理器
+func LeaveFamilyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		if err := GetBookAccessService().LeaveFamily(openID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "您尚未加入家庭共享", nil)
+				return
+			}
+			InternalServerErrorResponse(c, "退出家庭共享失败", err)
+			return
+		}
+
+		SuccessResponse(c, "已退出家庭共享", nil)
+	}
+}
+
+// GrantBookPermissionHandler 管理员授予用户书籍权限处理器（可设置有效天数，如学期通行证）
+func GrantBookPermissionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID := c.Param("user_id")
+
+		var req models.GrantBookPermissionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		permission, err := GetBookAccessService().GrantBookPermission(openID, req)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidBookGrant):
+				BadRequestResponse(c, "书籍ID或权限类型无效", nil)
+			case errors.Is(err, mongo.ErrNoDocuments):
+				NotFoundResponse(c, "用户或书籍不存在", err)
+			default:
+				InternalServerErrorResponse(c, "授予书籍权限失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "书籍权限授予成功", gin.H{
+			"user_id":    utils.EncodeOpenIDToSafeID(openID),
+			"permission": permission,
+		})
+	}
+}
+
+// RevokeBookPermissionHandler 管理员撤销用户书籍权限处理器
+func RevokeBookPermissionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID := c.Param("user_id")
+
+		if err := GetBookAccessService().RevokeBookPermission(openID, c.Param("book_id")); err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidBookGrant):
+				BadRequestResponse(c, "无效的书籍ID格式", nil)
+			case errors.Is(err, mongo.ErrNoDocuments):
+				NotFoundResponse(c, "用户没有该书籍的权限", nil)
+			default:
+				InternalServerErrorResponse(c, "撤销书籍权限失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "书籍权限已撤销", nil)
+	}
+}
@@ -0,0 +1,476 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 书籍访问权限服务层（限时权限、免费试读、家庭共享） =====
+
+// maxFamilyMembers 每个购买者最多可共享的家庭成员数
+const maxFamilyMembers = 4
+
+var (
+	// ErrInvalidFamilyRequest 家庭共享请求参数无效
+	ErrInvalidFamilyRequest = errors.New("家庭共享请求参数无效")
+	// ErrNoShareableBooks 没有可共享的已购书籍
+	ErrNoShareableBooks = errors.New("您还没有可共享的已购书籍")
+	// ErrAlreadyInFamily 成员已加入其他家庭
+	ErrAlreadyInFamily = errors.New("该用户已加入其他家庭共享")
+	// ErrFamilyFull 家庭成员已满
+	ErrFamilyFull = errors.New("家庭共享成员已达上限")
+	// ErrInvalidBookGrant 授予书籍权限参数无效
+	ErrInvalidBookGrant = errors.New("授予书籍权限参数无效")
+)
+
+// BookAccessService 书籍访问权限服务
+type BookAccessService struct{}
+
+// GetBookAccessService 获取书籍访问权限服务实例
+func GetBookAccessService() *BookAccessService {
+	return &BookAccessService{}
+}
+
+// GetBookAccess 计算用户对书籍的有效访问权限
+// 优先级：自有的有效权限 > 家庭共享 > 免费试读；已过期的自有权限不再生效，但会在结果中标记
+func (s *BookAccessService) GetBookAccess(openID string, bookID primitive.ObjectID) (*models.BookAccess, error) {
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	access := &models.BookAccess{BookID: bookID}
+
+	for _, permission := range user.UnlockedBooks {
+		if permission.BookID != bookID {
+			continue
+		}
+		access.ExpiresAt = permission.ExpiresAt
+		if !permission.IsActive(now) {
+			access.Expired = true
+			break
+		}
+		access.HasAccess = true
+		access.FullAccess = true
+		access.AccessType = permission.AccessType
+		access.Source = permission.Source
+		if access.Source == "" {
+			access.Source = models.BookAccessSourcePurchase
+		}
+		return access, nil
+	}
+
+	link, shared, err := s.findFamilyPermission(openID, bookID, now)
+	if err != nil {
+		return nil, err
+	}
+	if shared != nil {
+		access.HasAccess = true
+		access.FullAccess = true
+		access.AccessType = "digital" // 家庭共享只共享电子版内容
+		access.Source = models.BookAccessSourceFamily
+		access.ExpiresAt = shared.ExpiresAt
+		access.SharedByOpenID = link.OwnerOpenID
+		return access, nil
+	}
+
+	trialUnitIDs, err := s.getTrialUnitIDs(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if len(trialUnitIDs) > 0 {
+		access.HasAccess = true
+		access.AccessType = models.BookAccessTypeTrial
+		access.Source = models.BookAccessSourceTrial
+		access.TrialUnitIDs = trialUnitIDs
+	}
+
+	return access, nil
+}
+
+// GetFullAccessBookIDs 获取用户可以完整访问的书籍ID（自有的有效权限和家庭共享，不含试读）
+func (s *BookAccessService) GetFullAccessBookIDs(user *models.User) ([]primitive.ObjectID, error) {
+	now := utils.GetCurrentUTCTime()
+	seen := make(map[primitive.ObjectID]bool)
+	bookIDs := make([]primitive.ObjectID, 0, len(user.UnlockedBooks))
+
+	for _, permission := range user.UnlockedBooks {
+		if permission.IsActive(now) && !seen[permission.BookID] {
+			seen[permission.BookID] = true
+			bookIDs = append(bookIDs, permission.BookID)
+		}
+	}
+
+	link, err := s.findJoinedLink(user.OpenID)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return bookIDs, nil
+	}
+
+	owner, err := GetUserService().FindUserByOpenID(link.OwnerOpenID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return bookIDs, nil
+		}
+		return nil, err
+	}
+	for _, permission := range owner.UnlockedBooks {
+		if permission.IsShareable(now) && !seen[permission.BookID] {
+			seen[permission.BookID] = true
+			bookIDs = append(bookIDs, permission.BookID)
+		}
+	}
+
+	return bookIDs, nil
+}
+
+// findJoinedLink 查找用户作为成员加入的家庭共享关系，未加入时返回 nil
+func (s *BookAccessService) findJoinedLink(openID string) (*models.FamilyLink, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var link models.FamilyLink
+	err := GetCollection("family_links").FindOne(ctx, bson.M{"member_openid": openID}).Decode(&link)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// findFamilyPermission 查找家庭购买者对该书籍的可共享权限
+func (s *BookAccessService) findFamilyPermission(openID string, bookID primitive.ObjectID, now time.Time) (*models.FamilyLink, *models.BookPermission, error) {
+	link, err := s.findJoinedLink(openID)
+	if err != nil || link == nil {
+		return nil, nil, err
+	}
+
+	owner, err := GetUserService().FindUserByOpenID(link.OwnerOpenID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	for i := range owner.UnlockedBooks {
+		if owner.UnlockedBooks[i].BookID == bookID && owner.UnlockedBooks[i].IsShareable(now) {
+			return link, &owner.UnlockedBooks[i], nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// getTrialUnitIDs 获取书籍免费试读的单元（按单元创建顺序取前 trial_units 个）
+func (s *BookAccessService) getTrialUnitIDs(bookID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var book models.Book
+	err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if book.TrialUnits <= 0 {
+		return nil, nil
+	}
+
+	opts := options.Find().
+		SetSort(unitOrderSort).
+		SetLimit(int64(book.TrialUnits)).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := GetCollection("units").Find(ctx, bson.M{"book_id": bookID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var units []models.Unit
+	if err := cursor.All(ctx, &units); err != nil {
+		return nil, err
+	}
+
+	unitIDs := make([]primitive.ObjectID, 0, len(units))
+	for _, unit := range units {
+		unitIDs = append(unitIDs, unit.ID)
+	}
+	return unitIDs, nil
+}
+
+// mergeBookPermission 将新获得的书籍权限合并到用户已有权限中，返回是否有变化
+// 实体权限覆盖电子权限；永久权限覆盖限时权限；限时权限在未过期部分的基础上顺延；已过期的权限视为重新获得
+// 同一订单重复处理时不会重复顺延
+func mergeBookPermission(permissions []models.BookPermission, granted models.BookPermission, days int, now time.Time) ([]models.BookPermission, bool) {
+	var expiresAt *time.Time
+	if days > 0 {
+		t := now.AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	for i := range permissions {
+		permission := &permissions[i]
+		if permission.BookID != granted.BookID {
+			continue
+		}
+
+		if !granted.OrderID.IsZero() && permission.OrderID == granted.OrderID {
+			return permissions, false
+		}
+
+		if !permission.IsActive(now) {
+			granted.UnlockedAt = now
+			granted.ExpiresAt = expiresAt
+			*permission = granted
+			return permissions, true
+		}
+
+		changed := false
+		if granted.Source != models.BookAccessSourceGrant && permission.Source == models.BookAccessSourceGrant {
+			permission.Source = granted.Source
+			changed = true
+		}
+		if permission.AccessType == "digital" && granted.AccessType == "physical" {
+			permission.AccessType = "physical"
+			permission.UnlockedAt = now
+			changed = true
+		}
+		if permission.ExpiresAt != nil {
+			if days == 0 {
+				permission.ExpiresAt = nil
+				permission.Source = granted.Source
+			} else {
+				extended := permission.ExpiresAt.AddDate(0, 0, days)
+				permission.ExpiresAt = &extended
+			}
+			changed = true
+		}
+		if changed && !granted.OrderID.IsZero() {
+			permission.OrderID = granted.OrderID
+		}
+		return permissions, changed
+	}
+
+	granted.UnlockedAt = now
+	granted.ExpiresAt = expiresAt
+	return append(permissions, granted), true
+}
+
+// ===== 管理员授予/撤销书籍权限 =====
+
+// GrantBookPermission 管理员为用户授予书籍权限（如学期通行证），days 为 0 表示永久
+func (s *BookAccessService) GrantBookPermission(openID string, req models.GrantBookPermissionRequest) (*models.BookPermission, error) {
+	bookID, err := primitive.ObjectIDFromHex(req.BookID)
+	if err != nil {
+		return nil, ErrInvalidBookGrant
+	}
+	accessType := req.AccessType
+	if accessType == "" {
+		accessType = "digital"
+	}
+	if accessType != "digital" && accessType != "physical" {
+		return nil, ErrInvalidBookGrant
+	}
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	permissions, changed := mergeBookPermission(user.UnlockedBooks, models.BookPermission{
+		BookID:     bookID,
+		BookName:   book.BookName,
+		AccessType: accessType,
+		Source:     models.BookAccessSourceGrant,
+	}, req.Days, now)
+
+	if changed {
+		_, err = GetCollection("users").UpdateOne(ctx, bson.M{"openID": openID}, bson.M{
+			"$set": bson.M{
+				"unlocked_books": permissions,
+				"updated_at":     now,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range permissions {
+		if permissions[i].BookID == bookID {
+			return &permissions[i], nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// RevokeBookPermission 管理员撤销用户的书籍权限
+func (s *BookAccessService) RevokeBookPermission(openID, bookIDHex string) error {
+	bookID, err := primitive.ObjectIDFromHex(bookIDHex)
+	if err != nil {
+		return ErrInvalidBookGrant
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection("users").UpdateOne(ctx,
+		bson.M{"openID": openID, "unlocked_books.book_id": bookID},
+		bson.M{
+			"$pull": bson.M{"unlocked_books": bson.M{"book_id": bookID}},
+			"$set":  bson.M{"updated_at": utils.GetCurrentUTCTime()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ===== 家庭共享 =====
+
+// GetFamily 获取用户的家庭共享信息（共享给的成员和加入的家庭）
+func (s *BookAccessService) GetFamily(openID string) (*models.FamilyInfo, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection("family_links").Find(ctx,
+		bson.M{"owner_openid": openID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	info := &models.FamilyInfo{
+		Members:    []models.FamilyLink{},
+		MaxMembers: maxFamilyMembers,
+	}
+	if err := cursor.All(ctx, &info.Members); err != nil {
+		return nil, err
+	}
+
+	info.JoinedLink, err = s.findJoinedLink(openID)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// AddFamilyMember 购买者将关联账号加入家庭共享，成员可访问购买者所有有效的已购书籍
+func (s *BookAccessService) AddFamilyMember(ownerOpenID, memberOpenID string) (*models.FamilyLink, error) {
+	if memberOpenID == "" || memberOpenID == ownerOpenID {
+		return nil, ErrInvalidFamilyRequest
+	}
+
+	userService := GetUserService()
+	owner, err := userService.FindUserByOpenID(ownerOpenID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := userService.FindUserByOpenID(memberOpenID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	shareable := false
+	for _, permission := range owner.UnlockedBooks {
+		if permission.IsShareable(now) {
+			shareable = true
+			break
+		}
+	}
+	if !shareable {
+		return nil, ErrNoShareableBooks
+	}
+
+	collection := GetCollection("family_links")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	count, err := collection.CountDocuments(ctx, bson.M{"owner_openid": ownerOpenID})
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxFamilyMembers {
+		return nil, ErrFamilyFull
+	}
+
+	link := models.FamilyLink{
+		OwnerOpenID:  ownerOpenID,
+		OwnerName:    owner.UserName,
+		MemberOpenID: memberOpenID,
+		MemberName:   member.UserName,
+		CreatedAt:    now,
+	}
+	result, err := collection.InsertOne(ctx, link)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrAlreadyInFamily
+		}
+		return nil, err
+	}
+	link.ID = result.InsertedID.(primitive.ObjectID)
+	return &link, nil
+}
+
+// RemoveFamilyMember 购买者将成员移出家庭共享
+func (s *BookAccessService) RemoveFamilyMember(ownerOpenID, memberOpenID string) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection("family_links").DeleteOne(ctx, bson.M{
+		"owner_openid":  ownerOpenID,
+		"member_openid": memberOpenID,
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// LeaveFamily 成员退出加入的家庭共享
+func (s *BookAccessService) LeaveFamily(memberOpenID string) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection("family_links").DeleteOne(ctx, bson.M{"member_openid": memberOpenID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -8,6 +8,32 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// requireBookAccess 检查用户对书籍中指定单元的访问权限，无权限时写入错误响应
+// 已过期的权限提示续费；试读用户只能访问书籍的前 N 个单元
+func requireBookAccess(c *gin.Context, openID string, bookID, unitID primitive.ObjectID, deniedMessage string) (*models.BookAccess, bool) {
+	access, err := GetBookAccessService().GetBookAccess(openID, bookID)
+	if err != nil {
+		InternalServerErrorResponse(c, "检查用户权限失败", err)
+		return nil, false
+	}
+
+	if !access.HasAccess {
+		if access.Expired {
+			ForbiddenResponse(c, "您对该书籍的访问权限已过期，请续费后继续学习", nil)
+			return nil, false
+		}
+		ForbiddenResponse(c, deniedMessage, nil)
+		return nil, false
+	}
+
+	if !unitID.IsZero() && !access.AllowsUnit(unitID) {
+		ForbiddenResponse(c, "该单元不在免费试读范围内，请先购买相关单词卡", nil)
+		return nil, false
+	}
+
+	return access, true
+}
+
 // GetUnitWordsHandler 获取指定单元的所有单词列表
 func GetUnitWordsHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -40,15 +66,9 @@ func GetUnitWordsHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 检查用户是否有访问该书籍的权限
-		hasPermission, accessType, err := CheckUserBookPermission(userID.(string), unit.BookID)
-		if err != nil {
-			InternalServerErrorResponse(c, "检查用户权限失败", err)
-			return
-		}
-
-		if !hasPermission {
-			ForbiddenResponse(c, "您没有访问该书籍的权限，请先购买相关单词卡", nil)
+		// 检查用户是否有访问该单元的权限
+		access, ok := requireBookAccess(c, userID.(string), unit.BookID, unit.ID, "您没有访问该书籍的权限，请先购买相关单词卡")
+		if !ok {
 			return
 		}
 
@@ -78,7 +98,7 @@ func GetUnitWordsHandler() gin.HandlerFunc {
 			"unit_id":     unitIDStr,
 			"words":       words,
 			"word_count":  len(words),
-			"access_type": accessType,
+			"access_type": access.AccessType,
 		})
 	}
 }
@@ -123,15 +143,9 @@ func GetWordCardHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 检查用户是否有访问该书籍的权限
-		hasPermission, accessType, err := CheckUserBookPermission(userID.(string), word.BookID)
-		if err != nil {
-			InternalServerErrorResponse(c, "检查用户权限失败", err)
-			return
-		}
-
-		if !hasPermission {
-			ForbiddenResponse(c, "您没有访问该单词卡的权限，请先购买相关单词卡", nil)
+		// 检查用户是否有访问该单词所属单元的权限
+		access, ok := requireBookAccess(c, userID.(string), word.BookID, word.UnitID, "您没有访问该单词卡的权限，请先购买相关单词卡")
+		if !ok {
 			return
 		}
 
@@ -143,20 +157,45 @@ func GetWordCardHandler() gin.HandlerFunc {
 			isCollected = false
 		}
 
-		// 返回单词详细信息，特别是图片URL
+		// 返回单词详细信息，特别是图片URL；原有字段保持不变，义项、例句和关联词为扩展字段
 		SuccessResponse(c, "获取单词信息成功", gin.H{
+			"word_id":           word.ID.Hex(),
 			"word_name":         word.WordName,
 			"word_meaning":      word.WordMeaning,
+			"phonetic":          word.Phonetic,
+			"phonetic_uk":       word.PhoneticUK,
+			"phonetic_us":       word.PhoneticUS,
 			"pronunciation_url": word.PronunciationURL,
 			"img_url":           word.ImgURL,
+			"senses":            wordCardSenses(word),
+			"relations":         wordCardRelations(word),
 			"unit_id":           word.UnitID.Hex(),
 			"book_id":           word.BookID.Hex(),
-			"access_type":       accessType,
+			"access_type":       access.AccessType,
 			"is_collected":      isCollected,
 		})
 	}
 }
 
+// wordCardSenses 单词卡片的义项；尚未录入义项的单词用简要释义生成一个义项，便于客户端统一展示
+func wordCardSenses(word models.Word) []models.WordSense {
+	if len(word.Senses) > 0 {
+		return word.Senses
+	}
+	if word.WordMeaning == "" {
+		return []models.WordSense{}
+	}
+	return []models.WordSense{{Definition: word.WordMeaning}}
+}
+
+// wordCardRelations 单词卡片的关联词（没有时返回空数组）
+func wordCardRelations(word models.Word) []models.WordRelation {
+	if word.Relations == nil {
+		return []models.WordRelation{}
+	}
+	return word.Relations
+}
+
 // GetWordsByUnitNameHandler 通过单元名称获取单词列表（备用方案）
 func GetWordsByUnitNameHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -215,15 +254,9 @@ func GetWordsByUnitNameHandler() gin.HandlerFunc {
 			bookID = unit.BookID
 		}
 
-		// 检查用户是否有访问该书籍的权限
-		hasPermission, accessType, err := CheckUserBookPermission(userID.(string), bookID)
-		if err != nil {
-			InternalServerErrorResponse(c, "检查用户权限失败", err)
-			return
-		}
-
-		if !hasPermission {
-			ForbiddenResponse(c, "您没有访问该书籍的权限，请先购买相关单词卡", nil)
+		// 检查用户是否有访问该单元的权限
+		access, ok := requireBookAccess(c, userID.(string), bookID, unit.ID, "您没有访问该书籍的权限，请先购买相关单词卡")
+		if !ok {
 			return
 		}
 
@@ -251,7 +284,7 @@ func GetWordsByUnitNameHandler() gin.HandlerFunc {
 			"unit_id":     unit.ID.Hex(),
 			"words":       words,
 			"word_count":  len(words),
-			"access_type": accessType,
+			"access_type": access.AccessType,
 		})
 	}
 }
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// requireTeacher 校验当前登录用户为 :user_id 本人且拥有教师权限
+func requireTeacher(c *gin.Context) (*models.User, bool) {
+	openID, ok := requireAccountOwner(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			NotFoundResponse(c, "用户不存在", err)
+			return nil, false
+		}
+		InternalServerErrorResponse(c, "查询用户失败", err)
+		return nil, false
+	}
+	if !user.HasPermission(models.PermissionTeacher) {
+		ForbiddenResponse(c, "需要教师权限", nil)
+		return nil, false
+	}
+	return user, true
+}
+
+// respondClassroomError 统一处理班级相关错误
+func respondClassroomError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidClassroomRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrInvalidJoinCode):
+		NotFoundResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrAlreadyInClassroom), errors.Is(err, ErrClassroomFull):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, "班级不存在", err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// CreateClassroomHandler 教师创建班级处理器
+func CreateClassroomHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		var req models.CreateClassroomRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		classroom, err := GetClassroomService().CreateClassroom(teacher, req)
+		if err != nil {
+			respondClassroomError(c, "创建班级失败", err)
+			return
+		}
+
+		CreatedResponse(c, "班级创建成功", classroom)
+	}
+}
+
+// GetTeacherClassroomsHandler 获取教师创建的班级列表处理器
+func GetTeacherClassroomsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		classrooms, err := GetClassroomService().ListTeacherClassrooms(teacher.OpenID)
+		if err != nil {
+			InternalServerErrorResponse(c, "获取班级列表失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取班级列表成功", classrooms)
+	}
+}
+
+// GetTeacherClassroomHandler 获取班级详情处理器（仅限教师自己的班级）
+func GetTeacherClassroomHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		classroom, err := GetClassroomService().GetTeacherClassroom(teacher.OpenID, c.Param("classroom_id"))
+		if err != nil {
+			respondClassroomError(c, "获取班级失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取班级成功", classroom)
+	}
+}
+
+// DeleteClassroomHandler 教师解散班级处理器
+func DeleteClassroomHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		if err := GetClassroomService().DeleteClassroom(teacher.OpenID, c.Param("classroom_id")); err != nil {
+			respondClassroomError(c, "解散班级失败", err)
+			return
+		}
+
+		SuccessResponse(c, "班级已解散", nil)
+	}
+}
+
+// RemoveClassroomStudentHandler 教师将学生移出班级处理器（:student_id 为学生的安全用户标识符）
+func RemoveClassroomStudentHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		studentOpenID, err := utils.DecodeSafeIDToOpenID(c.Param("student_id"))
+		if err != nil {
+			BadRequestResponse(c, "无效的学生标识符", nil)
+			return
+		}
+
+		classroom, err := GetClassroomService().RemoveStudent(teacher.OpenID, c.Param("classroom_id"), studentOpenID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "班级或学生不存在", nil)
+				return
+			}
+			respondClassroomError(c, "移出学生失败", err)
+			return
+		}
+
+		SuccessResponse(c, "学生已移出班级", classroom)
+	}
+}
+
+// CreateClassAssignmentHandler 教师布置单元处理器
+func CreateClassAssignmentHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		var req models.CreateClassAssignmentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		classroom, err := GetClassroomService().AddAssignment(teacher.OpenID, c.Param("classroom_id"), req)
+		if err != nil {
+			respondClassroomError(c, "布置单元失败", err)
+			return
+		}
+
+		CreatedResponse(c, "单元布置成功", classroom)
+	}
+}
+
+// DeleteClassAssignmentHandler 教师删除班级任务处理器
+func DeleteClassAssignmentHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		classroom, err := GetClassroomService().RemoveAssignment(teacher.OpenID, c.Param("classroom_id"), c.Param("assignment_id"))
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "班级或任务不存在", nil)
+				return
+			}
+			respondClassroomError(c, "删除任务失败", err)
+			return
+		}
+
+		SuccessResponse(c, "任务已删除", classroom)
+	}
+}
+
+// GetClassroomReportHandler 获取班级学习报告处理器（format=json 或 csv）
+func GetClassroomReportHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacher, ok := requireTeacher(c)
+		if !ok {
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" {
+			BadRequestResponse(c, "不支持的导出格式，仅支持 json 或 csv", nil)
+			return
+		}
+
+		classroomService := GetClassroomService()
+		report, err := classroomService.BuildReport(teacher.OpenID, c.Param("classroom_id"))
+		if err != nil {
+			respondClassroomError(c, "生成班级报告失败", err)
+			return
+		}
+
+		if format == "json" {
+			SuccessResponse(c, "获取班级报告成功", report)
+			return
+		}
+
+		content, err := classroomService.BuildReportCSV(report)
+		if err != nil {
+			InternalServerErrorResponse(c, "生成报告文件失败", err)
+			return
+		}
+
+		filename := fmt.Sprintf("classroom_%s_%s.csv", report.ClassroomID.Hex(), report.GeneratedAt.Format("20060102150405"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", content)
+	}
+}
+
+// JoinClassroomHandler 学生通过加入码加入班级处理器
+func JoinClassroomHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.JoinClassroomRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		classroom, err := GetClassroomService().JoinClassroom(openID, req.JoinCode)
+		if err != nil {
+			respondClassroomError(c, "加入班级失败", err)
+			return
+		}
+
+		SuccessResponse(c, "加入班级成功", classroom)
+	}
+}
+
+// GetStudentClassroomsHandler 获取学生加入的班级及任务处理器
+func GetStudentClassroomsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		classrooms, err := GetClassroomService().ListStudentClassrooms(openID)
+		if err != nil {
+			InternalServerErrorResponse(c, "获取班级列表失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取班级列表成功", classrooms)
+	}
+}
+
+// LeaveClassroomHandler 学生退出班级处理器
+func LeaveClassroomHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		if err := GetClassroomService().LeaveClassroom(openID, c.Param("classroom_id")); err != nil {
+			respondClassroomError(c, "退出班级失败", err)
+			return
+		}
+
+		SuccessResponse(c, "已退出班级", nil)
+	}
+}
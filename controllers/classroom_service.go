@@ -0,0 +1,601 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 班级服务层（教师布置单元、查看本班学生学习情况） =====
+
+var (
+	// ErrInvalidClassroomRequest 班级参数错误
+	ErrInvalidClassroomRequest = errors.New("班级参数错误")
+	// ErrInvalidJoinCode 加入码无效
+	ErrInvalidJoinCode = errors.New("加入码无效，请向老师确认")
+	// ErrAlreadyInClassroom 已在班级中
+	ErrAlreadyInClassroom = errors.New("已经加入该班级")
+	// ErrClassroomFull 班级人数已满
+	ErrClassroomFull = errors.New("班级人数已满")
+)
+
+const (
+	classroomsCollection  = "classrooms"
+	joinCodeLength        = 6
+	maxClassroomStudents  = 200
+	joinCodeGenerateTries = 5
+)
+
+// ClassroomService 班级服务
+type ClassroomService struct{}
+
+// GetClassroomService 获取班级服务实例
+func GetClassroomService() *ClassroomService {
+	return &ClassroomService{}
+}
+
+// generateJoinCode 生成班级加入码
+func (s *ClassroomService) generateJoinCode() (string, error) {
+	return GenerateReadableCode(joinCodeLength)
+}
+
+// CreateClassroom 教师创建班级（加入码冲突时重新生成）
+func (s *ClassroomService) CreateClassroom(teacher *models.User, req models.CreateClassroomRequest) (*models.Classroom, error) {
+	now := utils.GetCurrentUTCTime()
+	classroom := &models.Classroom{
+		Name:          strings.TrimSpace(req.Name),
+		School:        strings.TrimSpace(req.School),
+		TeacherOpenID: teacher.OpenID,
+		TeacherName:   teacher.UserName,
+		Students:      []models.ClassroomStudent{},
+		Assignments:   []models.ClassAssignment{},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if classroom.Name == "" {
+		return nil, fmt.Errorf("%w: 班级名称不能为空", ErrInvalidClassroomRequest)
+	}
+	if classroom.School == "" {
+		classroom.School = teacher.School
+	}
+
+	collection := GetCollection(classroomsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	for attempt := 0; attempt < joinCodeGenerateTries; attempt++ {
+		code, err := s.generateJoinCode()
+		if err != nil {
+			return nil, err
+		}
+		classroom.ID = primitive.NewObjectID()
+		classroom.JoinCode = code
+
+		_, err = collection.InsertOne(ctx, classroom)
+		if err == nil {
+			return classroom, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+	return nil, errors.New("生成班级加入码失败，请重试")
+}
+
+// ListTeacherClassrooms 获取教师创建的班级
+func (s *ClassroomService) ListTeacherClassrooms(teacherOpenID string) ([]models.Classroom, error) {
+	collection := GetCollection(classroomsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"teacher_openid": teacherOpenID},
+		options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	classrooms := []models.Classroom{}
+	if err := cursor.All(ctx, &classrooms); err != nil {
+		return nil, err
+	}
+	return classrooms, nil
+}
+
+// teacherClassroomFilter 教师只能访问自己创建的班级
+func (s *ClassroomService) teacherClassroomFilter(teacherOpenID, classroomID string) (bson.M, error) {
+	classroomObjectID, err := primitive.ObjectIDFromHex(classroomID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的班级ID", ErrInvalidClassroomRequest)
+	}
+	return bson.M{"_id": classroomObjectID, "teacher_openid": teacherOpenID}, nil
+}
+
+// GetTeacherClassroom 获取教师自己的班级
+func (s *ClassroomService) GetTeacherClassroom(teacherOpenID, classroomID string) (*models.Classroom, error) {
+	filter, err := s.teacherClassroomFilter(teacherOpenID, classroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var classroom models.Classroom
+	if err := GetCollection(classroomsCollection).FindOne(ctx, filter).Decode(&classroom); err != nil {
+		return nil, err
+	}
+	return &classroom, nil
+}
+
+// DeleteClassroom 教师解散班级
+func (s *ClassroomService) DeleteClassroom(teacherOpenID, classroomID string) error {
+	filter, err := s.teacherClassroomFilter(teacherOpenID, classroomID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection(classroomsCollection).DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// updateTeacherClassroom 更新教师自己的班级并返回更新后的班级
+func (s *ClassroomService) updateTeacherClassroom(filter bson.M, update bson.M) (*models.Classroom, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var classroom models.Classroom
+	err := GetCollection(classroomsCollection).FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&classroom)
+	if err != nil {
+		return nil, err
+	}
+	return &classroom, nil
+}
+
+// RemoveStudent 教师将学生移出班级
+func (s *ClassroomService) RemoveStudent(teacherOpenID, classroomID, studentOpenID string) (*models.Classroom, error) {
+	filter, err := s.teacherClassroomFilter(teacherOpenID, classroomID)
+	if err != nil {
+		return nil, err
+	}
+	filter["students.student_openid"] = studentOpenID
+
+	return s.updateTeacherClassroom(filter, bson.M{
+		"$pull": bson.M{"students": bson.M{"student_openid": studentOpenID}},
+		"$set":  bson.M{"updated_at": utils.GetCurrentUTCTime()},
+	})
+}
+
+// AddAssignment 教师给班级布置单元
+func (s *ClassroomService) AddAssignment(teacherOpenID, classroomID string, req models.CreateClassAssignmentRequest) (*models.Classroom, error) {
+	filter, err := s.teacherClassroomFilter(teacherOpenID, classroomID)
+	if err != nil {
+		return nil, err
+	}
+	bookID, err := primitive.ObjectIDFromHex(req.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的书籍ID", ErrInvalidClassroomRequest)
+	}
+	unitID, err := primitive.ObjectIDFromHex(req.UnitID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的单元ID", ErrInvalidClassroomRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: 书籍不存在", ErrInvalidClassroomRequest)
+		}
+		return nil, err
+	}
+	var unit models.Unit
+	if err := GetCollection("units").FindOne(ctx, bson.M{"_id": unitID, "book_id": bookID}).Decode(&unit); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: 单元不存在或不属于该书籍", ErrInvalidClassroomRequest)
+		}
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	assignment := models.ClassAssignment{
+		ID:        primitive.NewObjectID(),
+		Title:     strings.TrimSpace(req.Title),
+		BookID:    book.ID,
+		BookName:  book.BookName,
+		UnitID:    unit.ID,
+		UnitName:  unit.UnitName,
+		DueAt:     req.DueAt.UTC(),
+		CreatedAt: now,
+	}
+	if assignment.Title == "" {
+		assignment.Title = unit.UnitName
+	}
+
+	return s.updateTeacherClassroom(filter, bson.M{
+		"$push": bson.M{"assignments": assignment},
+		"$set":  bson.M{"updated_at": now},
+	})
+}
+
+// RemoveAssignment 教师删除班级任务
+func (s *ClassroomService) RemoveAssignment(teacherOpenID, classroomID, assignmentID string) (*models.Classroom, error) {
+	filter, err := s.teacherClassroomFilter(teacherOpenID, classroomID)
+	if err != nil {
+		return nil, err
+	}
+	assignmentObjectID, err := primitive.ObjectIDFromHex(assignmentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的任务ID", ErrInvalidClassroomRequest)
+	}
+	filter["assignments._id"] = assignmentObjectID
+
+	return s.updateTeacherClassroom(filter, bson.M{
+		"$pull": bson.M{"assignments": bson.M{"_id": assignmentObjectID}},
+		"$set":  bson.M{"updated_at": utils.GetCurrentUTCTime()},
+	})
+}
+
+// JoinClassroom 学生通过加入码加入班级
+func (s *ClassroomService) JoinClassroom(studentOpenID, joinCode string) (*models.Classroom, error) {
+	joinCode = strings.ToUpper(strings.TrimSpace(joinCode))
+
+	collection := GetCollection(classroomsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var classroom models.Classroom
+	if err := collection.FindOne(ctx, bson.M{"join_code": joinCode}).Decode(&classroom); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidJoinCode
+		}
+		return nil, err
+	}
+	if classroom.TeacherOpenID == studentOpenID {
+		return nil, fmt.Errorf("%w: 不能加入自己创建的班级", ErrInvalidClassroomRequest)
+	}
+	for _, student := range classroom.Students {
+		if student.StudentOpenID == studentOpenID {
+			return nil, ErrAlreadyInClassroom
+		}
+	}
+	if len(classroom.Students) >= maxClassroomStudents {
+		return nil, ErrClassroomFull
+	}
+
+	student, err := GetUserService().FindUserByOpenID(studentOpenID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 条件更新防止并发加入时重复或超过人数上限
+	now := utils.GetCurrentUTCTime()
+	result, err := collection.UpdateOne(ctx,
+		bson.M{
+			"_id":                     classroom.ID,
+			"students.student_openid": bson.M{"$ne": studentOpenID},
+			fmt.Sprintf("students.%d", maxClassroomStudents-1): bson.M{"$exists": false},
+		},
+		bson.M{
+			"$push": bson.M{"students": models.ClassroomStudent{
+				StudentOpenID: studentOpenID,
+				UserName:      student.UserName,
+				JoinedAt:      now,
+			}},
+			"$set": bson.M{"updated_at": now},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		// 并发情况下重新判断是已加入还是人数已满
+		if _, err := s.getStudentClassroom(studentOpenID, classroom.ID); err == nil {
+			return nil, ErrAlreadyInClassroom
+		}
+		return nil, ErrClassroomFull
+	}
+
+	joined, err := s.getStudentClassroom(studentOpenID, classroom.ID)
+	if err != nil {
+		return nil, err
+	}
+	return joined, nil
+}
+
+// getStudentClassroom 获取学生所在的班级（不返回其他同学信息）
+func (s *ClassroomService) getStudentClassroom(studentOpenID string, classroomID primitive.ObjectID) (*models.Classroom, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var classroom models.Classroom
+	err := GetCollection(classroomsCollection).FindOne(ctx,
+		bson.M{"_id": classroomID, "students.student_openid": studentOpenID},
+		options.FindOne().SetProjection(bson.M{"students": 0, "join_code": 0}),
+	).Decode(&classroom)
+	if err != nil {
+		return nil, err
+	}
+	return &classroom, nil
+}
+
+// ListStudentClassrooms 获取学生加入的班级及任务（不返回其他同学信息）
+func (s *ClassroomService) ListStudentClassrooms(studentOpenID string) ([]models.Classroom, error) {
+	collection := GetCollection(classroomsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"students.student_openid": studentOpenID},
+		options.Find().SetSort(bson.M{"created_at": -1}).SetProjection(bson.M{"students": 0, "join_code": 0}))
+	if err != nil {
+		return nil, err
+	}
+	classrooms := []models.Classroom{}
+	if err := cursor.All(ctx, &classrooms); err != nil {
+		return nil, err
+	}
+	return classrooms, nil
+}
+
+// LeaveClassroom 学生退出班级
+func (s *ClassroomService) LeaveClassroom(studentOpenID, classroomID string) error {
+	classroomObjectID, err := primitive.ObjectIDFromHex(classroomID)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的班级ID", ErrInvalidClassroomRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection(classroomsCollection).UpdateOne(ctx,
+		bson.M{"_id": classroomObjectID, "students.student_openid": studentOpenID},
+		bson.M{
+			"$pull": bson.M{"students": bson.M{"student_openid": studentOpenID}},
+			"$set":  bson.M{"updated_at": utils.GetCurrentUTCTime()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// BuildReport 生成班级学习报告：每个学生在各任务单元的掌握进度和测验成绩
+func (s *ClassroomService) BuildReport(teacherOpenID, classroomID string) (*models.ClassroomReport, error) {
+	classroom, err := s.GetTeacherClassroom(teacherOpenID, classroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ClassroomReport{
+		ClassroomID: classroom.ID,
+		Name:        classroom.Name,
+		School:      classroom.School,
+		Assignments: classroom.Assignments,
+		Students:    []models.ClassroomStudentReport{},
+		GeneratedAt: utils.GetCurrentUTCTime(),
+	}
+	if len(classroom.Students) == 0 {
+		return report, nil
+	}
+
+	studentIDs := make([]string, len(classroom.Students))
+	for i, student := range classroom.Students {
+		studentIDs[i] = student.StudentOpenID
+	}
+	unitIDs := make([]primitive.ObjectID, len(classroom.Assignments))
+	for i, assignment := range classroom.Assignments {
+		unitIDs[i] = assignment.UnitID
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	// 学生当前的书籍权限和姓名
+	cursor, err := GetCollection("users").Find(ctx, bson.M{"openID": bson.M{"$in": studentIDs}},
+		options.Find().SetProjection(bson.M{"openID": 1, "user_name": 1, "unlocked_books": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	userMap := make(map[string]models.User, len(users))
+	for _, user := range users {
+		userMap[user.OpenID] = user
+	}
+
+	// 各任务单元的单词总数
+	unitTotals := make(map[primitive.ObjectID]int, len(unitIDs))
+	for _, unitID := range unitIDs {
+		if _, ok := unitTotals[unitID]; ok {
+			continue
+		}
+		total, err := GetCollection("words").CountDocuments(ctx, bson.M{"unit_id": unitID})
+		if err != nil {
+			return nil, err
+		}
+		unitTotals[unitID] = int(total)
+	}
+
+	// 学生在任务单元的学习进度
+	progressMap := map[string]models.UnitProgress{}
+	quizMap := map[string][]models.Quiz{}
+	if len(unitIDs) > 0 {
+		cursor, err = GetCollection(learningProgressCollection).Find(ctx, bson.M{
+			"user_openid": bson.M{"$in": studentIDs},
+			"unit_id":     bson.M{"$in": unitIDs},
+		}, options.Find().SetProjection(bson.M{"word_states": 0}))
+		if err != nil {
+			return nil, err
+		}
+		var progresses []models.UnitProgress
+		if err := cursor.All(ctx, &progresses); err != nil {
+			return nil, err
+		}
+		for _, progress := range progresses {
+			progressMap[progress.UserOpenID+":"+progress.UnitID.Hex()] = progress
+		}
+
+		// 学生在任务单元已提交的测验（按提交时间升序）
+		cursor, err = GetCollection("quizzes").Find(ctx, bson.M{
+			"user_openid": bson.M{"$in": studentIDs},
+			"unit_id":     bson.M{"$in": unitIDs},
+			"status":      models.QuizStatusSubmitted,
+		}, options.Find().SetSort(bson.M{"submitted_at": 1}).SetProjection(bson.M{"questions": 0}))
+		if err != nil {
+			return nil, err
+		}
+		var quizzes []models.Quiz
+		if err := cursor.All(ctx, &quizzes); err != nil {
+			return nil, err
+		}
+		for _, quiz := range quizzes {
+			key := quiz.UserOpenID + ":" + quiz.UnitID.Hex()
+			quizMap[key] = append(quizMap[key], quiz)
+		}
+	}
+
+	now := utils.GetCurrentUTCTime()
+	for _, student := range classroom.Students {
+		user := userMap[student.StudentOpenID]
+		studentReport := models.ClassroomStudentReport{
+			StudentOpenID: student.StudentOpenID,
+			UserName:      student.UserName,
+			JoinedAt:      student.JoinedAt,
+			Assignments:   make([]models.StudentAssignmentProgress, 0, len(classroom.Assignments)),
+		}
+		if user.UserName != "" {
+			studentReport.UserName = user.UserName
+		}
+
+		// 学生可完整访问的书籍（未过期的自有权限和家庭共享）
+		unlockedBooks := map[primitive.ObjectID]bool{}
+		if len(classroom.Assignments) > 0 && user.OpenID != "" {
+			bookIDs, err := GetBookAccessService().GetFullAccessBookIDs(&user)
+			if err != nil {
+				return nil, err
+			}
+			for _, bookID := range bookIDs {
+				unlockedBooks[bookID] = true
+			}
+		}
+
+		scoreSum := 0.0
+		for _, assignment := range classroom.Assignments {
+			key := student.StudentOpenID + ":" + assignment.UnitID.Hex()
+			progress := models.StudentAssignmentProgress{
+				AssignmentID: assignment.ID,
+				UnitID:       assignment.UnitID,
+				TotalWords:   unitTotals[assignment.UnitID],
+			}
+			progress.BookUnlocked = unlockedBooks[assignment.BookID]
+			if unitProgress, ok := progressMap[key]; ok {
+				progress.MasteredWords = len(unitProgress.MasteredWords)
+			}
+			progress.CompletionPercent = completionPercent(progress.MasteredWords, progress.TotalWords)
+			progress.Completed = progress.TotalWords > 0 && progress.MasteredWords >= progress.TotalWords
+			progress.Overdue = !progress.Completed && now.After(assignment.DueAt)
+
+			for _, quiz := range quizMap[key] {
+				progress.QuizCount++
+				progress.BestQuizScore = math.Max(progress.BestQuizScore, quiz.Score)
+				progress.LatestQuizScore = quiz.Score
+				submittedAt := quiz.SubmittedAt
+				progress.LastQuizAt = &submittedAt
+				scoreSum += quiz.Score
+			}
+
+			if progress.Completed {
+				studentReport.CompletedCount++
+			}
+			studentReport.QuizCount += progress.QuizCount
+			studentReport.Assignments = append(studentReport.Assignments, progress)
+		}
+		if studentReport.QuizCount > 0 {
+			studentReport.AverageQuizScore = math.Round(scoreSum/float64(studentReport.QuizCount)*100) / 100
+		}
+
+		report.Students = append(report.Students, studentReport)
+	}
+
+	return report, nil
+}
+
+// BuildReportCSV 将班级报告导出为CSV（带 UTF-8 BOM，便于 Excel 直接打开）
+func (s *ClassroomService) BuildReportCSV(report *models.ClassroomReport) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(buffer)
+
+	header := []string{"学生", "加入时间", "完成任务数", "测验次数", "测验平均分"}
+	for _, assignment := range report.Assignments {
+		header = append(header,
+			fmt.Sprintf("%s 完成度(%%)", assignment.Title),
+			fmt.Sprintf("%s 最高分", assignment.Title),
+			fmt.Sprintf("%s 状态", assignment.Title),
+		)
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, student := range report.Students {
+		row := []string{
+			student.UserName,
+			utils.FormatTimeForResponse(student.JoinedAt),
+			fmt.Sprintf("%d/%d", student.CompletedCount, len(report.Assignments)),
+			fmt.Sprintf("%d", student.QuizCount),
+			fmt.Sprintf("%.2f", student.AverageQuizScore),
+		}
+		for _, progress := range student.Assignments {
+			status := "进行中"
+			switch {
+			case !progress.BookUnlocked:
+				status = "未解锁"
+			case progress.Completed:
+				status = "已完成"
+			case progress.Overdue:
+				status = "已逾期"
+			}
+			row = append(row,
+				fmt.Sprintf("%.2f", progress.CompletionPercent),
+				fmt.Sprintf("%.2f", progress.BestQuizScore),
+				status,
+			)
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
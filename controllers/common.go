@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"log"
+	"math/big"
 	"miniprogram/config"
 	"miniprogram/utils"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,7 +32,7 @@ func SuccessResponse(c *gin.Context, message string, data interface{}) {
 	c.JSON(http.StatusOK, APIResponse{
 		Code:    200,
 		Message: message,
-		Data:    data,
+		Data:    scrubOpenIDs(data),
 	})
 }
 
@@ -37,10 +41,63 @@ func CreatedResponse(c *gin.Context, message string, data interface{}) {
 	c.JSON(http.StatusCreated, APIResponse{
 		Code:    201,
 		Message: message,
-		Data:    data,
+		Data:    scrubOpenIDs(data),
 	})
 }
 
+// scrubOpenIDs 将响应数据中的openID字段替换为安全用户标识符
+// 字段名为 openID/openid 或以 _openid 结尾的字符串值都会被替换，字段名保持不变
+func scrubOpenIDs(data interface{}) interface{} {
+	if data == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+
+	// 使用 UseNumber 避免大整数精度丢失
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return data
+	}
+
+	return scrubOpenIDValue(tree)
+}
+
+// scrubOpenIDValue 递归处理响应数据
+func scrubOpenIDValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if str, ok := item.(string); ok && isOpenIDField(key) {
+				if str != "" && !utils.IsSafeUserID(str) {
+					v[key] = utils.EncodeOpenIDToSafeID(str)
+				}
+				continue
+			}
+			v[key] = scrubOpenIDValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = scrubOpenIDValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// isOpenIDField 判断字段名是否为openID字段
+func isOpenIDField(key string) bool {
+	lower := strings.ToLower(key)
+	return lower == "openid" || strings.HasSuffix(lower, "_openid")
+}
+
 // 错误响应
 func ErrorResponse(c *gin.Context, httpStatus int, code int, message string, err error) {
 	response := APIResponse{
@@ -141,8 +198,11 @@ func CloseMongoDB() {
 // HealthCheckHandler 健康检查处理器
 func HealthCheckHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		cfg := config.GetConfig()
 		SuccessResponse(c, "服务运行正常", gin.H{
-			"status": "ok",
+			"status":        "ok",
+			"environment":   cfg.Environment,
+			"test_identity": GetTestIdentityService().GetDiagnostics(),
 		})
 	}
 }
@@ -156,6 +216,23 @@ func GenerateRandomString(length int) string {
 	return hex.EncodeToString(bytes)
 }
 
+// readableCodeAlphabet 人工输入的邀请码、激活码使用的字符（去掉易混淆的 I、O、0、1）
+const readableCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateReadableCode 使用安全随机数生成指定长度、便于人工输入的大写编码
+func GenerateReadableCode(length int) (string, error) {
+	code := make([]byte, length)
+	alphabetSize := big.NewInt(int64(len(readableCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code[i] = readableCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
 // CalculateCommissionRate 根据代理等级计算佣金率
 func CalculateCommissionRate(agentLevel int) float64 {
 	switch agentLevel {
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"miniprogram/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondContentError 统一处理内容管理相关错误
+func respondContentError(c *gin.Context, message, notFoundMessage string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidContentRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrContentConflict), errors.Is(err, ErrContentInUse):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case mongo.IsDuplicateKeyError(err):
+		ErrorResponse(c, http.StatusConflict, 409, "名称与已有内容重复", nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, notFoundMessage, err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// CreateBookHandler 管理员创建书籍处理器
+func CreateBookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateBookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		book, err := GetContentService().CreateBook(req)
+		if err != nil {
+			respondContentError(c, "创建书籍失败", "书籍不存在", err)
+			return
+		}
+
+		CreatedResponse(c, "书籍创建成功", book)
+	}
+}
+
+// GetAdminBookHandler 管理员获取书籍详情处理器（含按顺序排列的单元及单词数）
+func GetAdminBookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		book, err := GetContentService().GetBook(c.Param("book_id"))
+		if err != nil {
+			respondContentError(c, "获取书籍详情失败", "书籍不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "获取书籍详情成功", book)
+	}
+}
+
+// UpdateBookHandler 管理员更新书籍处理器
+func UpdateBookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdateBookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		book, err := GetContentService().UpdateBook(c.Param("book_id"), req)
+		if err != nil {
+			respondContentError(c, "更新书籍失败", "书籍不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "书籍更新成功", book)
+	}
+}
+
+// DeleteBookHandler 管理员删除书籍处理器（仍被商品、激活码或用户权限引用时返回409）
+func DeleteBookHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := GetContentService().DeleteBook(c.Param("book_id")); err != nil {
+			respondContentError(c, "删除书籍失败", "书籍不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "书籍删除成功", nil)
+	}
+}
+
+// CreateUnitHandler 管理员在书籍中创建单元处理器
+func CreateUnitHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateUnitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		unit, err := GetContentService().CreateUnit(c.Param("book_id"), req)
+		if err != nil {
+			respondContentError(c, "创建单元失败", "书籍不存在", err)
+			return
+		}
+
+		CreatedResponse(c, "单元创建成功", unit)
+	}
+}
+
+// ReorderUnitsHandler 管理员调整书籍单元顺序处理器
+func ReorderUnitsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ReorderUnitsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		units, err := GetContentService().ReorderUnits(c.Param("book_id"), req.UnitIDs)
+		if err != nil {
+			respondContentError(c, "调整单元顺序失败", "书籍不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "单元顺序已更新", units)
+	}
+}
+
+// UpdateUnitHandler 管理员更新单元处理器
+func UpdateUnitHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdateUnitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		unit, err := GetContentService().UpdateUnit(c.Param("unit_id"), req)
+		if err != nil {
+			respondContentError(c, "更新单元失败", "单元不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "单元更新成功", unit)
+	}
+}
+
+// DeleteUnitHandler 管理员删除单元处理器（连同单元中的单词及用户学习数据）
+func DeleteUnitHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := GetContentService().DeleteUnit(c.Param("unit_id")); err != nil {
+			respondContentError(c, "删除单元失败", "单元不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "单元删除成功", nil)
+	}
+}
+
+// GetUnitWordsAdminHandler 管理员获取单元单词列表处理器
+func GetUnitWordsAdminHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		unit, words, err := GetContentService().ListUnitWords(c.Param("unit_id"))
+		if err != nil {
+			respondContentError(c, "获取单元单词失败", "单元不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "获取单元单词成功", gin.H{
+			"unit":  unit,
+			"words": words,
+		})
+	}
+}
+
+// CreateWordHandler 管理员在单元中添加单词处理器
+func CreateWordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateWordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		word, err := GetContentService().CreateWord(c.Param("unit_id"), req)
+		if err != nil {
+			respondContentError(c, "添加单词失败", "单元不存在", err)
+			return
+		}
+
+		CreatedResponse(c, "单词添加成功", word)
+	}
+}
+
+// UpdateWordHandler 管理员更新单词处理器（传入 unit_id 可移动到同一本书的其他单元）
+func UpdateWordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdateWordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		word, err := GetContentService().UpdateWord(c.Param("word_id"), req)
+		if err != nil {
+			respondContentError(c, "更新单词失败", "单词不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "单词更新成功", word)
+	}
+}
+
+// DeleteWordHandler 管理员删除单词处理器
+func DeleteWordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := GetContentService().DeleteWord(c.Param("word_id")); err != nil {
+			respondContentError(c, "删除单词失败", "单词不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "单词删除成功", nil)
+	}
+}
+
+// ImportContentHandler 管理员批量导入单词处理器（multipart 字段 file 为 CSV/XLSX 文件，dry_run=true 时只预览）
+func ImportContentHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			BadRequestResponse(c, "获取上传文件失败", err)
+			return
+		}
+		defer file.Close()
+
+		if header.Size > MaxContentImportFileSize {
+			BadRequestResponse(c, "文件大小超过10MB限制", nil)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(file, MaxContentImportFileSize+1))
+		if err != nil {
+			BadRequestResponse(c, "读取上传文件失败", err)
+			return
+		}
+		if len(data) > MaxContentImportFileSize {
+			BadRequestResponse(c, "文件大小超过10MB限制", nil)
+			return
+		}
+
+		dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", c.DefaultQuery("dry_run", "false")))
+
+		service := GetContentService()
+		rows, err := service.ParseContentFile(header.Filename, data)
+		if err != nil {
+			respondContentError(c, "解析导入文件失败", "书籍不存在", err)
+			return
+		}
+
+		result, err := service.ImportContent(rows, dryRun)
+		if err != nil {
+			respondContentError(c, "导入单词失败", "书籍不存在", err)
+			return
+		}
+
+		// 存在错误行时整个文件都不写入，返回逐行错误供编辑修改后重新上传
+		if len(result.Errors) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, APIResponse{
+				Code:    422,
+				Message: "导入文件校验未通过，未写入任何数据",
+				Data:    result,
+			})
+			return
+		}
+
+		message := "单词导入成功"
+		if dryRun {
+			message = "导入预览成功"
+		}
+		SuccessResponse(c, message, result)
+	}
+}
+
+// ExportBookContentHandler 管理员导出书籍单词处理器（format=csv|xlsx，列与导入文件一致）
+func ExportBookContentHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", ContentFormatCSV)
+
+		book, content, err := GetContentService().ExportBookContent(c.Param("book_id"), format)
+		if err != nil {
+			respondContentError(c, "导出书籍单词失败", "书籍不存在", err)
+			return
+		}
+
+		contentType := "text/csv; charset=utf-8"
+		if format == ContentFormatXLSX {
+			contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		}
+		filename := fmt.Sprintf("book_%s_words.%s", book.ID.Hex(), format)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, contentType, content)
+	}
+}
@@ -0,0 +1,558 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 词汇批量导入导出 =====
+
+const (
+	// ContentFormatCSV CSV 格式
+	ContentFormatCSV = "csv"
+	// ContentFormatXLSX Excel 格式
+	ContentFormatXLSX = "xlsx"
+
+	// MaxContentImportFileSize 导入文件大小上限
+	MaxContentImportFileSize = 10 * 1024 * 1024
+	// maxContentImportRows 单个文件最多导入的单词行数
+	maxContentImportRows = 20000
+	// contentImportBatchSize 批量写入单词时每批的数量
+	contentImportBatchSize = 1000
+
+	maxImportNameLength    = 100
+	maxImportMeaningLength = 500
+	maxImportURLLength     = 1000
+)
+
+// 导入行的处理方式
+const (
+	ContentImportActionCreate    = "create"
+	ContentImportActionUpdate    = "update"
+	ContentImportActionUnchanged = "unchanged"
+)
+
+// contentColumns 导入导出的列，导出时按此顺序写表头
+var contentColumns = []string{"book", "book_version", "unit", "word", "meaning", "phonetic", "pronunciation_url", "img_url"}
+
+// contentRequiredColumns 导入时必须提供的列
+var contentRequiredColumns = []string{"book", "unit", "word", "meaning"}
+
+// contentColumnAliases 表头别名（不区分大小写），方便编辑直接使用中文表头
+var contentColumnAliases = map[string]string{
+	"book": "book", "book_name": "book", "书籍": "book", "书名": "book",
+	"book_version": "book_version", "version": "book_version", "版本": "book_version",
+	"unit": "unit", "unit_name": "unit", "单元": "unit",
+	"word": "word", "word_name": "word", "单词": "word",
+	"meaning": "meaning", "word_meaning": "meaning", "释义": "meaning",
+	"phonetic": "phonetic", "音标": "phonetic",
+	"pronunciation_url": "pronunciation_url", "audio": "pronunciation_url", "发音": "pronunciation_url",
+	"img_url": "img_url", "image": "img_url", "图片": "img_url",
+}
+
+// contentImportRow 解析并校验通过的导入行
+type contentImportRow struct {
+	row    int
+	book   models.Book
+	unit   string
+	fields map[string]string
+}
+
+// ParseContentFile 按文件扩展名解析 CSV 或 XLSX 文件为行数据
+func (s *ContentService) ParseContentFile(filename string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("%w: CSV 文件需使用 UTF-8 编码", ErrInvalidContentRequest)
+		}
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: CSV 解析失败: %v", ErrInvalidContentRequest, err)
+		}
+		return rows, nil
+	case ".xlsx":
+		rows, err := utils.ReadXLSXRows(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidContentRequest, err)
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("%w: 仅支持 .csv 或 .xlsx 文件", ErrInvalidContentRequest)
+	}
+}
+
+// mapContentHeader 解析表头，返回标准列名到列号的映射
+func mapContentHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		canonical, ok := contentColumnAliases[key]
+		if !ok {
+			continue
+		}
+		if _, exists := columns[canonical]; exists {
+			return nil, fmt.Errorf("%w: 表头中 %s 列重复", ErrInvalidContentRequest, canonical)
+		}
+		columns[canonical] = i
+	}
+
+	missing := []string{}
+	for _, name := range contentRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: 缺少必需的列 %s", ErrInvalidContentRequest, strings.Join(missing, "、"))
+	}
+	return columns, nil
+}
+
+// isBlankRow 判断是否为空行
+func isBlankRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidMediaURL 媒体地址为空、http(s) 链接或存储桶中的相对路径
+func isValidMediaURL(value string) bool {
+	if value == "" || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://") {
+		return true
+	}
+	return !strings.Contains(value, "://") && !strings.ContainsAny(value, " \t\r\n")
+}
+
+// ImportContent 批量导入单词：按“书籍 + 单元 + 单词”新增或更新，单元不存在时自动创建
+// 所有行都校验通过才会写入；dryRun 为 true 时只返回预览
+func (s *ContentService) ImportContent(rows [][]string, dryRun bool) (*models.ContentImportResult, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: 文件为空", ErrInvalidContentRequest)
+	}
+	columns, err := mapContentHeader(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ContentImportResult{
+		DryRun:       dryRun,
+		CreatedUnits: []string{},
+		Errors:       []models.ContentImportRowError{},
+		Rows:         []models.ContentImportRow{},
+	}
+
+	dataRows := 0
+	for _, row := range rows[1:] {
+		if !isBlankRow(row) {
+			dataRows++
+		}
+	}
+	if dataRows == 0 {
+		return nil, fmt.Errorf("%w: 文件中没有数据行", ErrInvalidContentRequest)
+	}
+	if dataRows > maxContentImportRows {
+		return nil, fmt.Errorf("%w: 单个文件最多导入 %d 行", ErrInvalidContentRequest, maxContentImportRows)
+	}
+	result.TotalRows = dataRows
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	// 书籍需要事先创建，按“书名 + 版本”匹配
+	cursor, err := GetCollection("books").Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"book_name": 1, "book_version": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var books []models.Book
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	booksByName := make(map[string][]models.Book)
+	for _, book := range books {
+		booksByName[book.BookName] = append(booksByName[book.BookName], book)
+	}
+
+	addError := func(row int, column, message string) {
+		result.Errors = append(result.Errors, models.ContentImportRowError{Row: row, Column: column, Message: message})
+	}
+
+	parsed := make([]contentImportRow, 0, dataRows)
+	for i, row := range rows[1:] {
+		rowNumber := i + 2
+		if isBlankRow(row) {
+			continue
+		}
+
+		fields := make(map[string]string, len(contentColumns))
+		for name, index := range columns {
+			if index < len(row) {
+				fields[name] = strings.TrimSpace(row[index])
+			}
+		}
+
+		valid := true
+		for _, name := range contentRequiredColumns {
+			if fields[name] == "" {
+				addError(rowNumber, name, "不能为空")
+				valid = false
+			}
+		}
+		for _, name := range []string{"book", "book_version", "unit", "word", "phonetic"} {
+			if utf8.RuneCountInString(fields[name]) > maxImportNameLength {
+				addError(rowNumber, name, fmt.Sprintf("长度不能超过 %d 个字符", maxImportNameLength))
+				valid = false
+			}
+		}
+		if utf8.RuneCountInString(fields["meaning"]) > maxImportMeaningLength {
+			addError(rowNumber, "meaning", fmt.Sprintf("长度不能超过 %d 个字符", maxImportMeaningLength))
+			valid = false
+		}
+		for _, name := range []string{"pronunciation_url", "img_url"} {
+			if !isValidMediaURL(fields[name]) || len(fields[name]) > maxImportURLLength {
+				addError(rowNumber, name, "必须是 http(s) 链接或相对路径")
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		var matched []models.Book
+		for _, book := range booksByName[fields["book"]] {
+			if fields["book_version"] == "" || book.BookVersion == fields["book_version"] {
+				matched = append(matched, book)
+			}
+		}
+		switch {
+		case len(matched) == 0:
+			addError(rowNumber, "book", fmt.Sprintf("书籍「%s %s」不存在，请先创建书籍", fields["book"], fields["book_version"]))
+			continue
+		case len(matched) > 1:
+			addError(rowNumber, "book_version", fmt.Sprintf("书籍「%s」有多个版本，请填写版本", fields["book"]))
+			continue
+		}
+
+		parsed = append(parsed, contentImportRow{row: rowNumber, book: matched[0], unit: fields["unit"], fields: fields})
+	}
+
+	// 加载涉及书籍的现有单元和单词
+	bookIDs := []primitive.ObjectID{}
+	seenBooks := make(map[primitive.ObjectID]bool)
+	for _, row := range parsed {
+		if !seenBooks[row.book.ID] {
+			seenBooks[row.book.ID] = true
+			bookIDs = append(bookIDs, row.book.ID)
+		}
+	}
+
+	units := make(map[primitive.ObjectID]map[string]models.Unit)
+	words := make(map[primitive.ObjectID]map[string]models.Word)
+	if len(bookIDs) > 0 {
+		cursor, err := GetCollection("units").Find(ctx, bson.M{"book_id": bson.M{"$in": bookIDs}})
+		if err != nil {
+			return nil, err
+		}
+		var existingUnits []models.Unit
+		if err := cursor.All(ctx, &existingUnits); err != nil {
+			return nil, err
+		}
+		for _, unit := range existingUnits {
+			if units[unit.BookID] == nil {
+				units[unit.BookID] = make(map[string]models.Unit)
+			}
+			units[unit.BookID][unit.UnitName] = unit
+		}
+
+		cursor, err = GetCollection("words").Find(ctx, bson.M{"book_id": bson.M{"$in": bookIDs}})
+		if err != nil {
+			return nil, err
+		}
+		var existingWords []models.Word
+		if err := cursor.All(ctx, &existingWords); err != nil {
+			return nil, err
+		}
+		for _, word := range existingWords {
+			if words[word.UnitID] == nil {
+				words[word.UnitID] = make(map[string]models.Word)
+			}
+			words[word.UnitID][word.WordName] = word
+		}
+	}
+
+	type unitKey struct {
+		bookID primitive.ObjectID
+		name   string
+	}
+	newUnits := []unitKey{}
+	plannedUnits := make(map[unitKey]bool)
+	firstRow := make(map[string]int)
+	planned := make([]contentImportRow, 0, len(parsed))
+	actions := make([]string, 0, len(parsed))
+
+	for _, row := range parsed {
+		key := row.book.ID.Hex() + "\x00" + row.unit + "\x00" + row.fields["word"]
+		if first, ok := firstRow[key]; ok {
+			addError(row.row, "word", fmt.Sprintf("与第 %d 行重复", first))
+			continue
+		}
+		firstRow[key] = row.row
+
+		action := ContentImportActionCreate
+		if unit, ok := units[row.book.ID][row.unit]; ok {
+			if existing, ok := words[unit.ID][row.fields["word"]]; ok {
+				action = ContentImportActionUnchanged
+				if importedWordChanged(existing, row.fields, columns) {
+					action = ContentImportActionUpdate
+				}
+			}
+		} else {
+			uk := unitKey{row.book.ID, row.unit}
+			if !plannedUnits[uk] {
+				plannedUnits[uk] = true
+				newUnits = append(newUnits, uk)
+				result.CreatedUnits = append(result.CreatedUnits, row.book.BookName+" / "+row.unit)
+			}
+		}
+
+		switch action {
+		case ContentImportActionCreate:
+			result.CreatedWords++
+		case ContentImportActionUpdate:
+			result.UpdatedWords++
+		default:
+			result.Unchanged++
+		}
+		planned = append(planned, row)
+		actions = append(actions, action)
+		result.Rows = append(result.Rows, models.ContentImportRow{
+			Row:      row.row,
+			BookName: row.book.BookName,
+			UnitName: row.unit,
+			WordName: row.fields["word"],
+			Action:   action,
+		})
+	}
+
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	// 写入：先创建新单元（排在书中现有单元之后），再批量写入单词
+	now := utils.GetCurrentUTCTime()
+	unitsCollection := GetCollection("units")
+	nextOrder := make(map[primitive.ObjectID]int)
+	for _, uk := range newUnits {
+		if _, ok := nextOrder[uk.bookID]; !ok {
+			var last models.Unit
+			err := unitsCollection.FindOne(ctx, bson.M{"book_id": uk.bookID},
+				options.FindOne().SetSort(bson.D{{Key: "sort_order", Value: -1}})).Decode(&last)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return nil, err
+			}
+			nextOrder[uk.bookID] = last.SortOrder + 1
+		}
+
+		unit := models.Unit{
+			UnitName:  uk.name,
+			BookID:    uk.bookID,
+			SortOrder: nextOrder[uk.bookID],
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		res, err := unitsCollection.InsertOne(ctx, unit)
+		if err != nil {
+			return nil, fmt.Errorf("创建单元 %s 失败: %w", uk.name, err)
+		}
+		unit.ID = res.InsertedID.(primitive.ObjectID)
+		nextOrder[uk.bookID]++
+		if units[uk.bookID] == nil {
+			units[uk.bookID] = make(map[string]models.Unit)
+		}
+		units[uk.bookID][uk.name] = unit
+	}
+
+	writes := []mongo.WriteModel{}
+	createdPerUnit := make(map[primitive.ObjectID]int)
+	for i, row := range planned {
+		unit := units[row.book.ID][row.unit]
+		switch actions[i] {
+		case ContentImportActionCreate:
+			word := models.Word{
+				WordName:         row.fields["word"],
+				WordMeaning:      row.fields["meaning"],
+				Phonetic:         row.fields["phonetic"],
+				PronunciationURL: row.fields["pronunciation_url"],
+				ImgURL:           row.fields["img_url"],
+				UnitID:           unit.ID,
+				BookID:           row.book.ID,
+				CreatedAt:        now,
+				UpdatedAt:        now,
+			}
+			word.SearchKeys = wordSearchKeys(&word)
+			word.SearchKeysVer = searchKeysVersion
+			writes = append(writes, mongo.NewInsertOneModel().SetDocument(word))
+			createdPerUnit[unit.ID]++
+		case ContentImportActionUpdate:
+			existing := words[unit.ID][row.fields["word"]]
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": existing.ID}).
+				SetUpdate(bson.M{"$set": importedWordUpdates(existing, row.fields, columns, now)}))
+		}
+	}
+
+	wordsCollection := GetCollection("words")
+	for start := 0; start < len(writes); start += contentImportBatchSize {
+		end := start + contentImportBatchSize
+		if end > len(writes) {
+			end = len(writes)
+		}
+		if _, err := wordsCollection.BulkWrite(ctx, writes[start:end], options.BulkWrite().SetOrdered(true)); err != nil {
+			return nil, fmt.Errorf("写入单词失败: %w", err)
+		}
+	}
+
+	for unitID, count := range createdPerUnit {
+		if err := s.addWordsToUnitProgress(ctx, unitID, count); err != nil {
+			return nil, err
+		}
+	}
+	for _, bookID := range bookIDs {
+		if err := s.refreshBookTotalWords(ctx, bookID); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Applied = true
+	return result, nil
+}
+
+// importedWordChanged 判断导入行与现有单词是否有差异（文件中没有的可选列不参与比较）
+func importedWordChanged(existing models.Word, fields map[string]string, columns map[string]int) bool {
+	current := map[string]string{
+		"meaning":           existing.WordMeaning,
+		"phonetic":          existing.Phonetic,
+		"pronunciation_url": existing.PronunciationURL,
+		"img_url":           existing.ImgURL,
+	}
+	for name, value := range current {
+		if _, ok := columns[name]; ok && fields[name] != value {
+			return true
+		}
+	}
+	return false
+}
+
+// importedWordUpdates 生成更新现有单词的字段（文件中没有的可选列保持原值）
+func importedWordUpdates(existing models.Word, fields map[string]string, columns map[string]int, now time.Time) bson.M {
+	updates := bson.M{"word_meaning": fields["meaning"], "updated_at": now}
+	for name, field := range map[string]string{
+		"phonetic":          "phonetic",
+		"pronunciation_url": "pronunciation_url",
+		"img_url":           "img_url",
+	} {
+		if _, ok := columns[name]; ok {
+			updates[field] = fields[name]
+		}
+	}
+
+	// 按更新后的内容重新生成搜索键
+	existing.WordMeaning = fields["meaning"]
+	if _, ok := columns["phonetic"]; ok {
+		existing.Phonetic = fields["phonetic"]
+	}
+	updates["search_keys"] = wordSearchKeys(&existing)
+	updates["search_keys_version"] = searchKeysVersion
+	return updates
+}
+
+// ExportBookContent 导出书籍的全部单词，列与导入文件一致，可直接修改后重新导入
+func (s *ContentService) ExportBookContent(bookIDHex, format string) (*models.Book, []byte, error) {
+	if format != ContentFormatCSV && format != ContentFormatXLSX {
+		return nil, nil, fmt.Errorf("%w: 不支持的导出格式，仅支持 csv 或 xlsx", ErrInvalidContentRequest)
+	}
+	bookID, err := parseContentID(bookIDHex, "书籍")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		return nil, nil, err
+	}
+	units, err := s.listUnits(ctx, bookID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursor, err := GetCollection("words").Find(ctx, bson.M{"book_id": bookID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	var words []models.Word
+	if err := cursor.All(ctx, &words); err != nil {
+		return nil, nil, err
+	}
+	wordsByUnit := make(map[primitive.ObjectID][]models.Word)
+	for _, word := range words {
+		wordsByUnit[word.UnitID] = append(wordsByUnit[word.UnitID], word)
+	}
+
+	rows := [][]string{contentColumns}
+	for _, unit := range units {
+		for _, word := range wordsByUnit[unit.ID] {
+			rows = append(rows, []string{
+				book.BookName,
+				book.BookVersion,
+				unit.UnitName,
+				word.WordName,
+				word.WordMeaning,
+				word.Phonetic,
+				word.PronunciationURL,
+				word.ImgURL,
+			})
+		}
+	}
+
+	if format == ContentFormatXLSX {
+		content, err := utils.WriteXLSX("words", rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &book, content, nil
+	}
+
+	var buf bytes.Buffer
+	// 写入 BOM，便于 Excel 正确识别中文
+	buf.WriteString("\xef\xbb\xbf")
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, nil, err
+	}
+	return &book, buf.Bytes(), nil
+}
@@ -0,0 +1,1026 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 内容管理服务层（管理员维护书籍、单元和单词） =====
+
+var (
+	// ErrInvalidContentRequest 内容参数错误
+	ErrInvalidContentRequest = errors.New("内容参数错误")
+	// ErrContentConflict 名称重复等冲突
+	ErrContentConflict = errors.New("内容冲突")
+	// ErrContentInUse 内容仍被商品、激活码、用户权限或班级任务引用，不能删除
+	ErrContentInUse = errors.New("内容仍在使用中，不能删除")
+)
+
+// validBookLevels 书籍难度取值
+var validBookLevels = map[string]bool{
+	"beginner":     true,
+	"intermediate": true,
+	"advanced":     true,
+}
+
+// unitOrderSort 单元排序：先按 sort_order，相同时按创建顺序
+var unitOrderSort = bson.D{{Key: "sort_order", Value: 1}, {Key: "_id", Value: 1}}
+
+// ContentService 内容管理服务
+type ContentService struct{}
+
+// GetContentService 获取内容管理服务实例
+func GetContentService() *ContentService {
+	return &ContentService{}
+}
+
+// contentContext 内容管理涉及级联更新，使用较长的超时时间
+func contentContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Minute)
+}
+
+// parseContentID 解析路径中的ObjectID
+func parseContentID(idHex, name string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: 无效的%sID", ErrInvalidContentRequest, name)
+	}
+	return id, nil
+}
+
+// parsePublicationDate 解析出版日期（YYYY-MM-DD），为空时返回零值
+func parsePublicationDate(value string) (time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: 出版日期格式应为 YYYY-MM-DD", ErrInvalidContentRequest)
+	}
+	return date, nil
+}
+
+// refreshBookTotalWords 按实际单词数重新计算书籍的 total_words
+func (s *ContentService) refreshBookTotalWords(ctx context.Context, bookID primitive.ObjectID) error {
+	total, err := GetCollection("words").CountDocuments(ctx, bson.M{"book_id": bookID})
+	if err != nil {
+		return err
+	}
+	_, err = GetCollection("books").UpdateOne(ctx, bson.M{"_id": bookID}, bson.M{
+		"$set": bson.M{"total_words": int(total), "updated_at": utils.GetCurrentUTCTime()},
+	})
+	return err
+}
+
+// removeWordLearningData 删除单词时清理用户的复习记录、错题和收藏，并从单元进度中移除
+func (s *ContentService) removeWordLearningData(ctx context.Context, wordIDs []primitive.ObjectID) error {
+	if len(wordIDs) == 0 {
+		return nil
+	}
+	filter := bson.M{"word_id": bson.M{"$in": wordIDs}}
+	if _, err := GetCollection("word_reviews").DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("删除复习记录失败: %w", err)
+	}
+	if _, err := GetCollection("mistake_words").DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("删除错题失败: %w", err)
+	}
+	_, err := GetCollection("users").UpdateMany(ctx,
+		bson.M{"collected_cards.word_id": bson.M{"$in": wordIDs}},
+		bson.M{"$pull": bson.M{"collected_cards": bson.M{"word_id": bson.M{"$in": wordIDs}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("删除收藏失败: %w", err)
+	}
+	return nil
+}
+
+// unitCompletionPercentExpr 按已掌握单词数和单元单词总数计算完成度的聚合表达式（与 completionPercent 一致）
+var unitCompletionPercentExpr = bson.M{"$cond": bson.A{
+	bson.M{"$gt": bson.A{"$total_words", 0}},
+	bson.M{"$round": bson.A{
+		bson.M{"$min": bson.A{100, bson.M{"$divide": bson.A{
+			bson.M{"$multiply": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$mastered_words", bson.A{}}}}, 100}},
+			"$total_words",
+		}}}},
+		2,
+	}},
+	0,
+}}
+
+// removeWordFromUnitProgress 从单元学习进度中移除单词的状态，减少单元单词总数并重新计算完成度
+func (s *ContentService) removeWordFromUnitProgress(ctx context.Context, unitID, wordID primitive.ObjectID) error {
+	key := wordID.Hex()
+	without := func(field string) bson.M {
+		return bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$" + field, bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this", key}},
+		}}
+	}
+	_, err := GetCollection(learningProgressCollection).UpdateMany(ctx,
+		bson.M{"unit_id": unitID, "total_words": bson.M{"$gt": 0}},
+		bson.A{
+			bson.M{"$set": bson.M{
+				"mastered_words": without("mastered_words"),
+				"learning_words": without("learning_words"),
+				"total_words":    bson.M{"$add": bson.A{"$total_words", -1}},
+			}},
+			bson.M{"$unset": "word_states." + key},
+			bson.M{"$set": bson.M{"completion_percent": unitCompletionPercentExpr}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("更新单元学习进度失败: %w", err)
+	}
+	return nil
+}
+
+// addWordsToUnitProgress 单元新增单词后增加已有学习进度的单元单词总数并重新计算完成度
+func (s *ContentService) addWordsToUnitProgress(ctx context.Context, unitID primitive.ObjectID, count int) error {
+	if count <= 0 {
+		return nil
+	}
+	_, err := GetCollection(learningProgressCollection).UpdateMany(ctx,
+		bson.M{"unit_id": unitID},
+		bson.A{
+			bson.M{"$set": bson.M{"total_words": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$total_words", 0}}, count}}}},
+			bson.M{"$set": bson.M{"completion_percent": unitCompletionPercentExpr}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("更新单元学习进度失败: %w", err)
+	}
+	return nil
+}
+
+// ===== 书籍 =====
+
+// CreateBook 创建书籍
+func (s *ContentService) CreateBook(req models.CreateBookRequest) (*models.Book, error) {
+	if !validBookLevels[req.Level] {
+		return nil, fmt.Errorf("%w: 难度只能是 beginner、intermediate 或 advanced", ErrInvalidContentRequest)
+	}
+	publicationDate, err := parsePublicationDate(req.PublicationDate)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	book := &models.Book{
+		BookName:        strings.TrimSpace(req.BookName),
+		BookVersion:     strings.TrimSpace(req.BookVersion),
+		Description:     req.Description,
+		Level:           req.Level,
+		CoverImage:      req.CoverImage,
+		TrialUnits:      req.TrialUnits,
+		Author:          req.Author,
+		Publisher:       req.Publisher,
+		PublicationDate: publicationDate,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if book.BookName == "" || book.BookVersion == "" {
+		return nil, fmt.Errorf("%w: 书籍名称和版本不能为空", ErrInvalidContentRequest)
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	collection := GetCollection("books")
+	count, err := collection.CountDocuments(ctx, bson.M{"book_name": book.BookName, "book_version": book.BookVersion})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: 同版本的书籍已存在", ErrContentConflict)
+	}
+
+	book.SearchKeys = bookSearchKeys(book)
+	book.SearchKeysVer = searchKeysVersion
+	result, err := collection.InsertOne(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	book.ID = result.InsertedID.(primitive.ObjectID)
+	return book, nil
+}
+
+// GetBook 获取书籍详情及按顺序排列的单元（含每个单元的单词数）
+func (s *ContentService) GetBook(bookIDHex string) (*models.Book, error) {
+	bookID, err := parseContentID(bookIDHex, "书籍")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		return nil, err
+	}
+
+	units, err := s.listUnits(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := GetCollection("words").Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"book_id": bookID}},
+		{"$group": bson.M{"_id": "$unit_id", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		UnitID primitive.ObjectID `bson:"_id"`
+		Count  int                `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	countMap := make(map[primitive.ObjectID]int, len(counts))
+	for _, item := range counts {
+		countMap[item.UnitID] = item.Count
+	}
+	for i := range units {
+		units[i].WordCount = countMap[units[i].ID]
+	}
+
+	book.Units = units
+	return &book, nil
+}
+
+// listUnits 按顺序获取书籍的全部单元
+func (s *ContentService) listUnits(ctx context.Context, bookID primitive.ObjectID) ([]models.Unit, error) {
+	cursor, err := GetCollection("units").Find(ctx, bson.M{"book_id": bookID}, options.Find().SetSort(unitOrderSort))
+	if err != nil {
+		return nil, err
+	}
+	units := []models.Unit{}
+	if err := cursor.All(ctx, &units); err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// UpdateBook 更新书籍信息；书名变化时同步用户权限和激活码批次中冗余保存的书名
+func (s *ContentService) UpdateBook(bookIDHex string, req models.UpdateBookRequest) (*models.Book, error) {
+	bookID, err := parseContentID(bookIDHex, "书籍")
+	if err != nil {
+		return nil, err
+	}
+
+	updates := bson.M{"updated_at": utils.GetCurrentUTCTime()}
+	if req.BookName != nil {
+		name := strings.TrimSpace(*req.BookName)
+		if name == "" {
+			return nil, fmt.Errorf("%w: 书籍名称不能为空", ErrInvalidContentRequest)
+		}
+		updates["book_name"] = name
+	}
+	if req.BookVersion != nil {
+		version := strings.TrimSpace(*req.BookVersion)
+		if version == "" {
+			return nil, fmt.Errorf("%w: 书籍版本不能为空", ErrInvalidContentRequest)
+		}
+		updates["book_version"] = version
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Level != nil {
+		if !validBookLevels[*req.Level] {
+			return nil, fmt.Errorf("%w: 难度只能是 beginner、intermediate 或 advanced", ErrInvalidContentRequest)
+		}
+		updates["level"] = *req.Level
+	}
+	if req.Author != nil {
+		updates["author"] = *req.Author
+	}
+	if req.Publisher != nil {
+		updates["publisher"] = *req.Publisher
+	}
+	if req.CoverImage != nil {
+		updates["cover_image"] = *req.CoverImage
+	}
+	if req.PublicationDate != nil {
+		publicationDate, err := parsePublicationDate(*req.PublicationDate)
+		if err != nil {
+			return nil, err
+		}
+		updates["publication_date"] = publicationDate
+	}
+	if req.TrialUnits != nil {
+		if *req.TrialUnits < 0 {
+			return nil, fmt.Errorf("%w: 试读单元数不能为负数", ErrInvalidContentRequest)
+		}
+		updates["trial_units"] = *req.TrialUnits
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	collection := GetCollection("books")
+	var book models.Book
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": bookID}, bson.M{"$set": updates},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&book)
+	if err != nil {
+		return nil, err
+	}
+	if err := refreshBookSearchKeys(ctx, &book); err != nil {
+		return nil, err
+	}
+
+	if name, ok := updates["book_name"]; ok {
+		_, err = GetCollection("users").UpdateMany(ctx,
+			bson.M{"unlocked_books.book_id": bookID},
+			bson.M{"$set": bson.M{"unlocked_books.$[permission].book_name": name}},
+			options.Update().SetArrayFilters(options.ArrayFilters{
+				Filters: []interface{}{bson.M{"permission.book_id": bookID}},
+			}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("同步用户权限中的书名失败: %w", err)
+		}
+		if _, err := GetCollection(activationBatchesCollection).UpdateMany(ctx,
+			bson.M{"book_id": bookID}, bson.M{"$set": bson.M{"book_name": name}}); err != nil {
+			return nil, fmt.Errorf("同步激活码批次中的书名失败: %w", err)
+		}
+	}
+
+	return &book, nil
+}
+
+// DeleteBook 删除书籍及其单元和单词
+// 书籍仍被商品、激活码批次引用或已有用户解锁时拒绝删除，需先处理这些引用
+func (s *ContentService) DeleteBook(bookIDHex string) error {
+	bookID, err := parseContentID(bookIDHex, "书籍")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		return err
+	}
+
+	references := []struct {
+		collection string
+		filter     bson.M
+		label      string
+	}{
+		{"products", bson.M{"book_id": bookID}, "商品"},
+		{activationBatchesCollection, bson.M{"book_id": bookID}, "激活码批次"},
+		{"users", bson.M{"unlocked_books.book_id": bookID}, "已解锁的用户"},
+		{classroomsCollection, bson.M{"assignments.book_id": bookID}, "班级任务"},
+	}
+	for _, ref := range references {
+		count, err := GetCollection(ref.collection).CountDocuments(ctx, ref.filter)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: 该书籍关联了 %d 个%s", ErrContentInUse, count, ref.label)
+		}
+	}
+
+	wordIDs, err := s.findWordIDs(ctx, bson.M{"book_id": bookID})
+	if err != nil {
+		return err
+	}
+	if _, err := GetCollection("words").DeleteMany(ctx, bson.M{"book_id": bookID}); err != nil {
+		return fmt.Errorf("删除单词失败: %w", err)
+	}
+	if _, err := GetCollection("units").DeleteMany(ctx, bson.M{"book_id": bookID}); err != nil {
+		return fmt.Errorf("删除单元失败: %w", err)
+	}
+	if _, err := GetCollection("books").DeleteOne(ctx, bson.M{"_id": bookID}); err != nil {
+		return fmt.Errorf("删除书籍失败: %w", err)
+	}
+	return s.unlinkWordRelations(ctx, wordIDs)
+}
+
+// ===== 单元 =====
+
+// CreateUnit 在书籍中创建单元，未指定顺序时排在最后
+func (s *ContentService) CreateUnit(bookIDHex string, req models.CreateUnitRequest) (*models.Unit, error) {
+	bookID, err := parseContentID(bookIDHex, "书籍")
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.UnitName)
+	if name == "" {
+		return nil, fmt.Errorf("%w: 单元名称不能为空", ErrInvalidContentRequest)
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Err(); err != nil {
+		return nil, err
+	}
+
+	collection := GetCollection("units")
+	count, err := collection.CountDocuments(ctx, bson.M{"book_id": bookID, "unit_name": name})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: 书中已存在同名单元", ErrContentConflict)
+	}
+
+	sortOrder := 0
+	if req.SortOrder != nil {
+		sortOrder = *req.SortOrder
+	} else {
+		var last models.Unit
+		err := collection.FindOne(ctx, bson.M{"book_id": bookID},
+			options.FindOne().SetSort(bson.D{{Key: "sort_order", Value: -1}})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		if err == nil {
+			sortOrder = last.SortOrder + 1
+		} else {
+			sortOrder = 1
+		}
+	}
+
+	now := utils.GetCurrentUTCTime()
+	unit := &models.Unit{
+		UnitName:  name,
+		BookID:    bookID,
+		SortOrder: sortOrder,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	result, err := collection.InsertOne(ctx, unit)
+	if err != nil {
+		return nil, err
+	}
+	unit.ID = result.InsertedID.(primitive.ObjectID)
+	return unit, nil
+}
+
+// UpdateUnit 修改单元名称；同步班级任务中冗余保存的单元名称
+func (s *ContentService) UpdateUnit(unitIDHex string, req models.UpdateUnitRequest) (*models.Unit, error) {
+	unitID, err := parseContentID(unitIDHex, "单元")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	collection := GetCollection("units")
+	var unit models.Unit
+	if err := collection.FindOne(ctx, bson.M{"_id": unitID}).Decode(&unit); err != nil {
+		return nil, err
+	}
+	if req.UnitName == nil {
+		return &unit, nil
+	}
+
+	name := strings.TrimSpace(*req.UnitName)
+	if name == "" {
+		return nil, fmt.Errorf("%w: 单元名称不能为空", ErrInvalidContentRequest)
+	}
+	count, err := collection.CountDocuments(ctx, bson.M{"book_id": unit.BookID, "unit_name": name, "_id": bson.M{"$ne": unitID}})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: 书中已存在同名单元", ErrContentConflict)
+	}
+
+	now := utils.GetCurrentUTCTime()
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": unitID}, bson.M{
+		"$set": bson.M{"unit_name": name, "updated_at": now},
+	}); err != nil {
+		return nil, err
+	}
+	_, err = GetCollection(classroomsCollection).UpdateMany(ctx,
+		bson.M{"assignments.unit_id": unitID},
+		bson.M{"$set": bson.M{"assignments.$[assignment].unit_name": name}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"assignment.unit_id": unitID}},
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("同步班级任务中的单元名称失败: %w", err)
+	}
+
+	unit.UnitName = name
+	unit.UpdatedAt = now
+	return &unit, nil
+}
+
+// ReorderUnits 按给定顺序重新排列书中的全部单元
+func (s *ContentService) ReorderUnits(bookIDHex string, unitIDHexes []string) ([]models.Unit, error) {
+	bookID, err := parseContentID(bookIDHex, "书籍")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	units, err := s.listUnits(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[primitive.ObjectID]bool, len(units))
+	for _, unit := range units {
+		existing[unit.ID] = true
+	}
+
+	if len(unitIDHexes) != len(units) {
+		return nil, fmt.Errorf("%w: 需要提供书中全部 %d 个单元", ErrInvalidContentRequest, len(units))
+	}
+	seen := make(map[primitive.ObjectID]bool, len(unitIDHexes))
+	ordered := make([]primitive.ObjectID, 0, len(unitIDHexes))
+	for _, idHex := range unitIDHexes {
+		id, err := parseContentID(idHex, "单元")
+		if err != nil {
+			return nil, err
+		}
+		if !existing[id] || seen[id] {
+			return nil, fmt.Errorf("%w: 单元 %s 不属于该书籍或重复", ErrInvalidContentRequest, idHex)
+		}
+		seen[id] = true
+		ordered = append(ordered, id)
+	}
+
+	now := utils.GetCurrentUTCTime()
+	writes := make([]mongo.WriteModel, 0, len(ordered))
+	for i, id := range ordered {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$set": bson.M{"sort_order": i + 1, "updated_at": now}}))
+	}
+	if _, err := GetCollection("units").BulkWrite(ctx, writes); err != nil {
+		return nil, err
+	}
+
+	return s.listUnits(ctx, bookID)
+}
+
+// DeleteUnit 删除单元及其单词，并清理用户在这些单词上的学习数据
+// 单元仍被班级任务引用时拒绝删除
+func (s *ContentService) DeleteUnit(unitIDHex string) error {
+	unitID, err := parseContentID(unitIDHex, "单元")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var unit models.Unit
+	if err := GetCollection("units").FindOne(ctx, bson.M{"_id": unitID}).Decode(&unit); err != nil {
+		return err
+	}
+
+	count, err := GetCollection(classroomsCollection).CountDocuments(ctx, bson.M{"assignments.unit_id": unitID})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: 该单元被 %d 个班级布置为任务", ErrContentInUse, count)
+	}
+
+	wordIDs, err := s.findWordIDs(ctx, bson.M{"unit_id": unitID})
+	if err != nil {
+		return err
+	}
+	if err := s.removeWordLearningData(ctx, wordIDs); err != nil {
+		return err
+	}
+	if err := s.unlinkWordRelations(ctx, wordIDs); err != nil {
+		return err
+	}
+	if _, err := GetCollection(learningProgressCollection).DeleteMany(ctx, bson.M{"unit_id": unitID}); err != nil {
+		return fmt.Errorf("删除单元学习进度失败: %w", err)
+	}
+	if _, err := GetCollection("words").DeleteMany(ctx, bson.M{"unit_id": unitID}); err != nil {
+		return fmt.Errorf("删除单词失败: %w", err)
+	}
+	if _, err := GetCollection("units").DeleteOne(ctx, bson.M{"_id": unitID}); err != nil {
+		return fmt.Errorf("删除单元失败: %w", err)
+	}
+
+	return s.refreshBookTotalWords(ctx, unit.BookID)
+}
+
+// findWordIDs 查询匹配条件的单词ID
+func (s *ContentService) findWordIDs(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	cursor, err := GetCollection("words").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var words []models.Word
+	if err := cursor.All(ctx, &words); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(words))
+	for _, word := range words {
+		ids = append(ids, word.ID)
+	}
+	return ids, nil
+}
+
+// ===== 单词详情（义项、例句、关联词） =====
+
+const (
+	maxWordSenses    = 20
+	maxSenseExamples = 10
+	maxWordRelations = 30
+)
+
+// validWordRelationTypes 关联词类型取值
+var validWordRelationTypes = map[string]bool{
+	models.WordRelationSynonym:    true,
+	models.WordRelationAntonym:    true,
+	models.WordRelationDerivative: true,
+	models.WordRelationRelated:    true,
+}
+
+// normalizeWordSenses 校验义项和例句，去除首尾空白
+func normalizeWordSenses(senses []models.WordSense) ([]models.WordSense, error) {
+	if len(senses) > maxWordSenses {
+		return nil, fmt.Errorf("%w: 义项最多 %d 个", ErrInvalidContentRequest, maxWordSenses)
+	}
+	normalized := make([]models.WordSense, 0, len(senses))
+	for i, sense := range senses {
+		sense.PartOfSpeech = strings.TrimSpace(sense.PartOfSpeech)
+		sense.Definition = strings.TrimSpace(sense.Definition)
+		sense.DefinitionEn = strings.TrimSpace(sense.DefinitionEn)
+		if sense.Definition == "" {
+			return nil, fmt.Errorf("%w: 第 %d 个义项缺少释义", ErrInvalidContentRequest, i+1)
+		}
+		if len(sense.Examples) > maxSenseExamples {
+			return nil, fmt.Errorf("%w: 每个义项最多 %d 个例句", ErrInvalidContentRequest, maxSenseExamples)
+		}
+		examples := make([]models.WordExample, 0, len(sense.Examples))
+		for _, example := range sense.Examples {
+			example.Sentence = strings.TrimSpace(example.Sentence)
+			example.Translation = strings.TrimSpace(example.Translation)
+			example.AudioURL = strings.TrimSpace(example.AudioURL)
+			if example.Sentence == "" {
+				return nil, fmt.Errorf("%w: 第 %d 个义项的例句不能为空", ErrInvalidContentRequest, i+1)
+			}
+			examples = append(examples, example)
+		}
+		sense.Examples = examples
+		normalized = append(normalized, sense)
+	}
+	return normalized, nil
+}
+
+// resolveWordRelations 校验关联词并关联到词库中的单词
+// 指定 word_id 时必须存在；只填 word_name 时关联同一本书中的同名单词，找不到则只保存名称
+func (s *ContentService) resolveWordRelations(ctx context.Context, bookID, selfID primitive.ObjectID, requests []models.WordRelationRequest) ([]models.WordRelation, error) {
+	if len(requests) > maxWordRelations {
+		return nil, fmt.Errorf("%w: 关联词最多 %d 个", ErrInvalidContentRequest, maxWordRelations)
+	}
+
+	collection := GetCollection("words")
+	relations := make([]models.WordRelation, 0, len(requests))
+	seen := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		relationType := strings.TrimSpace(req.Type)
+		if !validWordRelationTypes[relationType] {
+			return nil, fmt.Errorf("%w: 关联词类型只能是 synonym、antonym、derivative 或 related", ErrInvalidContentRequest)
+		}
+		relation := models.WordRelation{Type: relationType, WordName: strings.TrimSpace(req.WordName)}
+
+		var target models.Word
+		if strings.TrimSpace(req.WordID) != "" {
+			targetID, err := parseContentID(strings.TrimSpace(req.WordID), "关联单词")
+			if err != nil {
+				return nil, err
+			}
+			if err := collection.FindOne(ctx, bson.M{"_id": targetID}).Decode(&target); err != nil {
+				if err == mongo.ErrNoDocuments {
+					return nil, fmt.Errorf("%w: 关联单词 %s 不存在", ErrInvalidContentRequest, req.WordID)
+				}
+				return nil, err
+			}
+		} else {
+			if relation.WordName == "" {
+				return nil, fmt.Errorf("%w: 关联词需要填写 word_name 或 word_id", ErrInvalidContentRequest)
+			}
+			err := collection.FindOne(ctx, bson.M{"book_id": bookID, "word_name": relation.WordName},
+				options.FindOne().SetSort(bson.D{{Key: "_id", Value: 1}})).Decode(&target)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return nil, err
+			}
+		}
+		if !target.ID.IsZero() {
+			if target.ID == selfID {
+				return nil, fmt.Errorf("%w: 单词不能关联自身", ErrInvalidContentRequest)
+			}
+			targetID := target.ID
+			relation.WordID = &targetID
+			relation.WordName = target.WordName
+		}
+
+		key := relation.Type + "\x00" + relation.WordName
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		relations = append(relations, relation)
+	}
+	return relations, nil
+}
+
+// unlinkWordRelations 单词删除后，其他单词中指向它的关联词只保留名称
+func (s *ContentService) unlinkWordRelations(ctx context.Context, wordIDs []primitive.ObjectID) error {
+	if len(wordIDs) == 0 {
+		return nil
+	}
+	_, err := GetCollection("words").UpdateMany(ctx,
+		bson.M{"relations.word_id": bson.M{"$in": wordIDs}},
+		bson.M{"$unset": bson.M{"relations.$[relation].word_id": ""}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"relation.word_id": bson.M{"$in": wordIDs}}},
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("更新关联词失败: %w", err)
+	}
+	return nil
+}
+
+// ===== 单词 =====
+
+// ListUnitWords 获取单元中的全部单词（按创建顺序）
+func (s *ContentService) ListUnitWords(unitIDHex string) (*models.Unit, []models.Word, error) {
+	unitID, err := parseContentID(unitIDHex, "单元")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var unit models.Unit
+	if err := GetCollection("units").FindOne(ctx, bson.M{"_id": unitID}).Decode(&unit); err != nil {
+		return nil, nil, err
+	}
+
+	cursor, err := GetCollection("words").Find(ctx, bson.M{"unit_id": unitID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	words := []models.Word{}
+	if err := cursor.All(ctx, &words); err != nil {
+		return nil, nil, err
+	}
+	unit.WordCount = len(words)
+	return &unit, words, nil
+}
+
+// CreateWord 在单元中添加单词，并更新书籍的单词总数
+func (s *ContentService) CreateWord(unitIDHex string, req models.CreateWordRequest) (*models.Word, error) {
+	unitID, err := parseContentID(unitIDHex, "单元")
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.WordName)
+	meaning := strings.TrimSpace(req.WordMeaning)
+	if name == "" || meaning == "" {
+		return nil, fmt.Errorf("%w: 单词和释义不能为空", ErrInvalidContentRequest)
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var unit models.Unit
+	if err := GetCollection("units").FindOne(ctx, bson.M{"_id": unitID}).Decode(&unit); err != nil {
+		return nil, err
+	}
+
+	collection := GetCollection("words")
+	count, err := collection.CountDocuments(ctx, bson.M{"unit_id": unitID, "word_name": name})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: 单元中已存在该单词", ErrContentConflict)
+	}
+
+	senses, err := normalizeWordSenses(req.Senses)
+	if err != nil {
+		return nil, err
+	}
+	wordID := primitive.NewObjectID()
+	relations, err := s.resolveWordRelations(ctx, unit.BookID, wordID, req.Relations)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	word := &models.Word{
+		ID:               wordID,
+		WordName:         name,
+		WordMeaning:      meaning,
+		Phonetic:         strings.TrimSpace(req.Phonetic),
+		PhoneticUK:       strings.TrimSpace(req.PhoneticUK),
+		PhoneticUS:       strings.TrimSpace(req.PhoneticUS),
+		PronunciationURL: req.PronunciationURL,
+		ImgURL:           req.ImgURL,
+		Senses:           senses,
+		Relations:        relations,
+		UnitID:           unitID,
+		BookID:           unit.BookID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	word.SearchKeys = wordSearchKeys(word)
+	word.SearchKeysVer = searchKeysVersion
+	if _, err := collection.InsertOne(ctx, word); err != nil {
+		return nil, err
+	}
+
+	if err := s.addWordsToUnitProgress(ctx, unitID, 1); err != nil {
+		return nil, err
+	}
+	if err := s.refreshBookTotalWords(ctx, unit.BookID); err != nil {
+		return nil, err
+	}
+	return word, nil
+}
+
+// UpdateWord 更新单词；可以移动到同一本书的其他单元，收藏中冗余保存的单词名称同步更新
+func (s *ContentService) UpdateWord(wordIDHex string, req models.UpdateWordRequest) (*models.Word, error) {
+	wordID, err := parseContentID(wordIDHex, "单词")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	collection := GetCollection("words")
+	var word models.Word
+	if err := collection.FindOne(ctx, bson.M{"_id": wordID}).Decode(&word); err != nil {
+		return nil, err
+	}
+
+	updates := bson.M{"updated_at": utils.GetCurrentUTCTime()}
+	name := word.WordName
+	if req.WordName != nil {
+		name = strings.TrimSpace(*req.WordName)
+		if name == "" {
+			return nil, fmt.Errorf("%w: 单词不能为空", ErrInvalidContentRequest)
+		}
+		updates["word_name"] = name
+	}
+	if req.WordMeaning != nil {
+		meaning := strings.TrimSpace(*req.WordMeaning)
+		if meaning == "" {
+			return nil, fmt.Errorf("%w: 释义不能为空", ErrInvalidContentRequest)
+		}
+		updates["word_meaning"] = meaning
+	}
+	if req.Phonetic != nil {
+		updates["phonetic"] = strings.TrimSpace(*req.Phonetic)
+	}
+	if req.PhoneticUK != nil {
+		updates["phonetic_uk"] = strings.TrimSpace(*req.PhoneticUK)
+	}
+	if req.PhoneticUS != nil {
+		updates["phonetic_us"] = strings.TrimSpace(*req.PhoneticUS)
+	}
+	if req.Senses != nil {
+		senses, err := normalizeWordSenses(*req.Senses)
+		if err != nil {
+			return nil, err
+		}
+		updates["senses"] = senses
+	}
+	if req.Relations != nil {
+		relations, err := s.resolveWordRelations(ctx, word.BookID, wordID, *req.Relations)
+		if err != nil {
+			return nil, err
+		}
+		updates["relations"] = relations
+	}
+	if req.PronunciationURL != nil {
+		updates["pronunciation_url"] = *req.PronunciationURL
+	}
+	if req.ImgURL != nil {
+		updates["img_url"] = *req.ImgURL
+	}
+
+	sourceUnitID := word.UnitID
+	targetUnitID := word.UnitID
+	if req.UnitID != nil {
+		targetUnitID, err = parseContentID(*req.UnitID, "单元")
+		if err != nil {
+			return nil, err
+		}
+		if targetUnitID != word.UnitID {
+			var target models.Unit
+			if err := GetCollection("units").FindOne(ctx, bson.M{"_id": targetUnitID}).Decode(&target); err != nil {
+				if err == mongo.ErrNoDocuments {
+					return nil, fmt.Errorf("%w: 目标单元不存在", ErrInvalidContentRequest)
+				}
+				return nil, err
+			}
+			if target.BookID != word.BookID {
+				return nil, fmt.Errorf("%w: 只能移动到同一本书的其他单元", ErrInvalidContentRequest)
+			}
+			updates["unit_id"] = targetUnitID
+		}
+	}
+
+	if name != word.WordName || targetUnitID != word.UnitID {
+		count, err := collection.CountDocuments(ctx, bson.M{"unit_id": targetUnitID, "word_name": name, "_id": bson.M{"$ne": wordID}})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("%w: 单元中已存在该单词", ErrContentConflict)
+		}
+	}
+
+	if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": wordID}, bson.M{"$set": updates},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&word); err != nil {
+		return nil, err
+	}
+	if err := refreshWordSearchKeys(ctx, &word); err != nil {
+		return nil, err
+	}
+
+	if targetUnitID != sourceUnitID {
+		if err := s.removeWordFromUnitProgress(ctx, sourceUnitID, wordID); err != nil {
+			return nil, err
+		}
+		if err := s.addWordsToUnitProgress(ctx, targetUnitID, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := updates["word_name"]; ok {
+		_, err = GetCollection("users").UpdateMany(ctx,
+			bson.M{"collected_cards.word_id": wordID},
+			bson.M{"$set": bson.M{"collected_cards.$[card].word_name": name}},
+			options.Update().SetArrayFilters(options.ArrayFilters{
+				Filters: []interface{}{bson.M{"card.word_id": wordID}},
+			}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("同步收藏中的单词名称失败: %w", err)
+		}
+	}
+
+	return &word, nil
+}
+
+// DeleteWord 删除单词，清理用户在该单词上的学习数据并更新书籍的单词总数
+func (s *ContentService) DeleteWord(wordIDHex string) error {
+	wordID, err := parseContentID(wordIDHex, "单词")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	var word models.Word
+	if err := GetCollection("words").FindOne(ctx, bson.M{"_id": wordID}).Decode(&word); err != nil {
+		return err
+	}
+
+	if err := s.removeWordLearningData(ctx, []primitive.ObjectID{wordID}); err != nil {
+		return err
+	}
+	if err := s.unlinkWordRelations(ctx, []primitive.ObjectID{wordID}); err != nil {
+		return err
+	}
+	if err := s.removeWordFromUnitProgress(ctx, word.UnitID, wordID); err != nil {
+		return err
+	}
+
+	if _, err := GetCollection("words").DeleteOne(ctx, bson.M{"_id": wordID}); err != nil {
+		return err
+	}
+	return s.refreshBookTotalWords(ctx, word.BookID)
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// GetLeaderboardHandler 获取排行榜处理器
+// scope=class、school 或 region（按当前用户资料中的学校、班级、城市），period=weekly 或 all_time，metric=words 或 time
+func GetLeaderboardHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit <= 0 || limit > 100 {
+			limit = 50
+		}
+
+		result, err := GetLeaderboardService().GetLeaderboard(openID,
+			c.DefaultQuery("scope", "school"),
+			c.DefaultQuery("period", models.LeaderboardPeriodWeekly),
+			c.DefaultQuery("metric", "words"),
+			limit,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidLeaderboardQuery), errors.Is(err, ErrLeaderboardScopeUnavailable):
+				BadRequestResponse(c, err.Error(), nil)
+			case errors.Is(err, mongo.ErrNoDocuments):
+				NotFoundResponse(c, "用户不存在", err)
+			default:
+				InternalServerErrorResponse(c, "获取排行榜失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "获取排行榜成功", result)
+	}
+}
+
+// UpdateLeaderboardSettingsHandler 设置是否出现在排行榜中处理器
+func UpdateLeaderboardSettingsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.UpdateLeaderboardSettingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		if err := GetLeaderboardService().UpdateSettings(openID, *req.OptOut); err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+				return
+			}
+			InternalServerErrorResponse(c, "更新排行榜设置失败", err)
+			return
+		}
+
+		SuccessResponse(c, "排行榜设置已更新", gin.H{"opt_out": *req.OptOut})
+	}
+}
+
+// RefreshLeaderboardsHandler 立即全量刷新排行榜快照处理器（管理员）
+func RefreshLeaderboardsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := GetLeaderboardService().RefreshLeaderboards(true)
+		if err != nil {
+			InternalServerErrorResponse(c, "刷新排行榜失败", err)
+			return
+		}
+
+		SuccessResponse(c, "排行榜刷新完成", result)
+	}
+}
@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 排行榜服务层（班级、学校、地区的单词掌握数和学习时长排行） =====
+
+var (
+	// ErrInvalidLeaderboardQuery 排行榜查询参数错误
+	ErrInvalidLeaderboardQuery = errors.New("排行榜查询参数错误")
+	// ErrLeaderboardScopeUnavailable 用户资料缺少排行榜范围信息
+	ErrLeaderboardScopeUnavailable = errors.New("请先在个人资料中完善学校、班级或城市信息")
+)
+
+const (
+	leaderboardEntriesCollection = "leaderboard_entries"
+	leaderboardRefreshBatchSize  = 500
+	leaderboardWeeksKept         = 8 // 保留最近几周的周榜快照
+)
+
+// leaderboardMetricFields 排行指标对应的快照字段（第二个字段用于并列时排序）
+var leaderboardMetricFields = map[string][2]string{
+	"words": {"words_mastered", "study_seconds"},
+	"time":  {"study_seconds", "words_mastered"},
+}
+
+var (
+	leaderboardMutex       sync.Mutex
+	leaderboardLastRefresh time.Time // 上次刷新时间，零值表示需要全量刷新（服务重启后）
+)
+
+// LeaderboardService 排行榜服务
+type LeaderboardService struct{}
+
+// GetLeaderboardService 获取排行榜服务实例
+func GetLeaderboardService() *LeaderboardService {
+	return &LeaderboardService{}
+}
+
+// appWeekStart 按应用时区计算本周一零点
+func appWeekStart(now time.Time) (time.Time, error) {
+	today, err := utils.ParseAppDate(utils.FormatAppDate(now))
+	if err != nil {
+		return time.Time{}, err
+	}
+	weekday := (int(today.Weekday()) + 6) % 7 // 周一为0
+	return today.AddDate(0, 0, -weekday), nil
+}
+
+// weeklyPeriodKey 周榜的快照周期标识
+func weeklyPeriodKey(weekStart time.Time) string {
+	return "week:" + weekStart.Format("2006-01-02")
+}
+
+// anonymizeDisplayName 排行榜显示名称只保留姓名首字，隐藏姓名长度
+func anonymizeDisplayName(userName string) string {
+	runes := []rune(userName)
+	if len(runes) == 0 {
+		return "匿名同学"
+	}
+	return string(runes[:1]) + "**"
+}
+
+// RefreshLeaderboards 增量刷新排行榜快照：只重新统计上次刷新后学习数据或资料有变化的用户
+// 服务启动后首次刷新或 full=true 时全量刷新
+func (s *LeaderboardService) RefreshLeaderboards(full bool) (*models.LeaderboardRefreshResult, error) {
+	leaderboardMutex.Lock()
+	defer leaderboardMutex.Unlock()
+
+	// 全量统计耗时较长，不使用默认的数据库超时
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	since := leaderboardLastRefresh
+	result := &models.LeaderboardRefreshResult{FullRefresh: full || since.IsZero(), RefreshedAt: now}
+
+	weekStart, err := appWeekStart(now)
+	if err != nil {
+		return nil, err
+	}
+
+	openIDs, err := s.changedUsers(ctx, since, result.FullRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(openIDs); start += leaderboardRefreshBatchSize {
+		end := start + leaderboardRefreshBatchSize
+		if end > len(openIDs) {
+			end = len(openIDs)
+		}
+		updated, err := s.refreshUsers(ctx, openIDs[start:end], weekStart, now)
+		if err != nil {
+			return nil, err
+		}
+		result.UpdatedUsers += updated
+	}
+
+	// 清理过期的周榜快照
+	oldestKept := weeklyPeriodKey(weekStart.AddDate(0, 0, -7*(leaderboardWeeksKept-1)))
+	_, err = GetCollection(leaderboardEntriesCollection).DeleteMany(ctx, bson.M{
+		"period_key": bson.M{"$regex": "^week:", "$lt": oldestKept},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	leaderboardLastRefresh = now
+	return result, nil
+}
+
+// changedUsers 获取需要重新统计的用户
+func (s *LeaderboardService) changedUsers(ctx context.Context, since time.Time, full bool) ([]string, error) {
+	filter := bson.M{}
+	if !full {
+		filter = bson.M{"updated_at": bson.M{"$gte": since}}
+	}
+
+	seen := map[string]bool{}
+	openIDs := []string{}
+	collect := func(collectionName, field string) error {
+		values, err := GetCollection(collectionName).Distinct(ctx, field, filter)
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			if openID, ok := value.(string); ok && openID != "" && !seen[openID] {
+				seen[openID] = true
+				openIDs = append(openIDs, openID)
+			}
+		}
+		return nil
+	}
+
+	if err := collect(learningProgressCollection, "user_openid"); err != nil {
+		return nil, err
+	}
+	if err := collect(studyDailyStatsCollection, "user_openid"); err != nil {
+		return nil, err
+	}
+	// 资料变化（学校、班级、城市、姓名、是否公开）也需要更新快照，全量刷新时只统计有学习数据的用户
+	if !full {
+		if err := collect("users", "openID"); err != nil {
+			return nil, err
+		}
+	}
+	return openIDs, nil
+}
+
+// refreshUsers 使用聚合统计一批用户的单词掌握数和学习时长，并写入全部时间和本周快照
+func (s *LeaderboardService) refreshUsers(ctx context.Context, openIDs []string, weekStart, now time.Time) (int, error) {
+	cursor, err := GetCollection("users").Find(ctx, bson.M{"openID": bson.M{"$in": openIDs}},
+		options.Find().SetProjection(bson.M{"openID": 1, "user_name": 1, "school": 1, "class": 1, "city": 1, "leaderboard_opt_out": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return 0, err
+	}
+
+	type totals struct {
+		ID    string `bson:"_id"`
+		Total int64  `bson:"total"`
+		Week  int64  `bson:"week"`
+	}
+	aggregate := func(collectionName string, pipeline mongo.Pipeline) (map[string]totals, error) {
+		cursor, err := GetCollection(collectionName).Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		var rows []totals
+		if err := cursor.All(ctx, &rows); err != nil {
+			return nil, err
+		}
+		result := make(map[string]totals, len(rows))
+		for _, row := range rows {
+			result[row.ID] = row
+		}
+		return result, nil
+	}
+	match := bson.D{{Key: "$match", Value: bson.M{"user_openid": bson.M{"$in": openIDs}}}}
+
+	// 已掌握单词数：全部时间按单元进度中的已掌握列表，本周按状态更新时间在本周内的已掌握单词
+	words, err := aggregate(learningProgressCollection, mongo.Pipeline{
+		match,
+		{{Key: "$project", Value: bson.M{
+			"user_openid": 1,
+			"total":       bson.M{"$size": bson.M{"$ifNull": bson.A{"$mastered_words", bson.A{}}}},
+			"week": bson.M{"$size": bson.M{"$filter": bson.M{
+				"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$word_states", bson.M{}}}},
+				"as":    "state",
+				"cond": bson.M{"$and": bson.A{
+					bson.M{"$eq": bson.A{"$$state.v.status", models.WordStatusMastered}},
+					bson.M{"$gte": bson.A{"$$state.v.updated_at", weekStart}},
+				}},
+			}}},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_openid", "total": bson.M{"$sum": "$total"}, "week": bson.M{"$sum": "$week"}}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("统计单词掌握数失败: %w", err)
+	}
+
+	// 学习时长：按应用时区的自然日汇总
+	seconds, err := aggregate(studyDailyStatsCollection, mongo.Pipeline{
+		match,
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$user_openid",
+			"total": bson.M{"$sum": "$duration_seconds"},
+			"week": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$date", weekStart.Format("2006-01-02")}}, "$duration_seconds", 0,
+			}}},
+		}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("统计学习时长失败: %w", err)
+	}
+
+	weekKey := weeklyPeriodKey(weekStart)
+	writes := make([]mongo.WriteModel, 0, len(users)*2)
+	for _, user := range users {
+		profile := bson.M{
+			"display_name": anonymizeDisplayName(user.UserName),
+			"school":       user.School,
+			"class":        user.Class,
+			"city":         user.City,
+			"hidden":       user.LeaderboardOptOut,
+			"updated_at":   now,
+		}
+		userWords, userSeconds := words[user.OpenID], seconds[user.OpenID]
+
+		allTime := bson.M{"words_mastered": userWords.Total, "study_seconds": userSeconds.Total}
+		for key, value := range profile {
+			allTime[key] = value
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"user_openid": user.OpenID, "period_key": models.LeaderboardPeriodAllTime}).
+			SetUpdate(bson.M{"$set": allTime}).
+			SetUpsert(true))
+
+		// 本周没有学习数据的用户不进入周榜
+		if userWords.Week == 0 && userSeconds.Week == 0 {
+			writes = append(writes, mongo.NewDeleteOneModel().
+				SetFilter(bson.M{"user_openid": user.OpenID, "period_key": weekKey}))
+			continue
+		}
+		weekly := bson.M{"words_mastered": userWords.Week, "study_seconds": userSeconds.Week}
+		for key, value := range profile {
+			weekly[key] = value
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"user_openid": user.OpenID, "period_key": weekKey}).
+			SetUpdate(bson.M{"$set": weekly}).
+			SetUpsert(true))
+	}
+
+	if len(writes) > 0 {
+		if _, err := GetCollection(leaderboardEntriesCollection).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return 0, fmt.Errorf("写入排行榜快照失败: %w", err)
+		}
+	}
+	return len(users), nil
+}
+
+// GetLeaderboard 查询当前用户所在班级、学校或地区的排行榜
+func (s *LeaderboardService) GetLeaderboard(openID, scope, period, metric string, limit int) (*models.LeaderboardResult, error) {
+	fields, ok := leaderboardMetricFields[metric]
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持的排行指标 %s", ErrInvalidLeaderboardQuery, metric)
+	}
+
+	result := &models.LeaderboardResult{Scope: scope, Period: period, Metric: metric, Items: []models.LeaderboardItem{}}
+	switch period {
+	case models.LeaderboardPeriodAllTime:
+		result.PeriodKey = models.LeaderboardPeriodAllTime
+	case models.LeaderboardPeriodWeekly:
+		weekStart, err := appWeekStart(utils.GetCurrentUTCTime())
+		if err != nil {
+			return nil, err
+		}
+		result.PeriodKey = weeklyPeriodKey(weekStart)
+	default:
+		return nil, fmt.Errorf("%w: 不支持的统计周期 %s", ErrInvalidLeaderboardQuery, period)
+	}
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"period_key": result.PeriodKey, "hidden": false, fields[0]: bson.M{"$gt": 0}}
+	switch scope {
+	case "class":
+		if user.School == "" || user.Class == "" {
+			return nil, ErrLeaderboardScopeUnavailable
+		}
+		filter["school"] = user.School
+		filter["class"] = user.Class
+		result.ScopeName = user.School + " " + user.Class
+	case "school":
+		if user.School == "" {
+			return nil, ErrLeaderboardScopeUnavailable
+		}
+		filter["school"] = user.School
+		result.ScopeName = user.School
+	case "region":
+		if user.City == "" {
+			return nil, ErrLeaderboardScopeUnavailable
+		}
+		filter["city"] = user.City
+		result.ScopeName = user.City
+	default:
+		return nil, fmt.Errorf("%w: 不支持的排行范围 %s", ErrInvalidLeaderboardQuery, scope)
+	}
+
+	collection := GetCollection(leaderboardEntriesCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: fields[0], Value: -1}, {Key: fields[1], Value: -1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.LeaderboardEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	// 指标相同的用户并列
+	for i, entry := range entries {
+		item := models.LeaderboardItem{
+			Rank:          i + 1,
+			DisplayName:   entry.DisplayName,
+			WordsMastered: entry.WordsMastered,
+			StudySeconds:  entry.StudySeconds,
+			IsMe:          entry.UserOpenID == openID,
+		}
+		if i > 0 && leaderboardValue(entry, metric) == leaderboardValue(entries[i-1], metric) {
+			item.Rank = result.Items[i-1].Rank
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if user.LeaderboardOptOut {
+		result.OptedOut = true
+		return result, nil
+	}
+
+	// 当前用户的名次 = 指标更高的人数 + 1
+	var mine models.LeaderboardEntry
+	err = collection.FindOne(ctx, bson.M{"user_openid": openID, "period_key": result.PeriodKey}).Decode(&mine)
+	if err == mongo.ErrNoDocuments {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if leaderboardValue(mine, metric) > 0 {
+		filter[fields[0]] = bson.M{"$gt": leaderboardValue(mine, metric)}
+		higher, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Me = &models.LeaderboardItem{
+			Rank:          int(higher) + 1,
+			DisplayName:   mine.DisplayName,
+			WordsMastered: mine.WordsMastered,
+			StudySeconds:  mine.StudySeconds,
+			IsMe:          true,
+		}
+	}
+
+	return result, nil
+}
+
+// leaderboardValue 获取快照的排行指标值
+func leaderboardValue(entry models.LeaderboardEntry, metric string) int64 {
+	if metric == "time" {
+		return entry.StudySeconds
+	}
+	return int64(entry.WordsMastered)
+}
+
+// UpdateSettings 设置是否出现在排行榜中（立即隐藏或恢复已有快照）
+func (s *LeaderboardService) UpdateSettings(openID string, optOut bool) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	result, err := GetCollection("users").UpdateOne(ctx,
+		bson.M{"openID": openID},
+		bson.M{"$set": bson.M{"leaderboard_opt_out": optOut, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = GetCollection(leaderboardEntriesCollection).UpdateMany(ctx,
+		bson.M{"user_openid": openID},
+		bson.M{"$set": bson.M{"hidden": optOut}},
+	)
+	return err
+}
+
+// StartLeaderboardWorker 启动后台任务，定期增量刷新排行榜快照
+func StartLeaderboardWorker(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			result, err := GetLeaderboardService().RefreshLeaderboards(false)
+			if err != nil {
+				log.Printf("[排行榜] 刷新排行榜失败: %v", err)
+			} else if result.UpdatedUsers > 0 {
+				log.Printf("[排行榜] 刷新排行榜: 全量=%v, 用户数=%d", result.FullRefresh, result.UpdatedUsers)
+			}
+			<-ticker.C
+		}
+	}()
+}
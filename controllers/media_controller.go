@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"miniprogram/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondMediaError 统一处理媒体资源相关错误
+func respondMediaError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidMediaRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrMediaInUse):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, "媒体资源不存在", err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// UploadMediaHandler 管理员上传音频或图片处理器（multipart 字段 file，可选 media_type=audio|image）
+func UploadMediaHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminOpenID, _ := c.Get("user_openid")
+
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			BadRequestResponse(c, "获取上传文件失败", err)
+			return
+		}
+		defer file.Close()
+
+		if header.Size > MaxMediaUploadSize {
+			BadRequestResponse(c, "文件大小超过10MB限制", nil)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(file, MaxMediaUploadSize+1))
+		if err != nil {
+			BadRequestResponse(c, "读取上传文件失败", err)
+			return
+		}
+		if len(data) > MaxMediaUploadSize {
+			BadRequestResponse(c, "文件大小超过10MB限制", nil)
+			return
+		}
+
+		asset, err := GetMediaService().Upload(adminOpenID.(string), header.Filename, c.PostForm("media_type"), data)
+		if err != nil {
+			respondMediaError(c, "上传媒体文件失败", err)
+			return
+		}
+
+		if asset.Duplicate {
+			SuccessResponse(c, "文件已存在，返回已有资源", asset)
+			return
+		}
+		CreatedResponse(c, "媒体文件上传成功", asset)
+	}
+}
+
+// GetMediaAssetsHandler 管理员获取媒体资源列表处理器
+func GetMediaAssetsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		assets, total, err := GetMediaService().List(c.Query("media_type"), page, limit)
+		if err != nil {
+			respondMediaError(c, "获取媒体资源失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取媒体资源成功", gin.H{
+			"assets": assets,
+			"pagination": models.Pagination{
+				Page:       page,
+				Limit:      limit,
+				Total:      int(total),
+				TotalPages: int((total + int64(limit) - 1) / int64(limit)),
+			},
+		})
+	}
+}
+
+// DeleteMediaAssetHandler 管理员删除媒体资源处理器（仍被引用时返回409，force=true 强制删除）
+func DeleteMediaAssetHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		force, _ := strconv.ParseBool(c.DefaultQuery("force", "false"))
+
+		if err := GetMediaService().Delete(c.Param("asset_id"), force); err != nil {
+			respondMediaError(c, "删除媒体资源失败", err)
+			return
+		}
+
+		SuccessResponse(c, "媒体资源删除成功", nil)
+	}
+}
+
+// AuditMediaHandler 管理员检查失效和孤立媒体处理器（check_remote=true 时同时检查外部地址）
+func AuditMediaHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkRemote, _ := strconv.ParseBool(c.DefaultQuery("check_remote", "false"))
+
+		result, err := GetMediaService().Audit(checkRemote)
+		if err != nil {
+			respondMediaError(c, "检查媒体资源失败", err)
+			return
+		}
+
+		SuccessResponse(c, "媒体资源检查完成", result)
+	}
+}
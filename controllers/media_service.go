@@ -0,0 +1,493 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 媒体资源服务层 =====
+
+const (
+	mediaAssetsCollection = "media_assets"
+
+	// MaxMediaUploadSize 上传文件的最大字节数（音频上限）
+	MaxMediaUploadSize = 10 * 1024 * 1024
+	// maxImageUploadSize 图片的最大字节数
+	maxImageUploadSize = 5 * 1024 * 1024
+	// mediaThumbnailSize 缩略图长边像素
+	mediaThumbnailSize = 320
+	// mediaOrphanGracePeriod 上传后多久未被引用才视为孤立资源
+	mediaOrphanGracePeriod = 24 * time.Hour
+	// mediaCheckConcurrency 检查媒体地址的并发数
+	mediaCheckConcurrency = 8
+)
+
+var (
+	// ErrInvalidMediaRequest 媒体请求参数无效
+	ErrInvalidMediaRequest = errors.New("媒体请求参数无效")
+	// ErrMediaInUse 媒体资源仍被内容引用
+	ErrMediaInUse = errors.New("媒体资源仍被单词、书籍或商品引用")
+)
+
+// MediaService 媒体资源服务
+type MediaService struct{}
+
+// GetMediaService 获取媒体资源服务实例
+func GetMediaService() *MediaService {
+	return &MediaService{}
+}
+
+// mediaContext 媒体操作上下文（包含存储读写，超时较长）
+func mediaContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 2*time.Minute)
+}
+
+// Upload 上传音频或图片：按文件内容识别类型并校验大小，相同内容只保存一份，图片同时生成缩略图
+func (s *MediaService) Upload(uploaderOpenID, filename, expectedType string, data []byte) (*models.MediaAsset, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: 文件为空", ErrInvalidMediaRequest)
+	}
+	format, err := utils.DetectMediaFormat(data, filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMediaRequest, err)
+	}
+	if expectedType != "" && expectedType != format.MediaType {
+		return nil, fmt.Errorf("%w: 文件内容为 %s，与指定的类型 %s 不符", ErrInvalidMediaRequest, format.ContentType, expectedType)
+	}
+	if format.MediaType == utils.MediaTypeImage && len(data) > maxImageUploadSize {
+		return nil, fmt.Errorf("%w: 图片大小超过5MB限制", ErrInvalidMediaRequest)
+	}
+	if len(data) > MaxMediaUploadSize {
+		return nil, fmt.Errorf("%w: 音频大小超过10MB限制", ErrInvalidMediaRequest)
+	}
+
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	ctx, cancel := mediaContext()
+	defer cancel()
+	collection := GetCollection(mediaAssetsCollection)
+
+	// 相同内容已上传过时直接返回，存储中的文件丢失时重新写入
+	var existing models.MediaAsset
+	err = collection.FindOne(ctx, bson.M{"sha256": hash}).Decode(&existing)
+	if err == nil {
+		if exists, err := storage.Exists(ctx, existing.Key); err == nil && !exists {
+			if err := storage.Put(ctx, existing.Key, data, existing.ContentType); err != nil {
+				return nil, fmt.Errorf("重新保存文件失败: %w", err)
+			}
+		}
+		existing.Duplicate = true
+		return &existing, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	asset := models.MediaAsset{
+		MediaType:        format.MediaType,
+		ContentType:      format.ContentType,
+		Size:             int64(len(data)),
+		SHA256:           hash,
+		Key:              fmt.Sprintf("media/%s/%s/%s%s", format.MediaType, hash[:2], hash, format.Extension),
+		OriginalName:     strings.TrimSpace(filename),
+		UploadedByOpenID: uploaderOpenID,
+		CreatedAt:        utils.GetCurrentUTCTime(),
+	}
+	asset.URL = storage.URL(asset.Key)
+	if err := storage.Put(ctx, asset.Key, data, asset.ContentType); err != nil {
+		return nil, fmt.Errorf("保存文件失败: %w", err)
+	}
+
+	if asset.MediaType == utils.MediaTypeImage {
+		asset.Width, asset.Height = utils.ImageDimensions(data)
+		if err := s.attachThumbnail(ctx, storage, &asset, data); err != nil {
+			log.Printf("[媒体资源] 生成缩略图失败: sha256=%s, err=%v", hash, err)
+		}
+	}
+
+	result, err := collection.InsertOne(ctx, asset)
+	if err != nil {
+		// 并发上传相同内容时以先写入的记录为准（对象键相同，文件无需清理）
+		if mongo.IsDuplicateKeyError(err) {
+			if err := collection.FindOne(ctx, bson.M{"sha256": hash}).Decode(&existing); err != nil {
+				return nil, err
+			}
+			existing.Duplicate = true
+			return &existing, nil
+		}
+		return nil, err
+	}
+	asset.ID = result.InsertedID.(primitive.ObjectID)
+	return &asset, nil
+}
+
+// attachThumbnail 生成并保存缩略图；图片本身不超过缩略图尺寸时直接使用原图
+func (s *MediaService) attachThumbnail(ctx context.Context, storage utils.ObjectStorage, asset *models.MediaAsset, data []byte) error {
+	if asset.Width > 0 && asset.Width <= mediaThumbnailSize && asset.Height <= mediaThumbnailSize {
+		asset.ThumbnailURL = asset.URL
+		return nil
+	}
+	thumbnail, contentType, err := utils.MakeThumbnail(data, mediaThumbnailSize)
+	if errors.Is(err, utils.ErrUnsupportedMedia) {
+		// WebP 等无法解码的格式不生成缩略图
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ext := ".jpg"
+	if contentType == "image/png" {
+		ext = ".png"
+	}
+	key := fmt.Sprintf("media/thumbnails/%s/%s%s", asset.SHA256[:2], asset.SHA256, ext)
+	if err := storage.Put(ctx, key, thumbnail, contentType); err != nil {
+		return err
+	}
+	asset.ThumbnailKey = key
+	asset.ThumbnailURL = storage.URL(key)
+	return nil
+}
+
+// List 分页获取媒体资源
+func (s *MediaService) List(mediaType string, page, limit int) ([]models.MediaAsset, int64, error) {
+	filter := bson.M{}
+	if mediaType != "" {
+		if mediaType != utils.MediaTypeAudio && mediaType != utils.MediaTypeImage {
+			return nil, 0, fmt.Errorf("%w: 媒体类型只能为 audio 或 image", ErrInvalidMediaRequest)
+		}
+		filter["media_type"] = mediaType
+	}
+
+	collection := GetCollection(mediaAssetsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	assets := []models.MediaAsset{}
+	if err := cursor.All(ctx, &assets); err != nil {
+		return nil, 0, err
+	}
+	return assets, total, nil
+}
+
+// countReferences 统计引用指定地址的单词、书籍和商品数量
+func (s *MediaService) countReferences(ctx context.Context, urls []string) (int64, error) {
+	in := bson.M{"$in": urls}
+	wordCount, err := GetCollection("words").CountDocuments(ctx, bson.M{"$or": []bson.M{
+		{"pronunciation_url": in},
+		{"img_url": in},
+		{"senses.examples.audio_url": in},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	bookCount, err := GetCollection("books").CountDocuments(ctx, bson.M{"cover_image": in})
+	if err != nil {
+		return 0, err
+	}
+	productCount, err := GetCollection("products").CountDocuments(ctx, bson.M{"images": in})
+	if err != nil {
+		return 0, err
+	}
+	return wordCount + bookCount + productCount, nil
+}
+
+// Delete 删除媒体资源及存储中的文件；仍被引用时需要 force
+func (s *MediaService) Delete(assetIDHex string, force bool) error {
+	assetID, err := primitive.ObjectIDFromHex(assetIDHex)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的资源ID", ErrInvalidMediaRequest)
+	}
+
+	ctx, cancel := mediaContext()
+	defer cancel()
+	collection := GetCollection(mediaAssetsCollection)
+
+	var asset models.MediaAsset
+	if err := collection.FindOne(ctx, bson.M{"_id": assetID}).Decode(&asset); err != nil {
+		return err
+	}
+
+	if !force {
+		urls := []string{asset.URL}
+		if asset.ThumbnailURL != "" && asset.ThumbnailURL != asset.URL {
+			urls = append(urls, asset.ThumbnailURL)
+		}
+		count, err := s.countReferences(ctx, urls)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w（%d 处），确认删除请使用 force=true", ErrMediaInUse, count)
+		}
+	}
+
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{asset.Key, asset.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Delete(ctx, key); err != nil {
+			return fmt.Errorf("删除文件失败: %w", err)
+		}
+	}
+	_, err = collection.DeleteOne(ctx, bson.M{"_id": assetID})
+	return err
+}
+
+// collectReferences 收集单词、书籍和商品中引用的全部媒体地址
+func (s *MediaService) collectReferences(ctx context.Context) ([]models.MediaReference, error) {
+	refs := []models.MediaReference{}
+	add := func(source string, id primitive.ObjectID, name, field, rawURL string) {
+		if rawURL = strings.TrimSpace(rawURL); rawURL != "" {
+			refs = append(refs, models.MediaReference{Source: source, SourceID: id, SourceName: name, Field: field, URL: rawURL})
+		}
+	}
+
+	wordCursor, err := GetCollection("words").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"word_name": 1, "pronunciation_url": 1, "img_url": 1, "senses.examples.audio_url": 1,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	defer wordCursor.Close(ctx)
+	for wordCursor.Next(ctx) {
+		var word models.Word
+		if err := wordCursor.Decode(&word); err != nil {
+			return nil, err
+		}
+		add("word", word.ID, word.WordName, "pronunciation_url", word.PronunciationURL)
+		add("word", word.ID, word.WordName, "img_url", word.ImgURL)
+		for _, sense := range word.Senses {
+			for _, example := range sense.Examples {
+				add("word", word.ID, word.WordName, "senses.examples.audio_url", example.AudioURL)
+			}
+		}
+	}
+	if err := wordCursor.Err(); err != nil {
+		return nil, err
+	}
+
+	bookCursor, err := GetCollection("books").Find(ctx, bson.M{"cover_image": bson.M{"$nin": bson.A{"", nil}}},
+		options.Find().SetProjection(bson.M{"book_name": 1, "cover_image": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var books []models.Book
+	if err := bookCursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	for _, book := range books {
+		add("book", book.ID, book.BookName, "cover_image", book.CoverImage)
+	}
+
+	productCursor, err := GetCollection("products").Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"name": 1, "images": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var products []models.Product
+	if err := productCursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		for _, image := range product.Images {
+			add("product", product.ID, product.Name, "images", image)
+		}
+	}
+	return refs, nil
+}
+
+// Audit 检查失效和孤立的媒体：
+// 存储中的地址检查文件是否存在；外部地址在 checkRemote 为 true 时发送 HEAD 请求检查，否则计为未检查；
+// 上传超过24小时且未被任何内容引用的资源视为孤立资源
+func (s *MediaService) Audit(checkRemote bool) (*models.MediaAuditResult, error) {
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	refs, err := s.collectReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	result := &models.MediaAuditResult{
+		CheckedAt:       now,
+		TotalReferences: len(refs),
+		Broken:          []models.MediaReference{},
+		Orphaned:        []models.MediaAsset{},
+	}
+
+	// 每个地址只检查一次
+	reasons := map[string]string{}
+	pending := []string{}
+	referenced := map[string]bool{}
+	for _, ref := range refs {
+		referenced[ref.URL] = true
+		if _, ok := reasons[ref.URL]; ok {
+			continue
+		}
+		reasons[ref.URL] = ""
+		pending = append(pending, ref.URL)
+	}
+
+	unchecked := map[string]bool{}
+	var mu sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	client := &http.Client{Timeout: 10 * time.Second}
+	baseURL := strings.TrimRight(config.GetConfig().BaseAPIURL, "/")
+	for i := 0; i < mediaCheckConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rawURL := range jobs {
+				reason, checked := s.checkURL(ctx, storage, client, baseURL, rawURL, checkRemote)
+				mu.Lock()
+				reasons[rawURL] = reason
+				if !checked {
+					unchecked[rawURL] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, rawURL := range pending {
+		jobs <- rawURL
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, ref := range refs {
+		if unchecked[ref.URL] {
+			result.UncheckedReferences++
+			continue
+		}
+		result.CheckedReferences++
+		if reason := reasons[ref.URL]; reason != "" {
+			ref.Reason = reason
+			result.Broken = append(result.Broken, ref)
+		}
+	}
+
+	cursor, err := GetCollection(mediaAssetsCollection).Find(ctx,
+		bson.M{"created_at": bson.M{"$lt": now.Add(-mediaOrphanGracePeriod)}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var asset models.MediaAsset
+		if err := cursor.Decode(&asset); err != nil {
+			return nil, err
+		}
+		if !referenced[asset.URL] && (asset.ThumbnailURL == "" || !referenced[asset.ThumbnailURL]) {
+			result.Orphaned = append(result.Orphaned, asset)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkURL 检查单个媒体地址，返回失效原因（为空表示正常）和是否已检查
+func (s *MediaService) checkURL(ctx context.Context, storage utils.ObjectStorage, client *http.Client, baseURL, rawURL string, checkRemote bool) (string, bool) {
+	if key, ok := utils.ObjectKeyFromURL(storage, rawURL); ok {
+		exists, err := storage.Exists(ctx, key)
+		if err != nil {
+			return "查询存储失败: " + err.Error(), true
+		}
+		if !exists {
+			return "存储中不存在该文件", true
+		}
+		return "", true
+	}
+
+	target := rawURL
+	if strings.HasPrefix(rawURL, "/") && !strings.HasPrefix(rawURL, "//") {
+		target = baseURL + rawURL
+	}
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "地址格式无效", true
+	}
+	if !checkRemote {
+		return "", false
+	}
+
+	status, err := s.probeURL(ctx, client, http.MethodHead, target)
+	// 部分服务不支持 HEAD 请求，改为只请求第一个字节
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusForbidden) {
+		status, err = s.probeURL(ctx, client, http.MethodGet, target)
+	}
+	if err != nil {
+		return "请求失败: " + err.Error(), true
+	}
+	if status >= 400 {
+		return fmt.Sprintf("HTTP %d", status), true
+	}
+	return "", true
+}
+
+// probeURL 请求地址并返回状态码
+func (s *MediaService) probeURL(ctx context.Context, client *http.Client, method, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"miniprogram/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// GetMistakesHandler 获取错题本处理器（支持按书籍、单元、状态筛选）
+func GetMistakesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		status := c.DefaultQuery("status", models.MistakeStatusActive)
+		if status != models.MistakeStatusActive && status != models.MistakeStatusRetired && status != "all" {
+			BadRequestResponse(c, "无效的错题状态，仅支持 active、retired 或 all", nil)
+			return
+		}
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+		mistakes, pagination, err := GetMistakeService().ListMistakes(openID, c.Query("book_id"), c.Query("unit_id"), status, page, limit)
+		if err != nil {
+			if err == ErrInvalidMistakeFilter {
+				BadRequestResponse(c, err.Error(), nil)
+				return
+			}
+			InternalServerErrorResponse(c, "获取错题本失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取错题本成功", gin.H{
+			"mistakes":   mistakes,
+			"pagination": pagination,
+		})
+	}
+}
+
+// GetMistakePracticeHandler 获取错题练习题目处理器
+func GetMistakePracticeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+
+		items, err := GetMistakeService().GetPracticeItems(openID, c.Query("book_id"), c.Query("unit_id"), limit)
+		if err != nil {
+			switch err {
+			case ErrInvalidMistakeFilter:
+				BadRequestResponse(c, err.Error(), nil)
+			case ErrBookNotUnlocked:
+				ForbiddenResponse(c, err.Error(), nil)
+			case mongo.ErrNoDocuments:
+				NotFoundResponse(c, "用户不存在", err)
+			default:
+				InternalServerErrorResponse(c, "获取错题练习失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "获取错题练习成功", gin.H{
+			"items":       items,
+			"total_count": len(items),
+		})
+	}
+}
+
+// SubmitMistakePracticeHandler 提交错题练习结果处理器（连续答对多次后移出错题本）
+func SubmitMistakePracticeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.MistakePracticeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		mistake, err := GetMistakeService().RecordPractice(openID, c.Param("word_id"), *req.Correct)
+		if err != nil {
+			switch err {
+			case ErrInvalidWordID:
+				BadRequestResponse(c, err.Error(), nil)
+			case mongo.ErrNoDocuments:
+				NotFoundResponse(c, "错题本中没有该单词", err)
+			default:
+				InternalServerErrorResponse(c, "记录练习结果失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "练习结果已记录", mistake)
+	}
+}
+
+// RemoveMistakeHandler 从错题本中删除单词处理器
+func RemoveMistakeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		err := GetMistakeService().RemoveMistake(openID, c.Param("word_id"))
+		if err != nil {
+			switch err {
+			case ErrInvalidWordID:
+				BadRequestResponse(c, err.Error(), nil)
+			case mongo.ErrNoDocuments:
+				NotFoundResponse(c, "错题本中没有该单词", err)
+			default:
+				InternalServerErrorResponse(c, "删除错题失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "已从错题本中删除", nil)
+	}
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"miniprogram/models"
+	"miniprogram/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 错题本服务层 =====
+
+// ErrInvalidMistakeFilter 错题筛选参数错误
+var ErrInvalidMistakeFilter = errors.New("无效的书籍或单元ID")
+
+const (
+	mistakeWordsCollection = "mistake_words"
+	mistakeRetireStreak    = 3 // 连续答对该次数后移出错题本
+)
+
+// MistakeService 错题本服务
+type MistakeService struct{}
+
+// GetMistakeService 获取错题本服务实例
+func GetMistakeService() *MistakeService {
+	return &MistakeService{}
+}
+
+// RecordResult 记录一次作答结果：答错时加入错题本（已移出的重新加入），
+// 答对时累计连续答对次数，达到阈值后移出错题本；不在错题本中的单词答对时忽略
+func (s *MistakeService) RecordResult(openID string, word models.Word, correct bool, source string) (*models.MistakeWord, error) {
+	collection := GetCollection(mistakeWordsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	filter := bson.M{"user_openid": openID, "word_id": word.ID}
+
+	var mistake models.MistakeWord
+	if !correct {
+		err := collection.FindOneAndUpdate(ctx, filter, bson.M{
+			"$set": bson.M{
+				// 单词所属书籍和单元可能被调整，以当前数据为准
+				"word_name":      word.WordName,
+				"book_id":        word.BookID,
+				"unit_id":        word.UnitID,
+				"status":         models.MistakeStatusActive,
+				"correct_streak": 0,
+				"last_source":    source,
+				"last_wrong_at":  now,
+				"updated_at":     now,
+			},
+			"$inc":         bson.M{"wrong_count": 1},
+			"$unset":       bson.M{"retired_at": ""},
+			"$setOnInsert": bson.M{"created_at": now},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true)).Decode(&mistake)
+		if err != nil {
+			return nil, err
+		}
+		return &mistake, nil
+	}
+
+	filter["status"] = models.MistakeStatusActive
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{
+		"$inc": bson.M{"correct_streak": 1},
+		"$set": bson.M{"last_correct_at": now, "updated_at": now},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&mistake)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if mistake.CorrectStreak >= mistakeRetireStreak {
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": mistake.ID, "status": models.MistakeStatusActive},
+			bson.M{"$set": bson.M{"status": models.MistakeStatusRetired, "retired_at": now}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		mistake.Status = models.MistakeStatusRetired
+		mistake.RetiredAt = &now
+	}
+
+	return &mistake, nil
+}
+
+// buildFilter 构建按书籍、单元筛选的查询条件
+func (s *MistakeService) buildFilter(openID, bookID, unitID string) (bson.M, error) {
+	filter := bson.M{"user_openid": openID}
+	if bookID != "" {
+		bookObjectID, err := primitive.ObjectIDFromHex(bookID)
+		if err != nil {
+			return nil, ErrInvalidMistakeFilter
+		}
+		filter["book_id"] = bookObjectID
+	}
+	if unitID != "" {
+		unitObjectID, err := primitive.ObjectIDFromHex(unitID)
+		if err != nil {
+			return nil, ErrInvalidMistakeFilter
+		}
+		filter["unit_id"] = unitObjectID
+	}
+	return filter, nil
+}
+
+// ListMistakes 分页获取错题本，status 为 active（默认）、retired 或 all
+func (s *MistakeService) ListMistakes(openID, bookID, unitID, status string, page, limit int) ([]models.MistakeWord, *models.Pagination, error) {
+	filter, err := s.buildFilter(openID, bookID, unitID)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch status {
+	case "", models.MistakeStatusActive:
+		filter["status"] = models.MistakeStatusActive
+	case models.MistakeStatusRetired:
+		filter["status"] = models.MistakeStatusRetired
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	collection := GetCollection(mistakeWordsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := options.Find().
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "last_wrong_at", Value: -1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	mistakes := []models.MistakeWord{}
+	if err := cursor.All(ctx, &mistakes); err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &models.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      int(total),
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	}
+	return mistakes, pagination, nil
+}
+
+// GetPracticeItems 获取错题练习题目：连续答对次数少、答错次数多的优先，只包含已解锁书籍中的单词
+func (s *MistakeService) GetPracticeItems(openID, bookID, unitID string, limit int) ([]models.MistakePracticeItem, error) {
+	filter, err := s.buildFilter(openID, bookID, unitID)
+	if err != nil {
+		return nil, err
+	}
+	bookIDs, err := GetReviewService().getUnlockedBookIDs(openID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []models.MistakePracticeItem{}
+	if len(bookIDs) == 0 {
+		return items, nil
+	}
+	filter["book_id"] = bson.M{"$in": bookIDs}
+	filter["status"] = models.MistakeStatusActive
+
+	collection := GetCollection(mistakeWordsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.D{
+			{Key: "correct_streak", Value: 1},
+			{Key: "wrong_count", Value: -1},
+			{Key: "last_wrong_at", Value: -1},
+		})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var mistakes []models.MistakeWord
+	if err := cursor.All(ctx, &mistakes); err != nil {
+		return nil, err
+	}
+	if len(mistakes) == 0 {
+		return items, nil
+	}
+
+	wordIDs := make([]primitive.ObjectID, len(mistakes))
+	for i, mistake := range mistakes {
+		wordIDs[i] = mistake.WordID
+	}
+	words, err := GetQuizService().loadWordsByIDs(wordIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mistake := range mistakes {
+		word, ok := words[mistake.WordID]
+		if !ok {
+			// 单词已被删除
+			continue
+		}
+		items = append(items, models.MistakePracticeItem{Word: word, Mistake: mistake})
+	}
+	return items, nil
+}
+
+// RecordPractice 记录一次错题练习结果（单词需在错题本中）
+func (s *MistakeService) RecordPractice(openID, wordID string, correct bool) (*models.MistakeWord, error) {
+	wordObjectID, err := primitive.ObjectIDFromHex(wordID)
+	if err != nil {
+		return nil, ErrInvalidWordID
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var existing models.MistakeWord
+	err = GetCollection(mistakeWordsCollection).FindOne(ctx, bson.M{"user_openid": openID, "word_id": wordObjectID}).Decode(&existing)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已移出错题本的单词答对不再累计
+	if correct && existing.Status != models.MistakeStatusActive {
+		return &existing, nil
+	}
+
+	var word models.Word
+	if err := GetCollection("words").FindOne(ctx, bson.M{"_id": wordObjectID}).Decode(&word); err != nil {
+		return nil, err
+	}
+
+	return s.RecordResult(openID, word, correct, models.MistakeSourcePractice)
+}
+
+// RemoveMistake 手动将单词从错题本中删除
+func (s *MistakeService) RemoveMistake(openID, wordID string) error {
+	wordObjectID, err := primitive.ObjectIDFromHex(wordID)
+	if err != nil {
+		return ErrInvalidWordID
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection(mistakeWordsCollection).DeleteOne(ctx, bson.M{"user_openid": openID, "word_id": wordObjectID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"miniprogram/middlewares"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ===== 敏感数据服务层 =====
+
+// PIIService 敏感数据（手机号、地址）访问控制与密钥轮换服务
+type PIIService struct{}
+
+// GetPIIService 获取敏感数据服务实例
+func GetPIIService() *PIIService {
+	return &PIIService{}
+}
+
+// HasViewPIIPermission 当前请求用户是否拥有查看完整敏感信息的权限（需为管理员且被授予 view_pii）
+func (s *PIIService) HasViewPIIPermission(c *gin.Context) bool {
+	claims, ok := middlewares.GetUserFromGinContext(c)
+	if !ok {
+		return false
+	}
+
+	user, err := GetUserByOpenID(claims.UserId)
+	if err != nil || !user.IsAdmin {
+		return false
+	}
+
+	for _, permission := range user.Permissions {
+		if permission == models.PermissionViewPII {
+			return true
+		}
+	}
+	return false
+}
+
+// CanViewFullPII 当前请求用户能否查看指定用户的完整敏感信息（本人或拥有 view_pii 权限）
+func (s *PIIService) CanViewFullPII(c *gin.Context, ownerOpenID string) bool {
+	if claims, ok := middlewares.GetUserFromGinContext(c); ok && claims.UserId == ownerOpenID {
+		return true
+	}
+	return s.HasViewPIIPermission(c)
+}
+
+// MaskUser 对用户的手机号和地址脱敏
+func (s *PIIService) MaskUser(user *models.User) {
+	if user == nil {
+		return
+	}
+	user.Phone = models.EncryptedString(utils.MaskPhone(user.Phone.String()))
+	s.MaskAddresses(user.Addresses)
+}
+
+// MaskAddresses 对地址列表脱敏
+func (s *PIIService) MaskAddresses(addresses []models.Address) {
+	for i := range addresses {
+		s.MaskAddress(&addresses[i])
+	}
+}
+
+// MaskAddress 对地址中的收货人、电话、详细地址脱敏
+func (s *PIIService) MaskAddress(address *models.Address) {
+	if address == nil {
+		return
+	}
+	address.RecipientName = models.EncryptedString(utils.MaskName(address.RecipientName.String()))
+	address.Phone = models.EncryptedString(utils.MaskPhone(address.Phone.String()))
+	address.Street = models.EncryptedString(utils.MaskStreet(address.Street.String()))
+}
+
+// ValidatePermissions 校验权限名称是否为系统支持的权限
+func (s *PIIService) ValidatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if permission != models.PermissionViewPII && permission != models.PermissionTeacher {
+			return fmt.Errorf("未知的权限: %s", permission)
+		}
+	}
+	return nil
+}
+
+// UpdateUserPermissions 更新用户的细粒度权限
+func (s *PIIService) UpdateUserPermissions(openID string, permissions []string) (*models.User, error) {
+	userService := GetUserService()
+	if _, err := userService.FindUserByOpenID(openID); err != nil {
+		return nil, err
+	}
+
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return userService.UpdateUser(openID, map[string]interface{}{
+		"permissions": permissions,
+	})
+}
+
+// ReencryptAll 使用当前版本密钥重新加密所有用户的敏感字段
+// 同时把历史明文数据加密并补齐手机号盲索引，可在密钥轮换或首次上线时执行
+func (s *PIIService) ReencryptAll() (*models.PIIReencryptResult, error) {
+	activeVersion, err := utils.ActivePIIKeyVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	collection := GetCollection("users")
+
+	// 全量扫描耗时较长，不使用默认的数据库超时
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &models.PIIReencryptResult{ActiveKeyVersion: activeVersion}
+	for cursor.Next(ctx) {
+		result.ScannedUsers++
+
+		if !s.needsReencrypt(cursor.Current, activeVersion) {
+			continue
+		}
+
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			log.Printf("[敏感数据] 解密用户数据失败: id=%v, err=%v", cursor.Current.Lookup("_id"), err)
+			result.FailedUsers++
+			continue
+		}
+
+		update := bson.M{"$set": bson.M{
+			"phone":      user.Phone,
+			"phone_bidx": utils.PhoneBlindIndex(user.Phone.String()),
+			"addresses":  user.Addresses,
+		}}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			log.Printf("[敏感数据] 重新加密用户数据失败: id=%s, err=%v", user.ID.Hex(), err)
+			result.FailedUsers++
+			continue
+		}
+		result.UpdatedUsers++
+	}
+
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	log.Printf("[敏感数据] 重新加密完成: 版本=%s, 扫描=%d, 更新=%d, 失败=%d",
+		activeVersion, result.ScannedUsers, result.UpdatedUsers, result.FailedUsers)
+	return result, nil
+}
+
+// needsReencrypt 检查原始文档中的敏感字段是否都已使用当前版本密钥加密
+func (s *PIIService) needsReencrypt(raw bson.Raw, activeVersion string) bool {
+	isStale := func(value bson.RawValue) bool {
+		str, ok := value.StringValueOK()
+		return ok && str != "" && utils.PIIKeyVersion(str) != activeVersion
+	}
+
+	phone := raw.Lookup("phone")
+	if isStale(phone) {
+		return true
+	}
+	if str, ok := phone.StringValueOK(); ok && str != "" {
+		if _, hasIndex := raw.Lookup("phone_bidx").StringValueOK(); !hasIndex {
+			return true
+		}
+	}
+
+	addresses, ok := raw.Lookup("addresses").ArrayOK()
+	if !ok {
+		return false
+	}
+	values, err := addresses.Values()
+	if err != nil {
+		return false
+	}
+	for _, value := range values {
+		address, ok := value.DocumentOK()
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"recipient_name", "phone", "street"} {
+			if isStale(address.Lookup(field)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"miniprogram/middlewares"
 	"miniprogram/models"
@@ -10,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // GetProgressHandler 获取用户学习进度处理器
@@ -39,50 +41,122 @@ func GetProgressHandler() gin.HandlerFunc {
 	}
 }
 
-// UpdateProgressHandler 更新用户学习进度处理器
+// UpdateProgressHandler 更新用户学习进度处理器（旧版接口）
+// 不再整体覆盖进度：当前单元的已学单词按一条增量变更走 SyncProgress 的幂等合并，新客户端请使用 SyncProgressHandler
 func UpdateProgressHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取 user_id 参数，注意：这里的 user_id 实际上是微信的 openID，不是 MongoDB 的 _id
-		openID := c.Param("user_id")
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
 		var req models.UpdateProgressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
 
+		result, err := GetProgressService().ApplyLegacyProgress(openID, req)
+		if err != nil {
+			respondProgressSyncError(c, err)
+			return
+		}
+
+		c.Header("Deprecation", "true")
+		SuccessResponse(c, "学习进度更新成功", gin.H{
+			"user_id":       utils.EncodeOpenIDToSafeID(openID), // 使用安全的用户标识符
+			"current_unit":  req.CurrentUnit,
+			"learned_words": req.LearnedWords,
+			"total_words":   len(req.LearnedWords),
+			"units":         result.Units,
+		})
+	}
+}
+
+// respondProgressSyncError 统一处理学习进度同步错误
+func respondProgressSyncError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidProgressChange):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrBookNotUnlocked):
+		ForbiddenResponse(c, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, "用户不存在", err)
+	default:
+		InternalServerErrorResponse(c, "同步学习进度失败", err)
+	}
+}
+
+// SyncProgressHandler 增量同步学习进度处理器
+// 按单元合并多个设备提交的变更（change_id 幂等、单词状态按设备时间戳合并），并返回 since 之后更新的单元进度
+func SyncProgressHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.SyncProgressRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			BadRequestResponse(c, "请求参数错误", err)
 			return
 		}
 
-		// 验证用户是否存在
-		_, err := GetUserByOpenID(openID)
+		result, err := GetProgressService().SyncProgress(openID, req)
 		if err != nil {
-			NotFoundResponse(c, "用户不存在", err)
+			respondProgressSyncError(c, err)
 			return
 		}
 
-		// 只更新progress字段
-		collection := GetCollection("users")
-		ctx, cancel := CreateDBContext()
-		defer cancel()
+		SuccessResponse(c, "学习进度同步成功", result)
+	}
+}
 
-		update := bson.M{
-			"$set": bson.M{
-				"progress.current_unit":  req.CurrentUnit,
-				"progress.learned_words": req.LearnedWords,
-				"updated_at":             utils.GetCurrentUTCTime(),
-			},
+// GetBookProgressListHandler 获取各书籍学习进度汇总处理器
+func GetBookProgressListHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
 		}
 
-		filter := bson.M{"openID": openID}
-		_, err = collection.UpdateOne(ctx, filter, update)
+		summaries, err := GetProgressService().GetBookProgressSummaries(openID)
 		if err != nil {
-			InternalServerErrorResponse(c, "更新学习进度失败", err)
+			InternalServerErrorResponse(c, "获取书籍学习进度失败", err)
 			return
 		}
 
-		SuccessResponse(c, "学习进度更新成功", gin.H{
-			"user_id":       utils.EncodeOpenIDToSafeID(openID), // 使用安全的用户标识符
-			"current_unit":  req.CurrentUnit,
-			"learned_words": req.LearnedWords,
-			"total_words":   len(req.LearnedWords),
+		SuccessResponse(c, "获取书籍学习进度成功", gin.H{
+			"books":       summaries,
+			"total_count": len(summaries),
+		})
+	}
+}
+
+// GetBookUnitProgressHandler 获取某本书各单元学习进度处理器
+func GetBookUnitProgressHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		bookID, err := primitive.ObjectIDFromHex(c.Param("book_id"))
+		if err != nil {
+			BadRequestResponse(c, "无效的书籍ID格式", err)
+			return
+		}
+
+		units, err := GetProgressService().GetUnitProgress(openID, bookID)
+		if err != nil {
+			InternalServerErrorResponse(c, "获取单元学习进度失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取单元学习进度成功", gin.H{
+			"book_id":     bookID.Hex(),
+			"units":       units,
+			"total_count": len(units),
 		})
 	}
 }
@@ -136,15 +210,18 @@ func GetBookWordsHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 检查用户是否有访问该书籍的权限
-		hasPermission, accessType, err := CheckUserBookPermission(userID.(string), bookObjectID)
-		if err != nil {
-			InternalServerErrorResponse(c, "检查用户权限失败", err)
-			return
+		var unitObjectID primitive.ObjectID
+		if unitID != "" {
+			unitObjectID, err = primitive.ObjectIDFromHex(unitID)
+			if err != nil {
+				BadRequestResponse(c, "无效的单元ID格式", err)
+				return
+			}
 		}
 
-		if !hasPermission {
-			ForbiddenResponse(c, "您没有访问该书籍的权限，请先购买相关单词卡", nil)
+		// 检查用户是否有访问该书籍（及指定单元）的权限
+		access, ok := requireBookAccess(c, userID.(string), bookObjectID, unitObjectID, "您没有访问该书籍的权限，请先购买相关单词卡")
+		if !ok {
 			return
 		}
 
@@ -157,11 +234,25 @@ func GetBookWordsHandler() gin.HandlerFunc {
 			}
 		}
 
+		// 试读用户只返回试读单元中的单词
+		if !access.FullAccess {
+			allowed := make([]models.Word, 0, len(words))
+			for _, word := range words {
+				if access.AllowsUnit(word.UnitID) {
+					allowed = append(allowed, word)
+				}
+			}
+			words = allowed
+			if unitInfo != nil {
+				unitInfo["total_words"] = len(words)
+			}
+		}
+
 		SuccessResponse(c, "获取单词列表成功", gin.H{
 			"words":       words,
 			"total_count": len(words),
 			"unit_info":   unitInfo,
-			"access_type": accessType,
+			"access_type": access.AccessType,
 		})
 	}
 }
@@ -182,10 +273,13 @@ func GetBooksList(page, limit int) ([]models.Book, int64, error) {
 		// 关联units集合
 		{
 			"$lookup": bson.M{
-				"from":         "units",
-				"localField":   "_id",
-				"foreignField": "book_id",
-				"as":           "units",
+				"from": "units",
+				"let":  bson.M{"book_id": "$_id"},
+				"pipeline": []bson.M{
+					{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$book_id", "$$book_id"}}}},
+					{"$sort": unitOrderSort},
+				},
+				"as": "units",
 			},
 		},
 		// 排序
@@ -0,0 +1,519 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 学习进度服务层（按书籍、单元存储，多设备增量同步） =====
+
+// ErrInvalidProgressChange 进度变更参数错误
+var ErrInvalidProgressChange = errors.New("进度变更参数错误")
+
+const (
+	maxProgressRetries         = 3               // 乐观锁冲突时的重试次数
+	maxDeviceClockSkew         = 5 * time.Minute // 允许的设备时钟超前量，超出部分按服务器时间处理
+	learningProgressCollection = "learning_progress"
+	appliedChangesCollection   = "progress_applied_changes"
+	// legacyProgressDeviceID 旧版整体进度接口提交的变更使用的设备ID
+	legacyProgressDeviceID = "legacy-progress-api"
+)
+
+// ProgressService 学习进度服务
+type ProgressService struct{}
+
+// GetProgressService 获取学习进度服务实例
+func GetProgressService() *ProgressService {
+	return &ProgressService{}
+}
+
+// unitChanges 同一单元的变更
+type unitChanges struct {
+	bookID  primitive.ObjectID
+	unitID  primitive.ObjectID
+	changes []models.ProgressChange
+}
+
+// SyncProgress 增量同步学习进度
+// 每条变更通过 change_id 保证幂等；单词状态按设备时间戳“后写入者胜出”合并，学习时长按增量累加，
+// 因此多个设备各自提交变更时不会互相覆盖
+func (s *ProgressService) SyncProgress(openID string, req models.SyncProgressRequest) (*models.SyncProgressResult, error) {
+	groups, err := s.groupChanges(req.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	result := &models.SyncProgressResult{Units: []models.UnitProgress{}, ServerTime: now}
+	units := map[primitive.ObjectID]models.UnitProgress{}
+
+	checkedBooks := map[primitive.ObjectID]bool{}
+	for _, group := range groups {
+		if !checkedBooks[group.bookID] {
+			hasPermission, _, err := CheckUserBookPermission(openID, group.bookID)
+			if err != nil {
+				return nil, err
+			}
+			if !hasPermission {
+				return nil, ErrBookNotUnlocked
+			}
+			checkedBooks[group.bookID] = true
+		}
+
+		progress, applied, duplicates, err := s.applyUnitChanges(openID, req.DeviceID, group, now)
+		if err != nil {
+			return nil, err
+		}
+		result.Applied += applied
+		result.Duplicates += duplicates
+		if progress != nil {
+			units[progress.ID] = *progress
+		}
+	}
+
+	// 拉取其他设备在 since 之后同步的单元进度
+	if !req.Since.IsZero() {
+		collection := GetCollection(learningProgressCollection)
+		ctx, cancel := CreateDBContext()
+		defer cancel()
+
+		cursor, err := collection.Find(ctx, bson.M{
+			"user_openid": openID,
+			"updated_at":  bson.M{"$gt": req.Since},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var updated []models.UnitProgress
+		if err := cursor.All(ctx, &updated); err != nil {
+			return nil, err
+		}
+		for _, progress := range updated {
+			units[progress.ID] = progress
+		}
+	}
+
+	for _, progress := range units {
+		result.Units = append(result.Units, progress)
+	}
+	sort.Slice(result.Units, func(i, j int) bool {
+		return result.Units[i].UpdatedAt.Before(result.Units[j].UpdatedAt)
+	})
+
+	return result, nil
+}
+
+// ApplyLegacyProgress 旧版整体进度接口：将当前单元的已学单词转换为一条增量变更，按 SyncProgress 合并，
+// 不再覆盖用户文档中的单词列表；不属于当前单元的单词会被忽略
+func (s *ProgressService) ApplyLegacyProgress(openID string, req models.UpdateProgressRequest) (*models.SyncProgressResult, error) {
+	unitID, err := primitive.ObjectIDFromHex(req.CurrentUnit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的单元ID %s", ErrInvalidProgressChange, req.CurrentUnit)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var unit models.Unit
+	err = GetCollection("units").FindOne(ctx, bson.M{"_id": unitID}, options.FindOne().SetProjection(bson.M{"book_id": 1})).Decode(&unit)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%w: 单元不存在 %s", ErrInvalidProgressChange, req.CurrentUnit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 当前单元只是位置记录，不影响单词进度
+	if _, err := GetCollection("users").UpdateOne(ctx, bson.M{"openID": openID}, bson.M{"$set": bson.M{
+		"progress.current_unit": req.CurrentUnit,
+		"updated_at":            utils.GetCurrentUTCTime(),
+	}}); err != nil {
+		return nil, err
+	}
+
+	return s.SyncProgress(openID, models.SyncProgressRequest{
+		DeviceID: legacyProgressDeviceID,
+		Changes: []models.ProgressChange{{
+			ChangeID:        "legacy-" + primitive.NewObjectID().Hex(),
+			BookID:          unit.BookID.Hex(),
+			UnitID:          unitID.Hex(),
+			MasteredWords:   req.LearnedWords,
+			DeviceTimestamp: utils.GetCurrentUTCTime(),
+		}},
+	})
+}
+
+// groupChanges 校验变更参数并按单元分组（保持提交顺序）
+func (s *ProgressService) groupChanges(changes []models.ProgressChange) ([]*unitChanges, error) {
+	groups := []*unitChanges{}
+	index := map[primitive.ObjectID]*unitChanges{}
+
+	for _, change := range changes {
+		if change.DeviceTimestamp.IsZero() {
+			return nil, fmt.Errorf("%w: 变更 %s 缺少设备时间", ErrInvalidProgressChange, change.ChangeID)
+		}
+		bookID, err := primitive.ObjectIDFromHex(change.BookID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的书籍ID %s", ErrInvalidProgressChange, change.BookID)
+		}
+		unitID, err := primitive.ObjectIDFromHex(change.UnitID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的单元ID %s", ErrInvalidProgressChange, change.UnitID)
+		}
+
+		group, ok := index[unitID]
+		if !ok {
+			group = &unitChanges{bookID: bookID, unitID: unitID}
+			index[unitID] = group
+			groups = append(groups, group)
+		} else if group.bookID != bookID {
+			return nil, fmt.Errorf("%w: 单元 %s 对应多个书籍", ErrInvalidProgressChange, change.UnitID)
+		}
+		group.changes = append(group.changes, change)
+	}
+
+	return groups, nil
+}
+
+// applyUnitChanges 将同一单元的变更合并到进度文档，返回进度、应用的变更数和重复变更数
+func (s *ProgressService) applyUnitChanges(openID, deviceID string, group *unitChanges, now time.Time) (*models.UnitProgress, int, int, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	// 校验单元属于该书籍，并统计单元单词数
+	var unit models.Unit
+	err := GetCollection("units").FindOne(ctx, bson.M{"_id": group.unitID, "book_id": group.bookID}).Decode(&unit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, 0, 0, fmt.Errorf("%w: 单元 %s 不属于书籍 %s", ErrInvalidProgressChange, group.unitID.Hex(), group.bookID.Hex())
+		}
+		return nil, 0, 0, err
+	}
+	totalWords, err := GetCollection("words").CountDocuments(ctx, bson.M{"unit_id": group.unitID})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	validWords, err := s.getUnitWordIDs(group)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// 先登记变更ID，登记成功的变更才会合并；写入进度失败时撤销登记，客户端重试时可再次应用
+	changes, duplicates, err := s.claimChanges(openID, group, now)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	progress, applied, err := s.writeUnitProgress(ctx, openID, deviceID, group, changes, validWords, int(totalWords), now)
+	if err != nil {
+		s.releaseChanges(openID, changes)
+		return nil, 0, 0, err
+	}
+	return progress, applied, duplicates, nil
+}
+
+// writeUnitProgress 将已登记的变更合并到单元进度文档，版本冲突时重新读取后重试
+func (s *ProgressService) writeUnitProgress(ctx context.Context, openID, deviceID string, group *unitChanges, changes []models.ProgressChange, validWords map[string]bool, totalWords int, now time.Time) (*models.UnitProgress, int, error) {
+	collection := GetCollection(learningProgressCollection)
+	filter := bson.M{"user_openid": openID, "unit_id": group.unitID}
+	for attempt := 0; attempt < maxProgressRetries; attempt++ {
+		var progress models.UnitProgress
+		isNew := false
+		err := collection.FindOne(ctx, filter).Decode(&progress)
+		if err == mongo.ErrNoDocuments {
+			isNew = true
+			progress = models.UnitProgress{
+				ID:         primitive.NewObjectID(),
+				UserOpenID: openID,
+				BookID:     group.bookID,
+				UnitID:     group.unitID,
+				CreatedAt:  now,
+			}
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		applied := s.mergeChanges(&progress, changes, validWords, now)
+		if applied == 0 {
+			if isNew {
+				return nil, 0, nil
+			}
+			return &progress, 0, nil
+		}
+
+		s.refreshDerivedFields(&progress, totalWords)
+		previousVersion := progress.Version
+		progress.Version++
+		progress.LastDeviceID = deviceID
+		progress.UpdatedAt = now
+
+		if isNew {
+			_, err = collection.InsertOne(ctx, progress)
+			if mongo.IsDuplicateKeyError(err) {
+				// 其他设备同时创建了该单元进度，重新读取后合并
+				continue
+			}
+		} else {
+			var updateResult *mongo.UpdateResult
+			updateResult, err = collection.ReplaceOne(ctx, bson.M{"_id": progress.ID, "version": previousVersion}, progress)
+			if err == nil && updateResult.MatchedCount == 0 {
+				// 版本已被其他设备更新，重新读取后合并
+				continue
+			}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return &progress, applied, nil
+	}
+
+	return nil, 0, errors.New("学习进度同步冲突，请稍后重试")
+}
+
+// claimChanges 登记变更ID，返回首次登记的变更和重复变更数（已应用过或同一请求中重复的变更ID）
+// 变更ID按用户唯一索引登记，并发提交同一变更时只有一个请求能登记成功
+func (s *ProgressService) claimChanges(openID string, group *unitChanges, now time.Time) ([]models.ProgressChange, int, error) {
+	collection := GetCollection(appliedChangesCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	claimed := []models.ProgressChange{}
+	duplicates := 0
+	for _, change := range group.changes {
+		_, err := collection.InsertOne(ctx, models.AppliedProgressChange{
+			ID:         primitive.NewObjectID(),
+			UserOpenID: openID,
+			ChangeID:   change.ChangeID,
+			UnitID:     group.unitID,
+			AppliedAt:  now,
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			duplicates++
+			continue
+		}
+		if err != nil {
+			s.releaseChanges(openID, claimed)
+			return nil, 0, err
+		}
+		claimed = append(claimed, change)
+	}
+	return claimed, duplicates, nil
+}
+
+// releaseChanges 撤销变更ID的登记
+func (s *ProgressService) releaseChanges(openID string, changes []models.ProgressChange) {
+	if len(changes) == 0 {
+		return
+	}
+	changeIDs := make([]string, 0, len(changes))
+	for _, change := range changes {
+		changeIDs = append(changeIDs, change.ChangeID)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	_, err := GetCollection(appliedChangesCollection).DeleteMany(ctx, bson.M{
+		"user_openid": openID,
+		"change_id":   bson.M{"$in": changeIDs},
+	})
+	if err != nil {
+		log.Printf("[学习进度] 撤销变更登记失败: openID=%s, err=%v", openID, err)
+	}
+}
+
+// getUnitWordIDs 获取变更中属于该单元的单词ID，不属于该单元的单词会被忽略
+func (s *ProgressService) getUnitWordIDs(group *unitChanges) (map[string]bool, error) {
+	ids := []primitive.ObjectID{}
+	for _, change := range group.changes {
+		for _, list := range [][]string{change.MasteredWords, change.LearningWords} {
+			for _, wordID := range list {
+				if objectID, err := primitive.ObjectIDFromHex(wordID); err == nil {
+					ids = append(ids, objectID)
+				}
+			}
+		}
+	}
+
+	valid := map[string]bool{}
+	if len(ids) == 0 {
+		return valid, nil
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection("words").Find(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "unit_id": group.unitID},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var words []models.Word
+	if err := cursor.All(ctx, &words); err != nil {
+		return nil, err
+	}
+	for _, word := range words {
+		valid[word.ID.Hex()] = true
+	}
+	return valid, nil
+}
+
+// mergeChanges 合并已登记的变更：单词状态只在设备时间不早于已有状态时覆盖，返回应用的变更数
+func (s *ProgressService) mergeChanges(progress *models.UnitProgress, changes []models.ProgressChange, validWords map[string]bool, now time.Time) int {
+	if progress.WordStates == nil {
+		progress.WordStates = map[string]models.WordProgressState{}
+	}
+
+	applied := 0
+	for _, change := range changes {
+		timestamp := change.DeviceTimestamp.UTC()
+		if latest := now.Add(maxDeviceClockSkew); timestamp.After(latest) {
+			timestamp = now
+		}
+
+		// 同一变更中同时出现时以“已掌握”为准
+		setState := func(wordIDs []string, status string) {
+			for _, wordID := range wordIDs {
+				if !validWords[wordID] {
+					continue
+				}
+				if existing, ok := progress.WordStates[wordID]; ok && existing.UpdatedAt.After(timestamp) {
+					continue
+				}
+				progress.WordStates[wordID] = models.WordProgressState{Status: status, UpdatedAt: timestamp}
+			}
+		}
+		setState(change.LearningWords, models.WordStatusLearning)
+		setState(change.MasteredWords, models.WordStatusMastered)
+
+		progress.TimeSpentSeconds += change.TimeSpentSeconds
+		if timestamp.After(progress.DeviceUpdatedAt) {
+			progress.DeviceUpdatedAt = timestamp
+		}
+
+		applied++
+	}
+	return applied
+}
+
+// refreshDerivedFields 根据单词状态重新计算已掌握、学习中列表和完成度
+func (s *ProgressService) refreshDerivedFields(progress *models.UnitProgress, totalWords int) {
+	progress.MasteredWords = []string{}
+	progress.LearningWords = []string{}
+	for wordID, state := range progress.WordStates {
+		if state.Status == models.WordStatusMastered {
+			progress.MasteredWords = append(progress.MasteredWords, wordID)
+		} else {
+			progress.LearningWords = append(progress.LearningWords, wordID)
+		}
+	}
+	sort.Strings(progress.MasteredWords)
+	sort.Strings(progress.LearningWords)
+
+	progress.TotalWords = totalWords
+	progress.CompletionPercent = completionPercent(len(progress.MasteredWords), totalWords)
+}
+
+// completionPercent 计算完成百分比（保留两位小数）
+func completionPercent(mastered, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	percent := math.Min(100, float64(mastered)*100/float64(total))
+	return math.Round(percent*100) / 100
+}
+
+// GetUnitProgress 获取用户在某本书各单元的学习进度
+func (s *ProgressService) GetUnitProgress(openID string, bookID primitive.ObjectID) ([]models.UnitProgress, error) {
+	collection := GetCollection(learningProgressCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx,
+		bson.M{"user_openid": openID, "book_id": bookID},
+		options.Find().SetSort(bson.D{{Key: "unit_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	units := []models.UnitProgress{}
+	if err := cursor.All(ctx, &units); err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// GetBookProgressSummaries 按书籍汇总用户的学习进度
+func (s *ProgressService) GetBookProgressSummaries(openID string) ([]models.BookProgressSummary, error) {
+	collection := GetCollection(learningProgressCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"user_openid": openID}},
+		{"$group": bson.M{
+			"_id":                "$book_id",
+			"unit_count":         bson.M{"$sum": 1},
+			"mastered_count":     bson.M{"$sum": bson.M{"$size": bson.M{"$ifNull": bson.A{"$mastered_words", bson.A{}}}}},
+			"learning_count":     bson.M{"$sum": bson.M{"$size": bson.M{"$ifNull": bson.A{"$learning_words", bson.A{}}}}},
+			"time_spent_seconds": bson.M{"$sum": "$time_spent_seconds"},
+			"updated_at":         bson.M{"$max": "$updated_at"},
+		}},
+		{"$sort": bson.M{"updated_at": -1}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []models.BookProgressSummary{}
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return summaries, nil
+	}
+
+	// 统计各书籍单词总数
+	bookIDs := make([]primitive.ObjectID, len(summaries))
+	for i, summary := range summaries {
+		bookIDs[i] = summary.BookID
+	}
+	cursor, err = GetCollection("words").Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"book_id": bson.M{"$in": bookIDs}}},
+		{"$group": bson.M{"_id": "$book_id", "total": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		BookID primitive.ObjectID `bson:"_id"`
+		Total  int                `bson:"total"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	totalMap := make(map[primitive.ObjectID]int, len(totals))
+	for _, total := range totals {
+		totalMap[total.BookID] = total.Total
+	}
+
+	for i := range summaries {
+		summaries[i].TotalWords = totalMap[summaries[i].BookID]
+		summaries[i].CompletionPercent = completionPercent(summaries[i].MasteredCount, summaries[i].TotalWords)
+	}
+	return summaries, nil
+}
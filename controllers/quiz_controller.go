@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/middlewares"
+	"miniprogram/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondQuizError 统一处理测验相关错误
+func respondQuizError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidQuizRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrBookNotUnlocked):
+		ForbiddenResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrQuizNotEnoughWords):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrQuizAudioNotFound):
+		NotFoundResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrQuizAlreadySubmitted):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, "测验不存在", err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// CreateQuizHandler 生成测验处理器（从已解锁的书籍或单元出题）
+func CreateQuizHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.CreateQuizRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		quizService := GetQuizService()
+		quiz, err := quizService.CreateQuiz(openID, req)
+		if err != nil {
+			respondQuizError(c, "生成测验失败", err)
+			return
+		}
+
+		quizService.HideAnswers(quiz)
+		CreatedResponse(c, "测验生成成功", quiz)
+	}
+}
+
+// GetQuizHandler 获取测验详情处理器（提交前不返回正确答案）
+func GetQuizHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		quizService := GetQuizService()
+		quiz, err := quizService.GetQuiz(openID, c.Param("quiz_id"))
+		if err != nil {
+			respondQuizError(c, "获取测验失败", err)
+			return
+		}
+
+		quizService.HideAnswers(quiz)
+		SuccessResponse(c, "获取测验成功", quiz)
+	}
+}
+
+// ListQuizzesHandler 获取测验记录列表处理器
+func ListQuizzesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+		quizzes, pagination, err := GetQuizService().ListQuizzes(openID, page, limit)
+		if err != nil {
+			InternalServerErrorResponse(c, "获取测验记录失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取测验记录成功", gin.H{
+			"quizzes":    quizzes,
+			"pagination": pagination,
+		})
+	}
+}
+
+// SubmitQuizHandler 提交测验答案处理器（服务端判分，结果计入单词复习和学习进度）
+func SubmitQuizHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.SubmitQuizRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		quiz, err := GetQuizService().SubmitQuiz(openID, c.Param("quiz_id"), req.Answers)
+		if err != nil {
+			respondQuizError(c, "提交测验失败", err)
+			return
+		}
+
+		SuccessResponse(c, "测验提交成功", quiz)
+	}
+}
+
+// GetQuizAudioHandler 听音题发音处理器（通过本人题目中的随机访问键获取，重定向到缓存发音的临时地址）
+func GetQuizAudioHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := middlewares.GetUserFromGinContext(c)
+		if !ok {
+			UnauthorizedResponse(c, "用户未认证", nil)
+			return
+		}
+
+		audioURL, err := GetQuizService().GetQuestionAudioURL(claims.UserId, c.Param("audio_key"))
+		if err != nil {
+			respondQuizError(c, "获取发音失败", err)
+			return
+		}
+
+		// 临时地址会过期，重定向响应不缓存
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, audioURL)
+	}
+}
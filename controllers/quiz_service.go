@@ -0,0 +1,649 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 单词测验服务层 =====
+
+var (
+	// ErrInvalidQuizRequest 测验参数错误
+	ErrInvalidQuizRequest = errors.New("测验参数错误")
+	// ErrQuizNotEnoughWords 可出题的单词不足
+	ErrQuizNotEnoughWords = errors.New("可出题的单词不足")
+	// ErrQuizAlreadySubmitted 测验已提交
+	ErrQuizAlreadySubmitted = errors.New("测验已提交，不能重复作答")
+	// ErrQuizAudioNotFound 听音题发音不存在
+	ErrQuizAudioNotFound = errors.New("发音不存在")
+)
+
+const (
+	defaultQuizQuestionCount = 10 // 默认题目数量
+	quizOptionCount          = 4  // 选择题选项数量（含正确答案）
+	quizAudioKeyLength       = 32 // 听音题发音访问键长度（十六进制字符）
+	// quizAudioCachePrefix 听音题发音在对象存储中的缓存目录（私有对象，键为发音地址的哈希，不包含单词本身）
+	quizAudioCachePrefix = "private/quiz-audio/"
+	// maxQuizAudioRedirects 下载外部发音时最多跟随的重定向次数（每次重定向的目标也必须在允许的域名中）
+	maxQuizAudioRedirects = 3
+)
+
+// quizAudioExtensions 缓存键保留的发音文件扩展名
+var quizAudioExtensions = map[string]bool{".mp3": true, ".m4a": true, ".aac": true, ".wav": true, ".ogg": true, ".amr": true}
+
+// quizTypes 支持的全部题型
+var quizTypes = []string{
+	models.QuizTypeMeaningToWord,
+	models.QuizTypeWordToMeaning,
+	models.QuizTypeSpelling,
+	models.QuizTypeAudioToWord,
+}
+
+// QuizService 单词测验服务
+type QuizService struct{}
+
+// GetQuizService 获取单词测验服务实例
+func GetQuizService() *QuizService {
+	return &QuizService{}
+}
+
+// CreateQuiz 从用户已解锁的书籍或单元生成测验
+func (s *QuizService) CreateQuiz(openID string, req models.CreateQuizRequest) (*models.Quiz, error) {
+	bookID, err := primitive.ObjectIDFromHex(req.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的书籍ID", ErrInvalidQuizRequest)
+	}
+	var unitID *primitive.ObjectID
+	if req.UnitID != "" {
+		id, err := primitive.ObjectIDFromHex(req.UnitID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的单元ID", ErrInvalidQuizRequest)
+		}
+		unitID = &id
+	}
+
+	types, err := s.resolveTypes(req.Types)
+	if err != nil {
+		return nil, err
+	}
+	count := req.Count
+	if count <= 0 {
+		count = defaultQuizQuestionCount
+	}
+
+	hasPermission, _, err := CheckUserBookPermission(openID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, ErrBookNotUnlocked
+	}
+
+	// 加载整本书的单词，既用于出题也作为干扰项来源
+	bookWords, err := s.loadBookWords(bookID)
+	if err != nil {
+		return nil, err
+	}
+	candidates := bookWords
+	if unitID != nil {
+		candidates = []models.Word{}
+		for _, word := range bookWords {
+			if word.UnitID == *unitID {
+				candidates = append(candidates, word)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrQuizNotEnoughWords
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+
+	unitWords := map[primitive.ObjectID][]models.Word{}
+	for _, word := range bookWords {
+		unitWords[word.UnitID] = append(unitWords[word.UnitID], word)
+	}
+
+	questions := make([]models.QuizQuestion, 0, count)
+	for i, word := range candidates[:count] {
+		question := s.buildQuestion(word, types, unitWords[word.UnitID], bookWords)
+		question.QuestionID = fmt.Sprintf("q%d", i+1)
+		questions = append(questions, question)
+	}
+
+	now := utils.GetCurrentUTCTime()
+	quiz := &models.Quiz{
+		ID:         primitive.NewObjectID(),
+		UserOpenID: openID,
+		BookID:     bookID,
+		UnitID:     unitID,
+		Status:     models.QuizStatusInProgress,
+		Questions:  questions,
+		TotalCount: len(questions),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	collection := GetCollection("quizzes")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	if _, err := collection.InsertOne(ctx, quiz); err != nil {
+		return nil, err
+	}
+	return quiz, nil
+}
+
+// resolveTypes 校验请求的题型，未指定时使用全部题型
+func (s *QuizService) resolveTypes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return quizTypes, nil
+	}
+	for _, quizType := range requested {
+		valid := false
+		for _, supported := range quizTypes {
+			if quizType == supported {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: 不支持的题型 %s", ErrInvalidQuizRequest, quizType)
+		}
+	}
+	return requested, nil
+}
+
+// loadBookWords 加载书籍的全部单词
+func (s *QuizService) loadBookWords(bookID primitive.ObjectID) ([]models.Word, error) {
+	collection := GetCollection("words")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"book_id": bookID})
+	if err != nil {
+		return nil, err
+	}
+	var words []models.Word
+	if err := cursor.All(ctx, &words); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// buildQuestion 为单词生成一道题目：随机选择题型，选择题干扰项优先取自同一单元，不足时从整本书补充
+func (s *QuizService) buildQuestion(word models.Word, types []string, unitWords, bookWords []models.Word) models.QuizQuestion {
+	quizType := types[rand.IntN(len(types))]
+	// 没有发音的单词无法出听音题
+	if quizType == models.QuizTypeAudioToWord && word.PronunciationURL == "" {
+		quizType = models.QuizTypeMeaningToWord
+	}
+
+	question := models.QuizQuestion{
+		Type:   quizType,
+		WordID: word.ID,
+		UnitID: word.UnitID,
+	}
+
+	// 选项取单词还是释义
+	optionOf := func(w models.Word) string { return w.WordName }
+	switch quizType {
+	case models.QuizTypeMeaningToWord, models.QuizTypeSpelling:
+		question.Prompt = word.WordMeaning
+		question.CorrectAnswer = word.WordName
+	case models.QuizTypeWordToMeaning:
+		question.Prompt = word.WordName
+		question.CorrectAnswer = word.WordMeaning
+		optionOf = func(w models.Word) string { return w.WordMeaning }
+	case models.QuizTypeAudioToWord:
+		// 发音地址中常带有单词本身，题干只给出随机键对应的转发地址
+		question.AudioKey = GenerateRandomString(quizAudioKeyLength)
+		question.Prompt = strings.TrimRight(config.GetConfig().BaseAPIURL, "/") + "/api/quiz-audio/" + question.AudioKey
+		question.CorrectAnswer = word.WordName
+	}
+
+	if quizType == models.QuizTypeSpelling {
+		return question
+	}
+
+	choices := []string{question.CorrectAnswer}
+	seen := map[string]bool{question.CorrectAnswer: true}
+	for _, pool := range [][]models.Word{unitWords, bookWords} {
+		shuffled := make([]models.Word, len(pool))
+		copy(shuffled, pool)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		for _, candidate := range shuffled {
+			if len(choices) >= quizOptionCount {
+				break
+			}
+			option := optionOf(candidate)
+			if option == "" || seen[option] {
+				continue
+			}
+			seen[option] = true
+			choices = append(choices, option)
+		}
+	}
+	rand.Shuffle(len(choices), func(i, j int) {
+		choices[i], choices[j] = choices[j], choices[i]
+	})
+	question.Options = choices
+
+	return question
+}
+
+// GetQuiz 获取用户的测验
+func (s *QuizService) GetQuiz(openID, quizID string) (*models.Quiz, error) {
+	objectID, err := primitive.ObjectIDFromHex(quizID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的测验ID", ErrInvalidQuizRequest)
+	}
+
+	collection := GetCollection("quizzes")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var quiz models.Quiz
+	if err := collection.FindOne(ctx, bson.M{"_id": objectID, "user_openid": openID}).Decode(&quiz); err != nil {
+		return nil, err
+	}
+	return &quiz, nil
+}
+
+// ListQuizzes 分页获取用户的测验记录（不含题目）
+func (s *QuizService) ListQuizzes(openID string, page, limit int) ([]models.Quiz, *models.Pagination, error) {
+	collection := GetCollection("quizzes")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	filter := bson.M{"user_openid": openID}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := options.Find().
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetSort(bson.M{"created_at": -1}).
+		SetProjection(bson.M{"questions": 0})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	quizzes := []models.Quiz{}
+	if err := cursor.All(ctx, &quizzes); err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &models.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      int(total),
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	}
+	return quizzes, pagination, nil
+}
+
+// GetQuestionAudioURL 根据本人测验中听音题的访问键返回发音的临时访问地址
+// 发音第一次被请求时复制到对象存储的缓存目录（外部地址只从允许的域名下载），之后直接签发缓存对象的地址
+func (s *QuizService) GetQuestionAudioURL(openID, audioKey string) (string, error) {
+	if len(audioKey) != quizAudioKeyLength {
+		return "", ErrQuizAudioNotFound
+	}
+
+	ctx, cancel := mediaContext()
+	defer cancel()
+
+	var quiz models.Quiz
+	err := GetCollection("quizzes").FindOne(ctx,
+		bson.M{"user_openid": openID, "questions.audio_key": audioKey},
+		options.FindOne().SetProjection(bson.M{"questions.$": 1}),
+	).Decode(&quiz)
+	if err == mongo.ErrNoDocuments || (err == nil && len(quiz.Questions) == 0) {
+		return "", ErrQuizAudioNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var word models.Word
+	err = GetCollection("words").FindOne(ctx, bson.M{"_id": quiz.Questions[0].WordID},
+		options.FindOne().SetProjection(bson.M{"pronunciation_url": 1})).Decode(&word)
+	if err == mongo.ErrNoDocuments || (err == nil && word.PronunciationURL == "") {
+		return "", ErrQuizAudioNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		return "", err
+	}
+	cacheKey := quizAudioCacheKey(word.PronunciationURL)
+	exists, err := storage.Exists(ctx, cacheKey)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		data, contentType, err := s.loadPronunciation(ctx, storage, word.PronunciationURL)
+		if err != nil {
+			return "", err
+		}
+		if err := storage.Put(ctx, cacheKey, data, contentType); err != nil {
+			return "", err
+		}
+	}
+	return storage.SignedURL(cacheKey, signedURLTTL())
+}
+
+// quizAudioCacheKey 发音地址对应的缓存对象键
+func quizAudioCacheKey(pronunciationURL string) string {
+	sum := sha256.Sum256([]byte(pronunciationURL))
+	key := quizAudioCachePrefix + hex.EncodeToString(sum[:])
+	if u, err := url.Parse(pronunciationURL); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); quizAudioExtensions[ext] {
+			key += ext
+		}
+	}
+	return key
+}
+
+// loadPronunciation 读取发音内容：存储中的文件直接读取，外部地址从允许的域名下载
+func (s *QuizService) loadPronunciation(ctx context.Context, storage utils.ObjectStorage, rawURL string) ([]byte, string, error) {
+	if key, ok := utils.ObjectKeyFromURL(storage, rawURL); ok {
+		data, err := storage.Get(ctx, key)
+		if errors.Is(err, utils.ErrObjectNotFound) {
+			return nil, "", ErrQuizAudioNotFound
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return data, http.DetectContentType(data), nil
+	}
+	return s.fetchRemoteAudio(ctx, rawURL)
+}
+
+// quizAudioAllowedHosts 允许下载发音的域名：BASE_API_URL 的域名和 QUIZ_AUDIO_ALLOWED_HOSTS
+func quizAudioAllowedHosts(cfg *config.Config) []string {
+	hosts := append([]string{}, cfg.QuizAudioAllowedHosts...)
+	if base, err := url.Parse(cfg.BaseAPIURL); err == nil && base.Hostname() != "" {
+		hosts = append(hosts, base.Hostname())
+	}
+	return hosts
+}
+
+// quizAudioHostAllowed 地址是否为 http(s) 且域名在允许列表中（不区分大小写，忽略端口）
+func quizAudioHostAllowed(u *url.URL, allowed []string) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	for _, host := range allowed {
+		if host != "" && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchRemoteAudio 从允许的域名下载外部发音（不超过 MaxMediaUploadSize），不在允许列表中的地址按发音不存在处理
+func (s *QuizService) fetchRemoteAudio(ctx context.Context, rawURL string) ([]byte, string, error) {
+	cfg := config.GetConfig()
+	target := rawURL
+	if strings.HasPrefix(rawURL, "/") && !strings.HasPrefix(rawURL, "//") {
+		target = strings.TrimRight(cfg.BaseAPIURL, "/") + rawURL
+	}
+	allowed := quizAudioAllowedHosts(cfg)
+	u, err := url.Parse(target)
+	if err != nil || !quizAudioHostAllowed(u, allowed) {
+		log.Printf("[测验] 发音地址不在允许的域名中: %s", rawURL)
+		return nil, "", ErrQuizAudioNotFound
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", ErrQuizAudioNotFound
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxQuizAudioRedirects {
+				return fmt.Errorf("重定向次数过多")
+			}
+			if !quizAudioHostAllowed(req.URL, allowed) {
+				return fmt.Errorf("重定向到不允许的域名: %s", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载发音失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, "", ErrQuizAudioNotFound
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxMediaUploadSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("下载发音失败: %w", err)
+	}
+	if len(data) > MaxMediaUploadSize {
+		return nil, "", fmt.Errorf("发音文件超过 %d 字节", MaxMediaUploadSize)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// HideAnswers 未提交的测验不返回正确答案
+func (s *QuizService) HideAnswers(quiz *models.Quiz) {
+	if quiz.Status == models.QuizStatusSubmitted {
+		return
+	}
+	for i := range quiz.Questions {
+		quiz.Questions[i].CorrectAnswer = ""
+	}
+}
+
+// SubmitQuiz 提交答案并判分，结果写入单词复习状态和单元学习进度
+func (s *QuizService) SubmitQuiz(openID, quizID string, answers []models.QuizAnswer) (*models.Quiz, error) {
+	quiz, err := s.GetQuiz(openID, quizID)
+	if err != nil {
+		return nil, err
+	}
+	if quiz.Status == models.QuizStatusSubmitted {
+		return nil, ErrQuizAlreadySubmitted
+	}
+
+	answerMap := make(map[string]string, len(answers))
+	for _, answer := range answers {
+		answerMap[answer.QuestionID] = strings.TrimSpace(answer.Answer)
+	}
+
+	correctCount := 0
+	for i := range quiz.Questions {
+		question := &quiz.Questions[i]
+		question.UserAnswer = answerMap[question.QuestionID]
+		if question.Type == models.QuizTypeSpelling {
+			question.IsCorrect = strings.EqualFold(question.UserAnswer, strings.TrimSpace(question.CorrectAnswer))
+		} else {
+			question.IsCorrect = question.UserAnswer != "" && question.UserAnswer == question.CorrectAnswer
+		}
+		if question.IsCorrect {
+			correctCount++
+		}
+	}
+
+	now := utils.GetCurrentUTCTime()
+	quiz.Status = models.QuizStatusSubmitted
+	quiz.CorrectCount = correctCount
+	if quiz.TotalCount > 0 {
+		quiz.Score = math.Round(float64(correctCount)*10000/float64(quiz.TotalCount)) / 100
+	}
+	quiz.SubmittedAt = now
+	quiz.UpdatedAt = now
+
+	// 只有未提交的测验可以更新，防止并发重复提交
+	collection := GetCollection("quizzes")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": quiz.ID, "status": models.QuizStatusInProgress},
+		bson.M{"$set": bson.M{
+			"status":        quiz.Status,
+			"questions":     quiz.Questions,
+			"correct_count": quiz.CorrectCount,
+			"score":         quiz.Score,
+			"submitted_at":  quiz.SubmittedAt,
+			"updated_at":    quiz.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrQuizAlreadySubmitted
+	}
+
+	s.applyMastery(openID, quiz)
+	return quiz, nil
+}
+
+// quizQuality 将作答结果换算为 SM-2 回忆质量
+func quizQuality(question models.QuizQuestion) int {
+	switch {
+	case question.UserAnswer == "":
+		return 0
+	case !question.IsCorrect:
+		return 1
+	case question.Type == models.QuizTypeSpelling:
+		return 5
+	default:
+		return 4
+	}
+}
+
+// applyMastery 根据测验结果更新单词复习状态、错题本和单元学习进度
+// 答对的单词在复习状态中连续记住 3 次及以上视为已掌握，其余记为学习中；失败只记录日志，不影响测验结果
+func (s *QuizService) applyMastery(openID string, quiz *models.Quiz) {
+	reviewService := GetReviewService()
+	changes := map[primitive.ObjectID]*models.ProgressChange{}
+	order := []primitive.ObjectID{}
+	now := utils.GetCurrentUTCTime()
+
+	// 以单词当前数据为准（出题后单词可能被调整或删除）
+	wordIDs := make([]primitive.ObjectID, len(quiz.Questions))
+	for i, question := range quiz.Questions {
+		wordIDs[i] = question.WordID
+	}
+	words, err := s.loadWordsByIDs(wordIDs)
+	if err != nil {
+		log.Printf("[单词测验] 查询单词失败: quiz=%s, err=%v", quiz.ID.Hex(), err)
+		return
+	}
+
+	for _, question := range quiz.Questions {
+		word, ok := words[question.WordID]
+		if !ok {
+			continue
+		}
+		review, err := reviewService.recordWordReview(openID, word, quizQuality(question))
+		if err != nil {
+			log.Printf("[单词测验] 更新复习状态失败: quiz=%s, word=%s, err=%v", quiz.ID.Hex(), question.WordID.Hex(), err)
+			continue
+		}
+		if _, err := GetMistakeService().RecordResult(openID, word, question.IsCorrect, models.MistakeSourceQuiz); err != nil {
+			log.Printf("[错题本] 记录测验结果失败: quiz=%s, word=%s, err=%v", quiz.ID.Hex(), question.WordID.Hex(), err)
+		}
+
+		change, ok := changes[word.UnitID]
+		if !ok {
+			change = &models.ProgressChange{
+				ChangeID:        "quiz:" + quiz.ID.Hex() + ":" + word.UnitID.Hex(),
+				BookID:          word.BookID.Hex(),
+				UnitID:          word.UnitID.Hex(),
+				DeviceTimestamp: now,
+			}
+			changes[word.UnitID] = change
+			order = append(order, word.UnitID)
+		}
+		if question.IsCorrect && review.Repetitions >= 3 {
+			change.MasteredWords = append(change.MasteredWords, question.WordID.Hex())
+		} else {
+			change.LearningWords = append(change.LearningWords, question.WordID.Hex())
+		}
+	}
+
+	if len(order) == 0 {
+		return
+	}
+	req := models.SyncProgressRequest{DeviceID: "quiz"}
+	for _, unitID := range order {
+		req.Changes = append(req.Changes, *changes[unitID])
+	}
+	if _, err := GetProgressService().SyncProgress(openID, req); err != nil {
+		log.Printf("[单词测验] 更新学习进度失败: quiz=%s, err=%v", quiz.ID.Hex(), err)
+	}
+}
+
+// loadWordsByIDs 按ID批量查询单词
+func (s *QuizService) loadWordsByIDs(ids []primitive.ObjectID) (map[primitive.ObjectID]models.Word, error) {
+	collection := GetCollection("words")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var words []models.Word
+	if err := cursor.All(ctx, &words); err != nil {
+		return nil, err
+	}
+
+	wordMap := make(map[primitive.ObjectID]models.Word, len(words))
+	for _, word := range words {
+		wordMap[word.ID] = word
+	}
+	return wordMap, nil
+}
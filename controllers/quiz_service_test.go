@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestQuizAudioHostAllowed(t *testing.T) {
+	allowed := []string{"api.example.com", "cdn.example.com"}
+	tests := []struct {
+		rawURL string
+		want   bool
+	}{
+		{"https://cdn.example.com/audio/apple.mp3", true},
+		{"http://CDN.Example.com:8080/audio/apple.mp3", true},
+		{"https://api.example.com/uploads/apple.mp3", true},
+		{"https://evil.example.com/apple.mp3", false},
+		{"https://cdn.example.com.evil.com/apple.mp3", false},
+		{"ftp://cdn.example.com/apple.mp3", false},
+		{"file:///etc/passwd", false},
+		{"http://127.0.0.1/apple.mp3", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.rawURL)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) error: %v", tt.rawURL, err)
+		}
+		if got := quizAudioHostAllowed(u, allowed); got != tt.want {
+			t.Errorf("quizAudioHostAllowed(%q) = %v, want %v", tt.rawURL, got, tt.want)
+		}
+	}
+
+	u, _ := url.Parse("https://cdn.example.com/apple.mp3")
+	if quizAudioHostAllowed(u, nil) {
+		t.Errorf("quizAudioHostAllowed with empty list = true, want false")
+	}
+}
+
+func TestQuizAudioCacheKey(t *testing.T) {
+	tests := []struct {
+		rawURL  string
+		wantExt string
+	}{
+		{"https://cdn.example.com/audio/apple.mp3", ".mp3"},
+		{"https://cdn.example.com/audio/apple.M4A?v=2", ".m4a"},
+		{"https://cdn.example.com/audio/apple", ""},
+		{"https://cdn.example.com/audio/apple.php", ""},
+	}
+	for _, tt := range tests {
+		key := quizAudioCacheKey(tt.rawURL)
+		if !strings.HasPrefix(key, quizAudioCachePrefix) {
+			t.Errorf("quizAudioCacheKey(%q) = %q, want prefix %q", tt.rawURL, key, quizAudioCachePrefix)
+		}
+		if strings.Contains(key, "apple") {
+			t.Errorf("quizAudioCacheKey(%q) = %q, must not contain the word", tt.rawURL, key)
+		}
+		if got := strings.TrimPrefix(key, quizAudioCachePrefix)[64:]; got != tt.wantExt {
+			t.Errorf("quizAudioCacheKey(%q) ext = %q, want %q", tt.rawURL, got, tt.wantExt)
+		}
+	}
+
+	if quizAudioCacheKey("https://a.example.com/x.mp3") == quizAudioCacheKey("https://b.example.com/x.mp3") {
+		t.Errorf("quizAudioCacheKey returned the same key for different URLs")
+	}
+}
@@ -186,12 +186,19 @@ func (s *CommissionService) CreateCommissionRecord(openID string, amount float64
 
 	commissionID := GenerateCommissionID()
 
+	// 账号被封禁或暂停期间产生的佣金直接冻结
+	status, statusBeforeFreeze := "pending", ""
+	if blocked, err := GetUserStatusService().IsAccountBlocked(openID); err == nil && blocked {
+		status, statusBeforeFreeze = frozenStatus, "pending"
+	}
+
 	commission := models.Commission{
 		CommissionID:       commissionID,
 		UserOpenID:         openID,
 		Amount:             amount,
 		Date:               utils.GetCurrentUTCTime(),
-		Status:             "pending",
+		Status:             status,
+		StatusBeforeFreeze: statusBeforeFreeze,
 		Type:               commissionType,
 		Description:        description,
 		OrderID:            orderID,
@@ -412,6 +419,39 @@ func (s *AgentTieredCommissionService) ProcessAgentCommission(userOpenID string,
 	return nil
 }
 
+// ProcessAttributedAgentCommission 为指定代理计算分级提成（如线下销售的激活码被兑换），不按用户学校、城市匹配代理
+func (s *AgentTieredCommissionService) ProcessAttributedAgentCommission(agentOpenID string, user *models.User, saleAmount float64, orderID, description string) error {
+	agent, err := NewAgentUserService().IsValidAgent(agentOpenID)
+	if err != nil {
+		return err
+	}
+
+	newAccumulatedSales := agent.AccumulatedSales + saleAmount
+	if err := s.updateAgentAccumulatedSales(agent.OpenID, newAccumulatedSales); err != nil {
+		return err
+	}
+
+	var commissionRate float64
+	switch agent.AgentLevel {
+	case 1:
+		commissionRate = s.calculateSchoolAgentCommissionRate(newAccumulatedSales)
+	case 2:
+		commissionRate = s.calculateRegionalAgentCommissionRate(newAccumulatedSales)
+	default:
+		return nil
+	}
+
+	return s.commissionService.CreateCommissionRecord(
+		agent.OpenID,
+		saleAmount*commissionRate,
+		"agent",
+		description,
+		orderID,
+		user.OpenID,
+		user.UserName,
+	)
+}
+
 // processSchoolAgentCommission 处理校代理提成
 func (s *AgentTieredCommissionService) processSchoolAgentCommission(user *models.User, orderAmount float64, orderID string) error {
 	// 查找该学校的校代理
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"miniprogram/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// GetDueReviewsHandler 获取待复习单词处理器
+// 返回已到期的复习单词，不足 limit 时用未学过的新单词补足（最多 new_limit 个）
+func GetDueReviewsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		newLimit, _ := strconv.Atoi(c.DefaultQuery("new_limit", "10"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		if newLimit < 0 {
+			newLimit = 0
+		}
+
+		items, err := GetReviewService().GetDueReviews(openID, c.Query("book_id"), limit, newLimit)
+		if err != nil {
+			switch err {
+			case ErrBookNotUnlocked:
+				ForbiddenResponse(c, err.Error(), nil)
+			case mongo.ErrNoDocuments:
+				NotFoundResponse(c, "用户不存在", err)
+			default:
+				InternalServerErrorResponse(c, "获取待复习单词失败", err)
+			}
+			return
+		}
+
+		newCount := 0
+		for _, item := range items {
+			if item.IsNew {
+				newCount++
+			}
+		}
+
+		SuccessResponse(c, "获取待复习单词成功", gin.H{
+			"items":       items,
+			"total_count": len(items),
+			"due_count":   len(items) - newCount,
+			"new_count":   newCount,
+		})
+	}
+}
+
+// ReviewWordHandler 提交单词复习结果处理器
+func ReviewWordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.ReviewWordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		review, err := GetReviewService().RecordReview(openID, c.Param("word_id"), *req.Quality)
+		if err != nil {
+			switch err {
+			case ErrInvalidWordID:
+				BadRequestResponse(c, err.Error(), nil)
+			case ErrBookNotUnlocked:
+				ForbiddenResponse(c, err.Error(), nil)
+			case mongo.ErrNoDocuments:
+				NotFoundResponse(c, "单词不存在", err)
+			default:
+				InternalServerErrorResponse(c, "记录复习结果失败", err)
+			}
+			return
+		}
+
+		SuccessResponse(c, "复习结果已记录", review)
+	}
+}
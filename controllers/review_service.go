@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 单词复习服务层（SM-2 间隔重复） =====
+
+var (
+	// ErrBookNotUnlocked 用户未解锁该书籍
+	ErrBookNotUnlocked = errors.New("您没有访问该书籍的权限，请先购买相关单词卡")
+	// ErrInvalidWordID 单词ID格式错误
+	ErrInvalidWordID = errors.New("无效的单词ID格式")
+
+	// errStaleReview 复习时间早于已有的复习记录
+	errStaleReview = errors.New("复习记录已被更新的作答覆盖")
+)
+
+const (
+	defaultEaseFactor = 2.5 // 新单词的初始难度系数
+	minEaseFactor     = 1.3 // 难度系数下限
+	passingQuality    = 3   // 回忆质量达到该值视为记住
+)
+
+// ReviewService 单词复习服务
+type ReviewService struct{}
+
+// GetReviewService 获取单词复习服务实例
+func GetReviewService() *ReviewService {
+	return &ReviewService{}
+}
+
+// scheduleReview 按 SM-2 算法根据回忆质量更新复习状态并计算下次复习时间
+func (s *ReviewService) scheduleReview(review *models.WordReview, quality int, now time.Time) {
+	if review.EaseFactor == 0 {
+		review.EaseFactor = defaultEaseFactor
+	}
+
+	if quality >= passingQuality {
+		switch review.Repetitions {
+		case 0:
+			review.IntervalDays = 1
+		case 1:
+			review.IntervalDays = 6
+		default:
+			review.IntervalDays = int(math.Round(float64(review.IntervalDays) * review.EaseFactor))
+		}
+		review.Repetitions++
+	} else {
+		// 已经复习过的单词被遗忘才计为一次遗忘
+		if review.ReviewCount > 0 {
+			review.Lapses++
+		}
+		review.Repetitions = 0
+		review.IntervalDays = 1
+	}
+
+	diff := float64(5 - quality)
+	review.EaseFactor = math.Max(minEaseFactor, review.EaseFactor+0.1-diff*(0.08+diff*0.02))
+
+	review.ReviewCount++
+	review.LastQuality = quality
+	review.LastReviewedAt = now
+	review.DueAt = now.AddDate(0, 0, review.IntervalDays)
+	review.UpdatedAt = now
+}
+
+// getUnlockedBookIDs 获取用户可完整访问的书籍ID（未过期的自有权限和家庭共享）；指定书籍时校验是否已解锁
+func (s *ReviewService) getUnlockedBookIDs(openID, bookID string) ([]primitive.ObjectID, error) {
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessible, err := GetBookAccessService().GetFullAccessBookIDs(user)
+	if err != nil {
+		return nil, err
+	}
+
+	bookIDs := make([]primitive.ObjectID, 0, len(accessible))
+	for _, accessibleID := range accessible {
+		if bookID == "" || accessibleID.Hex() == bookID {
+			bookIDs = append(bookIDs, accessibleID)
+		}
+	}
+
+	if bookID != "" && len(bookIDs) == 0 {
+		return nil, ErrBookNotUnlocked
+	}
+	return bookIDs, nil
+}
+
+// GetDueReviews 获取下一批待复习单词：先返回已到期的复习，再用未学过的新单词补足
+// 只包含用户已解锁书籍中的单词，bookID 为空时包含所有已解锁书籍
+func (s *ReviewService) GetDueReviews(openID, bookID string, limit, newLimit int) ([]models.ReviewItem, error) {
+	bookIDs, err := s.getUnlockedBookIDs(openID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []models.ReviewItem{}
+	if len(bookIDs) == 0 || limit <= 0 {
+		return items, nil
+	}
+
+	reviewsCollection := GetCollection("word_reviews")
+	wordsCollection := GetCollection("words")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	// 1. 已到期的复习，最早到期的优先
+	cursor, err := reviewsCollection.Find(ctx, bson.M{
+		"user_openid": openID,
+		"book_id":     bson.M{"$in": bookIDs},
+		"due_at":      bson.M{"$lte": utils.GetCurrentUTCTime()},
+	}, options.Find().SetSort(bson.D{{Key: "due_at", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var reviews []models.WordReview
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+
+	if len(reviews) > 0 {
+		wordIDs := make([]primitive.ObjectID, len(reviews))
+		for i, review := range reviews {
+			wordIDs[i] = review.WordID
+		}
+		cursor, err = wordsCollection.Find(ctx, bson.M{"_id": bson.M{"$in": wordIDs}})
+		if err != nil {
+			return nil, err
+		}
+		var words []models.Word
+		if err := cursor.All(ctx, &words); err != nil {
+			return nil, err
+		}
+		wordMap := make(map[primitive.ObjectID]models.Word, len(words))
+		for _, word := range words {
+			wordMap[word.ID] = word
+		}
+
+		for i := range reviews {
+			word, ok := wordMap[reviews[i].WordID]
+			if !ok {
+				// 单词已被删除
+				continue
+			}
+			items = append(items, models.ReviewItem{Word: word, Review: &reviews[i]})
+		}
+	}
+
+	// 2. 用新单词补足本批次
+	remaining := limit - len(items)
+	if newLimit < remaining {
+		remaining = newLimit
+	}
+	if remaining <= 0 {
+		return items, nil
+	}
+
+	reviewedIDs, err := reviewsCollection.Distinct(ctx, "word_id", bson.M{"user_openid": openID})
+	if err != nil {
+		return nil, err
+	}
+	cursor, err = wordsCollection.Find(ctx, bson.M{
+		"book_id": bson.M{"$in": bookIDs},
+		"_id":     bson.M{"$nin": reviewedIDs},
+	}, options.Find().SetSort(bson.D{{Key: "unit_id", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(remaining)))
+	if err != nil {
+		return nil, err
+	}
+	var newWords []models.Word
+	if err := cursor.All(ctx, &newWords); err != nil {
+		return nil, err
+	}
+	for _, word := range newWords {
+		items = append(items, models.ReviewItem{Word: word, IsNew: true})
+	}
+
+	return items, nil
+}
+
+// RecordReview 记录一次复习结果并重新安排复习时间
+func (s *ReviewService) RecordReview(openID, wordID string, quality int) (*models.WordReview, error) {
+	wordObjectID, err := primitive.ObjectIDFromHex(wordID)
+	if err != nil {
+		return nil, ErrInvalidWordID
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var word models.Word
+	if err := GetCollection("words").FindOne(ctx, bson.M{"_id": wordObjectID}).Decode(&word); err != nil {
+		return nil, err
+	}
+
+	hasPermission, _, err := CheckUserBookPermission(openID, word.BookID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, ErrBookNotUnlocked
+	}
+
+	review, err := s.recordWordReview(openID, word, quality)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := GetMistakeService().RecordResult(openID, word, quality >= passingQuality, models.MistakeSourceReview); err != nil {
+		log.Printf("[错题本] 记录复习结果失败: word=%s, err=%v", word.ID.Hex(), err)
+	}
+
+	return review, nil
+}
+
+// recordWordReview 更新单词复习状态（调用方需已校验书籍权限）
+func (s *ReviewService) recordWordReview(openID string, word models.Word, quality int) (*models.WordReview, error) {
+	return s.recordWordReviewAt(openID, word, quality, utils.GetCurrentUTCTime())
+}
+
+// recordWordReviewAt 按指定的复习时间更新单词复习状态（用于离线作答）
+// 复习时间早于已有记录的最近复习时间时不做修改，返回 errStaleReview
+func (s *ReviewService) recordWordReviewAt(openID string, word models.Word, quality int, now time.Time) (*models.WordReview, error) {
+	reviewsCollection := GetCollection("word_reviews")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	wordObjectID := word.ID
+	var review models.WordReview
+	err := reviewsCollection.FindOne(ctx, bson.M{"user_openid": openID, "word_id": wordObjectID}).Decode(&review)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		review = models.WordReview{
+			ID:         primitive.NewObjectID(),
+			UserOpenID: openID,
+			WordID:     wordObjectID,
+			CreatedAt:  now,
+		}
+	}
+
+	if now.Before(review.LastReviewedAt) {
+		return &review, errStaleReview
+	}
+
+	// 单词所属书籍和单元可能被调整，以当前数据为准
+	review.WordName = word.WordName
+	review.BookID = word.BookID
+	review.UnitID = word.UnitID
+	s.scheduleReview(&review, quality, now)
+
+	_, err = reviewsCollection.ReplaceOne(ctx,
+		bson.M{"user_openid": openID, "word_id": wordObjectID},
+		review,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"miniprogram/models"
+	"miniprogram/utils"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -43,96 +46,82 @@ func SearchHandler() gin.HandlerFunc {
 		skip := (req.Page - 1) * req.Limit
 
 		// 根据搜索类型执行不同的搜索逻辑
+		var err error
 		switch strings.ToLower(req.Type) {
 		case "word":
-			searchWords(ctx, req.Query, skip, req.Limit, &response)
+			err = searchWords(ctx, req.Query, skip, req.Limit, &response)
 		case "book":
-			searchBooks(ctx, req.Query, skip, req.Limit, &response)
+			err = searchBooks(ctx, req.Query, skip, req.Limit, &response)
 		case "order":
 			searchOrders(ctx, req.Query, skip, req.Limit, &response, c)
 		case "all":
 			// 搜索所有类型，但限制每种类型的结果数量
-			searchWords(ctx, req.Query, 0, req.Limit/3, &response)
-			searchBooks(ctx, req.Query, 0, req.Limit/3, &response)
+			if err = searchWords(ctx, req.Query, 0, req.Limit/3, &response); err == nil {
+				err = searchBooks(ctx, req.Query, 0, req.Limit/3, &response)
+			}
 			searchOrders(ctx, req.Query, 0, req.Limit/3, &response, c)
 		default:
 			BadRequestResponse(c, "不支持的搜索类型", nil)
 			return
 		}
+		if err != nil {
+			respondSearchError(c, err)
+			return
+		}
 
+		if req.Page == 1 && strings.ToLower(req.Type) != "order" {
+			GetSearchSuggestService().RecordSearchAsync(searchUserOpenID(c), c.ClientIP(), req.Query, strings.ToLower(req.Type))
+		}
 		SuccessResponse(c, "搜索完成", response)
 	}
 }
 
-// searchWords 搜索单词
-func searchWords(ctx context.Context, query string, skip, limit int, response *models.SearchResponse) {
-	collection := GetCollection("words")
-
-	// 构建模糊搜索过滤器 - 支持单词名称和含义的模糊搜索
-	filter := bson.M{
-		"$or": []bson.M{
-			{"word_name": bson.M{"$regex": query, "$options": "i"}},
-			{"word_meaning": bson.M{"$regex": query, "$options": "i"}},
-		},
-	}
-
-	// 设置查询选项
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit))
-
-	// 执行查询
-	cursor, err := collection.Find(ctx, filter, opts)
+// searchWords 搜索单词（单词、音标、释义、义项、例句和关联词），按相关度排序
+func searchWords(ctx context.Context, query string, skip, limit int, response *models.SearchResponse) error {
+	words, highlights, total, truncated, err := GetSearchService().SearchWords(ctx, query, skip, limit)
 	if err != nil {
-		return
-	}
-	defer cursor.Close(ctx)
-
-	// 解析结果
-	var words []models.Word
-	if err := cursor.All(ctx, &words); err == nil {
-		response.Words = words
-
-		// 获取总数量
-		if count, err := collection.CountDocuments(ctx, filter); err == nil {
-			response.Total += count
-		}
+		return err
 	}
+	response.Words = words
+	response.Total += total
+	response.Truncated = response.Truncated || truncated
+	addSearchHighlights(response, highlights)
+	return nil
 }
 
-// searchBooks 搜索课本
-func searchBooks(ctx context.Context, query string, skip, limit int, response *models.SearchResponse) {
-	collection := GetCollection("books")
-
-	// 构建模糊搜索过滤器 - 支持书名、版本、描述、作者、出版社的模糊搜索
-	filter := bson.M{
-		"$or": []bson.M{
-			{"book_name": bson.M{"$regex": query, "$options": "i"}},
-			{"book_version": bson.M{"$regex": query, "$options": "i"}},
-			{"description": bson.M{"$regex": query, "$options": "i"}},
-			{"author": bson.M{"$regex": query, "$options": "i"}},
-			{"publisher": bson.M{"$regex": query, "$options": "i"}},
-		},
+// searchBooks 搜索课本（书名、版本、作者、出版社、描述），按相关度排序
+func searchBooks(ctx context.Context, query string, skip, limit int, response *models.SearchResponse) error {
+	books, highlights, total, truncated, err := GetSearchService().SearchBooks(ctx, query, skip, limit)
+	if err != nil {
+		return err
 	}
+	response.Books = books
+	response.Total += total
+	response.Truncated = response.Truncated || truncated
+	addSearchHighlights(response, highlights)
+	return nil
+}
 
-	// 设置查询选项
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit))
-
-	// 执行查询
-	cursor, err := collection.Find(ctx, filter, opts)
-	if err != nil {
+// addSearchHighlights 合并高亮结果
+func addSearchHighlights(response *models.SearchResponse, highlights map[string][]models.SearchHighlight) {
+	if len(highlights) == 0 {
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析结果
-	var books []models.Book
-	if err := cursor.All(ctx, &books); err == nil {
-		response.Books = books
+	if response.Highlights == nil {
+		response.Highlights = make(map[string][]models.SearchHighlight)
+	}
+	for id, items := range highlights {
+		response.Highlights[id] = items
+	}
+}
 
-		// 获取总数量
-		if count, err := collection.CountDocuments(ctx, filter); err == nil {
-			response.Total += count
-		}
+// respondSearchError 统一处理搜索错误
+func respondSearchError(c *gin.Context, err error) {
+	if errors.Is(err, ErrEmptySearchQuery) {
+		BadRequestResponse(c, err.Error(), nil)
+		return
 	}
+	InternalServerErrorResponse(c, "搜索失败", err)
 }
 
 // searchOrders 搜索订单
@@ -143,8 +132,12 @@ func searchOrders(ctx context.Context, query string, skip, limit int, response *
 	// 注意：这里的 user_id 实际上是微信的 openID，不是 MongoDB 的 _id
 	userID := c.Param("user_id")
 	if userID == "" {
-		// 如果没有用户ID，尝试从查询参数获取
-		userID = c.Query("user_id")
+		// 如果没有用户ID，尝试从查询参数获取（可能是安全用户标识符）
+		openID, err := utils.ResolveUserID(c.Query("user_id"))
+		if err != nil {
+			return
+		}
+		userID = openID
 	}
 
 	// 构建基础过滤器
@@ -156,8 +149,9 @@ func searchOrders(ctx context.Context, query string, skip, limit int, response *
 	// 首先搜索商品名称匹配的订单
 	// 这需要通过订单中的商品ID来查找商品名称
 	productsCollection := GetCollection("products")
+	// 关键词按字面匹配，转义正则特殊字符
 	productFilter := bson.M{
-		"name": bson.M{"$regex": query, "$options": "i"},
+		"name": bson.M{"$regex": regexp.QuoteMeta(strings.TrimSpace(query)), "$options": "i"},
 	}
 
 	productCursor, err := productsCollection.Find(ctx, productFilter)
@@ -237,8 +231,14 @@ func SearchWordsHandler() gin.HandlerFunc {
 		skip := (page - 1) * limit
 
 		// 搜索单词
-		searchWords(ctx, query, skip, limit, &response)
+		if err := searchWords(ctx, query, skip, limit, &response); err != nil {
+			respondSearchError(c, err)
+			return
+		}
 
+		if page == 1 {
+			GetSearchSuggestService().RecordSearchAsync(searchUserOpenID(c), c.ClientIP(), query, "word")
+		}
 		SuccessResponse(c, "单词搜索完成", response)
 	}
 }
@@ -276,8 +276,14 @@ func SearchBooksHandler() gin.HandlerFunc {
 		skip := (page - 1) * limit
 
 		// 搜索课本
-		searchBooks(ctx, query, skip, limit, &response)
+		if err := searchBooks(ctx, query, skip, limit, &response); err != nil {
+			respondSearchError(c, err)
+			return
+		}
 
+		if page == 1 {
+			GetSearchSuggestService().RecordSearchAsync(searchUserOpenID(c), c.ClientIP(), query, "book")
+		}
 		SuccessResponse(c, "课本搜索完成", response)
 	}
 }
@@ -320,3 +326,16 @@ func SearchOrdersHandler() gin.HandlerFunc {
 		SuccessResponse(c, "订单搜索完成", response)
 	}
 }
+
+// ReindexSearchHandler 管理员重建单词和课本搜索键处理器（升级后或直接修改数据库后执行）
+func ReindexSearchHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := GetSearchService().Reindex()
+		if err != nil {
+			InternalServerErrorResponse(c, "重建搜索索引失败", err)
+			return
+		}
+
+		SuccessResponse(c, "搜索索引重建完成", result)
+	}
+}
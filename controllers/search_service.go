@@ -0,0 +1,425 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 搜索服务层 =====
+
+// searchCandidateLimit 单次搜索最多参与排序的候选文档数
+const searchCandidateLimit = 500
+
+// searchReindexBatchSize 重建搜索键时每批写入的文档数
+const searchReindexBatchSize = 500
+
+// searchKeysVersion 搜索键生成规则的版本（2 起包含拼音搜索键），修改规则后递增，启动时自动重建旧版本文档的搜索键
+const searchKeysVersion = 2
+
+// ErrEmptySearchQuery 搜索关键词中没有可搜索的文字
+var ErrEmptySearchQuery = errors.New("搜索关键词不能为空")
+
+// SearchService 单词、课本搜索服务
+// 搜索键在写入时生成并建立索引，查询不拼接用户输入的正则表达式，候选结果在服务端计算相关度和高亮
+type SearchService struct{}
+
+// GetSearchService 获取搜索服务实例
+func GetSearchService() *SearchService {
+	return &SearchService{}
+}
+
+// searchField 参与搜索的字段
+type searchField struct {
+	path   string // 字段路径
+	text   string
+	weight int  // 相关度权重
+	name   bool // 名称类字段（按词前缀匹配）
+	pinyin bool // 中文内容可按拼音、拼音首字母搜索
+}
+
+// wordSearchFields 单词参与搜索的字段：单词、音标、释义、义项、例句和关联词，中文释义支持拼音搜索
+func wordSearchFields(word *models.Word) []searchField {
+	fields := []searchField{
+		{"word_name", word.WordName, 100, true, false},
+		{"word_meaning", word.WordMeaning, 50, false, true},
+		// 音标中的音节分隔点不参与匹配
+		{"phonetic", strings.ReplaceAll(word.Phonetic, ".", ""), 40, true, false},
+		{"phonetic_uk", strings.ReplaceAll(word.PhoneticUK, ".", ""), 40, true, false},
+		{"phonetic_us", strings.ReplaceAll(word.PhoneticUS, ".", ""), 40, true, false},
+	}
+	for i, sense := range word.Senses {
+		prefix := "senses." + strconv.Itoa(i)
+		fields = append(fields,
+			searchField{prefix + ".definition", sense.Definition, 30, false, false},
+			searchField{prefix + ".definition_en", sense.DefinitionEn, 30, false, false},
+		)
+		for j, example := range sense.Examples {
+			examplePrefix := prefix + ".examples." + strconv.Itoa(j)
+			fields = append(fields,
+				searchField{examplePrefix + ".sentence", example.Sentence, 10, false, false},
+				searchField{examplePrefix + ".translation", example.Translation, 10, false, false},
+			)
+		}
+	}
+	for i, relation := range word.Relations {
+		fields = append(fields, searchField{"relations." + strconv.Itoa(i) + ".word_name", relation.WordName, 20, true, false})
+	}
+	return fields
+}
+
+// bookSearchFields 课本参与搜索的字段，书名支持拼音搜索
+func bookSearchFields(book *models.Book) []searchField {
+	return []searchField{
+		{"book_name", book.BookName, 100, true, true},
+		{"book_version", book.BookVersion, 40, true, false},
+		{"author", book.Author, 30, true, false},
+		{"publisher", book.Publisher, 20, false, false},
+		{"description", book.Description, 10, false, false},
+	}
+}
+
+// buildSearchKeys 根据字段生成搜索键
+func buildSearchKeys(fields []searchField) []string {
+	builder := utils.NewSearchKeyBuilder()
+	for _, field := range fields {
+		if field.name {
+			builder.AddName(field.text)
+		} else {
+			builder.AddText(field.text)
+		}
+		if field.pinyin {
+			builder.AddPinyin(field.text)
+		}
+	}
+	return builder.Keys()
+}
+
+// wordSearchKeys 生成单词的搜索键
+func wordSearchKeys(word *models.Word) []string {
+	return buildSearchKeys(wordSearchFields(word))
+}
+
+// bookSearchKeys 生成课本的搜索键
+func bookSearchKeys(book *models.Book) []string {
+	return buildSearchKeys(bookSearchFields(book))
+}
+
+// refreshWordSearchKeys 单词内容变化后重新生成搜索键
+func refreshWordSearchKeys(ctx context.Context, word *models.Word) error {
+	word.SearchKeys = wordSearchKeys(word)
+	word.SearchKeysVer = searchKeysVersion
+	if _, err := GetCollection("words").UpdateOne(ctx, bson.M{"_id": word.ID},
+		bson.M{"$set": bson.M{"search_keys": word.SearchKeys, "search_keys_version": searchKeysVersion}}); err != nil {
+		return fmt.Errorf("更新单词搜索键失败: %w", err)
+	}
+	return nil
+}
+
+// refreshBookSearchKeys 课本内容变化后重新生成搜索键
+func refreshBookSearchKeys(ctx context.Context, book *models.Book) error {
+	book.SearchKeys = bookSearchKeys(book)
+	book.SearchKeysVer = searchKeysVersion
+	if _, err := GetCollection("books").UpdateOne(ctx, bson.M{"_id": book.ID},
+		bson.M{"$set": bson.M{"search_keys": book.SearchKeys, "search_keys_version": searchKeysVersion}}); err != nil {
+		return fmt.Errorf("更新课本搜索键失败: %w", err)
+	}
+	return nil
+}
+
+// searchQuery 解析后的搜索词
+type searchQuery struct {
+	normalized string
+	terms      utils.SearchTerms
+	keys       []string
+}
+
+// parseSearchQuery 规范化并切分搜索词
+func parseSearchQuery(query string) (*searchQuery, error) {
+	terms := utils.TokenizeSearchText(query)
+	if terms.Empty() {
+		return nil, ErrEmptySearchQuery
+	}
+	return &searchQuery{
+		normalized: utils.NormalizeSearchText(query),
+		terms:      terms,
+		keys:       utils.SearchQueryKeys(terms),
+	}, nil
+}
+
+// searchHit 一个候选结果的相关度和高亮
+type searchHit struct {
+	index      int
+	score      int
+	name       string
+	highlights []models.SearchHighlight
+}
+
+// scoreSearchFields 计算相关度，所有查询词都必须在某个字段中命中，否则返回 false
+// 字段得分 = 权重 × 命中词比例，完全相同再加两倍权重，以查询开头再加一倍权重，通过拼音命中时减半
+func scoreSearchFields(fields []searchField, query *searchQuery) (int, []models.SearchHighlight, bool) {
+	termCount := len(query.terms.Words) + len(query.terms.CJK)
+	covered := make([]bool, termCount)
+	score := 0
+	highlights := []models.SearchHighlight{}
+
+	for _, field := range fields {
+		match := utils.MatchSearchText(field.text, query.terms, query.normalized, field.name, field.pinyin)
+		if len(match.Terms) == 0 {
+			continue
+		}
+		for _, term := range match.Terms {
+			covered[term] = true
+		}
+		fieldScore := field.weight * len(match.Terms) / termCount
+		if match.Exact {
+			fieldScore += field.weight * 2
+		} else if match.Prefix {
+			fieldScore += field.weight
+		}
+		if match.Pinyin {
+			// 拼音命中的相关度低于直接命中
+			fieldScore /= 2
+		}
+		score += fieldScore
+		highlights = append(highlights, models.SearchHighlight{Field: field.path, Snippet: match.Highlight(field.text)})
+	}
+
+	for _, ok := range covered {
+		if !ok {
+			return 0, nil, false
+		}
+	}
+	return score, highlights, true
+}
+
+// rankSearchHits 按相关度排序，相同时名称短的在前
+func rankSearchHits(hits []searchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		if len(hits[i].name) != len(hits[j].name) {
+			return len(hits[i].name) < len(hits[j].name)
+		}
+		return hits[i].name < hits[j].name
+	})
+}
+
+// findSearchCandidates 按搜索键查询候选文档（按 _id 排序保证分页稳定，最多多取一条，用于判断是否超过候选上限）
+func findSearchCandidates(ctx context.Context, collectionName string, query *searchQuery, results interface{}) error {
+	filter := bson.M{"search_keys": bson.M{"$all": query.keys}}
+	cursor, err := GetCollection(collectionName).Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(searchCandidateLimit+1).
+		SetProjection(bson.M{"search_keys": 0}))
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+// pageSearchHits 截取分页范围
+func pageSearchHits(hits []searchHit, skip, limit int) []searchHit {
+	if skip >= len(hits) {
+		return nil
+	}
+	end := skip + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return hits[skip:end]
+}
+
+// SearchWords 搜索单词，按相关度排序并返回命中字段的高亮
+// 匹配的文档超过候选上限时只对前 searchCandidateLimit 条排序，total 为其中的命中数，truncated 为 true
+func (s *SearchService) SearchWords(ctx context.Context, rawQuery string, skip, limit int) ([]models.Word, map[string][]models.SearchHighlight, int64, bool, error) {
+	query, err := parseSearchQuery(rawQuery)
+	if err != nil {
+		return nil, nil, 0, false, err
+	}
+
+	var candidates []models.Word
+	if err := findSearchCandidates(ctx, "words", query, &candidates); err != nil {
+		return nil, nil, 0, false, err
+	}
+	truncated := len(candidates) > searchCandidateLimit
+	if truncated {
+		candidates = candidates[:searchCandidateLimit]
+	}
+
+	hits := []searchHit{}
+	for i := range candidates {
+		score, highlights, ok := scoreSearchFields(wordSearchFields(&candidates[i]), query)
+		if ok {
+			hits = append(hits, searchHit{index: i, score: score, name: candidates[i].WordName, highlights: highlights})
+		}
+	}
+	rankSearchHits(hits)
+
+	words := []models.Word{}
+	highlights := make(map[string][]models.SearchHighlight)
+	for _, hit := range pageSearchHits(hits, skip, limit) {
+		word := candidates[hit.index]
+		words = append(words, word)
+		highlights[word.ID.Hex()] = hit.highlights
+	}
+	return words, highlights, int64(len(hits)), truncated, nil
+}
+
+// SearchBooks 搜索课本，按相关度排序并返回命中字段的高亮
+// 匹配的文档超过候选上限时只对前 searchCandidateLimit 条排序，total 为其中的命中数，truncated 为 true
+func (s *SearchService) SearchBooks(ctx context.Context, rawQuery string, skip, limit int) ([]models.Book, map[string][]models.SearchHighlight, int64, bool, error) {
+	query, err := parseSearchQuery(rawQuery)
+	if err != nil {
+		return nil, nil, 0, false, err
+	}
+
+	var candidates []models.Book
+	if err := findSearchCandidates(ctx, "books", query, &candidates); err != nil {
+		return nil, nil, 0, false, err
+	}
+	truncated := len(candidates) > searchCandidateLimit
+	if truncated {
+		candidates = candidates[:searchCandidateLimit]
+	}
+
+	hits := []searchHit{}
+	for i := range candidates {
+		score, highlights, ok := scoreSearchFields(bookSearchFields(&candidates[i]), query)
+		if ok {
+			hits = append(hits, searchHit{index: i, score: score, name: candidates[i].BookName, highlights: highlights})
+		}
+	}
+	rankSearchHits(hits)
+
+	books := []models.Book{}
+	highlights := make(map[string][]models.SearchHighlight)
+	for _, hit := range pageSearchHits(hits, skip, limit) {
+		book := candidates[hit.index]
+		books = append(books, book)
+		highlights[book.ID.Hex()] = hit.highlights
+	}
+	return books, highlights, int64(len(hits)), truncated, nil
+}
+
+// Reindex 为全部单词和课本重新生成搜索键并确保索引存在（升级或批量修改数据后执行）
+func (s *SearchService) Reindex() (*models.SearchReindexResult, error) {
+	return s.reindex(bson.M{})
+}
+
+// BackfillSearchKeys 为没有搜索键或搜索键规则版本较旧的单词和课本重新生成搜索键（启动时执行，已是当前版本的文档不处理）
+func (s *SearchService) BackfillSearchKeys() (*models.SearchReindexResult, error) {
+	return s.reindex(bson.M{"search_keys_version": bson.M{"$ne": searchKeysVersion}})
+}
+
+// StartSearchKeyBackfill 后台补全缺少搜索键或搜索键版本较旧的文档，升级后不需要手动重建搜索索引
+func StartSearchKeyBackfill() {
+	go func() {
+		result, err := GetSearchService().BackfillSearchKeys()
+		if err != nil {
+			log.Printf("[搜索] 补全搜索键失败: %v", err)
+			return
+		}
+		if result.Words > 0 || result.Books > 0 {
+			log.Printf("[搜索] 补全搜索键: 单词=%d, 课本=%d", result.Words, result.Books)
+		}
+	}()
+}
+
+// reindex 为符合条件的单词和课本生成搜索键并确保索引存在
+func (s *SearchService) reindex(filter bson.M) (*models.SearchReindexResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	result := &models.SearchReindexResult{}
+	for _, name := range []string{"words", "books"} {
+		if _, err := GetCollection(name).Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "search_keys", Value: 1}}},
+			// 启动时按版本查找需要重建搜索键的文档
+			{Keys: bson.D{{Key: "search_keys_version", Value: 1}}},
+		}); err != nil {
+			return nil, fmt.Errorf("创建搜索索引失败: %w", err)
+		}
+	}
+
+	words, err := reindexCollection(ctx, "words", filter, func(raw bson.Raw) (primitive.ObjectID, []string, error) {
+		var word models.Word
+		if err := bson.Unmarshal(raw, &word); err != nil {
+			return primitive.NilObjectID, nil, err
+		}
+		return word.ID, wordSearchKeys(&word), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Words = words
+
+	books, err := reindexCollection(ctx, "books", filter, func(raw bson.Raw) (primitive.ObjectID, []string, error) {
+		var book models.Book
+		if err := bson.Unmarshal(raw, &book); err != nil {
+			return primitive.NilObjectID, nil, err
+		}
+		return book.ID, bookSearchKeys(&book), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Books = books
+	return result, nil
+}
+
+// reindexCollection 遍历集合中符合条件的文档，分批写入搜索键
+func reindexCollection(ctx context.Context, name string, filter bson.M, keysOf func(bson.Raw) (primitive.ObjectID, []string, error)) (int, error) {
+	collection := GetCollection(name)
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"search_keys": 0}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	writes := []mongo.WriteModel{}
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("写入搜索键失败: %w", err)
+		}
+		writes = writes[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		id, keys, err := keysOf(cursor.Current)
+		if err != nil {
+			return count, err
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$set": bson.M{"search_keys": keys, "search_keys_version": searchKeysVersion}}))
+		count++
+		if len(writes) >= searchReindexBatchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/middlewares"
+	"miniprogram/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondSearchSuggestError 统一处理搜索建议、历史和搜索词规则相关错误
+func respondSearchSuggestError(c *gin.Context, message, notFoundMessage string, err error) {
+	switch {
+	case errors.Is(err, ErrEmptySearchQuery), errors.Is(err, ErrInvalidSearchRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrSearchRuleExists):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, notFoundMessage, err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// searchUserOpenID 公开搜索接口中识别已登录用户（携带有效令牌时），用于记录搜索历史；未登录返回空字符串
+func searchUserOpenID(c *gin.Context) string {
+	if c.GetHeader("Authorization") == "" {
+		return ""
+	}
+	tokenString, err := middlewares.ExtractBearerTokenFromGin(c)
+	if err != nil {
+		return ""
+	}
+	claims, err := middlewares.ValidateToken(tokenString)
+	if err != nil {
+		return ""
+	}
+	return claims.UserId
+}
+
+// queryLimit 解析 limit 查询参数，超出范围时使用默认值
+func queryLimit(c *gin.Context, defaultLimit, maxLimit int) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		return defaultLimit
+	}
+	return limit
+}
+
+// SearchSuggestHandler 搜索建议处理器（输入时的前缀补全，q 为输入内容）
+func SearchSuggestHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Query("q")
+		if query == "" {
+			BadRequestResponse(c, "搜索关键词不能为空", nil)
+			return
+		}
+
+		response, err := GetSearchSuggestService().Suggest(query, queryLimit(c, 10, 20))
+		if err != nil {
+			respondSearchSuggestError(c, "获取搜索建议失败", "", err)
+			return
+		}
+
+		SuccessResponse(c, "获取搜索建议成功", response)
+	}
+}
+
+// GetHotSearchQueriesHandler 热门搜索词处理器（window=day|week|month）
+func GetHotSearchQueriesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response, err := GetSearchSuggestService().HotQueries(c.DefaultQuery("window", "week"), queryLimit(c, 10, 50))
+		if err != nil {
+			respondSearchSuggestError(c, "获取热门搜索失败", "", err)
+			return
+		}
+
+		SuccessResponse(c, "获取热门搜索成功", response)
+	}
+}
+
+// GetTrendingSearchQueriesHandler 飙升搜索词处理器（window=day|week）
+func GetTrendingSearchQueriesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response, err := GetSearchSuggestService().TrendingQueries(c.DefaultQuery("window", "day"), queryLimit(c, 10, 50))
+		if err != nil {
+			respondSearchSuggestError(c, "获取飙升搜索失败", "", err)
+			return
+		}
+
+		SuccessResponse(c, "获取飙升搜索成功", response)
+	}
+}
+
+// GetSearchHistoryHandler 获取本人搜索历史处理器
+func GetSearchHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		history, err := GetSearchSuggestService().GetHistory(openID, queryLimit(c, 20, maxSearchHistoryPerUser))
+		if err != nil {
+			respondSearchSuggestError(c, "获取搜索历史失败", "", err)
+			return
+		}
+
+		SuccessResponse(c, "获取搜索历史成功", gin.H{"history": history})
+	}
+}
+
+// ClearSearchHistoryHandler 清空本人搜索历史处理器
+func ClearSearchHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		deleted, err := GetSearchSuggestService().ClearHistory(openID)
+		if err != nil {
+			respondSearchSuggestError(c, "清空搜索历史失败", "", err)
+			return
+		}
+
+		SuccessResponse(c, "搜索历史已清空", gin.H{"deleted": deleted})
+	}
+}
+
+// DeleteSearchHistoryHandler 删除本人的一条搜索历史处理器
+func DeleteSearchHistoryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		if err := GetSearchSuggestService().DeleteHistory(openID, c.Param("history_id")); err != nil {
+			respondSearchSuggestError(c, "删除搜索历史失败", "搜索历史不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "搜索历史已删除", nil)
+	}
+}
+
+// GetSearchRulesHandler 管理员获取屏蔽词和置顶建议处理器（可选 type=blocked|pinned）
+func GetSearchRulesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules, err := GetSearchSuggestService().ListRules(c.Query("type"))
+		if err != nil {
+			respondSearchSuggestError(c, "获取搜索词规则失败", "", err)
+			return
+		}
+
+		SuccessResponse(c, "获取搜索词规则成功", gin.H{"rules": rules})
+	}
+}
+
+// CreateSearchRuleHandler 管理员添加屏蔽词或置顶建议处理器
+func CreateSearchRuleHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminOpenID, _ := c.Get("user_openid")
+
+		var req models.CreateSearchRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		rule, err := GetSearchSuggestService().CreateRule(adminOpenID.(string), req)
+		if err != nil {
+			respondSearchSuggestError(c, "添加搜索词规则失败", "", err)
+			return
+		}
+
+		CreatedResponse(c, "搜索词规则已添加", rule)
+	}
+}
+
+// DeleteSearchRuleHandler 管理员删除屏蔽词或置顶建议处理器
+func DeleteSearchRuleHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := GetSearchSuggestService().DeleteRule(c.Param("rule_id")); err != nil {
+			respondSearchSuggestError(c, "删除搜索词规则失败", "搜索词规则不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "搜索词规则已删除", nil)
+	}
+}
@@ -0,0 +1,759 @@
+package controllers
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 搜索建议、历史与热门搜索服务层 =====
+
+const (
+	searchHistoryCollection    = "search_history"
+	searchQueryStatsCollection = "search_query_stats"
+	searchRulesCollection      = "search_rules"
+
+	// maxSearchHistoryPerUser 每个用户保留的搜索历史条数
+	maxSearchHistoryPerUser = 50
+	// maxRecordedQueryLength 记录的关键词最大长度（字符数），超出部分截断
+	maxRecordedQueryLength = 50
+	// searchSuggestCandidateLimit 每类建议最多参与排序的候选文档数
+	searchSuggestCandidateLimit = 200
+	// searchSuggestQueryWindow 搜索建议中热门搜索词的统计窗口
+	searchSuggestQueryWindow = 7 * 24 * time.Hour
+	// searchTrendingMinCount 进入飙升榜的最少搜索次数
+	searchTrendingMinCount = 3
+	// searchRecordQueueSize 待记录搜索的队列长度，队列满时丢弃（搜索统计允许少量误差）
+	searchRecordQueueSize = 1000
+	// searchRecordWorkers 记录搜索的后台协程数
+	searchRecordWorkers = 2
+	// searchStatsDedupWindow 同一客户端相同的关键词在窗口内只计入一次搜索词统计
+	searchStatsDedupWindow = time.Hour
+	// maxSearchStatsDedupEntries 去重表最多保存的条目数，超出时淘汰最早的条目
+	maxSearchStatsDedupEntries = 100000
+)
+
+var (
+	// ErrInvalidSearchRequest 搜索请求参数无效
+	ErrInvalidSearchRequest = errors.New("搜索请求参数无效")
+	// ErrSearchRuleExists 搜索词规则已存在
+	ErrSearchRuleExists = errors.New("相同的搜索词规则已存在")
+)
+
+// searchHotWindows 热门搜索的统计窗口
+var searchHotWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+// searchTrendingWindows 飙升搜索的统计窗口（与上一个相同长度的窗口对比）
+var searchTrendingWindows = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// SearchSuggestService 搜索建议、搜索历史、热门和飙升搜索词服务
+// 单词、课本搜索的第一页会记录到用户的搜索历史（已登录时）和按小时汇总的搜索词统计，
+// 管理员可以屏蔽搜索词或置顶搜索建议
+type SearchSuggestService struct{}
+
+// GetSearchSuggestService 获取搜索建议服务实例
+func GetSearchSuggestService() *SearchSuggestService {
+	return &SearchSuggestService{}
+}
+
+// normalizeRecordedQuery 规范化要记录的关键词：合并空白并截断，没有可搜索内容时返回空字符串
+func normalizeRecordedQuery(query string) string {
+	if utils.TokenizeSearchText(query).Empty() {
+		return ""
+	}
+	normalized := []rune(strings.Join(strings.Fields(utils.NormalizeSearchText(query)), " "))
+	if len(normalized) > maxRecordedQueryLength {
+		normalized = normalized[:maxRecordedQueryLength]
+	}
+	return strings.TrimSpace(string(normalized))
+}
+
+// searchRecord 待记录的一次搜索
+type searchRecord struct {
+	openID     string
+	query      string
+	searchType string
+	countStats bool // 是否计入搜索词统计
+}
+
+var (
+	searchRecordQueue = make(chan searchRecord, searchRecordQueueSize)
+	searchRecordOnce  sync.Once
+	searchStatsDedup  = newSearchDedup(searchStatsDedupWindow, maxSearchStatsDedupEntries)
+)
+
+// searchDedup 按键去重的时间窗口表（内存中，多实例部署时各实例分别去重）
+type searchDedup struct {
+	mu         sync.Mutex
+	window     time.Duration
+	maxEntries int
+	order      *list.List               // 按记录时间从旧到新排列的条目
+	seen       map[string]*list.Element // 键对应的条目
+}
+
+// searchDedupEntry 去重表条目
+type searchDedupEntry struct {
+	key string
+	at  time.Time
+}
+
+// newSearchDedup 创建去重表
+func newSearchDedup(window time.Duration, maxEntries int) *searchDedup {
+	return &searchDedup{window: window, maxEntries: maxEntries, order: list.New(), seen: make(map[string]*list.Element)}
+}
+
+// allow 键在窗口内第一次出现时返回 true；先清理过期条目，表仍然满时淘汰最早的条目，
+// 因此大量新客户端只会让较早的客户端可能被重复计入，不会停止统计
+func (d *searchDedup) allow(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if element, ok := d.seen[key]; ok {
+		if now.Sub(element.Value.(*searchDedupEntry).at) < d.window {
+			return false
+		}
+		d.remove(element)
+	}
+	for front := d.order.Front(); front != nil && now.Sub(front.Value.(*searchDedupEntry).at) >= d.window; front = d.order.Front() {
+		d.remove(front)
+	}
+	for d.order.Len() >= d.maxEntries {
+		d.remove(d.order.Front())
+	}
+	d.seen[key] = d.order.PushBack(&searchDedupEntry{key: key, at: now})
+	return true
+}
+
+// remove 删除条目
+func (d *searchDedup) remove(element *list.Element) {
+	delete(d.seen, element.Value.(*searchDedupEntry).key)
+	d.order.Remove(element)
+}
+
+// RecordSearch 记录一次搜索：countStats 为 true 时累加搜索词统计，openID 不为空时更新该用户的搜索历史
+func (s *SearchSuggestService) RecordSearch(openID, query, searchType string, countStats bool) error {
+	normalized := normalizeRecordedQuery(query)
+	if normalized == "" {
+		return nil
+	}
+	display := []rune(strings.Join(strings.Fields(query), " "))
+	if len(display) > maxRecordedQueryLength {
+		display = display[:maxRecordedQueryLength]
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	now := utils.GetCurrentUTCTime()
+
+	if countStats {
+		if _, err := GetCollection(searchQueryStatsCollection).UpdateOne(ctx,
+			bson.M{"query": normalized, "bucket": now.Truncate(time.Hour)},
+			bson.M{"$inc": bson.M{"count": 1}},
+			options.Update().SetUpsert(true),
+		); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("更新搜索词统计失败: %w", err)
+		}
+	}
+
+	if openID == "" {
+		return nil
+	}
+	collection := GetCollection(searchHistoryCollection)
+	if _, err := collection.UpdateOne(ctx,
+		bson.M{"user_openid": openID, "normalized": normalized},
+		bson.M{
+			"$set":         bson.M{"query": string(display), "type": searchType, "last_searched_at": now},
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("更新搜索历史失败: %w", err)
+	}
+
+	// 超出保留条数的旧记录直接删除
+	cursor, err := collection.Find(ctx, bson.M{"user_openid": openID}, options.Find().
+		SetSort(bson.D{{Key: "last_searched_at", Value: -1}}).
+		SetSkip(maxSearchHistoryPerUser).
+		SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return fmt.Errorf("查询搜索历史失败: %w", err)
+	}
+	var stale []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &stale); err != nil {
+		return fmt.Errorf("查询搜索历史失败: %w", err)
+	}
+	if len(stale) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(stale))
+		for _, item := range stale {
+			ids = append(ids, item.ID)
+		}
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return fmt.Errorf("清理搜索历史失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecordSearchAsync 交给后台协程记录搜索，不影响搜索响应
+// 同一客户端（已登录按用户，未登录按 IP）相同的关键词每小时只计入一次搜索词统计；未登录且不计入统计时直接忽略
+func (s *SearchSuggestService) RecordSearchAsync(openID, clientIP, query, searchType string) {
+	normalized := normalizeRecordedQuery(query)
+	if normalized == "" {
+		return
+	}
+	client := "user:" + openID
+	if openID == "" {
+		client = "ip:" + clientIP
+	}
+	countStats := searchStatsDedup.allow(client+"\n"+normalized, time.Now())
+	if !countStats && openID == "" {
+		return
+	}
+
+	searchRecordOnce.Do(func() {
+		for i := 0; i < searchRecordWorkers; i++ {
+			go s.runSearchRecordWorker()
+		}
+	})
+	select {
+	case searchRecordQueue <- searchRecord{openID: openID, query: query, searchType: searchType, countStats: countStats}:
+	default:
+		// 队列已满时丢弃，避免搜索高峰时堆积协程
+	}
+}
+
+// runSearchRecordWorker 依次处理队列中的搜索记录
+func (s *SearchSuggestService) runSearchRecordWorker() {
+	for record := range searchRecordQueue {
+		if err := s.RecordSearch(record.openID, record.query, record.searchType, record.countStats); err != nil {
+			log.Printf("[搜索] 记录搜索失败: %v", err)
+		}
+	}
+}
+
+// ===== 搜索历史 =====
+
+// GetHistory 获取用户最近的搜索历史
+func (s *SearchSuggestService) GetHistory(openID string, limit int) ([]models.SearchHistory, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection(searchHistoryCollection).Find(ctx, bson.M{"user_openid": openID}, options.Find().
+		SetSort(bson.D{{Key: "last_searched_at", Value: -1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	history := []models.SearchHistory{}
+	if err := cursor.All(ctx, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// DeleteHistory 删除一条搜索历史
+func (s *SearchSuggestService) DeleteHistory(openID, historyIDHex string) error {
+	historyID, err := primitive.ObjectIDFromHex(historyIDHex)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的搜索历史ID", ErrInvalidSearchRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection(searchHistoryCollection).DeleteOne(ctx, bson.M{"_id": historyID, "user_openid": openID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ClearHistory 清空用户的搜索历史，返回删除的条数
+func (s *SearchSuggestService) ClearHistory(openID string) (int64, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection(searchHistoryCollection).DeleteMany(ctx, bson.M{"user_openid": openID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// ===== 屏蔽词与置顶建议 =====
+
+// searchRuleSet 当前生效的搜索词规则
+type searchRuleSet struct {
+	blocked []string
+	pinned  []models.SearchRule
+}
+
+// isBlocked 关键词是否包含屏蔽词
+func (r *searchRuleSet) isBlocked(normalized string) bool {
+	for _, blocked := range r.blocked {
+		if strings.Contains(normalized, blocked) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSearchRules 读取全部搜索词规则，置顶建议按 position、创建时间排序
+func loadSearchRules(ctx context.Context) (*searchRuleSet, error) {
+	cursor, err := GetCollection(searchRulesCollection).Find(ctx, bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "position", Value: 1}, {Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.SearchRule
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	set := &searchRuleSet{}
+	for _, rule := range rules {
+		switch rule.RuleType {
+		case models.SearchRuleBlocked:
+			set.blocked = append(set.blocked, rule.Normalized)
+		case models.SearchRulePinned:
+			set.pinned = append(set.pinned, rule)
+		}
+	}
+	return set, nil
+}
+
+// ListRules 获取搜索词规则，ruleType 为空时返回全部
+func (s *SearchSuggestService) ListRules(ruleType string) ([]models.SearchRule, error) {
+	filter := bson.M{}
+	if ruleType != "" {
+		if ruleType != models.SearchRuleBlocked && ruleType != models.SearchRulePinned {
+			return nil, fmt.Errorf("%w: 规则类型只能是 blocked 或 pinned", ErrInvalidSearchRequest)
+		}
+		filter["rule_type"] = ruleType
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection(searchRulesCollection).Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "rule_type", Value: 1}, {Key: "position", Value: 1}, {Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	rules := []models.SearchRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// CreateRule 创建屏蔽词或置顶建议
+func (s *SearchSuggestService) CreateRule(adminOpenID string, req models.CreateSearchRuleRequest) (*models.SearchRule, error) {
+	normalized := normalizeRecordedQuery(req.Query)
+	if normalized == "" {
+		return nil, fmt.Errorf("%w: 搜索词不能只包含符号", ErrInvalidSearchRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	rule := &models.SearchRule{
+		RuleType:        req.RuleType,
+		Query:           strings.Join(strings.Fields(req.Query), " "),
+		Normalized:      normalized,
+		Position:        req.Position,
+		CreatedByOpenID: adminOpenID,
+		CreatedAt:       utils.GetCurrentUTCTime(),
+	}
+	result, err := GetCollection(searchRulesCollection).InsertOne(ctx, rule)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrSearchRuleExists
+		}
+		return nil, err
+	}
+	rule.ID = result.InsertedID.(primitive.ObjectID)
+	return rule, nil
+}
+
+// DeleteRule 删除搜索词规则
+func (s *SearchSuggestService) DeleteRule(ruleIDHex string) error {
+	ruleID, err := primitive.ObjectIDFromHex(ruleIDHex)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的规则ID", ErrInvalidSearchRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection(searchRulesCollection).DeleteOne(ctx, bson.M{"_id": ruleID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ===== 热门与飙升搜索词 =====
+
+// blockedQueryPattern 匹配包含任一屏蔽词的正则表达式（屏蔽词按字面匹配），没有屏蔽词时返回空字符串
+func blockedQueryPattern(blocked []string) string {
+	quoted := make([]string, 0, len(blocked))
+	for _, word := range blocked {
+		if word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	return strings.Join(quoted, "|")
+}
+
+// queryPrefixPattern 匹配以 prefix 开头的搜索词的正则表达式（prefix 按字面匹配）
+func queryPrefixPattern(prefix string) string {
+	return "^" + regexp.QuoteMeta(prefix)
+}
+
+// queryStatsMatch 搜索词统计的查询条件：since 之后、以 prefix 开头（不为空时），
+// 排除包含屏蔽词和 excluded 中的搜索词，使 $limit 之后的结果不会再被过滤
+func queryStatsMatch(since time.Time, prefix string, blocked, excluded []string) bson.M {
+	match := bson.M{"bucket": bson.M{"$gte": since}}
+	condition := bson.M{}
+	if prefix != "" {
+		condition["$regex"] = queryPrefixPattern(prefix)
+	}
+	nin := bson.A{}
+	for _, query := range excluded {
+		nin = append(nin, query)
+	}
+	if pattern := blockedQueryPattern(blocked); pattern != "" {
+		nin = append(nin, primitive.Regex{Pattern: pattern})
+	}
+	if len(nin) > 0 {
+		condition["$nin"] = nin
+	}
+	if len(condition) > 0 {
+		match["query"] = condition
+	}
+	return match
+}
+
+// pinnedQueries 置顶建议的规范化搜索词
+func (r *searchRuleSet) pinnedQueries() []string {
+	queries := make([]string, 0, len(r.pinned))
+	for _, rule := range r.pinned {
+		queries = append(queries, rule.Normalized)
+	}
+	return queries
+}
+
+// aggregateQueryCounts 按条件统计各搜索词的搜索次数（按次数从高到低）
+func aggregateQueryCounts(ctx context.Context, match bson.M, limit int) ([]models.SearchQueryRank, error) {
+	cursor, err := GetCollection(searchQueryStatsCollection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$query", "count": bson.M{"$sum": "$count"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Query string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	ranks := make([]models.SearchQueryRank, 0, len(rows))
+	for _, row := range rows {
+		ranks = append(ranks, models.SearchQueryRank{Query: row.Query, Count: row.Count})
+	}
+	return ranks, nil
+}
+
+// HotQueries 热门搜索词：管理员置顶的在前，其余按统计窗口内的搜索次数排序，过滤屏蔽词
+func (s *SearchSuggestService) HotQueries(window string, limit int) (*models.SearchQueryRankResponse, error) {
+	duration, ok := searchHotWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("%w: window 只能是 day、week 或 month", ErrInvalidSearchRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	rules, err := loadSearchRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	since := utils.GetCurrentUTCTime().Truncate(time.Hour).Add(time.Hour - duration)
+	// 屏蔽词和置顶词在统计查询中排除，取 limit 条即可填满
+	ranks, err := aggregateQueryCounts(ctx, queryStatsMatch(since, "", rules.blocked, rules.pinnedQueries()), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SearchQueryRankResponse{Window: window, Since: since, Queries: mergeHotQueries(rules, ranks, limit)}, nil
+}
+
+// mergeHotQueries 合并置顶建议和统计结果：置顶的在前，去掉重复和包含屏蔽词的搜索词，最多 limit 条
+func mergeHotQueries(rules *searchRuleSet, ranks []models.SearchQueryRank, limit int) []models.SearchQueryRank {
+	queries := []models.SearchQueryRank{}
+	seen := make(map[string]bool)
+	for _, rule := range rules.pinned {
+		if len(queries) < limit && !seen[rule.Normalized] && !rules.isBlocked(rule.Normalized) {
+			seen[rule.Normalized] = true
+			queries = append(queries, models.SearchQueryRank{Query: rule.Query, Pinned: true})
+		}
+	}
+	for _, rank := range ranks {
+		if len(queries) < limit && !seen[rank.Query] && !rules.isBlocked(rank.Query) {
+			seen[rank.Query] = true
+			queries = append(queries, rank)
+		}
+	}
+	return queries
+}
+
+// TrendingQueries 飙升搜索词：对比当前窗口与上一个相同长度窗口的搜索次数
+// 增长倍数 = 当前窗口次数 ÷ (上一窗口次数 + 1)，当前窗口至少搜索 searchTrendingMinCount 次且多于上一窗口才会上榜
+func (s *SearchSuggestService) TrendingQueries(window string, limit int) (*models.SearchQueryRankResponse, error) {
+	duration, ok := searchTrendingWindows[window]
+	if !ok {
+		return nil, fmt.Errorf("%w: window 只能是 day 或 week", ErrInvalidSearchRequest)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	rules, err := loadSearchRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	since := utils.GetCurrentUTCTime().Truncate(time.Hour).Add(time.Hour - duration)
+	previousSince := since.Add(-duration)
+
+	cursor, err := GetCollection(searchQueryStatsCollection).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: queryStatsMatch(previousSince, "", rules.blocked, nil)}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$query",
+			"count": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$bucket", since}}, "$count", 0,
+			}}},
+			"previous_count": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{"$bucket", since}}, "$count", 0,
+			}}},
+		}}},
+		{{Key: "$match", Value: bson.M{
+			"count": bson.M{"$gte": searchTrendingMinCount},
+			"$expr": bson.M{"$gt": bson.A{"$count", "$previous_count"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+		{{Key: "$limit", Value: searchCandidateLimit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Query         string `bson:"_id"`
+		Count         int64  `bson:"count"`
+		PreviousCount int64  `bson:"previous_count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make([]models.SearchQueryRank, 0, len(rows))
+	for _, row := range rows {
+		counts = append(counts, models.SearchQueryRank{Query: row.Query, Count: row.Count, PreviousCount: row.PreviousCount})
+	}
+	return &models.SearchQueryRankResponse{Window: window, Since: since, Queries: rankTrendingQueries(counts, rules, limit)}, nil
+}
+
+// trendingGrowth 飙升榜增长倍数：当前窗口次数 ÷ (上一窗口次数 + 1)
+func trendingGrowth(count, previousCount int64) float64 {
+	return float64(count) / float64(previousCount+1)
+}
+
+// rankTrendingQueries 计算增长倍数并排序（倍数相同时次数多的在前），去掉包含屏蔽词的搜索词，最多 limit 条
+func rankTrendingQueries(rows []models.SearchQueryRank, rules *searchRuleSet, limit int) []models.SearchQueryRank {
+	ranks := []models.SearchQueryRank{}
+	for _, row := range rows {
+		if rules.isBlocked(row.Query) {
+			continue
+		}
+		row.Growth = trendingGrowth(row.Count, row.PreviousCount)
+		ranks = append(ranks, row)
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].Growth != ranks[j].Growth {
+			return ranks[i].Growth > ranks[j].Growth
+		}
+		return ranks[i].Count > ranks[j].Count
+	})
+	if len(ranks) > limit {
+		ranks = ranks[:limit]
+	}
+	return ranks
+}
+
+// ===== 搜索建议 =====
+
+// suggestCandidate 待排序的单词或课本建议
+type suggestCandidate struct {
+	suggestion models.SearchSuggestion
+	exact      bool
+	prefix     bool
+}
+
+// matchSuggestName 名称是否命中全部查询词（按词的开头匹配），返回建议和排序信息
+func matchSuggestName(name, id, suggestionType string, query *searchQuery, pinyin bool) (suggestCandidate, bool) {
+	match := utils.MatchSearchText(name, query.terms, query.normalized, true, pinyin)
+	if len(match.Terms) < len(query.terms.Words)+len(query.terms.CJK) {
+		return suggestCandidate{}, false
+	}
+	return suggestCandidate{
+		suggestion: models.SearchSuggestion{Text: name, Type: suggestionType, ID: id, Highlight: match.Highlight(name)},
+		exact:      match.Exact,
+		prefix:     match.Prefix,
+	}, true
+}
+
+// findSuggestCandidates 按搜索键查询候选单词或课本，只读取名称字段
+func findSuggestCandidates(ctx context.Context, collectionName, nameField string, query *searchQuery) ([]bson.M, error) {
+	cursor, err := GetCollection(collectionName).Find(ctx,
+		bson.M{"search_keys": bson.M{"$all": query.keys}},
+		options.Find().
+			SetSort(bson.D{{Key: nameField, Value: 1}}).
+			SetLimit(searchSuggestCandidateLimit).
+			SetProjection(bson.M{nameField: 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Suggest 搜索建议：依次为以输入开头的置顶建议、名称匹配的单词和课本（完全相同、以输入开头、名称短的在前）、以输入开头的热门搜索词
+// 单词名、书名按词的开头匹配，书名还支持拼音；包含屏蔽词的建议不返回
+func (s *SearchSuggestService) Suggest(rawQuery string, limit int) (*models.SearchSuggestResponse, error) {
+	query, err := parseSearchQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	// 与搜索词统计、规则使用相同的规范化形式（合并空白）
+	prefix := normalizeRecordedQuery(rawQuery)
+	response := &models.SearchSuggestResponse{Query: prefix, Suggestions: []models.SearchSuggestion{}}
+	rules, err := loadSearchRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules.isBlocked(prefix) {
+		return response, nil
+	}
+
+	seen := make(map[string]bool)
+	add := func(suggestion models.SearchSuggestion) {
+		key := normalizeRecordedQuery(suggestion.Text)
+		if len(response.Suggestions) < limit && !seen[key] && !rules.isBlocked(key) {
+			seen[key] = true
+			response.Suggestions = append(response.Suggestions, suggestion)
+		}
+	}
+
+	for _, rule := range rules.pinned {
+		if strings.HasPrefix(rule.Normalized, prefix) {
+			add(models.SearchSuggestion{Text: rule.Query, Type: models.SearchSuggestionPinned})
+		}
+	}
+
+	candidates := []suggestCandidate{}
+	words, err := findSuggestCandidates(ctx, "words", "word_name", query)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range words {
+		name, _ := doc["word_name"].(string)
+		id, _ := doc["_id"].(primitive.ObjectID)
+		if candidate, ok := matchSuggestName(name, id.Hex(), models.SearchSuggestionWord, query, false); ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+	books, err := findSuggestCandidates(ctx, "books", "book_name", query)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range books {
+		name, _ := doc["book_name"].(string)
+		id, _ := doc["_id"].(primitive.ObjectID)
+		if candidate, ok := matchSuggestName(name, id.Hex(), models.SearchSuggestionBook, query, true); ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.exact != b.exact {
+			return a.exact
+		}
+		if a.prefix != b.prefix {
+			return a.prefix
+		}
+		if len([]rune(a.suggestion.Text)) != len([]rune(b.suggestion.Text)) {
+			return len([]rune(a.suggestion.Text)) < len([]rune(b.suggestion.Text))
+		}
+		return a.suggestion.Text < b.suggestion.Text
+	})
+	for _, candidate := range candidates {
+		add(candidate.suggestion)
+	}
+
+	if len(response.Suggestions) < limit {
+		// 已加入的建议和屏蔽词在统计查询中排除，取剩余条数即可填满
+		added := make([]string, 0, len(seen))
+		for key := range seen {
+			added = append(added, key)
+		}
+		since := utils.GetCurrentUTCTime().Add(-searchSuggestQueryWindow)
+		ranks, err := aggregateQueryCounts(ctx, queryStatsMatch(since, prefix, rules.blocked, added), limit-len(response.Suggestions))
+		if err != nil {
+			return nil, err
+		}
+		for _, rank := range ranks {
+			add(models.SearchSuggestion{Text: rank.Query, Type: models.SearchSuggestionQuery})
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"fmt"
+	"miniprogram/models"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormalizeRecordedQuery(t *testing.T) {
+	tests := map[string]string{
+		"  Apple   Pie ": "apple pie",
+		"ＡＰＰＬＥ":          "apple",
+		"红　苹果":           "红 苹果",
+		".*":             "",
+		"":               "",
+		strings.Repeat("a", maxRecordedQueryLength+10): strings.Repeat("a", maxRecordedQueryLength),
+	}
+	for query, want := range tests {
+		if got := normalizeRecordedQuery(query); got != want {
+			t.Errorf("normalizeRecordedQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestSearchRuleSetIsBlocked(t *testing.T) {
+	rules := &searchRuleSet{blocked: []string{"spam", "a.b"}}
+	tests := map[string]bool{
+		"spam":       true,
+		"cheap spam": true,
+		"spa":        false,
+		"a.b":        true,
+		"axb":        false, // 屏蔽词按字面匹配
+	}
+	for query, want := range tests {
+		if got := rules.isBlocked(query); got != want {
+			t.Errorf("isBlocked(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestMatchSuggestName(t *testing.T) {
+	tests := []struct {
+		name, query   string
+		pinyin        bool
+		ok            bool
+		exact, prefix bool
+	}{
+		{"apple", "apple", false, true, true, true},
+		{"apple pie", "app", false, true, false, true},
+		{"apple pie", "app pie", false, true, false, false},
+		{"apple pie", "app cake", false, false, false, false},
+		{"苹果", "pg", true, true, false, true},
+		{"苹果", "pg", false, false, false, false},
+	}
+	for _, tt := range tests {
+		query, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Fatalf("parseSearchQuery(%q): %v", tt.query, err)
+		}
+		candidate, ok := matchSuggestName(tt.name, "id", models.SearchSuggestionWord, query, tt.pinyin)
+		if ok != tt.ok || candidate.exact != tt.exact || candidate.prefix != tt.prefix {
+			t.Errorf("matchSuggestName(%q, %q, %v) = %+v %v, want ok %v exact %v prefix %v",
+				tt.name, tt.query, tt.pinyin, candidate, ok, tt.ok, tt.exact, tt.prefix)
+		}
+	}
+}
+
+func TestSearchDedupAllow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dedup := newSearchDedup(time.Hour, 2)
+	steps := []struct {
+		key    string
+		offset time.Duration
+		want   bool
+	}{
+		{"a", 0, true},
+		{"a", 30 * time.Minute, false},
+		{"b", 30 * time.Minute, true},
+		{"a", time.Hour, true},         // a 已过期
+		{"c", 70 * time.Minute, true},  // 表已满，淘汰最早的 b
+		{"a", 80 * time.Minute, false}, // 较新的 a 仍在表中
+		{"b", 80 * time.Minute, true},  // b 已被淘汰，再次计入并淘汰 a
+		{"c", 90 * time.Minute, false},
+		{"a", 90 * time.Minute, true},
+	}
+	for i, step := range steps {
+		if got := dedup.allow(step.key, start.Add(step.offset)); got != step.want {
+			t.Errorf("step %d: allow(%q, +%v) = %v, want %v", i, step.key, step.offset, got, step.want)
+		}
+	}
+}
+
+func TestSearchDedupFullTableKeepsCounting(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dedup := newSearchDedup(time.Hour, 100)
+	for i := 0; i < 1000; i++ {
+		if !dedup.allow(fmt.Sprintf("client-%d", i), now) {
+			t.Fatalf("allow(client-%d) = false on a full table, want true", i)
+		}
+	}
+	if len(dedup.seen) != 100 || dedup.order.Len() != 100 {
+		t.Errorf("table size = %d/%d, want 100", len(dedup.seen), dedup.order.Len())
+	}
+	if dedup.allow("client-999", now) {
+		t.Errorf("allow(client-999) = true, want false for the newest entry")
+	}
+	if !dedup.allow("client-0", now) {
+		t.Errorf("allow(client-0) = false, want true after it was evicted")
+	}
+}
+
+func TestTrendingGrowth(t *testing.T) {
+	tests := []struct {
+		count, previousCount int64
+		want                 float64
+	}{
+		{10, 0, 10},
+		{10, 4, 2},
+		{5, 4, 1},
+		{1, 9, 0.1},
+	}
+	for _, tt := range tests {
+		if got := trendingGrowth(tt.count, tt.previousCount); got != tt.want {
+			t.Errorf("trendingGrowth(%d, %d) = %v, want %v", tt.count, tt.previousCount, got, tt.want)
+		}
+	}
+}
+
+// rankQueries 取出排行榜中的搜索词
+func rankQueries(ranks []models.SearchQueryRank) []string {
+	queries := make([]string, 0, len(ranks))
+	for _, rank := range ranks {
+		queries = append(queries, rank.Query)
+	}
+	return queries
+}
+
+func TestRankTrendingQueries(t *testing.T) {
+	rules := &searchRuleSet{blocked: []string{"bad"}}
+	rows := []models.SearchQueryRank{
+		{Query: "steady", Count: 100, PreviousCount: 99},
+		{Query: "new", Count: 6},
+		{Query: "badword", Count: 50},
+		{Query: "double", Count: 12, PreviousCount: 1},
+		{Query: "rising", Count: 12},
+	}
+
+	// double 与 new 的增长倍数相同，次数多的在前；包含屏蔽词的 badword 被去掉
+	ranks := rankTrendingQueries(rows, rules, 10)
+	if got, want := rankQueries(ranks), []string{"rising", "double", "new", "steady"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rankTrendingQueries() = %v, want %v", got, want)
+	}
+	for _, rank := range ranks {
+		if rank.Growth != trendingGrowth(rank.Count, rank.PreviousCount) {
+			t.Errorf("%q growth = %v", rank.Query, rank.Growth)
+		}
+	}
+	if got := rankQueries(rankTrendingQueries(rows, rules, 2)); !reflect.DeepEqual(got, []string{"rising", "double"}) {
+		t.Errorf("rankTrendingQueries(limit 2) = %v", got)
+	}
+}
+
+func TestMergeHotQueries(t *testing.T) {
+	rules := &searchRuleSet{
+		blocked: []string{"spam"},
+		pinned: []models.SearchRule{
+			{Query: "Apple", Normalized: "apple"},
+			{Query: "Spam Deal", Normalized: "spam deal"},
+			{Query: "Book", Normalized: "book"},
+		},
+	}
+	ranks := []models.SearchQueryRank{
+		{Query: "cat", Count: 9},
+		{Query: "apple", Count: 8},
+		{Query: "spammy", Count: 7},
+		{Query: "dog", Count: 6},
+	}
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{10, []string{"Apple", "Book", "cat", "dog"}},
+		{3, []string{"Apple", "Book", "cat"}},
+		{2, []string{"Apple", "Book"}},
+	}
+	for _, tt := range tests {
+		queries := mergeHotQueries(rules, ranks, tt.limit)
+		if got := rankQueries(queries); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mergeHotQueries(limit %d) = %v, want %v", tt.limit, got, tt.want)
+		}
+		for i, query := range queries {
+			if query.Pinned != (i < 2) {
+				t.Errorf("mergeHotQueries(limit %d): %q pinned = %v", tt.limit, query.Query, query.Pinned)
+			}
+		}
+	}
+}
+
+func TestBlockedQueryPattern(t *testing.T) {
+	if pattern := blockedQueryPattern(nil); pattern != "" {
+		t.Errorf("blockedQueryPattern(nil) = %q, want empty", pattern)
+	}
+
+	blocked := []string{"a.b", "c++", "(x", "[y]", "$z", ""}
+	pattern := blockedQueryPattern(blocked)
+	if want := `a\.b|c\+\+|\(x|\[y\]|\$z`; pattern != want {
+		t.Fatalf("blockedQueryPattern(%q) = %q, want %q", blocked, pattern, want)
+	}
+	re := regexp.MustCompile(pattern)
+	for query, want := range map[string]bool{
+		"1a.b2": true, "c++": true, "(x)": true, "[y]": true, "$z": true,
+		"axb": false, "c": false, "x": false, "y": false, "z": false,
+	} {
+		if got := re.MatchString(query); got != want {
+			t.Errorf("pattern %q match %q = %v, want %v", pattern, query, got, want)
+		}
+	}
+}
+
+func TestQueryPrefixPattern(t *testing.T) {
+	re := regexp.MustCompile(queryPrefixPattern("c++ ("))
+	for query, want := range map[string]bool{"c++ (primer)": true, "cc (": false, "x c++ (": false} {
+		if got := re.MatchString(query); got != want {
+			t.Errorf("prefix pattern match %q = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestQueryStatsMatch(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := bson.M{"$gte": since}
+	tests := []struct {
+		prefix   string
+		blocked  []string
+		excluded []string
+		want     bson.M
+	}{
+		{"", nil, nil, bson.M{"bucket": bucket}},
+		{"a.b", nil, nil, bson.M{"bucket": bucket, "query": bson.M{"$regex": `^a\.b`}}},
+		{"", []string{"x+"}, []string{"apple"}, bson.M{"bucket": bucket, "query": bson.M{
+			"$nin": bson.A{"apple", primitive.Regex{Pattern: `x\+`}},
+		}}},
+	}
+	for _, tt := range tests {
+		if got := queryStatsMatch(since, tt.prefix, tt.blocked, tt.excluded); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("queryStatsMatch(%q, %v, %v) = %v, want %v", tt.prefix, tt.blocked, tt.excluded, got, tt.want)
+		}
+	}
+}
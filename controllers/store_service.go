@@ -722,7 +722,11 @@ func (s *OrderService) ProcessOrderUnlockBooks(orderID primitive.ObjectID) error
 	}
 
 	// 获取订单中所有商品的书籍权限信息
-	bookPermissions := make(map[primitive.ObjectID]string) // bookID -> productType
+	type bookGrant struct {
+		accessType string
+		days       int // 有效天数，0 表示永久
+	}
+	bookPermissions := make(map[primitive.ObjectID]bookGrant)
 
 	productCollection := GetCollection("products")
 	for _, item := range order.Items {
@@ -731,22 +735,30 @@ func (s *OrderService) ProcessOrderUnlockBooks(orderID primitive.ObjectID) error
 		if err != nil {
 			continue // 跳过无法找到的商品
 		}
+		if product.ProductType != "physical" && product.ProductType != "digital" {
+			continue
+		}
 
 		// 如果是实体卡，提供完整权限（包含电子版）
 		// 如果是电子卡，只提供电子版权限
-		if product.ProductType == "physical" {
-			bookPermissions[product.BookID] = "physical"
-		} else if product.ProductType == "digital" {
-			// 如果已经有实体权限，保持实体权限
-			if existingType, exists := bookPermissions[product.BookID]; !exists || existingType != "physical" {
-				bookPermissions[product.BookID] = "digital"
+		// 同一本书既有永久商品又有限时商品时按永久处理，多个限时商品取最长有效期
+		grant, exists := bookPermissions[product.BookID]
+		if !exists {
+			grant = bookGrant{accessType: product.ProductType, days: product.AccessDays}
+		} else {
+			if product.ProductType == "physical" {
+				grant.accessType = "physical"
+			}
+			if grant.days > 0 && (product.AccessDays == 0 || product.AccessDays > grant.days) {
+				grant.days = product.AccessDays
 			}
 		}
+		bookPermissions[product.BookID] = grant
 	}
 
 	// 解锁用户的书籍权限
-	for bookID, accessType := range bookPermissions {
-		err := s.unlockBookForUser(order.UserOpenID, bookID, accessType, orderID)
+	for bookID, grant := range bookPermissions {
+		err := s.unlockBookForUser(order.UserOpenID, bookID, grant.accessType, grant.days, orderID)
 		if err != nil {
 			return err
 		}
@@ -755,8 +767,8 @@ func (s *OrderService) ProcessOrderUnlockBooks(orderID primitive.ObjectID) error
 	return nil
 }
 
-// unlockBookForUser 为用户解锁指定书籍的权限
-func (s *OrderService) unlockBookForUser(userOpenID string, bookID primitive.ObjectID, accessType string, orderID primitive.ObjectID) error {
+// unlockBookForUser 为用户解锁指定书籍的权限，days 为权限有效天数（0 表示永久）
+func (s *OrderService) unlockBookForUser(userOpenID string, bookID primitive.ObjectID, accessType string, days int, orderID primitive.ObjectID) error {
 	userCollection := GetCollection("users")
 	bookCollection := GetCollection("books")
 	ctx, cancel := CreateDBContext()
@@ -769,49 +781,28 @@ func (s *OrderService) unlockBookForUser(userOpenID string, bookID primitive.Obj
 		return err
 	}
 
-	// 检查用户是否已经有此书的权限
 	var user models.User
 	err = userCollection.FindOne(ctx, bson.M{"openID": userOpenID}).Decode(&user)
 	if err != nil {
 		return err
 	}
 
-	// 查找是否已存在该书籍的权限
-	hasPermission := false
-	needUpdate := false
-	for i, permission := range user.UnlockedBooks {
-		if permission.BookID == bookID {
-			hasPermission = true
-			// 如果当前是电子权限，但新购买的是实体权限，则升级
-			if permission.AccessType == "digital" && accessType == "physical" {
-				user.UnlockedBooks[i].AccessType = "physical"
-				user.UnlockedBooks[i].OrderID = orderID
-				user.UnlockedBooks[i].UnlockedAt = utils.GetCurrentUTCTime()
-				needUpdate = true
-			}
-			break
-		}
-	}
-
-	// 如果没有权限，添加新权限
-	if !hasPermission {
-		newPermission := models.BookPermission{
-			BookID:     bookID,
-			BookName:   book.BookName,
-			AccessType: accessType,
-			OrderID:    orderID,
-			UnlockedAt: utils.GetCurrentUTCTime(),
-		}
-		user.UnlockedBooks = append(user.UnlockedBooks, newPermission)
-		needUpdate = true
-	}
+	// 合并到已有权限：实体权限升级、限时权限顺延、过期权限重新解锁
+	now := utils.GetCurrentUTCTime()
+	permissions, needUpdate := mergeBookPermission(user.UnlockedBooks, models.BookPermission{
+		BookID:     bookID,
+		BookName:   book.BookName,
+		AccessType: accessType,
+		Source:     models.BookAccessSourcePurchase,
+		OrderID:    orderID,
+	}, days, now)
 
 	// 更新用户权限
 	if needUpdate {
 		filter := bson.M{"openID": userOpenID}
 		update := bson.M{
 			"$set": bson.M{
-				"unlocked_books": user.UnlockedBooks,
+				"unlocked_books": permissions,
 				"updated_at":     time.Now(),
 			},
 		}
@@ -822,26 +813,17 @@ func (s *OrderService) unlockBookForUser(userOpenID string, bookID primitive.Obj
 	return nil
 }
 
-// CheckUserBookPermission 检查用户是否有访问指定书籍的权限
+// CheckUserBookPermission 检查用户是否可以完整访问指定书籍
+// 已过期的权限不再生效；家庭共享计入，免费试读不计入（需要按单元判断时使用 BookAccessService.GetBookAccess）
 func CheckUserBookPermission(userOpenID string, bookID primitive.ObjectID) (bool, string, error) {
-	collection := GetCollection("users")
-	ctx, cancel := CreateDBContext()
-	defer cancel()
-
-	var user models.User
-	err := collection.FindOne(ctx, bson.M{"openID": userOpenID}).Decode(&user)
+	access, err := GetBookAccessService().GetBookAccess(userOpenID, bookID)
 	if err != nil {
 		return false, "", err
 	}
-
-	// 检查用户是否有该书籍的权限
-	for _, permission := range user.UnlockedBooks {
-		if permission.BookID == bookID {
-			return true, permission.AccessType, nil
-		}
+	if !access.FullAccess {
+		return false, "", nil
 	}
-
-	return false, "", nil
+	return true, access.AccessType, nil
 }
 
 // generateCartID 生成购物车ID
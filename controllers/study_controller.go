@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondStudyError 统一处理学习会话相关错误
+func respondStudyError(c *gin.Context, notFoundMessage, message string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidStudySession):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrBookNotUnlocked):
+		ForbiddenResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrStudySessionEnded), errors.Is(err, ErrStudySessionActive):
+		ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, notFoundMessage, err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// StartStudySessionHandler 开始学习会话处理器
+func StartStudySessionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.StartStudySessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		session, err := GetStudyService().StartSession(openID, req)
+		if err != nil {
+			respondStudyError(c, "用户不存在", "开始学习会话失败", err)
+			return
+		}
+
+		CreatedResponse(c, "学习会话已开始", session)
+	}
+}
+
+// EndStudySessionHandler 结束学习会话处理器（记录复习单词数和正确率，计入每日统计）
+func EndStudySessionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.EndStudySessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		session, err := GetStudyService().EndSession(openID, c.Param("session_id"), req)
+		if err != nil {
+			respondStudyError(c, "学习会话不存在", "结束学习会话失败", err)
+			return
+		}
+
+		SuccessResponse(c, "学习会话已结束", session)
+	}
+}
+
+// ListStudySessionsHandler 获取学习会话记录处理器
+func ListStudySessionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+		sessions, pagination, err := GetStudyService().ListSessions(openID, page, limit)
+		if err != nil {
+			InternalServerErrorResponse(c, "获取学习会话记录失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取学习会话记录成功", gin.H{
+			"sessions":   sessions,
+			"pagination": pagination,
+		})
+	}
+}
+
+// GetStudyGoalHandler 获取每日学习目标处理器
+func GetStudyGoalHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		goal, err := GetStudyService().GetGoal(openID)
+		if err != nil {
+			respondStudyError(c, "用户不存在", "获取学习目标失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取学习目标成功", goal)
+	}
+}
+
+// UpdateStudyGoalHandler 设置每日学习目标处理器
+func UpdateStudyGoalHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.UpdateStudyGoalRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		goal, err := GetStudyService().UpdateGoal(openID, req)
+		if err != nil {
+			respondStudyError(c, "用户不存在", "设置学习目标失败", err)
+			return
+		}
+
+		SuccessResponse(c, "学习目标已更新", goal)
+	}
+}
+
+// GetStudyStatsHandler 获取学习统计处理器（period=daily、weekly 或 monthly）
+func GetStudyStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		count, _ := strconv.Atoi(c.DefaultQuery("count", "0"))
+
+		stats, err := GetStudyService().GetStats(openID, c.DefaultQuery("period", "daily"), count)
+		if err != nil {
+			respondStudyError(c, "用户不存在", "获取学习统计失败", err)
+			return
+		}
+
+		SuccessResponse(c, "获取学习统计成功", stats)
+	}
+}
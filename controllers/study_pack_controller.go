@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"miniprogram/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ===== HTTP 处理器 =====
+
+// respondStudyPackError 统一处理离线学习包相关错误
+func respondStudyPackError(c *gin.Context, message, notFoundMessage string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidStudyPackRequest):
+		BadRequestResponse(c, err.Error(), nil)
+	case errors.Is(err, ErrBookNotUnlocked), errors.Is(err, ErrBookAccessExpired), errors.Is(err, ErrUnitNotInTrial):
+		ForbiddenResponse(c, err.Error(), nil)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		NotFoundResponse(c, notFoundMessage, err)
+	default:
+		InternalServerErrorResponse(c, message, err)
+	}
+}
+
+// writeVersionedResponse 设置 ETag，客户端携带相同版本的 If-None-Match 时返回 304
+func writeVersionedResponse(c *gin.Context, version, message string, data interface{}) {
+	etag := `"` + version + `"`
+	c.Header("ETag", etag)
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	SuccessResponse(c, message, data)
+}
+
+// GetStudyPackIndexHandler 获取书籍学习包版本列表处理器（book_id 为必填查询参数）
+func GetStudyPackIndexHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		bookID := c.Query("book_id")
+		if bookID == "" {
+			BadRequestResponse(c, "缺少书籍ID参数", nil)
+			return
+		}
+
+		index, err := GetStudyPackService().GetIndex(openID, bookID)
+		if err != nil {
+			respondStudyPackError(c, "获取学习包版本失败", "书籍不存在", err)
+			return
+		}
+
+		writeVersionedResponse(c, index.Version, "获取学习包版本成功", index)
+	}
+}
+
+// GetBookStudyPackHandler 下载整本书学习包处理器
+func GetBookStudyPackHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		pack, err := GetStudyPackService().GetBookPack(openID, c.Param("book_id"))
+		if err != nil {
+			respondStudyPackError(c, "生成学习包失败", "书籍不存在", err)
+			return
+		}
+
+		writeVersionedResponse(c, pack.Version, "获取学习包成功", pack)
+	}
+}
+
+// GetUnitStudyPackHandler 下载单元学习包处理器
+func GetUnitStudyPackHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		pack, err := GetStudyPackService().GetUnitPack(openID, c.Param("unit_id"))
+		if err != nil {
+			respondStudyPackError(c, "生成学习包失败", "单元不存在", err)
+			return
+		}
+
+		writeVersionedResponse(c, pack.Version, "获取学习包成功", pack)
+	}
+}
+
+// UploadOfflineAnswersHandler 批量上传离线作答处理器
+func UploadOfflineAnswersHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.UploadOfflineAnswersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		result, err := GetStudyPackService().UploadAnswers(openID, req)
+		if err != nil {
+			respondStudyPackError(c, "上传离线作答失败", "单词不存在", err)
+			return
+		}
+
+		SuccessResponse(c, "离线作答上传成功", result)
+	}
+}
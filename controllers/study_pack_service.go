@@ -0,0 +1,632 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 离线学习包服务层 =====
+
+const (
+	offlineAnswersCollection = "offline_answers"
+
+	// offlineAnswerMaxAge 可上传的离线作答最长时间
+	offlineAnswerMaxAge = 30 * 24 * time.Hour
+	// offlineAnswerClockSkew 允许的设备时钟超前时间
+	offlineAnswerClockSkew = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidStudyPackRequest 学习包请求参数无效
+	ErrInvalidStudyPackRequest = errors.New("学习包请求参数无效")
+	// ErrUnitNotInTrial 单元不在试读范围内
+	ErrUnitNotInTrial = errors.New("该单元不在免费试读范围内，请先购买相关单词卡")
+	// ErrBookAccessExpired 书籍访问权限已过期
+	ErrBookAccessExpired = errors.New("您对该书籍的访问权限已过期，请续费后继续学习")
+)
+
+// studyPackClaims 学习包签名凭证内容
+type studyPackClaims struct {
+	UserID    string `json:"uid"`
+	BookID    string `json:"book_id"`
+	UnitID    string `json:"unit_id,omitempty"`
+	Version   string `json:"version"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// covers 凭证是否覆盖指定时间在指定单元的作答
+func (c studyPackClaims) covers(bookID, unitID primitive.ObjectID, at time.Time) bool {
+	if c.BookID != bookID.Hex() || (c.UnitID != "" && c.UnitID != unitID.Hex()) {
+		return false
+	}
+	return at.Unix() >= c.IssuedAt && at.Unix() <= c.ExpiresAt
+}
+
+// StudyPackService 离线学习包服务
+type StudyPackService struct{}
+
+// GetStudyPackService 获取离线学习包服务实例
+func GetStudyPackService() *StudyPackService {
+	return &StudyPackService{}
+}
+
+// getPackValidity 获取学习包凭证有效期
+func (s *StudyPackService) getPackValidity() time.Duration {
+	validity, err := time.ParseDuration(config.GetConfig().StudyPackValidity)
+	if err != nil || validity <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return validity
+}
+
+// contentVersion 计算内容版本（规范化 JSON 的 SHA-256 前 32 位）
+func contentVersion(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:32], nil
+}
+
+// checkAccess 检查用户对书籍的访问权限，无权限时返回对应错误
+func (s *StudyPackService) checkAccess(openID string, bookID primitive.ObjectID) (*models.BookAccess, error) {
+	access, err := GetBookAccessService().GetBookAccess(openID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.HasAccess {
+		if access.Expired {
+			return nil, ErrBookAccessExpired
+		}
+		return nil, ErrBookNotUnlocked
+	}
+	return access, nil
+}
+
+// loadBook 查询书籍
+func (s *StudyPackService) loadBook(bookID primitive.ObjectID) (*models.Book, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var book models.Book
+	if err := GetCollection("books").FindOne(ctx, bson.M{"_id": bookID}).Decode(&book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// buildUnits 按单元顺序加载单元及单词并计算各单元版本，只包含用户可访问的单元
+func (s *StudyPackService) buildUnits(bookID primitive.ObjectID, access *models.BookAccess, onlyUnit *primitive.ObjectID) ([]models.StudyPackUnit, error) {
+	ctx, cancel := contentContext()
+	defer cancel()
+
+	filter := bson.M{"book_id": bookID}
+	if onlyUnit != nil {
+		filter["_id"] = *onlyUnit
+	}
+	cursor, err := GetCollection("units").Find(ctx, filter, options.Find().SetSort(unitOrderSort))
+	if err != nil {
+		return nil, err
+	}
+	var units []models.Unit
+	if err := cursor.All(ctx, &units); err != nil {
+		return nil, err
+	}
+
+	unitIDs := make([]primitive.ObjectID, 0, len(units))
+	for _, unit := range units {
+		if access.AllowsUnit(unit.ID) {
+			unitIDs = append(unitIDs, unit.ID)
+		}
+	}
+
+	wordsByUnit := map[primitive.ObjectID][]models.Word{}
+	if len(unitIDs) > 0 {
+		wordCursor, err := GetCollection("words").Find(ctx, bson.M{"unit_id": bson.M{"$in": unitIDs}},
+			options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+		if err != nil {
+			return nil, err
+		}
+		var words []models.Word
+		if err := wordCursor.All(ctx, &words); err != nil {
+			return nil, err
+		}
+		for _, word := range words {
+			wordsByUnit[word.UnitID] = append(wordsByUnit[word.UnitID], word)
+		}
+	}
+
+	packUnits := make([]models.StudyPackUnit, 0, len(unitIDs))
+	for _, unit := range units {
+		if !access.AllowsUnit(unit.ID) {
+			continue
+		}
+		packUnit := models.StudyPackUnit{
+			UnitID:    unit.ID,
+			UnitName:  unit.UnitName,
+			SortOrder: unit.SortOrder,
+			Words:     wordsByUnit[unit.ID],
+		}
+		if packUnit.Words == nil {
+			packUnit.Words = []models.Word{}
+		}
+		version, err := contentVersion(packUnit)
+		if err != nil {
+			return nil, err
+		}
+		packUnit.Version = version
+		packUnits = append(packUnits, packUnit)
+	}
+	return packUnits, nil
+}
+
+// bookVersion 根据书籍信息和各单元版本计算整本书的版本
+func (s *StudyPackService) bookVersion(book *models.Book, units []models.StudyPackUnit) (string, error) {
+	unitVersions := make([]string, len(units))
+	for i, unit := range units {
+		unitVersions[i] = unit.UnitID.Hex() + ":" + unit.Version
+	}
+	return contentVersion(struct {
+		BookName    string   `json:"book_name"`
+		BookVersion string   `json:"book_version"`
+		Units       []string `json:"units"`
+	}{book.BookName, book.BookVersion, unitVersions})
+}
+
+// GetIndex 获取书籍各单元学习包的版本，客户端据此判断需要更新的单元
+func (s *StudyPackService) GetIndex(openID, bookIDHex string) (*models.StudyPackIndex, error) {
+	bookID, err := primitive.ObjectIDFromHex(bookIDHex)
+	if err != nil {
+		return nil, ErrInvalidStudyPackRequest
+	}
+	access, err := s.checkAccess(openID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	book, err := s.loadBook(bookID)
+	if err != nil {
+		return nil, err
+	}
+	units, err := s.buildUnits(bookID, access, nil)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.bookVersion(book, units)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &models.StudyPackIndex{
+		BookID:     book.ID,
+		BookName:   book.BookName,
+		Version:    version,
+		AccessType: access.AccessType,
+		Units:      make([]models.StudyPackUnitVersion, len(units)),
+	}
+	for i, unit := range units {
+		index.Units[i] = models.StudyPackUnitVersion{
+			UnitID:    unit.UnitID,
+			UnitName:  unit.UnitName,
+			SortOrder: unit.SortOrder,
+			Version:   unit.Version,
+			WordCount: len(unit.Words),
+		}
+	}
+	return index, nil
+}
+
+// GetBookPack 生成整本书的学习包（试读用户只包含试读单元）
+func (s *StudyPackService) GetBookPack(openID, bookIDHex string) (*models.StudyPack, error) {
+	bookID, err := primitive.ObjectIDFromHex(bookIDHex)
+	if err != nil {
+		return nil, ErrInvalidStudyPackRequest
+	}
+	access, err := s.checkAccess(openID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	book, err := s.loadBook(bookID)
+	if err != nil {
+		return nil, err
+	}
+	units, err := s.buildUnits(bookID, access, nil)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.bookVersion(book, units)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &models.StudyPack{
+		Scope:       "book",
+		BookID:      book.ID,
+		BookName:    book.BookName,
+		BookVersion: book.BookVersion,
+		Version:     version,
+		Units:       units,
+	}
+	if err := s.finishPack(openID, pack, access); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// GetUnitPack 生成单个单元的学习包
+func (s *StudyPackService) GetUnitPack(openID, unitIDHex string) (*models.StudyPack, error) {
+	unitID, err := primitive.ObjectIDFromHex(unitIDHex)
+	if err != nil {
+		return nil, ErrInvalidStudyPackRequest
+	}
+
+	ctx, cancel := CreateDBContext()
+	var unit models.Unit
+	err = GetCollection("units").FindOne(ctx, bson.M{"_id": unitID}).Decode(&unit)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.checkAccess(openID, unit.BookID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.AllowsUnit(unitID) {
+		return nil, ErrUnitNotInTrial
+	}
+	book, err := s.loadBook(unit.BookID)
+	if err != nil {
+		return nil, err
+	}
+	units, err := s.buildUnits(unit.BookID, access, &unitID)
+	if err != nil {
+		return nil, err
+	}
+	if len(units) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	pack := &models.StudyPack{
+		Scope:       "unit",
+		BookID:      book.ID,
+		BookName:    book.BookName,
+		BookVersion: book.BookVersion,
+		UnitID:      &unitID,
+		Version:     units[0].Version,
+		Units:       units,
+	}
+	if err := s.finishPack(openID, pack, access); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// finishPack 补充单词数、媒体清单，完整权限时签发凭证
+func (s *StudyPackService) finishPack(openID string, pack *models.StudyPack, access *models.BookAccess) error {
+	now := utils.GetCurrentUTCTime()
+	pack.AccessType = access.AccessType
+	pack.GeneratedAt = now
+	pack.Media = []models.StudyPackMedia{}
+
+	seen := map[string]bool{}
+	addMedia := func(url, mediaType string) {
+		url = strings.TrimSpace(url)
+		if url == "" || seen[url] {
+			return
+		}
+		seen[url] = true
+		pack.Media = append(pack.Media, models.StudyPackMedia{URL: url, Type: mediaType})
+	}
+	for _, unit := range pack.Units {
+		pack.WordCount += len(unit.Words)
+		for _, word := range unit.Words {
+			addMedia(word.PronunciationURL, "audio")
+			addMedia(word.ImgURL, "image")
+			for _, sense := range word.Senses {
+				for _, example := range sense.Examples {
+					addMedia(example.AudioURL, "audio")
+				}
+			}
+		}
+	}
+
+	// 试读内容不签发凭证，离线作答只有完整权限才计入复习记录
+	if !access.FullAccess {
+		return nil
+	}
+	expiresAt := now.Add(s.getPackValidity())
+	if access.ExpiresAt != nil && access.ExpiresAt.Before(expiresAt) {
+		expiresAt = *access.ExpiresAt
+	}
+	claims := studyPackClaims{
+		UserID:    utils.EncodeOpenIDToSafeID(openID),
+		BookID:    pack.BookID.Hex(),
+		Version:   pack.Version,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+	if pack.UnitID != nil {
+		claims.UnitID = pack.UnitID.Hex()
+	}
+	token, err := s.signToken(claims)
+	if err != nil {
+		return err
+	}
+	pack.Token = token
+	pack.ExpiresAt = &expiresAt
+	return nil
+}
+
+// signToken 签发学习包凭证：base64url(内容).base64url(HMAC-SHA256)
+func (s *StudyPackService) signToken(claims studyPackClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	secret := config.GetConfig().StudyPackSecret
+	if secret == "" {
+		return "", fmt.Errorf("未配置 STUDY_PACK_SECRET")
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// parseToken 校验学习包凭证签名及所属用户
+func (s *StudyPackService) parseToken(openID, token string) (*studyPackClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("凭证格式错误")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("凭证签名格式错误")
+	}
+	secret := config.GetConfig().StudyPackSecret
+	if secret == "" {
+		return nil, fmt.Errorf("未配置 STUDY_PACK_SECRET")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("凭证签名无效")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("凭证内容格式错误")
+	}
+	var claims studyPackClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("凭证内容格式错误")
+	}
+	if claims.UserID != utils.EncodeOpenIDToSafeID(openID) {
+		return nil, fmt.Errorf("凭证不属于当前用户")
+	}
+	return &claims, nil
+}
+
+// UploadAnswers 批量上传离线作答：按 answer_id 去重，按作答时间顺序更新复习状态、错题本和学习进度
+// 当前拥有完整权限，或作答时间落在学习包凭证有效期内的作答才会被接受
+func (s *StudyPackService) UploadAnswers(openID string, req models.UploadOfflineAnswersRequest) (*models.OfflineAnswersResult, error) {
+	result := &models.OfflineAnswersResult{Errors: []models.OfflineAnswerError{}}
+	reject := func(answerID, message string) {
+		result.Rejected++
+		result.Errors = append(result.Errors, models.OfflineAnswerError{AnswerID: answerID, Message: message})
+	}
+
+	claims := make([]studyPackClaims, 0, len(req.PackTokens))
+	for _, token := range req.PackTokens {
+		parsed, err := s.parseToken(openID, token)
+		if err != nil {
+			log.Printf("[离线学习包] 忽略无效凭证: user=%s, err=%v", utils.EncodeOpenIDToSafeID(openID), err)
+			continue
+		}
+		claims = append(claims, *parsed)
+	}
+
+	answers := make([]models.OfflineAnswerInput, len(req.Answers))
+	copy(answers, req.Answers)
+	sort.SliceStable(answers, func(i, j int) bool {
+		return answers[i].AnsweredAt.Before(answers[j].AnsweredAt)
+	})
+
+	// 查询涉及的单词
+	wordIDs := make([]primitive.ObjectID, 0, len(answers))
+	for _, answer := range answers {
+		if id, err := primitive.ObjectIDFromHex(answer.WordID); err == nil {
+			wordIDs = append(wordIDs, id)
+		}
+	}
+	words, err := GetQuizService().loadWordsByIDs(wordIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	fullAccess := map[primitive.ObjectID]bool{}
+	seenIDs := map[string]bool{}
+	answerCollection := GetCollection(offlineAnswersCollection)
+	reviewService := GetReviewService()
+	changes := map[primitive.ObjectID]*models.ProgressChange{}
+	order := []primitive.ObjectID{}
+
+	for _, answer := range answers {
+		if seenIDs[answer.AnswerID] {
+			result.Duplicates++
+			continue
+		}
+		seenIDs[answer.AnswerID] = true
+
+		answeredAt := answer.AnsweredAt.UTC()
+		if answeredAt.After(now.Add(offlineAnswerClockSkew)) {
+			reject(answer.AnswerID, "作答时间晚于当前时间")
+			continue
+		}
+		if answeredAt.Before(now.Add(-offlineAnswerMaxAge)) {
+			reject(answer.AnswerID, "作答时间超过30天，不再接受上传")
+			continue
+		}
+		wordID, err := primitive.ObjectIDFromHex(answer.WordID)
+		if err != nil {
+			reject(answer.AnswerID, ErrInvalidWordID.Error())
+			continue
+		}
+		word, ok := words[wordID]
+		if !ok {
+			reject(answer.AnswerID, "单词不存在")
+			continue
+		}
+
+		// 优先按当前权限判断，权限到期后再按学习包凭证判断
+		allowed, checked := fullAccess[word.BookID]
+		if !checked {
+			hasPermission, _, err := CheckUserBookPermission(openID, word.BookID)
+			if err != nil {
+				return nil, err
+			}
+			allowed = hasPermission
+			fullAccess[word.BookID] = allowed
+		}
+		if !allowed {
+			for _, claim := range claims {
+				if claim.covers(word.BookID, word.UnitID, answeredAt) {
+					allowed = true
+					break
+				}
+			}
+		}
+		if !allowed {
+			reject(answer.AnswerID, "作答不在有效的书籍权限或学习包凭证范围内")
+			continue
+		}
+
+		quality := 1
+		if answer.Correct {
+			quality = 4
+		}
+		if answer.Quality != nil {
+			quality = *answer.Quality
+		}
+
+		record := models.OfflineAnswer{
+			UserOpenID: openID,
+			AnswerID:   answer.AnswerID,
+			WordID:     word.ID,
+			BookID:     word.BookID,
+			UnitID:     word.UnitID,
+			Correct:    answer.Correct,
+			Quality:    quality,
+			AnsweredAt: answeredAt,
+			Status:     models.OfflineAnswerApplied,
+			CreatedAt:  now,
+		}
+		ctx, cancel := CreateDBContext()
+		insertResult, err := answerCollection.InsertOne(ctx, record)
+		cancel()
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				result.Duplicates++
+				continue
+			}
+			return nil, err
+		}
+
+		review, err := reviewService.recordWordReviewAt(openID, word, quality, answeredAt)
+		stale := errors.Is(err, errStaleReview)
+		if err != nil && !stale {
+			// 处理失败时删除去重记录，客户端可以重新上传该作答
+			log.Printf("[离线学习包] 更新复习状态失败: answer=%s, word=%s, err=%v", answer.AnswerID, word.ID.Hex(), err)
+			ctx, cancel := CreateDBContext()
+			if _, err := answerCollection.DeleteOne(ctx, bson.M{"_id": insertResult.InsertedID}); err != nil {
+				log.Printf("[离线学习包] 删除作答记录失败: answer=%s, err=%v", answer.AnswerID, err)
+			}
+			cancel()
+			result.Failed++
+			result.Errors = append(result.Errors, models.OfflineAnswerError{AnswerID: answer.AnswerID, Message: "更新复习状态失败，请稍后重新上传"})
+			continue
+		}
+		if _, err := GetMistakeService().RecordResult(openID, word, answer.Correct, models.MistakeSourceOffline); err != nil {
+			log.Printf("[错题本] 记录离线作答失败: answer=%s, word=%s, err=%v", answer.AnswerID, word.ID.Hex(), err)
+		}
+		if stale {
+			result.Stale++
+			ctx, cancel := CreateDBContext()
+			_, err := answerCollection.UpdateOne(ctx, bson.M{"_id": insertResult.InsertedID},
+				bson.M{"$set": bson.M{"status": models.OfflineAnswerStale}})
+			cancel()
+			if err != nil {
+				log.Printf("[离线学习包] 更新作答状态失败: answer=%s, err=%v", answer.AnswerID, err)
+			}
+			continue
+		}
+		result.Applied++
+
+		// 学习进度同步要求当前拥有完整权限，仅凭凭证接受的作答只更新复习状态和错题本
+		if !fullAccess[word.BookID] {
+			continue
+		}
+		change, ok := changes[word.UnitID]
+		if !ok {
+			change = &models.ProgressChange{
+				ChangeID: "offline:" + answer.AnswerID + ":" + word.UnitID.Hex(),
+				BookID:   word.BookID.Hex(),
+				UnitID:   word.UnitID.Hex(),
+			}
+			changes[word.UnitID] = change
+			order = append(order, word.UnitID)
+		}
+		change.DeviceTimestamp = answeredAt
+		// 同一单词多次作答时以最后一次为准
+		wordHex := word.ID.Hex()
+		change.MasteredWords = removeString(change.MasteredWords, wordHex)
+		change.LearningWords = removeString(change.LearningWords, wordHex)
+		if answer.Correct && review.Repetitions >= 3 {
+			change.MasteredWords = append(change.MasteredWords, wordHex)
+		} else {
+			change.LearningWords = append(change.LearningWords, wordHex)
+		}
+	}
+
+	if len(order) > 0 {
+		progressReq := models.SyncProgressRequest{DeviceID: "offline"}
+		for _, unitID := range order {
+			progressReq.Changes = append(progressReq.Changes, *changes[unitID])
+		}
+		if _, err := GetProgressService().SyncProgress(openID, progressReq); err != nil {
+			log.Printf("[离线学习包] 更新学习进度失败: user=%s, err=%v", utils.EncodeOpenIDToSafeID(openID), err)
+		}
+	}
+	return result, nil
+}
+
+// removeString 从切片中移除指定字符串
+func removeString(values []string, target string) []string {
+	result := values[:0]
+	for _, value := range values {
+		if value != target {
+			result = append(result, value)
+		}
+	}
+	return result
+}
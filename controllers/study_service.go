@@ -0,0 +1,517 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 学习会话、目标与统计服务层 =====
+
+var (
+	// ErrInvalidStudySession 学习会话参数错误
+	ErrInvalidStudySession = errors.New("学习会话参数错误")
+	// ErrStudySessionEnded 学习会话已结束
+	ErrStudySessionEnded = errors.New("学习会话已结束")
+	// ErrStudySessionActive 已有进行中的学习会话
+	ErrStudySessionActive = errors.New("已有进行中的学习会话")
+)
+
+const (
+	studySessionsCollection   = "study_sessions"
+	studyDailyStatsCollection = "study_daily_stats"
+	maxStudySessionDuration   = 4 * time.Hour // 单次会话最长计入时长，防止忘记结束的会话虚增学习时长
+)
+
+// studyStatsPeriods 统计周期：默认数量和最大数量
+var studyStatsPeriods = map[string]struct{ defaultCount, maxCount int }{
+	"daily":   {7, 90},
+	"weekly":  {8, 52},
+	"monthly": {6, 24},
+}
+
+// StudyService 学习会话、目标与统计服务
+type StudyService struct{}
+
+// GetStudyService 获取学习服务实例
+func GetStudyService() *StudyService {
+	return &StudyService{}
+}
+
+// StartSession 开始学习会话
+func (s *StudyService) StartSession(openID string, req models.StartStudySessionRequest) (*models.StudySession, error) {
+	now := utils.GetCurrentUTCTime()
+	session := &models.StudySession{
+		ID:         primitive.NewObjectID(),
+		UserOpenID: openID,
+		Activity:   req.Activity,
+		Status:     models.StudySessionActive,
+		StudyDate:  utils.FormatAppDate(now),
+		StartedAt:  now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if req.BookID != "" {
+		bookID, err := primitive.ObjectIDFromHex(req.BookID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的书籍ID", ErrInvalidStudySession)
+		}
+		hasPermission, _, err := CheckUserBookPermission(openID, bookID)
+		if err != nil {
+			return nil, err
+		}
+		if !hasPermission {
+			return nil, ErrBookNotUnlocked
+		}
+		session.BookID = &bookID
+	}
+	if req.UnitID != "" {
+		unitID, err := primitive.ObjectIDFromHex(req.UnitID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的单元ID", ErrInvalidStudySession)
+		}
+		session.UnitID = &unitID
+	}
+
+	// 同一用户同时只有一个进行中的会话，避免并行会话重复计入学习时长
+	if err := s.endActiveSessions(openID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	// 并发开始时由 (user_openid, status=active) 部分唯一索引保证只有一个成功
+	if _, err := GetCollection(studySessionsCollection).InsertOne(ctx, session); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrStudySessionActive
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// endActiveSessions 结束用户进行中的会话（不记录复习单词数），时长按结束时间计算
+func (s *StudyService) endActiveSessions(openID string) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection(studySessionsCollection).Find(ctx,
+		bson.M{"user_openid": openID, "status": models.StudySessionActive},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var sessions []models.StudySession
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return err
+	}
+	for _, active := range sessions {
+		_, err := s.EndSession(openID, active.ID.Hex(), models.EndStudySessionRequest{})
+		if err != nil && !errors.Is(err, ErrStudySessionEnded) {
+			return err
+		}
+	}
+	return nil
+}
+
+// EndSession 结束学习会话，记录复习单词数和正确率并计入会话开始当天的统计
+func (s *StudyService) EndSession(openID, sessionID string, req models.EndStudySessionRequest) (*models.StudySession, error) {
+	if req.CorrectCount > req.WordsReviewed {
+		return nil, fmt.Errorf("%w: 正确数不能大于复习单词数", ErrInvalidStudySession)
+	}
+	sessionObjectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的会话ID", ErrInvalidStudySession)
+	}
+
+	collection := GetCollection(studySessionsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var session models.StudySession
+	err = collection.FindOne(ctx, bson.M{"_id": sessionObjectID, "user_openid": openID}).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status == models.StudySessionEnded {
+		return nil, ErrStudySessionEnded
+	}
+
+	now := utils.GetCurrentUTCTime()
+	duration := now.Sub(session.StartedAt)
+	if duration > maxStudySessionDuration {
+		duration = maxStudySessionDuration
+	}
+
+	session.Status = models.StudySessionEnded
+	session.EndedAt = now
+	session.DurationSeconds = int64(duration.Seconds())
+	session.WordsReviewed = req.WordsReviewed
+	session.CorrectCount = req.CorrectCount
+	session.Accuracy = accuracyPercent(req.CorrectCount, req.WordsReviewed)
+	session.UpdatedAt = now
+
+	// 只有进行中的会话可以结束，防止重复提交重复计入统计
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": session.ID, "status": models.StudySessionActive},
+		bson.M{"$set": bson.M{
+			"status":           session.Status,
+			"ended_at":         session.EndedAt,
+			"duration_seconds": session.DurationSeconds,
+			"words_reviewed":   session.WordsReviewed,
+			"correct_count":    session.CorrectCount,
+			"accuracy":         session.Accuracy,
+			"updated_at":       session.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrStudySessionEnded
+	}
+
+	if err := s.addToDailyStat(openID, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// addToDailyStat 将结束的会话累加到当日统计并更新目标达成状态
+func (s *StudyService) addToDailyStat(openID string, session *models.StudySession) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	var stat models.StudyDailyStat
+	err := GetCollection(studyDailyStatsCollection).FindOneAndUpdate(ctx,
+		bson.M{"user_openid": openID, "date": session.StudyDate},
+		bson.M{
+			"$inc": bson.M{
+				"session_count":    1,
+				"duration_seconds": session.DurationSeconds,
+				"words_reviewed":   session.WordsReviewed,
+				"correct_count":    session.CorrectCount,
+			},
+			"$set":         bson.M{"updated_at": now},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&stat)
+	if err != nil {
+		return err
+	}
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return err
+	}
+	return s.refreshGoalMet(openID, &stat, user.StudyGoal)
+}
+
+// refreshGoalMet 按当前目标重新计算某天是否达成目标
+func (s *StudyService) refreshGoalMet(openID string, stat *models.StudyDailyStat, goal *models.StudyGoal) error {
+	goalMet := isGoalMet(goal, stat)
+	if goalMet == stat.GoalMet {
+		return nil
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	_, err := GetCollection(studyDailyStatsCollection).UpdateOne(ctx,
+		bson.M{"user_openid": openID, "date": stat.Date},
+		bson.M{"$set": bson.M{"goal_met": goalMet}},
+	)
+	if err != nil {
+		return err
+	}
+	stat.GoalMet = goalMet
+	return nil
+}
+
+// isGoalMet 判断某天是否达成目标；未设置目标时当天有结束的学习会话即视为达成
+func isGoalMet(goal *models.StudyGoal, stat *models.StudyDailyStat) bool {
+	if stat.SessionCount == 0 {
+		return false
+	}
+	if goal == nil {
+		return true
+	}
+	if goal.DailyWords > 0 && stat.WordsReviewed < goal.DailyWords {
+		return false
+	}
+	if goal.DailyMinutes > 0 && stat.DurationSeconds < int64(goal.DailyMinutes)*60 {
+		return false
+	}
+	return true
+}
+
+// accuracyPercent 计算正确率（百分比，保留两位小数）
+func accuracyPercent(correct, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(correct)*10000/float64(total)) / 100
+}
+
+// ListSessions 分页获取学习会话记录
+func (s *StudyService) ListSessions(openID string, page, limit int) ([]models.StudySession, *models.Pagination, error) {
+	collection := GetCollection(studySessionsCollection)
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	filter := bson.M{"user_openid": openID}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := options.Find().
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetSort(bson.M{"started_at": -1})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessions := []models.StudySession{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &models.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      int(total),
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	}
+	return sessions, pagination, nil
+}
+
+// GetGoal 获取每日学习目标，未设置时返回 nil
+func (s *StudyService) GetGoal(openID string) (*models.StudyGoal, error) {
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+	return user.StudyGoal, nil
+}
+
+// UpdateGoal 设置每日学习目标，并按新目标重新计算今天的达成状态
+func (s *StudyService) UpdateGoal(openID string, req models.UpdateStudyGoalRequest) (*models.StudyGoal, error) {
+	now := utils.GetCurrentUTCTime()
+	goal := &models.StudyGoal{
+		DailyWords:   req.DailyWords,
+		DailyMinutes: req.DailyMinutes,
+		UpdatedAt:    now,
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	result, err := GetCollection("users").UpdateOne(ctx,
+		bson.M{"openID": openID},
+		bson.M{"$set": bson.M{"study_goal": goal, "updated_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	// 历史日期保留当时的达成状态，只重新计算今天
+	var today models.StudyDailyStat
+	err = GetCollection(studyDailyStatsCollection).FindOne(ctx, bson.M{"user_openid": openID, "date": utils.FormatAppDate(now)}).Decode(&today)
+	if err == nil {
+		if err := s.refreshGoalMet(openID, &today, goal); err != nil {
+			return nil, err
+		}
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+// GetStats 获取学习统计：今日汇总、连续学习天数以及按天、周、月汇总的图表数据
+// 日期均按应用时区的自然日计算，周从周一开始
+func (s *StudyService) GetStats(openID, period string, count int) (*models.StudyStatsResult, error) {
+	limits, ok := studyStatsPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持的统计周期 %s", ErrInvalidStudySession, period)
+	}
+	if count <= 0 || count > limits.maxCount {
+		count = limits.defaultCount
+	}
+
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	todayDate := utils.FormatAppDate(now)
+	today, err := utils.ParseAppDate(todayDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计算每个数据点的起止日期（从早到晚）
+	type bucket struct{ start, end time.Time }
+	buckets := make([]bucket, count)
+	for i := 0; i < count; i++ {
+		offset := count - 1 - i
+		var start, end time.Time
+		switch period {
+		case "daily":
+			start = today.AddDate(0, 0, -offset)
+			end = start
+		case "weekly":
+			weekday := (int(today.Weekday()) + 6) % 7 // 周一为0
+			start = today.AddDate(0, 0, -weekday-7*offset)
+			end = start.AddDate(0, 0, 6)
+		case "monthly":
+			start = time.Date(today.Year(), today.Month()-time.Month(offset), 1, 0, 0, 0, 0, today.Location())
+			end = start.AddDate(0, 1, -1)
+		}
+		buckets[i] = bucket{start, end}
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	collection := GetCollection(studyDailyStatsCollection)
+	cursor, err := collection.Find(ctx, bson.M{
+		"user_openid": openID,
+		"date": bson.M{
+			"$gte": buckets[0].start.Format("2006-01-02"),
+			"$lte": buckets[count-1].end.Format("2006-01-02"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var stats []models.StudyDailyStat
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+	statMap := make(map[string]models.StudyDailyStat, len(stats))
+	for _, stat := range stats {
+		statMap[stat.Date] = stat
+	}
+
+	result := &models.StudyStatsResult{
+		Period: period,
+		Goal:   user.StudyGoal,
+		Today:  models.StudyDailyStat{Date: todayDate},
+		Points: make([]models.StudyStatsPoint, 0, count),
+	}
+	if stat, ok := statMap[todayDate]; ok {
+		result.Today = stat
+	}
+
+	for _, b := range buckets {
+		point := models.StudyStatsPoint{
+			StartDate: b.start.Format("2006-01-02"),
+			EndDate:   b.end.Format("2006-01-02"),
+		}
+		for day := b.start; !day.After(b.end); day = day.AddDate(0, 0, 1) {
+			stat, ok := statMap[day.Format("2006-01-02")]
+			if !ok {
+				continue
+			}
+			point.SessionCount += stat.SessionCount
+			point.DurationSeconds += stat.DurationSeconds
+			point.WordsReviewed += stat.WordsReviewed
+			point.CorrectCount += stat.CorrectCount
+			if stat.GoalMet {
+				point.GoalMetDays++
+			}
+		}
+		point.Accuracy = accuracyPercent(point.CorrectCount, point.WordsReviewed)
+		result.Points = append(result.Points, point)
+	}
+
+	streak, err := s.computeStreak(openID, today)
+	if err != nil {
+		return nil, err
+	}
+	result.Streak = *streak
+
+	return result, nil
+}
+
+// computeStreak 根据达成目标的日期计算当前和最长连续天数
+func (s *StudyService) computeStreak(openID string, today time.Time) (*models.StudyStreak, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := GetCollection(studyDailyStatsCollection).Find(ctx,
+		bson.M{"user_openid": openID, "goal_met": true},
+		options.Find().SetSort(bson.M{"date": 1}).SetProjection(bson.M{"date": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var days []models.StudyDailyStat
+	if err := cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+
+	streak := &models.StudyStreak{}
+	if len(days) == 0 {
+		return streak, nil
+	}
+
+	metDates := make(map[string]bool, len(days))
+	run := 0
+	var previous time.Time
+	for _, day := range days {
+		date, err := utils.ParseAppDate(day.Date)
+		if err != nil {
+			continue
+		}
+		metDates[day.Date] = true
+		if run > 0 && previous.AddDate(0, 0, 1).Equal(date) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > streak.Longest {
+			streak.Longest = run
+		}
+		previous = date
+	}
+	streak.LastStudyDate = days[len(days)-1].Date
+
+	// 今天还没达成时，连续天数从昨天算起，不算中断
+	day := today
+	if !metDates[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for metDates[day.Format("2006-01-02")] {
+		streak.Current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return streak, nil
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"net"
+	"sync"
+	"time"
+)
+
+// ===== 测试身份服务层 =====
+
+// TestIdentityService 测试身份服务
+type TestIdentityService struct{}
+
+// GetTestIdentityService 获取测试身份服务实例
+func GetTestIdentityService() *TestIdentityService {
+	return &TestIdentityService{}
+}
+
+// testIdentityStats 测试身份使用统计（用于健康检查输出）
+var testIdentityStats = struct {
+	mu         sync.Mutex
+	uses       int
+	rejected   int
+	lastUsedAt time.Time
+}{}
+
+// Authorize 校验测试身份请求：环境、接收请求的监听地址、来源地址、白名单和签名
+// localAddr 为接收请求的本地地址，必须是 TEST_IDENTITY_LISTEN 内部监听地址；
+// 同机部署的反向代理转发的请求来源也是回环地址，因此不能只依赖来源地址判断
+func (s *TestIdentityService) Authorize(req models.DevLoginRequest, remoteIP, localAddr string) error {
+	cfg := config.GetConfig()
+
+	if !cfg.DevLoginAllowed() {
+		return errors.New("测试身份登录未启用")
+	}
+
+	if localAddr != cfg.TestIdentityListen {
+		return errors.New("测试身份仅允许通过内部监听地址使用")
+	}
+
+	// 只允许本机回环地址访问
+	ip := net.ParseIP(remoteIP)
+	if ip == nil || !ip.IsLoopback() {
+		return errors.New("测试身份仅允许本机回环地址使用")
+	}
+
+	// 检查openID白名单
+	allowed := false
+	for _, openID := range cfg.TestIdentityOpenIDs {
+		if openID == req.OpenID {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return errors.New("openID不在测试身份白名单中")
+	}
+
+	return utils.VerifyTestIdentity(cfg.TestIdentitySecret, req.OpenID, req.ExpiresAt, req.Signature)
+}
+
+// RecordUse 记录测试身份的每一次使用（包括被拒绝的请求）
+func (s *TestIdentityService) RecordUse(openID, remoteIP, userAgent string, authErr error) {
+	record := models.TestIdentityLog{
+		UserOpenID: openID,
+		RemoteIP:   remoteIP,
+		UserAgent:  userAgent,
+		Success:    authErr == nil,
+		CreatedAt:  utils.GetCurrentUTCTime(),
+	}
+	if authErr != nil {
+		record.Reason = authErr.Error()
+	}
+
+	testIdentityStats.mu.Lock()
+	if authErr == nil {
+		testIdentityStats.uses++
+		testIdentityStats.lastUsedAt = record.CreatedAt
+	} else {
+		testIdentityStats.rejected++
+	}
+	testIdentityStats.mu.Unlock()
+
+	if authErr == nil {
+		log.Printf("[测试身份] 登录成功: openID=%s, ip=%s", openID, remoteIP)
+	} else {
+		log.Printf("[测试身份] 登录被拒绝: openID=%s, ip=%s, 原因=%v", openID, remoteIP, authErr)
+	}
+
+	collection := GetCollection("test_identity_logs")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	if _, err := collection.InsertOne(ctx, record); err != nil {
+		log.Printf("[测试身份] 写入使用记录失败: %v", err)
+	}
+}
+
+// GetDiagnostics 获取测试身份诊断信息
+func (s *TestIdentityService) GetDiagnostics() map[string]interface{} {
+	cfg := config.GetConfig()
+
+	testIdentityStats.mu.Lock()
+	defer testIdentityStats.mu.Unlock()
+
+	diagnostics := map[string]interface{}{
+		"enabled":          cfg.DevLoginAllowed(),
+		"allowed_openids":  len(cfg.TestIdentityOpenIDs),
+		"uses":             testIdentityStats.uses,
+		"rejected":         testIdentityStats.rejected,
+		"last_used_at":     nil,
+		"production_guard": cfg.IsProduction(),
+	}
+	if !testIdentityStats.lastUsedAt.IsZero() {
+		diagnostics["last_used_at"] = utils.FormatTimeForResponse(testIdentityStats.lastUsedAt)
+	}
+
+	return diagnostics
+}
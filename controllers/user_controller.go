@@ -1,12 +1,18 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
+	"miniprogram/config"
 	"miniprogram/models"
+	"miniprogram/utils"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +32,14 @@ func UpdateUserProfileHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 请求体中的用户标识可能是安全用户标识符
+		openID, err := utils.ResolveUserID(req.OpenID)
+		if err != nil {
+			BadRequestResponse(c, "无效的用户标识符", err)
+			return
+		}
+		req.OpenID = openID
+
 		// 初始化用户服务
 		userService := GetUserService()
 
@@ -78,6 +92,11 @@ func UpdateUserProfileHandler() gin.HandlerFunc {
 			}
 		}
 
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, req.OpenID) {
+			piiService.MaskUser(user)
+		}
+
 		SuccessResponse(c, "用户信息更新成功", gin.H{
 			"user": user,
 		})
@@ -103,10 +122,75 @@ func GetUserHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 非本人且无查看权限时脱敏
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, openID) {
+			piiService.MaskUser(user)
+		}
+		GetUserFileService().FillAvatarURL(user)
+
 		SuccessResponse(c, "获取用户信息成功", user)
 	}
 }
 
+// BindPhoneNumberHandler 通过微信 getPhoneNumber 绑定手机号处理器
+func BindPhoneNumberHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 只能为本人账号绑定手机号
+		openID, ok := requireAccountOwner(c)
+		if !ok {
+			return
+		}
+
+		var req models.BindPhoneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestResponse(c, "请求参数错误", err)
+			return
+		}
+
+		// 1. 检查用户是否存在
+		userService := GetUserService()
+		if _, err := userService.FindUserByOpenID(openID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+			} else {
+				InternalServerErrorResponse(c, "获取用户信息失败", err)
+			}
+			return
+		}
+
+		// 2. 使用 code 向微信换取手机号
+		phoneInfo, err := GetAuthService().GetWechatPhoneNumber(req.Code)
+		if err != nil {
+			BadRequestResponse(c, "获取微信手机号失败", err)
+			return
+		}
+
+		// 3. 绑定已验证的手机号
+		user, err := userService.BindVerifiedPhone(openID, phoneInfo.PurePhoneNumber, phoneInfo.CountryCode)
+		if err != nil {
+			if err == ErrPhoneAlreadyBound {
+				ErrorResponse(c, http.StatusConflict, 409, err.Error(), nil)
+				return
+			}
+			InternalServerErrorResponse(c, "绑定手机号失败", err)
+			return
+		}
+
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, openID) {
+			piiService.MaskUser(user)
+		}
+
+		SuccessResponse(c, "手机号绑定成功", gin.H{
+			"phone":              user.Phone,
+			"phone_verified":     user.PhoneVerified,
+			"phone_country_code": user.PhoneCountryCode,
+			"phone_verified_at":  utils.FormatTimeForResponse(user.PhoneVerifiedAt),
+		})
+	}
+}
+
 // CreateAddressHandler 创建地址处理器
 func CreateAddressHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -128,6 +212,11 @@ func CreateAddressHandler() gin.HandlerFunc {
 			return
 		}
 
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, openID) {
+			piiService.MaskAddress(address)
+		}
+
 		CreatedResponse(c, "地址创建成功", address)
 	}
 }
@@ -151,6 +240,12 @@ func GetUserAddressesHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 非本人且无查看权限时脱敏
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, openID) {
+			piiService.MaskAddresses(addresses)
+		}
+
 		SuccessResponse(c, "获取地址列表成功", gin.H{
 			"addresses": addresses,
 			"total":     len(addresses),
@@ -191,6 +286,11 @@ func UpdateAddressHandler() gin.HandlerFunc {
 			return
 		}
 
+		piiService := GetPIIService()
+		if !piiService.CanViewFullPII(c, openID) {
+			piiService.MaskAddress(updatedAddress)
+		}
+
 		SuccessResponse(c, "地址更新成功", updatedAddress)
 	}
 }
@@ -307,7 +407,7 @@ func AddToCollectedCardsHandler() gin.HandlerFunc {
 		SuccessResponse(c, "添加收藏成功", gin.H{
 			"word_name": word.WordName,
 			"word_id":   word.ID.Hex(),
-			"user_id":   userID,
+			"user_id":   utils.EncodeOpenIDToSafeID(userID),
 		})
 	}
 }
@@ -355,7 +455,7 @@ func RemoveFromCollectedCardsHandler() gin.HandlerFunc {
 		SuccessResponse(c, "取消收藏成功", gin.H{
 			"word_name": word.WordName,
 			"word_id":   word.ID.Hex(),
-			"user_id":   userID,
+			"user_id":   utils.EncodeOpenIDToSafeID(userID),
 		})
 	}
 }
@@ -376,7 +476,7 @@ func GetCollectedCardsHandler() gin.HandlerFunc {
 		}
 
 		SuccessResponse(c, "获取收藏列表成功", gin.H{
-			"user_id":         userID,
+			"user_id":         utils.EncodeOpenIDToSafeID(userID),
 			"collected_cards": collectedCards,
 			"total_count":     len(collectedCards),
 		})
@@ -426,7 +526,7 @@ func CheckCardCollectedHandler() gin.HandlerFunc {
 		SuccessResponse(c, "检查收藏状态成功", gin.H{
 			"word_name":    word.WordName,
 			"word_id":      word.ID.Hex(),
-			"user_id":      userID,
+			"user_id":      utils.EncodeOpenIDToSafeID(userID),
 			"is_collected": isCollected,
 		})
 	}
@@ -434,7 +534,7 @@ func CheckCardCollectedHandler() gin.HandlerFunc {
 
 // ===== 头像上传功能处理器 =====
 
-// UploadAvatarHandler 上传头像处理器
+// UploadAvatarHandler 上传头像处理器（保存到对象存储，返回临时访问地址）
 func UploadAvatarHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		openID := c.Param("user_id")
@@ -448,8 +548,7 @@ func UploadAvatarHandler() gin.HandlerFunc {
 		defer file.Close()
 
 		// 验证文件大小（2MB限制）
-		const maxFileSize = 2 * 1024 * 1024 // 2MB
-		if header.Size > maxFileSize {
+		if header.Size > MaxAvatarSize {
 			BadRequestResponse(c, "文件大小超过2MB限制", nil)
 			return
 		}
@@ -460,32 +559,46 @@ func UploadAvatarHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 获取文件扩展名
-		ext := getFileExtension(header.Filename)
+		data, err := io.ReadAll(io.LimitReader(file, MaxAvatarSize+1))
+		if err != nil {
+			BadRequestResponse(c, "读取上传文件失败", err)
+			return
+		}
+		if len(data) > MaxAvatarSize {
+			BadRequestResponse(c, "文件大小超过2MB限制", nil)
+			return
+		}
 
-		// 保存文件
-		avatarPath, err := saveAvatarFile(file, openID, ext)
+		user, err := GetUserService().FindUserByOpenID(openID)
 		if err != nil {
-			InternalServerErrorResponse(c, "保存头像文件失败", err)
+			if err == mongo.ErrNoDocuments {
+				NotFoundResponse(c, "用户不存在", err)
+			} else {
+				InternalServerErrorResponse(c, "获取用户信息失败", err)
+			}
 			return
 		}
 
-		// 更新用户头像路径
-		userService := GetUserService()
-		err = userService.UpdateUserAvatar(openID, avatarPath)
+		avatarURL, expiresAt, err := GetUserFileService().SaveAvatar(user, data)
 		if err != nil {
-			InternalServerErrorResponse(c, "更新用户头像信息失败", err)
+			if errors.Is(err, ErrInvalidAvatar) {
+				BadRequestResponse(c, err.Error(), nil)
+			} else {
+				InternalServerErrorResponse(c, "保存头像文件失败", err)
+			}
 			return
 		}
 
 		SuccessResponse(c, "头像上传成功", gin.H{
-			"avatar_path": avatarPath,
-			"user_id":     openID,
+			"avatar_url": avatarURL,
+			"expires_at": expiresAt,
+			"user_id":    utils.EncodeOpenIDToSafeID(openID),
 		})
 	}
 }
 
 // GetAvatarHandler 获取用户头像处理器
+// 对象存储中的头像重定向到临时访问地址，旧版本地头像直接返回文件
 func GetAvatarHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		openID := c.Param("user_id")
@@ -504,16 +617,29 @@ func GetAvatarHandler() gin.HandlerFunc {
 			return
 		}
 
+		// 禁用缓存，确保每次都获取最新头像
+		c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+		c.Header("Pragma", "no-cache")
+		c.Header("Expires", "0")
+
+		if user.AvatarKey != "" {
+			avatarURL, _, err := GetUserFileService().SignedURL(user.AvatarKey)
+			if err != nil {
+				InternalServerErrorResponse(c, "生成头像地址失败", err)
+				return
+			}
+			c.Redirect(http.StatusFound, avatarURL)
+			return
+		}
+
 		// 检查用户是否有头像
 		if user.Avatar == "" {
 			NotFoundResponse(c, "用户未设置头像", nil)
 			return
 		}
 
-		// 构建完整的文件路径
-		// user.Avatar 存储的是相对路径，如 "/image/avatar/openid.jpg"
-		// 需要转换为服务器上的实际路径
-		fullPath := "/www/wwwroot/miniprogram" + user.Avatar
+		// 旧版头像存储的是网站根目录下的相对路径，如 "/image/avatar/openid.jpg"
+		fullPath := avatarFullPath(user.Avatar)
 
 		// 检查文件是否存在
 		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
@@ -521,17 +647,12 @@ func GetAvatarHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 禁用缓存，确保每次都获取最新头像
-		c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
-		c.Header("Pragma", "no-cache")
-		c.Header("Expires", "0")
-
 		// 直接返回文件，Gin会自动设置正确的Content-Type
 		c.File(fullPath)
 	}
 }
 
-// GetUserQRCodeHandler 获取用户二维码处理器
+// GetUserQRCodeHandler 获取用户二维码处理器（返回临时访问地址）
 func GetUserQRCodeHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		openID := c.Param("user_id")
@@ -548,19 +669,86 @@ func GetUserQRCodeHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 检查是否有二维码
-		if user.QRCode == "" {
-			NotFoundResponse(c, "用户二维码不存在", nil)
+		qrCodeURL, expiresAt, err := GetUserFileService().QRCodeURL(user)
+		if err != nil {
+			if errors.Is(err, ErrUserQRCodeNotFound) {
+				NotFoundResponse(c, err.Error(), nil)
+			} else {
+				InternalServerErrorResponse(c, "获取小程序码失败", err)
+			}
 			return
 		}
 
 		SuccessResponse(c, "获取小程序码成功", gin.H{
-			"qr_code": user.QRCode,
-			"scene":   user.ReferralCode,
+			"qr_code_url": qrCodeURL,
+			"expires_at":  expiresAt,
+			"scene":       user.ReferralCode,
 		})
 	}
 }
 
+// ServeSignedFileHandler 本地存储签名地址文件访问处理器（校验签名和有效期）
+func ServeSignedFileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+
+		storage, err := utils.GetObjectStorage()
+		if err != nil {
+			InternalServerErrorResponse(c, "存储服务不可用", err)
+			return
+		}
+		local, ok := storage.(*utils.LocalStorage)
+		if !ok {
+			NotFoundResponse(c, "文件不存在", nil)
+			return
+		}
+
+		if err := local.VerifySignedURL(key, c.Query("expires"), c.Query("signature")); err != nil {
+			ForbiddenResponse(c, err.Error(), nil)
+			return
+		}
+		path, err := local.FilePath(key)
+		if err != nil {
+			ForbiddenResponse(c, err.Error(), nil)
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			NotFoundResponse(c, "文件不存在", nil)
+			return
+		}
+
+		c.Header("Cache-Control", "private, max-age=300")
+		c.File(path)
+	}
+}
+
+// MigrateUserFilesHandler 管理员将旧版 base64 小程序码和本地头像迁移到对象存储处理器（limit 为单批用户数，默认200；after 为上一批返回的 next_cursor）
+func MigrateUserFilesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "200"))
+		if limit < 1 || limit > 1000 {
+			limit = 200
+		}
+		var after primitive.ObjectID
+		if cursor := c.Query("after"); cursor != "" {
+			objectID, err := primitive.ObjectIDFromHex(cursor)
+			if err != nil {
+				BadRequestResponse(c, "after 参数格式无效", err)
+				return
+			}
+			after = objectID
+		}
+
+		result, err := GetUserFileService().MigrateLegacyFiles(limit, after)
+		if err != nil {
+			InternalServerErrorResponse(c, "迁移用户文件失败", err)
+			return
+		}
+
+		SuccessResponse(c, "用户文件迁移完成", result)
+	}
+}
+
 // ===== 头像上传辅助函数 =====
 
 // validateImageFormat 验证图片格式
@@ -583,42 +771,24 @@ func validateImageFormat(header *multipart.FileHeader) error {
 	return nil
 }
 
-// getFileExtension 获取文件扩展名
-func getFileExtension(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	// 统一使用jpg格式
-	if ext == ".jpeg" {
-		return ".jpg"
-	}
-	return ext
+// avatarFullPath 将旧版头像相对路径转换为磁盘路径
+func avatarFullPath(relativePath string) string {
+	return filepath.Join(config.GetConfig().LegacyFileRoot, filepath.Clean("/"+relativePath))
 }
 
-// saveAvatarFile 保存头像文件
-func saveAvatarFile(file multipart.File, openID, ext string) (string, error) {
-	// 创建目标目录
-	avatarDir := "/www/wwwroot/miniprogram/image/avatar"
-	if err := os.MkdirAll(avatarDir, 0755); err != nil {
-		return "", fmt.Errorf("创建头像目录失败: %w", err)
+// removeAvatarFiles 删除用户的旧版本地头像文件（包括历史上传的不同扩展名文件）
+func removeAvatarFiles(openID, relativePath string) {
+	paths := []string{}
+	if relativePath != "" {
+		paths = append(paths, avatarFullPath(relativePath))
 	}
-
-	// 构建文件名和路径
-	filename := openID + ext
-	fullPath := filepath.Join(avatarDir, filename)
-
-	// 创建目标文件
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		return "", fmt.Errorf("创建目标文件失败: %w", err)
+	if matches, err := filepath.Glob(filepath.Join(config.GetConfig().LegacyFileRoot, "image", "avatar", openID+".*")); err == nil {
+		paths = append(paths, matches...)
 	}
-	defer dst.Close()
 
-	// 复制文件内容
-	_, err = io.Copy(dst, file)
-	if err != nil {
-		return "", fmt.Errorf("保存文件失败: %w", err)
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[用户文件] 删除旧版头像文件失败: path=%s, err=%v", path, err)
+		}
 	}
-
-	// 返回相对路径
-	relativePath := "/image/avatar/" + filename
-	return relativePath, nil
 }
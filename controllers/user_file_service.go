@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 用户文件服务层 =====
+
+// MaxAvatarSize 头像文件大小上限
+const MaxAvatarSize = 2 * 1024 * 1024
+
+var (
+	// ErrInvalidAvatar 头像文件格式不支持
+	ErrInvalidAvatar = errors.New("不支持的文件格式，只支持 jpg、png、webp 格式")
+	// ErrUserQRCodeNotFound 用户没有推荐二维码
+	ErrUserQRCodeNotFound = errors.New("用户二维码不存在")
+)
+
+// UserFileService 用户文件服务（头像、推荐二维码），文件保存在对象存储的私有目录，通过签名地址访问
+type UserFileService struct{}
+
+// GetUserFileService 获取用户文件服务实例
+func GetUserFileService() *UserFileService {
+	return &UserFileService{}
+}
+
+// userFileDir 用户文件目录（对 openID 取哈希，避免在地址中暴露）
+func userFileDir(openID string) string {
+	sum := sha256.Sum256([]byte(openID))
+	return utils.PrivateObjectPrefix + "users/" + hex.EncodeToString(sum[:16])
+}
+
+// signedURLTTL 签名地址有效期
+func signedURLTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetConfig().StorageSignedURLTTL)
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+// SignedURL 生成对象的临时访问地址
+func (s *UserFileService) SignedURL(key string) (string, time.Time, error) {
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	ttl := signedURLTTL()
+	signedURL, err := storage.SignedURL(key, ttl)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signedURL, utils.GetCurrentUTCTime().Add(ttl), nil
+}
+
+// putUserFile 保存用户文件到对象存储
+func (s *UserFileService) putUserFile(key string, data []byte, contentType string) error {
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	return storage.Put(ctx, key, data, contentType)
+}
+
+// deleteUserFile 删除对象存储中的用户文件（失败只记录日志）
+func (s *UserFileService) deleteUserFile(key string) {
+	if key == "" {
+		return
+	}
+	storage, err := utils.GetObjectStorage()
+	if err != nil {
+		log.Printf("[用户文件] 删除文件失败: key=%s, err=%v", key, err)
+		return
+	}
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	if err := storage.Delete(ctx, key); err != nil {
+		log.Printf("[用户文件] 删除文件失败: key=%s, err=%v", key, err)
+	}
+}
+
+// detectAvatarFormat 根据文件内容识别头像格式
+func detectAvatarFormat(data []byte) (utils.MediaFormat, error) {
+	format, err := utils.DetectMediaFormat(data, "")
+	if err != nil || format.MediaType != utils.MediaTypeImage || format.ContentType == "image/gif" {
+		return utils.MediaFormat{}, ErrInvalidAvatar
+	}
+	return format, nil
+}
+
+// storeAvatar 保存头像并更新用户记录，返回新的对象键（同时清除旧版本地头像路径）
+func (s *UserFileService) storeAvatar(openID string, data []byte) (string, error) {
+	format, err := detectAvatarFormat(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	key := userFileDir(openID) + "/avatar-" + hex.EncodeToString(sum[:8]) + format.Extension
+	if err := s.putUserFile(key, data, format.ContentType); err != nil {
+		return "", fmt.Errorf("保存头像文件失败: %w", err)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	result, err := GetCollection("users").UpdateOne(ctx, bson.M{"openID": openID}, bson.M{
+		"$set":   bson.M{"avatar_key": key, "updated_at": utils.GetCurrentUTCTime()},
+		"$unset": bson.M{"avatar": ""},
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.MatchedCount == 0 {
+		return "", mongo.ErrNoDocuments
+	}
+	return key, nil
+}
+
+// SaveAvatar 上传新头像，返回头像临时访问地址和过期时间
+func (s *UserFileService) SaveAvatar(user *models.User, data []byte) (string, time.Time, error) {
+	key, err := s.storeAvatar(user.OpenID, data)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// 清理旧头像（内容相同时对象键不变）
+	if user.AvatarKey != "" && user.AvatarKey != key {
+		s.deleteUserFile(user.AvatarKey)
+	}
+	if user.Avatar != "" {
+		removeAvatarFiles(user.OpenID, user.Avatar)
+	}
+
+	return s.SignedURL(key)
+}
+
+// FillAvatarURL 为用户信息填充头像临时访问地址
+func (s *UserFileService) FillAvatarURL(user *models.User) {
+	if user.AvatarKey == "" {
+		return
+	}
+	avatarURL, _, err := s.SignedURL(user.AvatarKey)
+	if err != nil {
+		log.Printf("[用户文件] 生成头像地址失败: key=%s, err=%v", user.AvatarKey, err)
+		return
+	}
+	user.AvatarURL = avatarURL
+}
+
+// ReadAvatar 读取用户头像内容，返回内容和扩展名（兼容旧版本地文件）
+func (s *UserFileService) ReadAvatar(user *models.User) ([]byte, string, error) {
+	if user.AvatarKey != "" {
+		storage, err := utils.GetObjectStorage()
+		if err != nil {
+			return nil, "", err
+		}
+		ctx, cancel := CreateDBContext()
+		defer cancel()
+		content, err := storage.Get(ctx, user.AvatarKey)
+		if err != nil {
+			return nil, "", err
+		}
+		return content, filepath.Ext(user.AvatarKey), nil
+	}
+	if user.Avatar != "" {
+		content, err := os.ReadFile(avatarFullPath(user.Avatar))
+		if err != nil {
+			return nil, "", err
+		}
+		return content, filepath.Ext(user.Avatar), nil
+	}
+	return nil, "", utils.ErrObjectNotFound
+}
+
+// SaveQRCode 保存推荐二维码图片并更新用户记录（同时清除旧版 base64 数据）
+func (s *UserFileService) SaveQRCode(openID string, data []byte) (string, error) {
+	format, err := utils.DetectMediaFormat(data, "")
+	if err != nil || format.MediaType != utils.MediaTypeImage {
+		return "", fmt.Errorf("小程序码图片格式无法识别")
+	}
+	key := userFileDir(openID) + "/qrcode" + format.Extension
+	if err := s.putUserFile(key, data, format.ContentType); err != nil {
+		return "", fmt.Errorf("保存小程序码失败: %w", err)
+	}
+
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	result, err := GetCollection("users").UpdateOne(ctx, bson.M{"openID": openID}, bson.M{
+		"$set":   bson.M{"qr_code_key": key, "updated_at": utils.GetCurrentUTCTime()},
+		"$unset": bson.M{"qr_code": ""},
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.MatchedCount == 0 {
+		return "", mongo.ErrNoDocuments
+	}
+	return key, nil
+}
+
+// QRCodeURL 获取用户推荐二维码的临时访问地址
+// 旧版 base64 数据在访问时迁移到对象存储；没有二维码但有推荐码时重新生成
+func (s *UserFileService) QRCodeURL(user *models.User) (string, time.Time, error) {
+	key := user.QRCodeKey
+	if key == "" && user.QRCode != "" {
+		if data, err := decodeDataURL(user.QRCode); err == nil {
+			if key, err = s.SaveQRCode(user.OpenID, data); err != nil {
+				return "", time.Time{}, err
+			}
+		}
+	}
+	if key == "" && user.ReferralCode != "" {
+		data, err := GetAuthService().generateUserQRCode(user.ReferralCode)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if key, err = s.SaveQRCode(user.OpenID, data); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	if key == "" {
+		return "", time.Time{}, ErrUserQRCodeNotFound
+	}
+	return s.SignedURL(key)
+}
+
+// DeleteUserFiles 删除用户在对象存储和旧版目录中的所有文件（账号注销时使用）
+func (s *UserFileService) DeleteUserFiles(openID string, user *models.User) {
+	avatarPath := ""
+	if user != nil {
+		s.deleteUserFile(user.AvatarKey)
+		s.deleteUserFile(user.QRCodeKey)
+		avatarPath = user.Avatar
+	}
+	removeAvatarFiles(openID, avatarPath)
+}
+
+// decodeDataURL 解析 data:image/...;base64, 格式的数据
+func decodeDataURL(dataURL string) ([]byte, error) {
+	payload := dataURL
+	if strings.HasPrefix(dataURL, "data:") {
+		i := strings.Index(dataURL, ",")
+		if i < 0 || !strings.HasSuffix(dataURL[:i], ";base64") {
+			return nil, fmt.Errorf("无效的 data URL")
+		}
+		payload = dataURL[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("data URL 内容为空")
+	}
+	return data, nil
+}
+
+// legacyUserFileFilter 仍有旧版头像路径或 base64 二维码的用户
+func legacyUserFileFilter() bson.M {
+	return bson.M{"$or": []bson.M{
+		{"qr_code": bson.M{"$exists": true, "$ne": ""}},
+		{"avatar": bson.M{"$exists": true, "$ne": ""}},
+	}}
+}
+
+// unsetLegacyUserField 清除用户的旧版文件字段
+func unsetLegacyUserField(openID, field string) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+	_, err := GetCollection("users").UpdateOne(ctx, bson.M{"openID": openID}, bson.M{"$unset": bson.M{field: ""}})
+	return err
+}
+
+// MigrateLegacyFiles 将旧版 base64 二维码和本地头像迁移到对象存储，每次最多处理 limit 个用户
+// 按 _id 顺序从 after 之后继续处理，返回的 NextCursor 作为下一批的 after，迁移失败的用户不会阻塞后续批次
+func (s *UserFileService) MigrateLegacyFiles(limit int, after primitive.ObjectID) (*models.UserFileMigrationResult, error) {
+	collection := GetCollection("users")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	filter := legacyUserFileFilter()
+	if !after.IsZero() {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": after}}}}
+	}
+	findOptions := options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"openID": 1, "avatar": 1, "avatar_key": 1, "qr_code": 1})
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	result := &models.UserFileMigrationResult{Scanned: len(users)}
+	for i := range users {
+		user := &users[i]
+		failed := false
+
+		if user.QRCode != "" {
+			data, err := decodeDataURL(user.QRCode)
+			switch {
+			case err != nil:
+				// 数据损坏，直接清除，访问时重新生成
+				if err := unsetLegacyUserField(user.OpenID, "qr_code"); err != nil {
+					failed = true
+				} else {
+					result.QRCodesDropped++
+				}
+			default:
+				if _, err := s.SaveQRCode(user.OpenID, data); err != nil {
+					log.Printf("[用户文件迁移] 迁移小程序码失败: user=%s, err=%v", utils.EncodeOpenIDToSafeID(user.OpenID), err)
+					failed = true
+				} else {
+					result.QRCodesMigrated++
+				}
+			}
+		}
+
+		if user.Avatar != "" {
+			data, err := os.ReadFile(avatarFullPath(user.Avatar))
+			switch {
+			case os.IsNotExist(err):
+				if err := unsetLegacyUserField(user.OpenID, "avatar"); err != nil {
+					failed = true
+				} else {
+					result.AvatarsMissing++
+				}
+			case err != nil:
+				log.Printf("[用户文件迁移] 读取头像失败: path=%s, err=%v", user.Avatar, err)
+				failed = true
+			default:
+				key, err := s.storeAvatar(user.OpenID, data)
+				if err != nil {
+					log.Printf("[用户文件迁移] 迁移头像失败: user=%s, err=%v", utils.EncodeOpenIDToSafeID(user.OpenID), err)
+					failed = true
+					break
+				}
+				if user.AvatarKey != "" && user.AvatarKey != key {
+					s.deleteUserFile(user.AvatarKey)
+				}
+				removeAvatarFiles(user.OpenID, user.Avatar)
+				result.AvatarsMigrated++
+			}
+		}
+
+		if failed {
+			result.Failed++
+			result.FailedUserIDs = append(result.FailedUserIDs, utils.EncodeOpenIDToSafeID(user.OpenID))
+		}
+	}
+
+	countCtx, countCancel := CreateDBContext()
+	defer countCancel()
+	remaining, err := collection.CountDocuments(countCtx, legacyUserFileFilter())
+	if err != nil {
+		return nil, err
+	}
+	result.Remaining = remaining
+	// 本批已取满时可能还有后续用户，返回游标继续处理
+	if len(users) == limit {
+		result.NextCursor = users[len(users)-1].ID.Hex()
+	}
+	return result, nil
+}
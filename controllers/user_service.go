@@ -3,6 +3,7 @@ package controllers
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"miniprogram/models"
 	"miniprogram/utils"
 
@@ -17,6 +18,9 @@ import (
 // UserService 用户服务
 type UserService struct{}
 
+// ErrPhoneAlreadyBound 手机号已被其他用户验证绑定
+var ErrPhoneAlreadyBound = errors.New("该手机号已被其他用户绑定")
+
 // GetUserService 获取用户服务实例
 func GetUserService() *UserService {
 	return &UserService{}
@@ -125,6 +129,23 @@ func (s *UserService) UpdateUser(openID string, updates map[string]interface{})
 	return s.FindUserByOpenID(openID)
 }
 
+// BindVerifiedPhone 绑定经微信验证的手机号（同一手机号只能被一个用户验证绑定）
+// 唯一性由 phone_bidx 的唯一索引（仅 phone_verified 为 true 的文档）保证，并发绑定同一手机号时只有一个成功
+func (s *UserService) BindVerifiedPhone(openID, phone, countryCode string) (*models.User, error) {
+	updates := map[string]interface{}{
+		"phone":              models.EncryptedString(phone),
+		"phone_bidx":         utils.PhoneBlindIndex(phone), // 手机号加密存储，通过盲索引比较
+		"phone_verified":     true,
+		"phone_country_code": countryCode,
+		"phone_verified_at":  utils.GetCurrentUTCTime(),
+	}
+	user, err := s.UpdateUser(openID, updates)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrPhoneAlreadyBound
+	}
+	return user, err
+}
+
 // initializeUserArrays 确保用户的数组字段都初始化为空切片
 func (s *UserService) initializeUserArrays(user *models.User) {
 	if user.CollectedCards == nil {
@@ -215,7 +236,8 @@ func (s *UserService) UpdateUserProfile(req models.CreateUserRequest) (*models.U
 		updates["school"] = req.School
 	}
 	if req.Phone != "" {
-		updates["phone"] = req.Phone
+		updates["phone"] = models.EncryptedString(req.Phone)
+		updates["phone_bidx"] = utils.PhoneBlindIndex(req.Phone)
 	}
 	if req.City != "" {
 		updates["city"] = req.City
@@ -227,6 +249,11 @@ func (s *UserService) UpdateUserProfile(req models.CreateUserRequest) (*models.U
 		return nil, err
 	}
 
+	// 手动修改手机号后，原有的验证状态失效
+	if req.Phone != "" && req.Phone != existingUser.Phone.String() {
+		updates["phone_verified"] = false
+	}
+
 	// 推荐码不能和自己的推荐码一样
 	if req.ReferredBy != "" && req.ReferredBy == existingUser.ReferralCode {
 		return nil, &models.ReferralError{
@@ -282,12 +309,12 @@ func (s *AddressService) CreateAddress(openID string, req models.AddressRequest)
 	newAddress := models.Address{
 		ID:            primitive.NewObjectID(),
 		UserOpenID:    openID,
-		RecipientName: req.RecipientName,
-		Phone:         req.Phone,
+		RecipientName: models.EncryptedString(req.RecipientName),
+		Phone:         models.EncryptedString(req.Phone),
 		Province:      req.Province,
 		City:          req.City,
 		District:      req.District,
-		Street:        req.Street,
+		Street:        models.EncryptedString(req.Street),
 		PostalCode:    req.PostalCode,
 		IsDefault:     req.IsDefault,
 		CreatedAt:     utils.GetCurrentUTCTime(),
@@ -350,12 +377,12 @@ func (s *AddressService) UpdateAddress(openID string, addressID primitive.Object
 	for i := range user.Addresses {
 		if user.Addresses[i].ID == addressID {
 			// 更新地址信息
-			user.Addresses[i].RecipientName = req.RecipientName
-			user.Addresses[i].Phone = req.Phone
+			user.Addresses[i].RecipientName = models.EncryptedString(req.RecipientName)
+			user.Addresses[i].Phone = models.EncryptedString(req.Phone)
 			user.Addresses[i].Province = req.Province
 			user.Addresses[i].City = req.City
 			user.Addresses[i].District = req.District
-			user.Addresses[i].Street = req.Street
+			user.Addresses[i].Street = models.EncryptedString(req.Street)
 			user.Addresses[i].PostalCode = req.PostalCode
 			user.Addresses[i].UpdatedAt = utils.GetCurrentUTCTime()
 
@@ -609,60 +636,6 @@ func CheckPassword(hashedPassword, password string) bool {
 	return service.CheckPassword(hashedPassword, password)
 }
 
-// UpdateUserAvatar 更新用户头像
-func (s *UserService) UpdateUserAvatar(openID, avatarPath string) error {
-	collection := GetCollection("users")
-	ctx, cancel := CreateDBContext()
-	defer cancel()
-
-	// 更新用户头像字段
-	filter := bson.M{"openID": openID}
-	update := bson.M{
-		"$set": bson.M{
-			"avatar":     avatarPath,
-			"updated_at": utils.GetCurrentUTCTime(),
-		},
-	}
-
-	result, err := collection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return err
-	}
-
-	if result.MatchedCount == 0 {
-		return mongo.ErrNoDocuments
-	}
-
-	return nil
-}
-
-// UpdateUserQRCode 更新用户二维码
-func (s *UserService) UpdateUserQRCode(openID, qrCode string) error {
-	collection := GetCollection("users")
-	ctx, cancel := CreateDBContext()
-	defer cancel()
-
-	// 更新用户二维码字段
-	filter := bson.M{"openID": openID}
-	update := bson.M{
-		"$set": bson.M{
-			"qr_code":    qrCode,
-			"updated_at": utils.GetCurrentUTCTime(),
-		},
-	}
-
-	result, err := collection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return err
-	}
-
-	if result.MatchedCount == 0 {
-		return mongo.ErrNoDocuments
-	}
-
-	return nil
-}
-
 // SetDefaultAddress 设置默认地址 (向后兼容)
 func SetDefaultAddress(openID string, defaultAddressID primitive.ObjectID) error {
 	service := GetAddressService()
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ===== 用户账号状态服务层（封禁/暂停） =====
+
+var (
+	// ErrAccountBanned 账号已被封禁
+	ErrAccountBanned = errors.New("账号已被封禁")
+	// ErrAccountSuspended 账号已被暂停使用
+	ErrAccountSuspended = errors.New("账号已被暂停使用")
+)
+
+// frozenStatus 账号被封禁或暂停期间佣金、提现记录的状态
+const frozenStatus = "frozen"
+
+// freezableCommissionStatuses 封禁/暂停时需要冻结的佣金状态（只冻结未结算的佣金，已发放的 paid 记录不再改动）
+var freezableCommissionStatuses = []string{"pending"}
+
+// freezableWithdrawStatuses 封禁/暂停时需要冻结的提现状态（已提交微信转账的 processing 记录无法冻结）
+var freezableWithdrawStatuses = []string{"pending"}
+
+// UserStatusService 用户账号状态服务
+type UserStatusService struct{}
+
+// GetUserStatusService 获取用户账号状态服务实例
+func GetUserStatusService() *UserStatusService {
+	return &UserStatusService{}
+}
+
+// CheckAccountStatus 检查账号是否允许登录和访问，封禁或暂停时返回对应错误
+func (s *UserStatusService) CheckAccountStatus(user *models.User) error {
+	switch user.EffectiveAccountStatus(utils.GetCurrentUTCTime()) {
+	case models.AccountStatusBanned:
+		return fmt.Errorf("%w: %s", ErrAccountBanned, user.StatusReason)
+	case models.AccountStatusSuspended:
+		return fmt.Errorf("%w（至 %s）: %s", ErrAccountSuspended,
+			utils.FormatTimeForResponse(user.SuspendedUntil), user.StatusReason)
+	}
+	return nil
+}
+
+// IsAccountBlocked 账号当前是否处于封禁或暂停状态
+func (s *UserStatusService) IsAccountBlocked(openID string) (bool, error) {
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return false, err
+	}
+	return user.EffectiveAccountStatus(utils.GetCurrentUTCTime()) != models.AccountStatusActive, nil
+}
+
+// GetUserStatus 获取用户账号状态及冻结情况
+func (s *UserStatusService) GetUserStatus(openID string) (*models.UserStatusResult, error) {
+	user, err := GetUserService().FindUserByOpenID(openID)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildStatusResult(user)
+}
+
+// UpdateUserStatus 更新用户账号状态
+// 封禁或暂停时冻结用户待结算的佣金和待处理的提现，并使微信会话失效；恢复正常时解冻
+func (s *UserStatusService) UpdateUserStatus(openID string, req models.UpdateUserStatusRequest, operatorOpenID string) (*models.UserStatusResult, error) {
+	userService := GetUserService()
+	if _, err := userService.FindUserByOpenID(openID); err != nil {
+		return nil, err
+	}
+
+	now := utils.GetCurrentUTCTime()
+	updates := map[string]interface{}{
+		"account_status":         req.Status,
+		"status_reason":          req.Reason,
+		"status_updated_at":      now,
+		"status_operator_openid": operatorOpenID,
+	}
+
+	switch req.Status {
+	case models.AccountStatusSuspended:
+		if req.SuspendedUntil.IsZero() || !req.SuspendedUntil.After(now) {
+			return nil, errors.New("暂停截止时间必须晚于当前时间")
+		}
+		updates["suspended_until"] = req.SuspendedUntil.UTC()
+	default:
+		updates["suspended_until"] = time.Time{}
+	}
+
+	user, err := userService.UpdateUser(openID, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Status == models.AccountStatusActive {
+		if err := s.UnfreezeFinancials(openID); err != nil {
+			return nil, fmt.Errorf("解冻佣金和提现失败: %w", err)
+		}
+	} else {
+		if err := s.FreezeFinancials(openID); err != nil {
+			return nil, fmt.Errorf("冻结佣金和提现失败: %w", err)
+		}
+		if err := GetWechatSessionService().InvalidateSession(openID); err != nil {
+			log.Printf("[账号状态] 删除微信会话失败: openID=%s, err=%v", openID, err)
+		}
+	}
+
+	log.Printf("[账号状态] 用户状态已更新: openID=%s, status=%s, operator=%s", openID, req.Status, operatorOpenID)
+	return s.buildStatusResult(user)
+}
+
+// FreezeFinancials 冻结用户的佣金余额和待处理提现，记录冻结前的状态
+func (s *UserStatusService) FreezeFinancials(openID string) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	targets := []struct {
+		collection string
+		statuses   []string
+	}{
+		{"commissions", freezableCommissionStatuses},
+		{"withdrawals", freezableWithdrawStatuses},
+	}
+
+	for _, target := range targets {
+		_, err := GetCollection(target.collection).UpdateMany(ctx,
+			bson.M{"user_openid": openID, "status": bson.M{"$in": target.statuses}},
+			bson.A{bson.M{"$set": bson.M{
+				"status_before_freeze": "$status",
+				"status":               frozenStatus,
+				"updated_at":           now,
+			}}},
+		)
+		if err != nil {
+			return fmt.Errorf("冻结%s失败: %w", target.collection, err)
+		}
+	}
+	return nil
+}
+
+// UnfreezeFinancials 解冻用户的佣金和提现，恢复冻结前的状态
+func (s *UserStatusService) UnfreezeFinancials(openID string) error {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	for _, collectionName := range []string{"commissions", "withdrawals"} {
+		_, err := GetCollection(collectionName).UpdateMany(ctx,
+			bson.M{"user_openid": openID, "status": frozenStatus},
+			bson.A{
+				bson.M{"$set": bson.M{
+					"status":     bson.M{"$ifNull": bson.A{"$status_before_freeze", "pending"}},
+					"updated_at": now,
+				}},
+				bson.M{"$unset": "status_before_freeze"},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("解冻%s失败: %w", collectionName, err)
+		}
+	}
+	return nil
+}
+
+// RestoreExpiredSuspensions 将暂停期已结束的账号恢复为正常状态并解冻佣金和提现
+func (s *UserStatusService) RestoreExpiredSuspensions() (int, error) {
+	collection := GetCollection("users")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"account_status":  models.AccountStatusSuspended,
+		"suspended_until": bson.M{"$lte": utils.GetCurrentUTCTime()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var users []struct {
+		OpenID string `bson:"openID"`
+	}
+	if err := cursor.All(ctx, &users); err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, user := range users {
+		req := models.UpdateUserStatusRequest{
+			Status: models.AccountStatusActive,
+			Reason: "暂停期已结束",
+		}
+		if _, err := s.UpdateUserStatus(user.OpenID, req, ""); err != nil {
+			log.Printf("[账号状态] 恢复暂停账号失败: openID=%s, err=%v", user.OpenID, err)
+			continue
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// buildStatusResult 构建账号状态结果，附带冻结记录数
+func (s *UserStatusService) buildStatusResult(user *models.User) (*models.UserStatusResult, error) {
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	filter := bson.M{"user_openid": user.OpenID, "status": frozenStatus}
+	frozenCommissions, err := GetCollection("commissions").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	frozenWithdrawals, err := GetCollection("withdrawals").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserStatusResult{
+		UserOpenID:        user.OpenID,
+		Status:            user.EffectiveAccountStatus(utils.GetCurrentUTCTime()),
+		Reason:            user.StatusReason,
+		SuspendedUntil:    user.SuspendedUntil,
+		StatusUpdatedAt:   user.StatusUpdatedAt,
+		FrozenCommissions: frozenCommissions,
+		FrozenWithdrawals: frozenWithdrawals,
+	}, nil
+}
+
+// StartUserStatusWorker 启动后台任务，定期恢复暂停期已结束的账号
+func StartUserStatusWorker(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			restored, err := GetUserStatusService().RestoreExpiredSuspensions()
+			if err != nil {
+				log.Printf("[账号状态] 恢复暂停账号失败: %v", err)
+				continue
+			}
+			if restored > 0 {
+				log.Printf("[账号状态] 已恢复暂停期结束的账号: %d", restored)
+			}
+		}
+	}()
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"miniprogram/config"
+	"miniprogram/models"
+	"miniprogram/utils"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== 微信会话服务层 =====
+
+// ErrWechatSessionExpired 微信会话不存在或已过期，需要重新登录
+var ErrWechatSessionExpired = errors.New("微信会话已过期，请重新登录")
+
+// WechatSessionService 微信会话服务
+type WechatSessionService struct{}
+
+// GetWechatSessionService 获取微信会话服务实例
+func GetWechatSessionService() *WechatSessionService {
+	return &WechatSessionService{}
+}
+
+// getSessionTTL 获取会话有效期
+func (s *WechatSessionService) getSessionTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetConfig().WechatSessionTTL)
+	if err != nil || ttl <= 0 {
+		return 72 * time.Hour
+	}
+	return ttl
+}
+
+// SaveSession 加密保存用户的 session_key（每个用户只保留最新的一条）
+func (s *WechatSessionService) SaveSession(openID, sessionKey, unionID string) error {
+	encrypted, err := utils.EncryptSessionKey(sessionKey)
+	if err != nil {
+		return fmt.Errorf("加密session_key失败: %w", err)
+	}
+
+	collection := GetCollection("wechat_sessions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	now := utils.GetCurrentUTCTime()
+	update := bson.M{
+		"$set": bson.M{
+			"session_key_encrypted": encrypted,
+			"unionid":               unionID,
+			"expires_at":            now.Add(s.getSessionTTL()),
+			"updated_at":            now,
+		},
+		"$setOnInsert": bson.M{
+			"user_openid": openID,
+			"created_at":  now,
+		},
+	}
+
+	_, err = collection.UpdateOne(ctx, bson.M{"user_openid": openID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetSession 获取用户当前有效的会话
+func (s *WechatSessionService) GetSession(openID string) (*models.WechatSession, error) {
+	collection := GetCollection("wechat_sessions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	var session models.WechatSession
+	err := collection.FindOne(ctx, bson.M{"user_openid": openID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrWechatSessionExpired
+		}
+		return nil, err
+	}
+
+	if utils.GetCurrentUTCTime().After(session.ExpiresAt) {
+		return nil, ErrWechatSessionExpired
+	}
+
+	return &session, nil
+}
+
+// GetSessionKey 获取用户当前有效的明文 session_key
+func (s *WechatSessionService) GetSessionKey(openID string) (string, error) {
+	session, err := s.GetSession(openID)
+	if err != nil {
+		return "", err
+	}
+	return decryptStoredSessionKey(openID, session)
+}
+
+// decryptStoredSessionKey 解密存储的 session_key；更换 WECHAT_SESSION_SECRET 后旧会话无法解密，按会话过期处理，客户端重新登录即可
+func decryptStoredSessionKey(openID string, session *models.WechatSession) (string, error) {
+	sessionKey, err := utils.DecryptSessionKey(session.SessionKeyEncrypted)
+	if err != nil {
+		log.Printf("[微信会话] 解密session_key失败，需要重新登录: openID=%s, err=%v", openID, err)
+		return "", ErrWechatSessionExpired
+	}
+	return sessionKey, nil
+}
+
+// InvalidateSession 删除用户的会话
+func (s *WechatSessionService) InvalidateSession(openID string) error {
+	collection := GetCollection("wechat_sessions")
+	ctx, cancel := CreateDBContext()
+	defer cancel()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"user_openid": openID})
+	return err
+}
+
+// DecryptUserData 使用用户的 session_key 解密 encryptedData，并校验数据水印
+func (s *WechatSessionService) DecryptUserData(openID, encryptedData, iv string) (map[string]interface{}, error) {
+	// 1. 获取有效的 session_key
+	sessionKey, err := s.GetSessionKey(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. AES-128-CBC 解密
+	plaintext, err := utils.DecryptWechatData(sessionKey, encryptedData, iv)
+	if err != nil {
+		return nil, fmt.Errorf("解密失败: %w", err)
+	}
+
+	// 3. 解析数据并校验水印
+	var data map[string]interface{}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, fmt.Errorf("解析解密数据失败: %w", err)
+	}
+
+	watermark, ok := data["watermark"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("解密数据缺少水印")
+	}
+	if appID, _ := watermark["appid"].(string); appID != config.GetConfig().WechatAppID {
+		return nil, errors.New("解密数据水印appid不匹配")
+	}
+
+	return data, nil
+}
+
+// CheckSession 检查用户会话是否仍然有效
+// 先检查服务端存储的有效期，再调用微信 checksession 接口确认 session_key 未被刷新
+func (s *WechatSessionService) CheckSession(openID string) (*models.WechatSession, error) {
+	session, err := s.GetSession(openID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey, err := decryptStoredSessionKey(openID, session)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, err := s.checkSessionWithWechat(openID, sessionKey)
+	if err != nil {
+		// 微信接口不可用时以服务端有效期为准
+		log.Printf("[微信会话] 调用checksession失败，使用本地有效期: openID=%s, err=%v", openID, err)
+		return session, nil
+	}
+	if !valid {
+		if err := s.InvalidateSession(openID); err != nil {
+			log.Printf("[微信会话] 删除失效会话失败: openID=%s, err=%v", openID, err)
+		}
+		return nil, ErrWechatSessionExpired
+	}
+
+	return session, nil
+}
+
+// checkSessionWithWechat 调用微信 checksession 接口校验 session_key
+func (s *WechatSessionService) checkSessionWithWechat(openID, sessionKey string) (bool, error) {
+	tokenService := GetWechatAccessTokenService()
+	accessToken, err := tokenService.GetAccessToken()
+	if err != nil {
+		return false, fmt.Errorf("获取微信访问令牌失败: %w", err)
+	}
+
+	// 签名为以 session_key 为密钥对空字符串做 HMAC-SHA256
+	mac := hmac.New(sha256.New, []byte(sessionKey))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	cfg := config.GetConfig()
+	params := url.Values{}
+	params.Add("access_token", accessToken)
+	params.Add("signature", signature)
+	params.Add("openid", openID)
+	params.Add("sig_method", "hmac_sha256")
+	apiURL := cfg.WechatAPIURL + "/wxa/checksession?" + params.Encode()
+
+	response, err := http.Get(apiURL)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	var data models.WechatCheckSessionResponse
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return false, fmt.Errorf("解析微信API响应失败: %w", err)
+	}
+
+	switch data.ErrCode {
+	case 0:
+		return true, nil
+	case 87009:
+		// 签名无效，说明 session_key 已失效
+		return false, nil
+	case 40001, 42001:
+		tokenService.InvalidateAccessToken()
+	}
+	return false, fmt.Errorf("微信API错误: %d - %s", data.ErrCode, data.ErrMsg)
+}
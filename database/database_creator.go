@@ -81,6 +81,78 @@ func (dc *DatabaseCreator) CreateAllCollections(ctx context.Context) error {
 		return fmt.Errorf("创建单词集合失败: %v", err)
 	}
 
+	if err := dc.CreateWechatSessionsCollection(ctx); err != nil {
+		return fmt.Errorf("创建微信会话集合失败: %v", err)
+	}
+
+	if err := dc.CreateAccountDeletionsCollection(ctx); err != nil {
+		return fmt.Errorf("创建账号注销集合失败: %v", err)
+	}
+
+	if err := dc.CreateWordReviewsCollection(ctx); err != nil {
+		return fmt.Errorf("创建单词复习集合失败: %v", err)
+	}
+
+	if err := dc.CreateLearningProgressCollection(ctx); err != nil {
+		return fmt.Errorf("创建学习进度集合失败: %v", err)
+	}
+
+	if err := dc.CreateProgressAppliedChangesCollection(ctx); err != nil {
+		return fmt.Errorf("创建进度变更登记集合失败: %v", err)
+	}
+
+	if err := dc.CreateQuizzesCollection(ctx); err != nil {
+		return fmt.Errorf("创建测验集合失败: %v", err)
+	}
+
+	if err := dc.CreateMistakeWordsCollection(ctx); err != nil {
+		return fmt.Errorf("创建错题本集合失败: %v", err)
+	}
+
+	if err := dc.CreateStudySessionsCollection(ctx); err != nil {
+		return fmt.Errorf("创建学习会话集合失败: %v", err)
+	}
+
+	if err := dc.CreateStudyDailyStatsCollection(ctx); err != nil {
+		return fmt.Errorf("创建每日学习统计集合失败: %v", err)
+	}
+
+	if err := dc.CreateClassroomsCollection(ctx); err != nil {
+		return fmt.Errorf("创建班级集合失败: %v", err)
+	}
+
+	if err := dc.CreateLeaderboardEntriesCollection(ctx); err != nil {
+		return fmt.Errorf("创建排行榜集合失败: %v", err)
+	}
+
+	if err := dc.CreateFamilyLinksCollection(ctx); err != nil {
+		return fmt.Errorf("创建家庭共享集合失败: %v", err)
+	}
+
+	if err := dc.CreateActivationCollections(ctx); err != nil {
+		return fmt.Errorf("创建激活码集合失败: %v", err)
+	}
+
+	if err := dc.CreateOfflineAnswersCollection(ctx); err != nil {
+		return fmt.Errorf("创建离线作答集合失败: %v", err)
+	}
+
+	if err := dc.CreateMediaAssetsCollection(ctx); err != nil {
+		return fmt.Errorf("创建媒体资源集合失败: %v", err)
+	}
+
+	if err := dc.CreateSearchHistoryCollection(ctx); err != nil {
+		return fmt.Errorf("创建搜索历史集合失败: %v", err)
+	}
+
+	if err := dc.CreateSearchQueryStatsCollection(ctx); err != nil {
+		return fmt.Errorf("创建搜索词统计集合失败: %v", err)
+	}
+
+	if err := dc.CreateSearchRulesCollection(ctx); err != nil {
+		return fmt.Errorf("创建搜索词规则集合失败: %v", err)
+	}
+
 	log.Println("所有MongoDB集合创建完成!")
 	return nil
 }
@@ -103,8 +175,9 @@ func (dc *DatabaseCreator) CreateUsersCollection(ctx context.Context) error {
 			Options: options.Index().SetUnique(true),
 		},
 		{
-			Keys:    bson.D{{Key: "phone", Value: 1}},
-			Options: options.Index().SetUnique(true),
+			// 手机号加密存储，唯一性通过盲索引保证；仅已验证的手机号需要唯一
+			Keys:    bson.D{{Key: "phone_bidx", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"phone_verified": true}),
 		},
 		{
 			Keys:    bson.D{{Key: "referral_code", Value: 1}},
@@ -116,6 +189,10 @@ func (dc *DatabaseCreator) CreateUsersCollection(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "school", Value: 1}},
 		},
+		{
+			// 用于查找暂停期已结束的账号
+			Keys: bson.D{{Key: "account_status", Value: 1}, {Key: "suspended_until", Value: 1}},
+		},
 		{
 			Keys: bson.D{{Key: "agent_level", Value: 1}},
 		},
@@ -494,6 +571,10 @@ func (dc *DatabaseCreator) CreateBooksCollection(ctx context.Context) error {
 			Keys:    bson.D{{Key: "book_name", Value: 1}, {Key: "book_version", Value: 1}},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			// 搜索键（书名、作者等的前缀和中文双字）
+			Keys: bson.D{{Key: "search_keys", Value: 1}},
+		},
 	}
 
 	_, err := collection.Indexes().CreateMany(ctx, indexes)
@@ -532,7 +613,7 @@ func (dc *DatabaseCreator) CreateUnitsCollection(ctx context.Context) error {
 	// 创建索引
 	indexes := []mongo.IndexModel{
 		{
-			Keys: bson.D{{Key: "book_id", Value: 1}},
+			Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "sort_order", Value: 1}},
 		},
 		{
 			Keys:    bson.D{{Key: "unit_name", Value: 1}, {Key: "book_id", Value: 1}},
@@ -550,6 +631,7 @@ func (dc *DatabaseCreator) CreateUnitsCollection(ctx context.Context) error {
 		"_id":        primitive.NewObjectID(),
 		"unit_name":  "Unit 1: Greetings",
 		"book_id":    primitive.NewObjectID(), // 实际使用时应该引用真实的书籍ID
+		"sort_order": 1,
 		"created_at": time.Now(),
 		"updated_at": time.Now(),
 	}
@@ -585,6 +667,13 @@ func (dc *DatabaseCreator) CreateWordsCollection(ctx context.Context) error {
 			Keys:    bson.D{{Key: "word_name", Value: 1}, {Key: "unit_id", Value: 1}},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			Keys: bson.D{{Key: "relations.word_id", Value: 1}},
+		},
+		{
+			// 搜索键（单词、音标、释义、例句等的前缀和中文双字）
+			Keys: bson.D{{Key: "search_keys", Value: 1}},
+		},
 	}
 
 	_, err := collection.Indexes().CreateMany(ctx, indexes)
@@ -613,3 +702,536 @@ func (dc *DatabaseCreator) CreateWordsCollection(ctx context.Context) error {
 	log.Printf("集合 %s 创建成功", collectionName)
 	return nil
 }
+
+// CreateWechatSessionsCollection 创建微信会话集合（加密的session_key，过期后自动删除）
+func (dc *DatabaseCreator) CreateWechatSessionsCollection(ctx context.Context) error {
+	collectionName := "wechat_sessions"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_openid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateAccountDeletionsCollection 创建账号注销申请集合
+func (dc *DatabaseCreator) CreateAccountDeletionsCollection(ctx context.Context) error {
+	collectionName := "account_deletions"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			// 每个用户同时只能有一个冷静期中的注销申请
+			Keys:    bson.D{{Key: "user_openid", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": "pending"}),
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "scheduled_at", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateWordReviewsCollection 创建单词复习状态集合（SM-2 间隔重复）
+func (dc *DatabaseCreator) CreateWordReviewsCollection(ctx context.Context) error {
+	collectionName := "word_reviews"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "word_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// 用于查询到期复习
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "book_id", Value: 1}, {Key: "due_at", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateLearningProgressCollection 创建单元学习进度集合（每个用户每个单元一条）
+func (dc *DatabaseCreator) CreateLearningProgressCollection(ctx context.Context) error {
+	collectionName := "learning_progress"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "unit_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "book_id", Value: 1}},
+		},
+		{
+			// 用于拉取 since 之后更新的单元进度
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "updated_at", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateQuizzesCollection 创建单词测验集合
+func (dc *DatabaseCreator) CreateQuizzesCollection(ctx context.Context) error {
+	collectionName := "quizzes"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			// 用于分页查询测验记录
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			// 用于按访问键获取听音题发音
+			Keys: bson.D{{Key: "questions.audio_key", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateMistakeWordsCollection 创建错题本集合（每个用户每个单词一条）
+func (dc *DatabaseCreator) CreateMistakeWordsCollection(ctx context.Context) error {
+	collectionName := "mistake_words"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "word_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// 用于按书籍、单元筛选错题
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "status", Value: 1}, {Key: "book_id", Value: 1}, {Key: "unit_id", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateStudySessionsCollection 创建学习会话集合
+func (dc *DatabaseCreator) CreateStudySessionsCollection(ctx context.Context) error {
+	collectionName := "study_sessions"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "started_at", Value: -1}},
+		},
+		{
+			// 每个用户同时只有一个进行中的会话（已有多个进行中会话的用户需先结束多余的会话才能建立索引）
+			Keys:    bson.D{{Key: "user_openid", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": "active"}),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateStudyDailyStatsCollection 创建每日学习统计集合（每个用户每天一条）
+func (dc *DatabaseCreator) CreateStudyDailyStatsCollection(ctx context.Context) error {
+	collectionName := "study_daily_stats"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// 用于计算连续学习天数
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "goal_met", Value: 1}, {Key: "date", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateClassroomsCollection 创建班级集合
+func (dc *DatabaseCreator) CreateClassroomsCollection(ctx context.Context) error {
+	collectionName := "classrooms"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "join_code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "teacher_openid", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			// 用于查询学生加入的班级
+			Keys: bson.D{{Key: "students.student_openid", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateLeaderboardEntriesCollection 创建排行榜快照集合（每个用户每个统计周期一条）
+func (dc *DatabaseCreator) CreateLeaderboardEntriesCollection(ctx context.Context) error {
+	collectionName := "leaderboard_entries"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "period_key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "period_key", Value: 1}, {Key: "school", Value: 1}, {Key: "class", Value: 1}, {Key: "words_mastered", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "period_key", Value: 1}, {Key: "school", Value: 1}, {Key: "study_seconds", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "period_key", Value: 1}, {Key: "city", Value: 1}, {Key: "words_mastered", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "period_key", Value: 1}, {Key: "city", Value: 1}, {Key: "study_seconds", Value: -1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateFamilyLinksCollection 创建家庭共享关系集合（每个成员只能加入一个家庭）
+func (dc *DatabaseCreator) CreateFamilyLinksCollection(ctx context.Context) error {
+	collectionName := "family_links"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	// 创建索引
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "member_openid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "owner_openid", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateActivationCollections 创建激活码批次和激活码集合
+func (dc *DatabaseCreator) CreateActivationCollections(ctx context.Context) error {
+	batchCollectionName := "activation_batches"
+	log.Printf("创建集合: %s", batchCollectionName)
+
+	batchIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "batch_no", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	}
+	if _, err := dc.db.Collection(batchCollectionName).Indexes().CreateMany(ctx, batchIndexes); err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+	log.Printf("集合 %s 创建成功", batchCollectionName)
+
+	codeCollectionName := "activation_codes"
+	log.Printf("创建集合: %s", codeCollectionName)
+
+	codeIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "batch_id", Value: 1}, {Key: "status", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "redeemed_by_openid", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	}
+	if _, err := dc.db.Collection(codeCollectionName).Indexes().CreateMany(ctx, codeIndexes); err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+	log.Printf("集合 %s 创建成功", codeCollectionName)
+
+	return nil
+}
+
+// CreateOfflineAnswersCollection 创建离线作答集合
+func (dc *DatabaseCreator) CreateOfflineAnswersCollection(ctx context.Context) error {
+	collectionName := "offline_answers"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			// 按客户端作答ID去重
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "answer_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// 去重记录保留90天（离线作答最多接受30天前的数据）
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(90 * 24 * 3600),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateMediaAssetsCollection 创建媒体资源集合
+func (dc *DatabaseCreator) CreateMediaAssetsCollection(ctx context.Context) error {
+	collectionName := "media_assets"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			// 按内容哈希去重
+			Keys:    bson.D{{Key: "sha256", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "media_type", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateProgressAppliedChangesCollection 创建学习进度已应用变更集合（变更ID幂等登记）
+func (dc *DatabaseCreator) CreateProgressAppliedChangesCollection(ctx context.Context) error {
+	collectionName := "progress_applied_changes"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			// 同一用户的变更ID只能登记一次
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "change_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// 登记保留90天，超过90天后再次提交同一变更会被重新应用
+			Keys:    bson.D{{Key: "applied_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(90 * 24 * 3600),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+func (dc *DatabaseCreator) CreateSearchHistoryCollection(ctx context.Context) error {
+	collectionName := "search_history"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			// 同一用户相同的关键词只保留一条
+			Keys:    bson.D{{Key: "user_openid", Value: 1}, {Key: "normalized", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_openid", Value: 1}, {Key: "last_searched_at", Value: -1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateSearchQueryStatsCollection 创建搜索词统计集合
+func (dc *DatabaseCreator) CreateSearchQueryStatsCollection(ctx context.Context) error {
+	collectionName := "search_query_stats"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			// 每个关键词每小时一条
+			Keys:    bson.D{{Key: "query", Value: 1}, {Key: "bucket", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// 统计数据保留62天（最长统计窗口为30天，飙升榜需要对比上一个窗口）
+			Keys:    bson.D{{Key: "bucket", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(62 * 24 * 3600),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
+
+// CreateSearchRulesCollection 创建搜索词规则集合（屏蔽词、置顶建议）
+func (dc *DatabaseCreator) CreateSearchRulesCollection(ctx context.Context) error {
+	collectionName := "search_rules"
+	log.Printf("创建集合: %s", collectionName)
+
+	collection := dc.db.Collection(collectionName)
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "rule_type", Value: 1}, {Key: "normalized", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	log.Printf("集合 %s 创建成功", collectionName)
+	return nil
+}
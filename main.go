@@ -6,6 +6,7 @@ import (
 	"miniprogram/controllers"
 	"miniprogram/middlewares"
 	"miniprogram/routes"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -20,6 +21,9 @@ func main() {
 
 	// 获取配置
 	cfg := config.GetConfig()
+	if err := cfg.ValidateSecrets(); err != nil {
+		log.Fatal("配置检查失败: ", err)
+	}
 
 	// 初始化MongoDB连接
 	controllers.InitMongoDB()
@@ -35,6 +39,18 @@ func main() {
 	// 设置middleware中的数据库访问函数
 	middlewares.SetGetCollectionFunc(controllers.GetCollection)
 
+	// 启动账号注销后台任务（每小时执行冷静期已结束的注销申请）
+	controllers.StartAccountDeletionWorker(time.Hour)
+
+	// 启动账号状态后台任务（恢复暂停期已结束的账号并解冻佣金和提现）
+	controllers.StartUserStatusWorker(10 * time.Minute)
+
+	// 后台补全缺少搜索键的单词和课本（升级后无需手动重建搜索索引）
+	controllers.StartSearchKeyBackfill()
+
+	// 启动排行榜后台任务（启动时全量统计，之后每10分钟增量刷新）
+	controllers.StartLeaderboardWorker(10 * time.Minute)
+
 	// 创建Gin路由器
 	r := gin.Default()
 
@@ -45,6 +61,16 @@ func main() {
 	// 设置路由
 	routes.SetupRoutes(r)
 
+	// 测试身份登录接口使用单独的内部监听地址（仅非生产环境且显式启用时）
+	internal := gin.Default()
+	if routes.SetupTestIdentityRoutes(internal) {
+		go func() {
+			if err := internal.Run(cfg.TestIdentityListen); err != nil {
+				log.Printf("测试身份内部监听启动失败: %v", err)
+			}
+		}()
+	}
+
 	// 启动服务器
 	log.Printf("服务器启动在端口 %s", cfg.ServerPort)
 	log.Printf("基础API地址: %s", cfg.BaseAPIURL)
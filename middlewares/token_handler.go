@@ -3,17 +3,20 @@ package middlewares
 import (
 	"context"
 	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"miniprogram/config"
+	"miniprogram/models"
 	"miniprogram/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // 专用上下文键类型，避免与外部包产生冲突
@@ -32,7 +35,7 @@ type User struct {
 // 定义JWT声明结构体
 type Claims struct {
 	UserName string
-	UserId   string // 注意：这里存储的是用户的 OpenID，不是 MongoDB 的 _id
+	UserId   string // 令牌中存储安全用户标识符，ValidateToken 后还原为用户的 OpenID（不是 MongoDB 的 _id）
 	jwt.RegisteredClaims
 }
 
@@ -44,10 +47,10 @@ func GenerateToken(user User) (string, error) {
 	// 设置过期时间 - 此处设置为24小时
 	expirationTime := utils.GetCurrentUTCTime().Add(24 * time.Hour)
 
-	// 创建JWT声明
+	// 创建JWT声明（令牌对客户端可见，只写入安全用户标识符）
 	claims := &Claims{
 		UserName: user.UserName,
-		UserId:   user.UserId,
+		UserId:   utils.EncodeOpenIDToSafeID(user.UserId),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(utils.GetCurrentUTCTime()),
@@ -83,6 +86,13 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("令牌无效")
 	}
 
+	// 将安全用户标识符还原为openID（早期签发的令牌直接存储openID）
+	openID, err := utils.ResolveUserID(claims.UserId)
+	if err != nil {
+		return nil, errors.New("令牌用户标识无效")
+	}
+	claims.UserId = openID
+
 	return claims, nil
 }
 
@@ -128,14 +138,76 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 检查账号是否被封禁或暂停
+		blocked, message, err := checkAccountStatus(claims.UserId)
+		if err != nil {
+			// 无法确认账号状态时拒绝请求，避免被封禁或暂停的账号在数据库异常期间继续访问
+			log.Printf("[账号状态] 查询失败: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"code":    503,
+				"message": "暂时无法验证账号状态，请稍后重试",
+				"error":   "account_status_unavailable",
+			})
+			c.Abort()
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": message,
+				"error":   "account_blocked",
+			})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息添加到gin上下文
 		c.Set("user", claims)
+		c.Set("user_openid", claims.UserId)
 
 		// 继续处理请求
 		c.Next()
 	}
 }
 
+// checkAccountStatus 检查用户账号状态，返回是否被封禁或暂停以及提示信息
+// 只查询状态字段；用户不存在时放行，由后续处理器处理；查询失败时返回错误
+func checkAccountStatus(openID string) (bool, string, error) {
+	if GetCollectionFunc == nil {
+		return false, "", nil
+	}
+
+	collection := GetCollectionFunc("users")
+	cfg := config.GetConfig()
+	timeout, err := time.ParseDuration(cfg.MongoDBTimeout)
+	if err != nil {
+		timeout = 10 * time.Second // 默认超时时间
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{
+		"account_status":  1,
+		"status_reason":   1,
+		"suspended_until": 1,
+	})
+	if err := collection.FindOne(ctx, bson.M{"openID": openID}, opts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, "", nil
+		}
+		return false, "", err
+	}
+
+	switch user.EffectiveAccountStatus(utils.GetCurrentUTCTime()) {
+	case models.AccountStatusBanned:
+		return true, "账号已被封禁: " + user.StatusReason, nil
+	case models.AccountStatusSuspended:
+		return true, "账号已被暂停使用至 " + utils.FormatTimeForResponse(user.SuspendedUntil) + ": " + user.StatusReason, nil
+	}
+	return false, "", nil
+}
+
 // 从Gin上下文中提取Bearer token
 func ExtractBearerTokenFromGin(c *gin.Context) (string, error) {
 	authHeader := c.GetHeader("Authorization")
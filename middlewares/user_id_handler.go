@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"miniprogram/config"
+	"miniprogram/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveUserIDMiddleware 用户标识解析中间件
+// 将路径参数 :user_id 中的安全用户标识符（uid_...）解码为openID，后续处理器仍按openID处理
+// 配置 REJECT_RAW_OPENID_PARAMS=true 后，不再接受直接传入的原始openID
+func ResolveUserIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for i, param := range c.Params {
+			if param.Key != "user_id" {
+				continue
+			}
+
+			if !utils.IsSafeUserID(param.Value) {
+				if config.GetConfig().RejectRawOpenIDParams {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"code":    400,
+						"message": "请使用安全用户标识符访问",
+						"error":   "invalid_user_id",
+					})
+					c.Abort()
+					return
+				}
+				continue
+			}
+
+			openID, err := utils.DecodeSafeIDToOpenID(param.Value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"code":    400,
+					"message": "无效的用户标识符",
+					"error":   "invalid_user_id",
+				})
+				c.Abort()
+				return
+			}
+			c.Params[i].Value = openID
+		}
+
+		c.Next()
+	}
+}
@@ -1,11 +1,52 @@
 package models
 
 import (
+	"miniprogram/utils"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// EncryptedString 静态加密的敏感字符串
+// 写入MongoDB时自动加密（enc:版本:密文），读取时自动解密；JSON序列化为明文，响应前需按权限脱敏
+type EncryptedString string
+
+// MarshalBSONValue 写入时使用当前版本密钥加密
+func (s EncryptedString) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	encrypted, err := utils.EncryptPII(string(s))
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(encrypted)
+}
+
+// UnmarshalBSONValue 读取时解密，兼容历史明文数据
+func (s *EncryptedString) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.Null || t == bsontype.Undefined {
+		*s = ""
+		return nil
+	}
+
+	var raw string
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(&raw); err != nil {
+		return err
+	}
+
+	plaintext, err := utils.DecryptPII(raw)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// String 返回明文
+func (s EncryptedString) String() string {
+	return string(s)
+}
+
 // ReferralError 推荐码相关错误
 type ReferralError struct {
 	Code    string `json:"code"`
@@ -25,13 +66,16 @@ type User struct {
 	Class          string             `bson:"class" json:"class"`
 	Age            int                `bson:"age" json:"age"`
 	School         string             `bson:"school" json:"school"`
-	Phone          string             `bson:"phone" json:"phone"`
+	Phone          EncryptedString    `bson:"phone" json:"phone"` // 加密存储
 	City           string             `bson:"city" json:"city"`
 	AgentLevel     int                `bson:"agent_level" json:"agent_level"`
 	ReferralCode   string             `bson:"referral_code" json:"referral_code"`
 	ReferredBy     string             `bson:"referred_by" json:"referred_by"`
-	Avatar         string             `bson:"avatar" json:"avatar"`                   // 用户头像路径
-	QRCode         string             `bson:"qr_code" json:"qr_code"`                 // 用户推荐二维码base64数据
+	Avatar         string             `bson:"avatar" json:"avatar"`                   // 用户头像路径（旧版本地文件，迁移到对象存储后清空）
+	AvatarKey      string             `bson:"avatar_key,omitempty" json:"-"`          // 头像在对象存储中的键
+	AvatarURL      string             `bson:"-" json:"avatar_url,omitempty"`          // 头像临时访问地址（响应时生成）
+	QRCode         string             `bson:"qr_code,omitempty" json:"-"`             // 旧版推荐二维码base64数据（待迁移）
+	QRCodeKey      string             `bson:"qr_code_key,omitempty" json:"-"`         // 推荐二维码在对象存储中的键
 	CollectedCards []CollectedCard    `bson:"collected_cards" json:"collected_cards"` // 收藏的单词卡列表
 	UnlockedBooks  []BookPermission   `bson:"unlocked_books" json:"unlocked_books"`   // 已解锁的书籍权限
 	Addresses      []Address          `bson:"addresses" json:"addresses"`
@@ -48,10 +92,68 @@ type User struct {
 	AccumulatedSales        float64 `bson:"accumulated_sales" json:"accumulated_sales"`                   // 累计销售额（用于提成计算）
 	HasUsedReferralDiscount bool    `bson:"has_used_referral_discount" json:"has_used_referral_discount"` // 是否已使用过推荐优惠
 
+	// 手机号验证字段（通过微信 getPhoneNumber 绑定）
+	PhoneVerified    bool      `bson:"phone_verified" json:"phone_verified"`
+	PhoneCountryCode string    `bson:"phone_country_code,omitempty" json:"phone_country_code,omitempty"`
+	PhoneVerifiedAt  time.Time `bson:"phone_verified_at,omitempty" json:"phone_verified_at,omitempty"`
+	PhoneIndex       string    `bson:"phone_bidx,omitempty" json:"-"` // 手机号盲索引，用于等值查询
+
+	// 权限字段
+	Permissions []string `bson:"permissions,omitempty" json:"permissions,omitempty"` // 细粒度权限，如 view_pii
+
+	// 账号状态字段（封禁/暂停）
+	AccountStatus   string    `bson:"account_status,omitempty" json:"account_status,omitempty"` // active, suspended, banned，空值视为 active
+	StatusReason    string    `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	SuspendedUntil  time.Time `bson:"suspended_until,omitempty" json:"suspended_until,omitempty"` // 暂停截止时间
+	StatusUpdatedAt time.Time `bson:"status_updated_at,omitempty" json:"status_updated_at,omitempty"`
+	StatusOperator  string    `bson:"status_operator_openid,omitempty" json:"status_operator_openid,omitempty"` // 执行操作的管理员OpenID
+
+	// 学习目标
+	StudyGoal *StudyGoal `bson:"study_goal,omitempty" json:"study_goal,omitempty"`
+
+	// 排行榜设置
+	LeaderboardOptOut bool `bson:"leaderboard_opt_out" json:"leaderboard_opt_out"` // 不出现在排行榜中
+
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
+// 用户细粒度权限
+const (
+	PermissionViewPII = "view_pii" // 查看完整的手机号、收货人、详细地址等敏感信息
+	PermissionTeacher = "teacher"  // 教师：创建班级、布置单元并查看本班学生的学习情况
+)
+
+// HasPermission 用户是否被授予指定的细粒度权限
+func (u *User) HasPermission(permission string) bool {
+	for _, granted := range u.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// 用户账号状态
+const (
+	AccountStatusActive    = "active"    // 正常
+	AccountStatusSuspended = "suspended" // 暂停使用至指定时间
+	AccountStatusBanned    = "banned"    // 永久封禁
+)
+
+// EffectiveAccountStatus 获取账号当前生效的状态（暂停到期后视为正常）
+func (u *User) EffectiveAccountStatus(now time.Time) string {
+	switch u.AccountStatus {
+	case AccountStatusBanned:
+		return AccountStatusBanned
+	case AccountStatusSuspended:
+		if u.SuspendedUntil.IsZero() || now.Before(u.SuspendedUntil) {
+			return AccountStatusSuspended
+		}
+	}
+	return AccountStatusActive
+}
+
 // Progress 学习进度结构体
 type Progress struct {
 	CurrentUnit  string   `bson:"current_unit" json:"current_unit"`
@@ -60,11 +162,42 @@ type Progress struct {
 
 // BookPermission 书籍权限结构体
 type BookPermission struct {
-	BookID     primitive.ObjectID `bson:"book_id" json:"book_id"`         // 书籍ID
-	BookName   string             `bson:"book_name" json:"book_name"`     // 书籍名称（便于查询）
-	AccessType string             `bson:"access_type" json:"access_type"` // "digital" 电子版权限, "physical" 实体版权限（包含电子版）
-	OrderID    primitive.ObjectID `bson:"order_id" json:"order_id"`       // 购买订单ID
-	UnlockedAt time.Time          `bson:"unlocked_at" json:"unlocked_at"` // 解锁时间
+	BookID     primitive.ObjectID `bson:"book_id" json:"book_id"`                           // 书籍ID
+	BookName   string             `bson:"book_name" json:"book_name"`                       // 书籍名称（便于查询）
+	AccessType string             `bson:"access_type" json:"access_type"`                   // "digital" 电子版权限, "physical" 实体版权限（包含电子版）
+	Source     string             `bson:"source,omitempty" json:"source,omitempty"`         // 来源："purchase" 购买（为空时视为购买）、"grant" 管理员授予、"code" 激活码
+	OrderID    primitive.ObjectID `bson:"order_id" json:"order_id"`                         // 购买订单ID
+	UnlockedAt time.Time          `bson:"unlocked_at" json:"unlocked_at"`                   // 解锁时间
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` // 到期时间（订阅、学期通行证），为空表示永久有效
+}
+
+// 书籍权限来源
+const (
+	BookAccessSourcePurchase = "purchase" // 购买解锁
+	BookAccessSourceGrant    = "grant"    // 管理员授予，如学期通行证（不可家庭共享）
+	BookAccessSourceCode     = "code"     // 兑换线下实体卡的激活码
+	BookAccessSourceFamily   = "family"   // 家庭共享：来自购买者的已购书籍
+	BookAccessSourceTrial    = "trial"    // 免费试读：书籍的前 N 个单元
+)
+
+// BookAccessTypeTrial 试读访问类型（仅可访问试读单元）
+const BookAccessTypeTrial = "trial"
+
+// IsActive 权限在指定时间是否仍然有效
+func (p BookPermission) IsActive(now time.Time) bool {
+	return p.ExpiresAt == nil || now.Before(*p.ExpiresAt)
+}
+
+// IsShareable 权限是否可以共享给家庭成员（仅限有效的购买或激活码权限）
+func (p BookPermission) IsShareable(now time.Time) bool {
+	return p.Source != BookAccessSourceGrant && p.IsActive(now)
+}
+
+// StudyGoal 每日学习目标（单词数和学习时长任一为 0 表示不要求）
+type StudyGoal struct {
+	DailyWords   int       `bson:"daily_words" json:"daily_words"`     // 每日复习单词数
+	DailyMinutes int       `bson:"daily_minutes" json:"daily_minutes"` // 每日学习分钟数
+	UpdatedAt    time.Time `bson:"updated_at" json:"updated_at"`
 }
 
 // CollectedCard 收藏的单词卡结构体
@@ -78,13 +211,13 @@ type CollectedCard struct {
 // Address 地址结构体
 type Address struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
-	UserOpenID    string             `bson:"user_openid" json:"user_openid"` // 使用OpenID而不是MongoDB的_id
-	RecipientName string             `bson:"recipient_name" json:"recipient_name"`
-	Phone         string             `bson:"phone" json:"phone"`
+	UserOpenID    string             `bson:"user_openid" json:"user_openid"`       // 使用OpenID而不是MongoDB的_id
+	RecipientName EncryptedString    `bson:"recipient_name" json:"recipient_name"` // 加密存储
+	Phone         EncryptedString    `bson:"phone" json:"phone"`                   // 加密存储
 	Province      string             `bson:"province" json:"province"`
 	City          string             `bson:"city" json:"city"`
 	District      string             `bson:"district" json:"district"`
-	Street        string             `bson:"street" json:"street"`
+	Street        EncryptedString    `bson:"street" json:"street"` // 加密存储
 	PostalCode    string             `bson:"postal_code" json:"postal_code"`
 	IsDefault     bool               `bson:"is_default" json:"is_default"`
 	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
@@ -96,14 +229,51 @@ type Word struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	WordName         string             `bson:"word_name" json:"word_name"`
 	WordMeaning      string             `bson:"word_meaning" json:"word_meaning"`
+	Phonetic         string             `bson:"phonetic,omitempty" json:"phonetic,omitempty"`       // 音标
+	PhoneticUK       string             `bson:"phonetic_uk,omitempty" json:"phonetic_uk,omitempty"` // 英式音标（IPA）
+	PhoneticUS       string             `bson:"phonetic_us,omitempty" json:"phonetic_us,omitempty"` // 美式音标（IPA）
 	PronunciationURL string             `bson:"pronunciation_url" json:"pronunciation_url"`
 	ImgURL           string             `bson:"img_url" json:"img_url"`
+	Senses           []WordSense        `bson:"senses,omitempty" json:"senses,omitempty"`       // 按词性划分的义项，word_meaning 为简要释义
+	Relations        []WordRelation     `bson:"relations,omitempty" json:"relations,omitempty"` // 近义词、反义词等关联词
+	SearchKeys       []string           `bson:"search_keys,omitempty" json:"-"`                 // 搜索键（写入时生成，用于索引搜索）
+	SearchKeysVer    int                `bson:"search_keys_version,omitempty" json:"-"`         // 生成搜索键时的规则版本
 	UnitID           primitive.ObjectID `bson:"unit_id" json:"unit_id"`
 	BookID           primitive.ObjectID `bson:"book_id" json:"book_id"`
 	CreatedAt        time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	UpdatedAt        time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
 
+// WordSense 单词的一个义项
+type WordSense struct {
+	PartOfSpeech string        `bson:"part_of_speech,omitempty" json:"part_of_speech,omitempty"` // 词性，如 n.、v.、adj.
+	Definition   string        `bson:"definition" json:"definition"`                             // 中文释义
+	DefinitionEn string        `bson:"definition_en,omitempty" json:"definition_en,omitempty"`   // 英文释义
+	Examples     []WordExample `bson:"examples,omitempty" json:"examples,omitempty"`             // 例句
+}
+
+// WordExample 例句及翻译
+type WordExample struct {
+	Sentence    string `bson:"sentence" json:"sentence"`
+	Translation string `bson:"translation,omitempty" json:"translation,omitempty"`
+	AudioURL    string `bson:"audio_url,omitempty" json:"audio_url,omitempty"`
+}
+
+// 关联词类型
+const (
+	WordRelationSynonym    = "synonym"    // 近义词
+	WordRelationAntonym    = "antonym"    // 反义词
+	WordRelationDerivative = "derivative" // 派生词
+	WordRelationRelated    = "related"    // 相关词
+)
+
+// WordRelation 关联词；词库中存在对应单词时记录其ID，卡片可直接跳转
+type WordRelation struct {
+	Type     string              `bson:"type" json:"type"`
+	WordName string              `bson:"word_name" json:"word_name"`
+	WordID   *primitive.ObjectID `bson:"word_id,omitempty" json:"word_id,omitempty"`
+}
+
 // Product 商品结构体
 type Product struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
@@ -113,9 +283,10 @@ type Product struct {
 	Description    string             `bson:"description" json:"description"`
 	Stock          int                `bson:"stock" json:"stock"`
 	Images         []string           `bson:"images" json:"images"`
-	ProductType    string             `bson:"product_type" json:"product_type"`       // "physical" 实体卡, "digital" 电子卡
-	ProductVersion string             `bson:"product_version" json:"product_version"` // 商品版本，如"人教版"、"外研社版"等，用于前端tab筛选
-	BookID         primitive.ObjectID `bson:"book_id" json:"book_id"`                 // 关联的书籍ID
+	ProductType    string             `bson:"product_type" json:"product_type"`                   // "physical" 实体卡, "digital" 电子卡
+	ProductVersion string             `bson:"product_version" json:"product_version"`             // 商品版本，如"人教版"、"外研社版"等，用于前端tab筛选
+	BookID         primitive.ObjectID `bson:"book_id" json:"book_id"`                             // 关联的书籍ID
+	AccessDays     int                `bson:"access_days,omitempty" json:"access_days,omitempty"` // 购买后书籍权限的有效天数，0 表示永久
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -177,8 +348,9 @@ type Commission struct {
 	UserOpenID         string             `bson:"user_openid" json:"user_openid"` // 推荐人/代理人OpenID
 	Amount             float64            `bson:"amount" json:"amount"`
 	Date               time.Time          `bson:"date" json:"date"`
-	Status             string             `bson:"status" json:"status"` // pending, paid, cancelled
-	Type               string             `bson:"type" json:"type"`     // referral, agent
+	Status             string             `bson:"status" json:"status"`                    // pending, paid, cancelled, frozen
+	Type               string             `bson:"type" json:"type"`                        // referral, agent
+	StatusBeforeFreeze string             `bson:"status_before_freeze,omitempty" json:"-"` // 冻结前的状态，解冻时恢复
 	Description        string             `bson:"description" json:"description"`
 	OrderID            string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
 	ReferredUserOpenID string             `bson:"referred_user_openid,omitempty" json:"referred_user_openid,omitempty"` // 被推荐用户OpenID
@@ -212,7 +384,8 @@ type WithdrawRecord struct {
 	Amount             float64            `bson:"amount" json:"amount"`
 	WithdrawMethod     string             `bson:"withdraw_method" json:"withdraw_method"`
 	AccountInfo        AccountInfo        `bson:"account_info,omitempty" json:"account_info,omitempty"` // 微信支付企业转账不需要，保留用于兼容
-	Status             string             `bson:"status" json:"status"`                                 // pending, processing, completed, rejected, failed
+	Status             string             `bson:"status" json:"status"`                                 // pending, processing, completed, rejected, failed, frozen
+	StatusBeforeFreeze string             `bson:"status_before_freeze,omitempty" json:"-"`              // 冻结前的状态，解冻时恢复
 	OutBillNo          string             `bson:"out_bill_no,omitempty" json:"out_bill_no,omitempty"`   // 微信转账商户单号
 	CompletedAt        time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
 	RejectionReason    string             `bson:"rejection_reason,omitempty" json:"rejection_reason,omitempty"`
@@ -340,6 +513,66 @@ type RefundGoodsDetail struct {
 	RefundQuantity   int64  `json:"refund_quantity"`    // 退款商品数量
 }
 
+// AccountDeletion 账号注销申请（冷静期结束后执行）
+type AccountDeletion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID  string             `bson:"user_openid" json:"user_openid"` // 注销完成后替换为匿名标识
+	Status      string             `bson:"status" json:"status"`           // pending 冷静期中, cancelled 已撤销, completed 已完成
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	RequestedAt time.Time          `bson:"requested_at" json:"requested_at"`
+	ScheduledAt time.Time          `bson:"scheduled_at" json:"scheduled_at"` // 冷静期结束、计划执行时间
+	CancelledAt time.Time          `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+	CompletedAt time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// 账号注销申请状态
+const (
+	AccountDeletionStatusPending   = "pending"
+	AccountDeletionStatusCancelled = "cancelled"
+	AccountDeletionStatusCompleted = "completed"
+)
+
+// AccountDeletionRequest 申请注销账号请求
+type AccountDeletionRequest struct {
+	Confirm bool   `json:"confirm" binding:"required"` // 必须为 true，确认已了解注销后果
+	Reason  string `json:"reason,omitempty"`
+}
+
+// AccountDeletionResult 到期注销执行结果
+type AccountDeletionResult struct {
+	Processed int `json:"processed"` // 执行的注销数
+	Deferred  int `json:"deferred"`  // 因提现未完成而延后的注销数
+	Failed    int `json:"failed"`    // 执行失败数
+}
+
+// UserDataExport 个人数据导出内容
+type UserDataExport struct {
+	ExportedAt     time.Time        `json:"exported_at"`
+	Profile        User             `json:"profile"`
+	Addresses      []Address        `json:"addresses"`
+	Orders         []Order          `json:"orders"`
+	Progress       Progress         `json:"progress"`
+	CollectedCards []CollectedCard  `json:"collected_cards"`
+	UnlockedBooks  []BookPermission `json:"unlocked_books"`
+	Referral       *Referral        `json:"referral,omitempty"`
+	Commissions    []Commission     `json:"commissions"`
+	Withdrawals    []WithdrawRecord `json:"withdrawals"`
+	Refunds        []RefundRecord   `json:"refunds"`
+	WordReviews    []WordReview     `json:"word_reviews"`
+	UnitProgress   []UnitProgress   `json:"unit_progress"`
+	Quizzes        []Quiz           `json:"quizzes"`
+	MistakeWords   []MistakeWord    `json:"mistake_words"`
+	StudySessions  []StudySession   `json:"study_sessions"`
+	StudyDays      []StudyDailyStat `json:"study_days"`
+	Classrooms     []Classroom      `json:"classrooms"`
+	FamilyLinks    []FamilyLink     `json:"family_links"`
+	Activations    []ActivationCode `json:"activations"`
+	OfflineAnswers []OfflineAnswer  `json:"offline_answers"`
+	SearchHistory  []SearchHistory  `json:"search_history"`
+}
+
 // CreateUserRequest 创建用户请求
 type CreateUserRequest struct {
 	OpenID       string `json:"openID" binding:"required"`
@@ -375,12 +608,108 @@ type SearchRequest struct {
 
 // SearchResponse 搜索响应结构体
 type SearchResponse struct {
-	Words  []Word  `json:"words,omitempty"`  // 单词搜索结果
-	Books  []Book  `json:"books,omitempty"`  // 课本搜索结果
-	Orders []Order `json:"orders,omitempty"` // 订单搜索结果
-	Total  int64   `json:"total"`            // 总数量
-	Page   int     `json:"page"`             // 当前页码
-	Limit  int     `json:"limit"`            // 每页数量
+	Words      []Word                       `json:"words,omitempty"`      // 单词搜索结果（按相关度排序）
+	Books      []Book                       `json:"books,omitempty"`      // 课本搜索结果（按相关度排序）
+	Orders     []Order                      `json:"orders,omitempty"`     // 订单搜索结果
+	Highlights map[string][]SearchHighlight `json:"highlights,omitempty"` // 按单词或课本ID列出的命中字段
+	Total      int64                        `json:"total"`                // 总数量
+	Truncated  bool                         `json:"truncated,omitempty"`  // 匹配的单词或课本超过候选上限，只返回前 500 条中的命中结果
+	Page       int                          `json:"page"`                 // 当前页码
+	Limit      int                          `json:"limit"`                // 每页数量
+}
+
+// SearchHighlight 搜索命中的字段，Snippet 为转义后的 HTML，命中部分用 <em></em> 包裹
+type SearchHighlight struct {
+	Field   string `json:"field"` // 字段路径，如 word_name、senses.0.examples.1.sentence
+	Snippet string `json:"snippet"`
+}
+
+// SearchReindexResult 重建搜索键结果
+type SearchReindexResult struct {
+	Words int `json:"words"`
+	Books int `json:"books"`
+}
+
+// 搜索建议类型
+const (
+	SearchSuggestionPinned = "pinned" // 管理员置顶的建议
+	SearchSuggestionWord   = "word"   // 单词
+	SearchSuggestionBook   = "book"   // 课本
+	SearchSuggestionQuery  = "query"  // 热门搜索词
+)
+
+// SearchSuggestion 一条搜索建议
+type SearchSuggestion struct {
+	Text      string `json:"text"`
+	Type      string `json:"type"`                // pinned、word、book 或 query
+	ID        string `json:"id,omitempty"`        // 单词或课本ID
+	Highlight string `json:"highlight,omitempty"` // 转义后的 HTML，命中部分用 <em></em> 包裹
+}
+
+// SearchSuggestResponse 搜索建议响应
+type SearchSuggestResponse struct {
+	Query       string             `json:"query"`
+	Suggestions []SearchSuggestion `json:"suggestions"`
+}
+
+// SearchHistory 用户搜索历史（集合 search_history，同一用户相同的关键词只保留一条）
+type SearchHistory struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID     string             `bson:"user_openid" json:"user_openid"`
+	Query          string             `bson:"query" json:"query"`           // 最近一次输入的原文
+	Normalized     string             `bson:"normalized" json:"normalized"` // 规范化后的关键词
+	Type           string             `bson:"type" json:"type"`             // 最近一次的搜索类型：word、book 或 all
+	Count          int                `bson:"count" json:"count"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	LastSearchedAt time.Time          `bson:"last_searched_at" json:"last_searched_at"`
+}
+
+// SearchQueryStat 搜索词按小时的搜索次数（集合 search_query_stats）
+type SearchQueryStat struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Query  string             `bson:"query" json:"query"`   // 规范化后的关键词
+	Bucket time.Time          `bson:"bucket" json:"bucket"` // 所在小时的开始时间（UTC）
+	Count  int64              `bson:"count" json:"count"`
+}
+
+// SearchQueryRank 热门或飙升搜索词
+type SearchQueryRank struct {
+	Query         string  `json:"query"`
+	Count         int64   `json:"count"`                    // 统计窗口内的搜索次数
+	PreviousCount int64   `json:"previous_count,omitempty"` // 上一个统计窗口的搜索次数（飙升榜）
+	Growth        float64 `json:"growth,omitempty"`         // 增长倍数（飙升榜）
+	Pinned        bool    `json:"pinned,omitempty"`         // 管理员置顶
+}
+
+// SearchQueryRankResponse 热门或飙升搜索词响应
+type SearchQueryRankResponse struct {
+	Window  string            `json:"window"`
+	Since   time.Time         `json:"since"`
+	Queries []SearchQueryRank `json:"queries"`
+}
+
+// 搜索词规则类型
+const (
+	SearchRuleBlocked = "blocked" // 屏蔽：包含该词的搜索词不出现在建议、热门和飙升榜中
+	SearchRulePinned  = "pinned"  // 置顶：显示在热门搜索最前面，并作为以输入开头的搜索建议
+)
+
+// SearchRule 管理员维护的搜索词规则（集合 search_rules）
+type SearchRule struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RuleType        string             `bson:"rule_type" json:"rule_type"`   // blocked 或 pinned
+	Query           string             `bson:"query" json:"query"`           // 显示的文字
+	Normalized      string             `bson:"normalized" json:"normalized"` // 规范化后的文字，用于匹配和去重
+	Position        int                `bson:"position" json:"position"`     // 置顶顺序，越小越靠前
+	CreatedByOpenID string             `bson:"created_by_openid" json:"created_by_openid"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// CreateSearchRuleRequest 创建搜索词规则请求
+type CreateSearchRuleRequest struct {
+	RuleType string `json:"rule_type" binding:"required,oneof=blocked pinned"`
+	Query    string `json:"query" binding:"required,max=50"`
+	Position int    `json:"position" binding:"min=0"`
 }
 
 // Book 课本结构体
@@ -393,9 +722,12 @@ type Book struct {
 	TotalWords      int                `bson:"total_words,omitempty" json:"total_words,omitempty"`
 	Units           []Unit             `bson:"-" json:"units,omitempty"` // 不存储到数据库，仅用于API响应
 	CoverImage      string             `bson:"cover_image,omitempty" json:"cover_image,omitempty"`
+	TrialUnits      int                `bson:"trial_units,omitempty" json:"trial_units,omitempty"` // 免费试读的单元数（按单元创建顺序取前 N 个）
 	Author          string             `bson:"author,omitempty" json:"author,omitempty"`
 	Publisher       string             `bson:"publisher,omitempty" json:"publisher,omitempty"`
 	PublicationDate time.Time          `bson:"publication_date,omitempty" json:"publication_date,omitempty"`
+	SearchKeys      []string           `bson:"search_keys,omitempty" json:"-"`         // 搜索键（写入时生成，用于索引搜索）
+	SearchKeysVer   int                `bson:"search_keys_version,omitempty" json:"-"` // 生成搜索键时的规则版本
 	CreatedAt       time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	UpdatedAt       time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
@@ -405,10 +737,287 @@ type Unit struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	UnitName  string             `bson:"unit_name" json:"unit_name"`
 	BookID    primitive.ObjectID `bson:"book_id" json:"book_id"`
+	SortOrder int                `bson:"sort_order" json:"sort_order"`  // 单元在书中的顺序，相同时按创建顺序
+	WordCount int                `bson:"-" json:"word_count,omitempty"` // 不存储到数据库，仅用于管理后台响应
 	CreatedAt time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	UpdatedAt time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
 
+// WordReview 单词复习状态（SM-2 间隔重复算法，每个用户每个单词一条）
+type WordReview struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID     string             `bson:"user_openid" json:"user_openid"`
+	WordID         primitive.ObjectID `bson:"word_id" json:"word_id"`
+	WordName       string             `bson:"word_name" json:"word_name"`
+	BookID         primitive.ObjectID `bson:"book_id" json:"book_id"`
+	UnitID         primitive.ObjectID `bson:"unit_id" json:"unit_id"`
+	EaseFactor     float64            `bson:"ease_factor" json:"ease_factor"`     // 难度系数，最小 1.3
+	IntervalDays   int                `bson:"interval_days" json:"interval_days"` // 当前复习间隔（天）
+	Repetitions    int                `bson:"repetitions" json:"repetitions"`     // 连续记住的次数
+	Lapses         int                `bson:"lapses" json:"lapses"`               // 遗忘次数
+	ReviewCount    int                `bson:"review_count" json:"review_count"`   // 总复习次数
+	LastQuality    int                `bson:"last_quality" json:"last_quality"`   // 最近一次回忆质量 0-5
+	DueAt          time.Time          `bson:"due_at" json:"due_at"`               // 下次复习时间
+	LastReviewedAt time.Time          `bson:"last_reviewed_at" json:"last_reviewed_at"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// UnitProgress 用户在某本书某个单元的学习进度（每个用户每个单元一条）
+type UnitProgress struct {
+	ID                primitive.ObjectID           `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID        string                       `bson:"user_openid" json:"user_openid"`
+	BookID            primitive.ObjectID           `bson:"book_id" json:"book_id"`
+	UnitID            primitive.ObjectID           `bson:"unit_id" json:"unit_id"`
+	WordStates        map[string]WordProgressState `bson:"word_states" json:"-"`                 // 单词ID -> 学习状态，按设备时间戳合并
+	MasteredWords     []string                     `bson:"mastered_words" json:"mastered_words"` // 已掌握的单词ID
+	LearningWords     []string                     `bson:"learning_words" json:"learning_words"` // 学习中的单词ID
+	TotalWords        int                          `bson:"total_words" json:"total_words"`       // 单元单词总数
+	CompletionPercent float64                      `bson:"completion_percent" json:"completion_percent"`
+	TimeSpentSeconds  int64                        `bson:"time_spent_seconds" json:"time_spent_seconds"`
+	LastDeviceID      string                       `bson:"last_device_id" json:"last_device_id"`
+	DeviceUpdatedAt   time.Time                    `bson:"device_updated_at" json:"device_updated_at"` // 已应用变更中最新的设备时间
+	Version           int64                        `bson:"version" json:"version"`                     // 乐观锁版本号
+	CreatedAt         time.Time                    `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time                    `bson:"updated_at" json:"updated_at"`
+}
+
+// WordProgressState 单词学习状态
+type WordProgressState struct {
+	Status    string    `bson:"status" json:"status"`         // learning, mastered
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"` // 设备时间戳
+}
+
+// 单词学习状态
+const (
+	WordStatusLearning = "learning"
+	WordStatusMastered = "mastered"
+)
+
+// Quiz 单词测验（由书籍或单元生成，服务端判分）
+type Quiz struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID   string              `bson:"user_openid" json:"user_openid"`
+	BookID       primitive.ObjectID  `bson:"book_id" json:"book_id"`
+	UnitID       *primitive.ObjectID `bson:"unit_id,omitempty" json:"unit_id,omitempty"` // 为空表示整本书
+	Status       string              `bson:"status" json:"status"`                       // in_progress, submitted
+	Questions    []QuizQuestion      `bson:"questions" json:"questions"`
+	TotalCount   int                 `bson:"total_count" json:"total_count"`
+	CorrectCount int                 `bson:"correct_count" json:"correct_count"`
+	Score        float64             `bson:"score" json:"score"` // 百分制得分
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+	SubmittedAt  time.Time           `bson:"submitted_at,omitempty" json:"submitted_at,omitempty"`
+	UpdatedAt    time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// QuizQuestion 测验题目
+type QuizQuestion struct {
+	QuestionID    string             `bson:"question_id" json:"question_id"`
+	Type          string             `bson:"type" json:"type"`
+	WordID        primitive.ObjectID `bson:"word_id" json:"word_id"`
+	UnitID        primitive.ObjectID `bson:"unit_id" json:"unit_id"`
+	Prompt        string             `bson:"prompt" json:"prompt"`                               // 题干：释义、单词或听音题的发音地址（不暴露原始发音URL）
+	AudioKey      string             `bson:"audio_key,omitempty" json:"-"`                       // 听音题发音的随机访问键
+	Options       []string           `bson:"options,omitempty" json:"options,omitempty"`         // 选择题选项，拼写题为空
+	CorrectAnswer string             `bson:"correct_answer" json:"correct_answer,omitempty"`     // 提交前不返回
+	UserAnswer    string             `bson:"user_answer,omitempty" json:"user_answer,omitempty"` // 用户作答
+	IsCorrect     bool               `bson:"is_correct" json:"is_correct"`
+}
+
+// 测验题型
+const (
+	QuizTypeMeaningToWord = "meaning_to_word" // 看释义选单词
+	QuizTypeWordToMeaning = "word_to_meaning" // 看单词选释义
+	QuizTypeSpelling      = "spelling"        // 看释义拼写单词
+	QuizTypeAudioToWord   = "audio_to_word"   // 听发音选单词
+)
+
+// 测验状态
+const (
+	QuizStatusInProgress = "in_progress"
+	QuizStatusSubmitted  = "submitted"
+)
+
+// MistakeWord 错题本单词（测验或复习答错时自动加入，每个用户每个单词一条）
+// 与手动收藏的 CollectedCard 相互独立
+type MistakeWord struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID    string             `bson:"user_openid" json:"user_openid"`
+	WordID        primitive.ObjectID `bson:"word_id" json:"word_id"`
+	WordName      string             `bson:"word_name" json:"word_name"`
+	BookID        primitive.ObjectID `bson:"book_id" json:"book_id"`
+	UnitID        primitive.ObjectID `bson:"unit_id" json:"unit_id"`
+	Status        string             `bson:"status" json:"status"`                 // active, retired
+	WrongCount    int                `bson:"wrong_count" json:"wrong_count"`       // 累计答错次数
+	CorrectStreak int                `bson:"correct_streak" json:"correct_streak"` // 最近一次答错后连续答对的次数
+	LastSource    string             `bson:"last_source" json:"last_source"`       // 最近一次答错的来源：quiz, review, practice
+	LastWrongAt   time.Time          `bson:"last_wrong_at" json:"last_wrong_at"`
+	LastCorrectAt *time.Time         `bson:"last_correct_at,omitempty" json:"last_correct_at,omitempty"`
+	RetiredAt     *time.Time         `bson:"retired_at,omitempty" json:"retired_at,omitempty"` // 连续答对移出错题本的时间
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// 错题状态
+const (
+	MistakeStatusActive  = "active"
+	MistakeStatusRetired = "retired"
+)
+
+// 错题来源
+const (
+	MistakeSourceQuiz     = "quiz"
+	MistakeSourceReview   = "review"
+	MistakeSourcePractice = "practice"
+	MistakeSourceOffline  = "offline"
+)
+
+// StudySession 学习会话（开始时创建，结束时记录复习单词数和正确率并计入当日统计）
+type StudySession struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID      string              `bson:"user_openid" json:"user_openid"`
+	Activity        string              `bson:"activity" json:"activity"` // learn, review, quiz, practice
+	BookID          *primitive.ObjectID `bson:"book_id,omitempty" json:"book_id,omitempty"`
+	UnitID          *primitive.ObjectID `bson:"unit_id,omitempty" json:"unit_id,omitempty"`
+	Status          string              `bson:"status" json:"status"`         // active, ended
+	StudyDate       string              `bson:"study_date" json:"study_date"` // 按应用时区计算的开始日期 YYYY-MM-DD
+	StartedAt       time.Time           `bson:"started_at" json:"started_at"`
+	EndedAt         time.Time           `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+	DurationSeconds int64               `bson:"duration_seconds" json:"duration_seconds"`
+	WordsReviewed   int                 `bson:"words_reviewed" json:"words_reviewed"`
+	CorrectCount    int                 `bson:"correct_count" json:"correct_count"`
+	Accuracy        float64             `bson:"accuracy" json:"accuracy"` // 正确率（百分比）
+	CreatedAt       time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// 学习会话状态
+const (
+	StudySessionActive = "active"
+	StudySessionEnded  = "ended"
+)
+
+// Classroom 班级（教师创建，学生通过加入码加入）
+type Classroom struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Name          string             `bson:"name" json:"name"`
+	School        string             `bson:"school" json:"school"`
+	TeacherOpenID string             `bson:"teacher_openid" json:"teacher_openid"`
+	TeacherName   string             `bson:"teacher_name" json:"teacher_name"`
+	JoinCode      string             `bson:"join_code" json:"join_code"` // 学生加入班级使用的邀请码
+	Students      []ClassroomStudent `bson:"students" json:"students"`
+	Assignments   []ClassAssignment  `bson:"assignments" json:"assignments"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ClassroomStudent 班级学生
+type ClassroomStudent struct {
+	StudentOpenID string    `bson:"student_openid" json:"student_openid"`
+	UserName      string    `bson:"user_name" json:"user_name"`
+	JoinedAt      time.Time `bson:"joined_at" json:"joined_at"`
+}
+
+// ClassAssignment 班级布置的学习任务（一个单元）
+type ClassAssignment struct {
+	ID        primitive.ObjectID `bson:"_id" json:"_id"`
+	Title     string             `bson:"title" json:"title"`
+	BookID    primitive.ObjectID `bson:"book_id" json:"book_id"`
+	BookName  string             `bson:"book_name" json:"book_name"`
+	UnitID    primitive.ObjectID `bson:"unit_id" json:"unit_id"`
+	UnitName  string             `bson:"unit_name" json:"unit_name"`
+	DueAt     time.Time          `bson:"due_at" json:"due_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// FamilyLink 家庭共享关系（购买者将自己购买的书籍共享给关联账号，每个账号只能加入一个家庭）
+type FamilyLink struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	OwnerOpenID  string             `bson:"owner_openid" json:"owner_openid"`
+	OwnerName    string             `bson:"owner_name" json:"owner_name"`
+	MemberOpenID string             `bson:"member_openid" json:"member_openid"`
+	MemberName   string             `bson:"member_name" json:"member_name"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ActivationCodeBatch 激活码批次（管理员为某本书批量生成，用于线下实体卡印刷）
+type ActivationCodeBatch struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	BatchNo        string             `bson:"batch_no" json:"batch_no"`
+	Name           string             `bson:"name" json:"name"`
+	BookID         primitive.ObjectID `bson:"book_id" json:"book_id"`
+	BookName       string             `bson:"book_name" json:"book_name"`
+	AccessType     string             `bson:"access_type" json:"access_type"`                     // digital 或 physical
+	AccessDays     int                `bson:"access_days,omitempty" json:"access_days,omitempty"` // 兑换后权限的有效天数，0 表示永久
+	Quantity       int                `bson:"quantity" json:"quantity"`
+	RedeemedCount  int                `bson:"redeemed_count" json:"redeemed_count"`
+	RevokedCount   int                `bson:"revoked_count" json:"revoked_count"`
+	UnitPrice      float64            `bson:"unit_price" json:"unit_price"`                         // 每张卡的销售金额，用于计算代理提成
+	AgentOpenID    string             `bson:"agent_openid,omitempty" json:"agent_openid,omitempty"` // 归属代理，兑换时为其计算提成
+	RedeemDeadline *time.Time         `bson:"redeem_deadline,omitempty" json:"redeem_deadline,omitempty"`
+	Status         string             `bson:"status" json:"status"` // active、revoked
+	CreatedBy      string             `bson:"created_by_openid" json:"created_by_openid"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ActivationCode 一次性激活码
+type ActivationCode struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Code             string             `bson:"code" json:"code"`
+	BatchID          primitive.ObjectID `bson:"batch_id" json:"batch_id"`
+	BookID           primitive.ObjectID `bson:"book_id" json:"book_id"`
+	Status           string             `bson:"status" json:"status"` // unused、redeemed、revoked
+	RedeemedByOpenID string             `bson:"redeemed_by_openid,omitempty" json:"redeemed_by_openid,omitempty"`
+	RedeemedAt       *time.Time         `bson:"redeemed_at,omitempty" json:"redeemed_at,omitempty"`
+	RevokedAt        *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// 激活码及批次状态
+const (
+	ActivationCodeUnused   = "unused"
+	ActivationCodeRedeemed = "redeemed"
+	ActivationCodeRevoked  = "revoked"
+	ActivationBatchActive  = "active"
+	ActivationBatchRevoked = "revoked"
+)
+
+// LeaderboardEntry 排行榜快照（每个用户每个统计周期一条，由后台任务增量刷新）
+type LeaderboardEntry struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserOpenID    string             `bson:"user_openid" json:"-"`
+	PeriodKey     string             `bson:"period_key" json:"period_key"`     // all_time 或 week:YYYY-MM-DD（周一日期）
+	DisplayName   string             `bson:"display_name" json:"display_name"` // 匿名化后的显示名称
+	School        string             `bson:"school" json:"-"`
+	Class         string             `bson:"class" json:"-"`
+	City          string             `bson:"city" json:"-"`
+	WordsMastered int                `bson:"words_mastered" json:"words_mastered"`
+	StudySeconds  int64              `bson:"study_seconds" json:"study_seconds"`
+	Hidden        bool               `bson:"hidden" json:"-"` // 用户选择不出现在排行榜中
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// 排行榜统计周期
+const (
+	LeaderboardPeriodAllTime = "all_time"
+	LeaderboardPeriodWeekly  = "weekly"
+)
+
+// StudyDailyStat 用户每日学习汇总（按应用时区的自然日，每个用户每天一条）
+type StudyDailyStat struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID      string             `bson:"user_openid" json:"user_openid"`
+	Date            string             `bson:"date" json:"date"` // YYYY-MM-DD
+	SessionCount    int                `bson:"session_count" json:"session_count"`
+	DurationSeconds int64              `bson:"duration_seconds" json:"duration_seconds"`
+	WordsReviewed   int                `bson:"words_reviewed" json:"words_reviewed"`
+	CorrectCount    int                `bson:"correct_count" json:"correct_count"`
+	GoalMet         bool               `bson:"goal_met" json:"goal_met"` // 是否达成当日目标（未设置目标时有学习即视为达成）
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
 // ===== 请求/响应结构体定义 =====
 
 // 商店相关请求结构体
@@ -470,6 +1079,55 @@ type WechatLoginResponse struct {
 	ErrMsg     string `json:"errmsg"`
 }
 
+// BindPhoneRequest 绑定手机号请求（小程序 getPhoneNumber 返回的 code）
+type BindPhoneRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// WechatPhoneNumberResponse 微信获取手机号响应（内部使用）
+type WechatPhoneNumberResponse struct {
+	ErrCode   int             `json:"errcode"`
+	ErrMsg    string          `json:"errmsg"`
+	PhoneInfo WechatPhoneInfo `json:"phone_info"`
+}
+
+// WechatPhoneInfo 微信手机号信息
+type WechatPhoneInfo struct {
+	PhoneNumber     string          `json:"phoneNumber"`     // 带区号的手机号
+	PurePhoneNumber string          `json:"purePhoneNumber"` // 不带区号的手机号
+	CountryCode     string          `json:"countryCode"`     // 区号
+	Watermark       WechatWatermark `json:"watermark"`
+}
+
+// WechatWatermark 微信数据水印
+type WechatWatermark struct {
+	Timestamp int64  `json:"timestamp"`
+	AppID     string `json:"appid"`
+}
+
+// WechatSession 微信会话（session_key 加密存储）
+type WechatSession struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserOpenID          string             `bson:"user_openid" json:"user_openid"`
+	SessionKeyEncrypted string             `bson:"session_key_encrypted" json:"-"`
+	UnionID             string             `bson:"unionid,omitempty" json:"-"`
+	ExpiresAt           time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// DecryptWechatDataRequest 解密微信加密数据请求（wx.getUserInfo、wx.getWeRunData 等返回的 encryptedData/iv）
+type DecryptWechatDataRequest struct {
+	EncryptedData string `json:"encrypted_data" binding:"required"`
+	IV            string `json:"iv" binding:"required"`
+}
+
+// WechatCheckSessionResponse 微信校验登录态响应（内部使用）
+type WechatCheckSessionResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
 // LoginResponse 登录响应
 type LoginResponse struct {
 	Code    int    `json:"code"`
@@ -488,12 +1146,25 @@ type WechatAccessTokenResponse struct {
 	ErrMsg      string `json:"errmsg"`
 }
 
-// DevLoginRequest 开发登录请求
+// DevLoginRequest 开发登录请求（签名测试身份夹具）
 type DevLoginRequest struct {
 	OpenID       string `json:"openID" binding:"required"`
+	ExpiresAt    int64  `json:"expires_at" binding:"required"` // 夹具过期时间（Unix秒）
+	Signature    string `json:"signature" binding:"required"`  // HMAC-SHA256(openID|expires_at)
 	ReferralCode string `json:"referral_code,omitempty"`
 }
 
+// TestIdentityLog 测试身份使用记录
+type TestIdentityLog struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID string             `bson:"user_openid" json:"user_openid"`
+	RemoteIP   string             `bson:"remote_ip" json:"remote_ip"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	Success    bool               `bson:"success" json:"success"`
+	Reason     string             `bson:"reason,omitempty" json:"reason,omitempty"` // 拒绝原因
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
 // 推荐相关请求结构体
 // TrackReferralRequest 跟踪推荐关系请求
 type TrackReferralRequest struct {
@@ -513,6 +1184,408 @@ type UpdateProgressRequest struct {
 	LearnedWords []string `json:"learned_words"`
 }
 
+// ProgressChange 单个单元的增量进度变更
+type ProgressChange struct {
+	ChangeID         string    `json:"change_id" binding:"required"` // 客户端生成的唯一变更ID，重复提交时忽略
+	BookID           string    `json:"book_id" binding:"required"`
+	UnitID           string    `json:"unit_id" binding:"required"`
+	MasteredWords    []string  `json:"mastered_words,omitempty"`                     // 标记为已掌握的单词ID
+	LearningWords    []string  `json:"learning_words,omitempty"`                     // 标记为学习中的单词ID
+	TimeSpentSeconds int64     `json:"time_spent_seconds" binding:"min=0,max=14400"` // 本次新增的学习时长（秒），最多 4 小时（与单次学习会话上限相同）
+	DeviceTimestamp  time.Time `json:"device_timestamp" binding:"required"`          // 变更发生时的设备时间（RFC3339）
+}
+
+// AppliedProgressChange 已应用的学习进度变更ID（用于幂等，按用户唯一，过期后自动删除）
+type AppliedProgressChange struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID string             `bson:"user_openid" json:"user_openid"`
+	ChangeID   string             `bson:"change_id" json:"change_id"`
+	UnitID     primitive.ObjectID `bson:"unit_id" json:"unit_id"`
+	AppliedAt  time.Time          `bson:"applied_at" json:"applied_at"`
+}
+
+// SyncProgressRequest 增量同步学习进度请求
+type SyncProgressRequest struct {
+	DeviceID string           `json:"device_id" binding:"required"`
+	Changes  []ProgressChange `json:"changes" binding:"max=100,dive"`
+	Since    time.Time        `json:"since,omitempty"` // 返回该服务器时间之后更新的单元进度，用于拉取其他设备的变更
+}
+
+// SyncProgressResult 增量同步学习进度结果
+type SyncProgressResult struct {
+	Applied    int            `json:"applied"`     // 应用的变更数
+	Duplicates int            `json:"duplicates"`  // 重复提交被忽略的变更数
+	Units      []UnitProgress `json:"units"`       // 本次变更涉及的单元以及 since 之后更新的单元
+	ServerTime time.Time      `json:"server_time"` // 下次同步时作为 since 传入
+}
+
+// BookProgressSummary 书籍学习进度汇总
+type BookProgressSummary struct {
+	BookID            primitive.ObjectID `bson:"_id" json:"book_id"`
+	UnitCount         int                `bson:"unit_count" json:"unit_count"` // 有学习记录的单元数
+	MasteredCount     int                `bson:"mastered_count" json:"mastered_count"`
+	LearningCount     int                `bson:"learning_count" json:"learning_count"`
+	TotalWords        int                `bson:"-" json:"total_words"` // 书籍单词总数
+	CompletionPercent float64            `bson:"-" json:"completion_percent"`
+	TimeSpentSeconds  int64              `bson:"time_spent_seconds" json:"time_spent_seconds"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CreateQuizRequest 生成测验请求
+type CreateQuizRequest struct {
+	BookID string   `json:"book_id" binding:"required"`
+	UnitID string   `json:"unit_id,omitempty"`                                // 为空时从整本书出题
+	Count  int      `json:"count,omitempty" binding:"omitempty,min=1,max=50"` // 题目数量，默认10
+	Types  []string `json:"types,omitempty"`                                  // 题型，默认全部题型随机
+}
+
+// SubmitQuizRequest 提交测验答案请求
+type SubmitQuizRequest struct {
+	Answers []QuizAnswer `json:"answers" binding:"required,dive"`
+}
+
+// QuizAnswer 单题作答
+type QuizAnswer struct {
+	QuestionID string `json:"question_id" binding:"required"`
+	Answer     string `json:"answer"`
+}
+
+// BookAccess 用户对某本书的有效访问权限（综合自有权限、家庭共享和免费试读）
+type BookAccess struct {
+	BookID         primitive.ObjectID   `json:"book_id"`
+	HasAccess      bool                 `json:"has_access"`                 // 是否可以访问（全部或部分单元）
+	FullAccess     bool                 `json:"full_access"`                // 是否可以访问全部单元
+	AccessType     string               `json:"access_type,omitempty"`      // digital、physical 或 trial
+	Source         string               `json:"source,omitempty"`           // purchase、grant、family 或 trial
+	ExpiresAt      *time.Time           `json:"expires_at,omitempty"`       // 当前权限（或已过期的自有权限）的到期时间
+	Expired        bool                 `json:"expired"`                    // 自有权限是否已过期
+	SharedByOpenID string               `json:"shared_by_openid,omitempty"` // 家庭共享的购买者
+	TrialUnitIDs   []primitive.ObjectID `json:"trial_unit_ids,omitempty"`   // 试读可访问的单元
+}
+
+// AllowsUnit 是否可以访问指定单元
+func (a *BookAccess) AllowsUnit(unitID primitive.ObjectID) bool {
+	if a.FullAccess {
+		return true
+	}
+	for _, id := range a.TrialUnitIDs {
+		if id == unitID {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantBookPermissionRequest 管理员授予书籍权限请求
+type GrantBookPermissionRequest struct {
+	BookID     string `json:"book_id" binding:"required"`
+	AccessType string `json:"access_type"`          // digital（默认）或 physical
+	Days       int    `json:"days" binding:"min=0"` // 有效天数，0 表示永久；已有未过期的限时权限时在其基础上顺延
+}
+
+// CreateActivationBatchRequest 生成激活码批次请求
+type CreateActivationBatchRequest struct {
+	Name           string  `json:"name" binding:"required"`
+	BookID         string  `json:"book_id" binding:"required"`
+	AccessType     string  `json:"access_type"` // physical（默认）或 digital
+	AccessDays     int     `json:"access_days" binding:"min=0"`
+	Quantity       int     `json:"quantity" binding:"required,min=1,max=10000"`
+	UnitPrice      float64 `json:"unit_price" binding:"min=0"`
+	AgentID        string  `json:"agent_id"`        // 归属代理的安全用户标识符（可选）
+	RedeemDeadline string  `json:"redeem_deadline"` // 兑换截止日期 YYYY-MM-DD（应用时区，当天有效，可选）
+}
+
+// RedeemActivationCodeRequest 兑换激活码请求
+type RedeemActivationCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RedeemActivationCodeResult 兑换激活码结果
+type RedeemActivationCodeResult struct {
+	Code       string         `json:"code"`
+	BookID     string         `json:"book_id"`
+	BookName   string         `json:"book_name"`
+	Permission BookPermission `json:"permission"`
+}
+
+// ActivationRevokeResult 作废激活码结果
+type ActivationRevokeResult struct {
+	RevokedCount int `json:"revoked_count"`
+}
+
+// StudyPack 离线学习包（单元或整本书的单词数据和媒体清单）
+type StudyPack struct {
+	Scope       string              `json:"scope"` // unit 或 book
+	BookID      primitive.ObjectID  `json:"book_id"`
+	BookName    string              `json:"book_name"`
+	BookVersion string              `json:"book_version"`
+	UnitID      *primitive.ObjectID `json:"unit_id,omitempty"`
+	Version     string              `json:"version"` // 内容版本，同时作为 ETag
+	AccessType  string              `json:"access_type"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"` // 签名凭证到期时间，试读包没有凭证
+	Token       string              `json:"token,omitempty"`      // 签名凭证，上传离线作答时提交
+	WordCount   int                 `json:"word_count"`
+	Units       []StudyPackUnit     `json:"units"`
+	Media       []StudyPackMedia    `json:"media"` // 需要预先下载的音频和图片
+}
+
+// StudyPackUnit 学习包中的单元
+type StudyPackUnit struct {
+	UnitID    primitive.ObjectID `json:"unit_id"`
+	UnitName  string             `json:"unit_name"`
+	SortOrder int                `json:"sort_order"`
+	Version   string             `json:"version"` // 单元内容版本，与单元学习包的版本一致
+	Words     []Word             `json:"words"`
+}
+
+// StudyPackMedia 学习包媒体清单项
+type StudyPackMedia struct {
+	URL  string `json:"url"`
+	Type string `json:"type"` // audio 或 image
+}
+
+// StudyPackIndex 书籍可下载的学习包及版本，客户端据此只下载有变化的单元
+type StudyPackIndex struct {
+	BookID     primitive.ObjectID     `json:"book_id"`
+	BookName   string                 `json:"book_name"`
+	Version    string                 `json:"version"` // 整本书学习包的版本
+	AccessType string                 `json:"access_type"`
+	Units      []StudyPackUnitVersion `json:"units"`
+}
+
+// StudyPackUnitVersion 单元学习包版本
+type StudyPackUnitVersion struct {
+	UnitID    primitive.ObjectID `json:"unit_id"`
+	UnitName  string             `json:"unit_name"`
+	SortOrder int                `json:"sort_order"`
+	Version   string             `json:"version"`
+	WordCount int                `json:"word_count"`
+}
+
+// 离线作答处理状态
+const (
+	OfflineAnswerApplied = "applied" // 已更新复习状态
+	OfflineAnswerStale   = "stale"   // 其他设备已有更新的复习记录，只计入错题本
+)
+
+// OfflineAnswer 已上传的离线作答（按 answer_id 去重）
+type OfflineAnswer struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserOpenID string             `bson:"user_openid" json:"user_openid"`
+	AnswerID   string             `bson:"answer_id" json:"answer_id"`
+	WordID     primitive.ObjectID `bson:"word_id" json:"word_id"`
+	BookID     primitive.ObjectID `bson:"book_id" json:"book_id"`
+	UnitID     primitive.ObjectID `bson:"unit_id" json:"unit_id"`
+	Correct    bool               `bson:"correct" json:"correct"`
+	Quality    int                `bson:"quality" json:"quality"`
+	AnsweredAt time.Time          `bson:"answered_at" json:"answered_at"`
+	Status     string             `bson:"status" json:"status"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OfflineAnswerInput 一条离线作答
+type OfflineAnswerInput struct {
+	AnswerID   string    `json:"answer_id" binding:"required,max=64"` // 客户端生成的唯一ID，重复上传时忽略
+	WordID     string    `json:"word_id" binding:"required"`
+	Correct    bool      `json:"correct"`
+	Quality    *int      `json:"quality" binding:"omitempty,min=0,max=5"` // 回忆质量 0-5，为空时答对记 4、答错记 1
+	AnsweredAt time.Time `json:"answered_at" binding:"required"`          // 作答时的设备时间（RFC3339）
+}
+
+// UploadOfflineAnswersRequest 批量上传离线作答请求
+type UploadOfflineAnswersRequest struct {
+	PackTokens []string             `json:"pack_tokens" binding:"max=50"` // 下载学习包时获得的签名凭证；权限到期后凭证内的作答仍可上传
+	Answers    []OfflineAnswerInput `json:"answers" binding:"required,min=1,max=500,dive"`
+}
+
+// OfflineAnswerError 未被接受的离线作答
+type OfflineAnswerError struct {
+	AnswerID string `json:"answer_id"`
+	Message  string `json:"message"`
+}
+
+// OfflineAnswersResult 批量上传离线作答结果
+type OfflineAnswersResult struct {
+	Applied    int                  `json:"applied"`    // 已更新复习状态的作答数
+	Stale      int                  `json:"stale"`      // 早于已有复习记录、只计入错题本的作答数
+	Duplicates int                  `json:"duplicates"` // 重复上传被忽略的作答数
+	Rejected   int                  `json:"rejected"`   // 被拒绝的作答数
+	Failed     int                  `json:"failed"`     // 服务端处理失败、可以重新上传的作答数
+	Errors     []OfflineAnswerError `json:"errors"`
+}
+
+// AddFamilyMemberRequest 添加家庭共享成员请求
+type AddFamilyMemberRequest struct {
+	MemberID string `json:"member_id" binding:"required"` // 成员的安全用户标识符
+}
+
+// FamilyInfo 家庭共享信息
+type FamilyInfo struct {
+	Members    []FamilyLink `json:"members"`          // 当前用户共享给的成员
+	MaxMembers int          `json:"max_members"`      // 最多可共享的成员数
+	JoinedLink *FamilyLink  `json:"joined,omitempty"` // 当前用户作为成员加入的家庭
+}
+
+// CreateClassroomRequest 创建班级请求
+type CreateClassroomRequest struct {
+	Name   string `json:"name" binding:"required,max=50"`
+	School string `json:"school,omitempty" binding:"max=100"` // 为空时使用教师资料中的学校
+}
+
+// JoinClassroomRequest 学生加入班级请求
+type JoinClassroomRequest struct {
+	JoinCode string `json:"join_code" binding:"required"`
+}
+
+// CreateClassAssignmentRequest 布置单元请求
+type CreateClassAssignmentRequest struct {
+	BookID string    `json:"book_id" binding:"required"`
+	UnitID string    `json:"unit_id" binding:"required"`
+	Title  string    `json:"title,omitempty" binding:"max=100"` // 为空时使用单元名称
+	DueAt  time.Time `json:"due_at" binding:"required"`
+}
+
+// ClassroomReport 班级学习报告
+type ClassroomReport struct {
+	ClassroomID primitive.ObjectID       `json:"classroom_id"`
+	Name        string                   `json:"name"`
+	School      string                   `json:"school"`
+	Assignments []ClassAssignment        `json:"assignments"`
+	Students    []ClassroomStudentReport `json:"students"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
+
+// ClassroomStudentReport 学生在班级任务中的学习情况
+type ClassroomStudentReport struct {
+	StudentOpenID    string                      `json:"student_openid"`
+	UserName         string                      `json:"user_name"`
+	JoinedAt         time.Time                   `json:"joined_at"`
+	Assignments      []StudentAssignmentProgress `json:"assignments"`
+	CompletedCount   int                         `json:"completed_count"`    // 已完成的任务数
+	QuizCount        int                         `json:"quiz_count"`         // 任务单元中已提交的测验数
+	AverageQuizScore float64                     `json:"average_quiz_score"` // 任务单元测验平均分
+}
+
+// StudentAssignmentProgress 学生某个任务的完成情况
+type StudentAssignmentProgress struct {
+	AssignmentID      primitive.ObjectID `json:"assignment_id"`
+	UnitID            primitive.ObjectID `json:"unit_id"`
+	BookUnlocked      bool               `json:"book_unlocked"` // 学生是否已解锁任务所属书籍
+	MasteredWords     int                `json:"mastered_words"`
+	TotalWords        int                `json:"total_words"`
+	CompletionPercent float64            `json:"completion_percent"`
+	Completed         bool               `json:"completed"` // 单元单词全部掌握
+	Overdue           bool               `json:"overdue"`   // 已过截止时间且未完成
+	QuizCount         int                `json:"quiz_count"`
+	BestQuizScore     float64            `json:"best_quiz_score"`
+	LatestQuizScore   float64            `json:"latest_quiz_score"`
+	LastQuizAt        *time.Time         `json:"last_quiz_at,omitempty"`
+}
+
+// UpdateLeaderboardSettingsRequest 排行榜设置请求
+type UpdateLeaderboardSettingsRequest struct {
+	OptOut *bool `json:"opt_out" binding:"required"`
+}
+
+// LeaderboardItem 排行榜条目
+type LeaderboardItem struct {
+	Rank          int    `json:"rank"`
+	DisplayName   string `json:"display_name"`
+	WordsMastered int    `json:"words_mastered"`
+	StudySeconds  int64  `json:"study_seconds"`
+	IsMe          bool   `json:"is_me"`
+}
+
+// LeaderboardResult 排行榜查询结果
+type LeaderboardResult struct {
+	Scope     string            `json:"scope"`      // class, school, region
+	ScopeName string            `json:"scope_name"` // 班级、学校或城市名称
+	Period    string            `json:"period"`     // weekly, all_time
+	PeriodKey string            `json:"period_key"`
+	Metric    string            `json:"metric"` // words, time
+	Items     []LeaderboardItem `json:"items"`
+	Me        *LeaderboardItem  `json:"me,omitempty"` // 当前用户的排名（已选择不出现时为空）
+	OptedOut  bool              `json:"opted_out"`
+}
+
+// LeaderboardRefreshResult 排行榜刷新结果
+type LeaderboardRefreshResult struct {
+	FullRefresh  bool      `json:"full_refresh"`
+	UpdatedUsers int       `json:"updated_users"`
+	RefreshedAt  time.Time `json:"refreshed_at"`
+}
+
+// StartStudySessionRequest 开始学习会话请求
+type StartStudySessionRequest struct {
+	Activity string `json:"activity" binding:"required,oneof=learn review quiz practice"`
+	BookID   string `json:"book_id,omitempty"`
+	UnitID   string `json:"unit_id,omitempty"`
+}
+
+// EndStudySessionRequest 结束学习会话请求
+type EndStudySessionRequest struct {
+	WordsReviewed int `json:"words_reviewed" binding:"min=0,max=10000"`
+	CorrectCount  int `json:"correct_count" binding:"min=0,max=10000"`
+}
+
+// UpdateStudyGoalRequest 设置每日学习目标请求
+type UpdateStudyGoalRequest struct {
+	DailyWords   int `json:"daily_words" binding:"min=0,max=1000"`
+	DailyMinutes int `json:"daily_minutes" binding:"min=0,max=1440"`
+}
+
+// StudyStreak 连续学习天数
+type StudyStreak struct {
+	Current       int    `json:"current"`                   // 当前连续达成天数（今天未达成时从昨天算起）
+	Longest       int    `json:"longest"`                   // 历史最长连续天数
+	LastStudyDate string `json:"last_study_date,omitempty"` // 最近一次达成目标的日期
+}
+
+// StudyStatsPoint 学习统计图表数据点（一天、一周或一个月）
+type StudyStatsPoint struct {
+	StartDate       string  `json:"start_date"`
+	EndDate         string  `json:"end_date"`
+	SessionCount    int     `json:"session_count"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	WordsReviewed   int     `json:"words_reviewed"`
+	CorrectCount    int     `json:"correct_count"`
+	Accuracy        float64 `json:"accuracy"`
+	GoalMetDays     int     `json:"goal_met_days"`
+}
+
+// StudyStatsResult 学习统计结果
+type StudyStatsResult struct {
+	Period string            `json:"period"` // daily, weekly, monthly
+	Goal   *StudyGoal        `json:"goal,omitempty"`
+	Today  StudyDailyStat    `json:"today"`
+	Streak StudyStreak       `json:"streak"`
+	Points []StudyStatsPoint `json:"points"`
+}
+
+// MistakePracticeRequest 提交错题练习结果请求
+type MistakePracticeRequest struct {
+	Correct *bool `json:"correct" binding:"required"`
+}
+
+// MistakePracticeItem 错题练习题目（错题记录及单词详情）
+type MistakePracticeItem struct {
+	Word    Word        `json:"word"`
+	Mistake MistakeWord `json:"mistake"`
+}
+
+// ReviewWordRequest 提交单词复习结果请求
+type ReviewWordRequest struct {
+	Quality *int `json:"quality" binding:"required,min=0,max=5"` // 回忆质量：0 完全忘记 ~ 5 轻松记住，低于 3 视为遗忘
+}
+
+// ReviewItem 待复习单词（新单词没有复习状态）
+type ReviewItem struct {
+	Word   Word        `json:"word"`
+	Review *WordReview `json:"review,omitempty"`
+	IsNew  bool        `json:"is_new"`
+}
+
 // 代理相关请求结构体
 // WithdrawRequest 提取佣金请求
 type WithdrawRequest struct {
@@ -609,6 +1682,37 @@ type UpdateUserAdminRequest struct {
 	IsAdmin bool `json:"is_admin" binding:"required"`
 }
 
+// UpdateUserPermissionsRequest 更新用户细粒度权限请求
+type UpdateUserPermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateUserStatusRequest 更新用户账号状态请求
+type UpdateUserStatusRequest struct {
+	Status         string    `json:"status" binding:"required,oneof=active suspended banned"`
+	Reason         string    `json:"reason,omitempty"`
+	SuspendedUntil time.Time `json:"suspended_until,omitempty"` // 暂停截止时间（RFC3339），status 为 suspended 时必填
+}
+
+// UserStatusResult 用户账号状态及冻结情况
+type UserStatusResult struct {
+	UserOpenID        string    `json:"user_openid"`
+	Status            string    `json:"status"` // 当前生效的状态
+	Reason            string    `json:"reason,omitempty"`
+	SuspendedUntil    time.Time `json:"suspended_until,omitempty"`
+	StatusUpdatedAt   time.Time `json:"status_updated_at,omitempty"`
+	FrozenCommissions int64     `json:"frozen_commissions"` // 处于冻结状态的佣金记录数
+	FrozenWithdrawals int64     `json:"frozen_withdrawals"` // 处于冻结状态的提现记录数
+}
+
+// PIIReencryptResult 敏感字段重新加密结果
+type PIIReencryptResult struct {
+	ActiveKeyVersion string `json:"active_key_version"` // 当前加密密钥版本
+	ScannedUsers     int    `json:"scanned_users"`      // 扫描的用户数
+	UpdatedUsers     int    `json:"updated_users"`      // 重新加密的用户数
+	FailedUsers      int    `json:"failed_users"`       // 处理失败的用户数
+}
+
 type AdminUserListResponse struct {
 	Users      []User     `json:"users"`
 	Pagination Pagination `json:"pagination"`
@@ -656,6 +1760,7 @@ type CreateProductRequest struct {
 	ProductVersion string   `json:"product_version"`
 	BookID         string   `json:"book_id"`
 	Images         []string `json:"images"`
+	AccessDays     int      `json:"access_days" binding:"min=0"` // 书籍权限有效天数，0 表示永久
 }
 
 type UpdateProductRequest struct {
@@ -667,6 +1772,7 @@ type UpdateProductRequest struct {
 	ProductVersion *string   `json:"product_version"`
 	BookID         *string   `json:"book_id"`
 	Images         *[]string `json:"images"`
+	AccessDays     *int      `json:"access_days"`
 }
 
 type UpdateProductStatusRequest struct {
@@ -712,7 +1818,8 @@ type CreateBookRequest struct {
 	Author          string `json:"author"`
 	Publisher       string `json:"publisher"`
 	CoverImage      string `json:"cover_image"`
-	PublicationDate string `json:"publication_date"`
+	PublicationDate string `json:"publication_date"` // YYYY-MM-DD
+	TrialUnits      int    `json:"trial_units" binding:"min=0"`
 }
 
 type UpdateBookRequest struct {
@@ -724,10 +1831,17 @@ type UpdateBookRequest struct {
 	Publisher       *string `json:"publisher"`
 	CoverImage      *string `json:"cover_image"`
 	PublicationDate *string `json:"publication_date"`
+	TrialUnits      *int    `json:"trial_units"`
 }
 
 type CreateUnitRequest struct {
-	UnitName string `json:"unit_name" binding:"required"`
+	UnitName  string `json:"unit_name" binding:"required"`
+	SortOrder *int   `json:"sort_order"` // 为空时排在最后
+}
+
+// ReorderUnitsRequest 调整单元顺序请求（需包含书中全部单元）
+type ReorderUnitsRequest struct {
+	UnitIDs []string `json:"unit_ids" binding:"required,min=1"`
 }
 
 type UpdateUnitRequest struct {
@@ -735,17 +1849,118 @@ type UpdateUnitRequest struct {
 }
 
 type CreateWordRequest struct {
-	WordName         string `json:"word_name" binding:"required"`
-	WordMeaning      string `json:"word_meaning" binding:"required"`
-	PronunciationURL string `json:"pronunciation_url"`
-	ImgURL           string `json:"img_url"`
+	WordName         string                `json:"word_name" binding:"required"`
+	WordMeaning      string                `json:"word_meaning" binding:"required"`
+	Phonetic         string                `json:"phonetic"`
+	PhoneticUK       string                `json:"phonetic_uk"`
+	PhoneticUS       string                `json:"phonetic_us"`
+	PronunciationURL string                `json:"pronunciation_url"`
+	ImgURL           string                `json:"img_url"`
+	Senses           []WordSense           `json:"senses"`
+	Relations        []WordRelationRequest `json:"relations"`
 }
 
 type UpdateWordRequest struct {
-	WordName         *string `json:"word_name"`
-	WordMeaning      *string `json:"word_meaning"`
-	PronunciationURL *string `json:"pronunciation_url"`
-	ImgURL           *string `json:"img_url"`
+	WordName         *string                `json:"word_name"`
+	WordMeaning      *string                `json:"word_meaning"`
+	Phonetic         *string                `json:"phonetic"`
+	PhoneticUK       *string                `json:"phonetic_uk"`
+	PhoneticUS       *string                `json:"phonetic_us"`
+	PronunciationURL *string                `json:"pronunciation_url"`
+	ImgURL           *string                `json:"img_url"`
+	Senses           *[]WordSense           `json:"senses"`    // 整体替换义项
+	Relations        *[]WordRelationRequest `json:"relations"` // 整体替换关联词
+	UnitID           *string                `json:"unit_id"`   // 移动到同一本书的其他单元
+}
+
+// WordRelationRequest 设置关联词请求；只填 word_name 时自动关联同一本书中的同名单词
+type WordRelationRequest struct {
+	Type     string `json:"type"`
+	WordName string `json:"word_name"`
+	WordID   string `json:"word_id"`
+}
+
+// ContentImportRowError 批量导入时某一行的校验错误
+type ContentImportRowError struct {
+	Row     int    `json:"row"`              // 表格中的行号（表头为第1行）
+	Column  string `json:"column,omitempty"` // 出错的列名
+	Message string `json:"message"`
+}
+
+// ContentImportRow 批量导入预览中的一行
+type ContentImportRow struct {
+	Row      int    `json:"row"`
+	BookName string `json:"book_name"`
+	UnitName string `json:"unit_name"`
+	WordName string `json:"word_name"`
+	Action   string `json:"action"` // create、update 或 unchanged
+}
+
+// ContentImportResult 批量导入结果（预览模式下不写入数据）
+type ContentImportResult struct {
+	DryRun       bool                    `json:"dry_run"`
+	Applied      bool                    `json:"applied"` // 是否已写入数据库；存在错误行时整个文件都不写入
+	TotalRows    int                     `json:"total_rows"`
+	CreatedWords int                     `json:"created_words"`
+	UpdatedWords int                     `json:"updated_words"`
+	Unchanged    int                     `json:"unchanged_words"`
+	CreatedUnits []string                `json:"created_units"` // 将新建的单元（书名 / 单元名）
+	Errors       []ContentImportRowError `json:"errors"`
+	Rows         []ContentImportRow      `json:"rows"`
+}
+
+// ===== 媒体资源相关结构体 =====
+
+// MediaAsset 上传的音频或图片（集合 media_assets，按内容哈希去重）
+type MediaAsset struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	MediaType        string             `bson:"media_type" json:"media_type"` // audio 或 image
+	ContentType      string             `bson:"content_type" json:"content_type"`
+	Size             int64              `bson:"size" json:"size"`
+	SHA256           string             `bson:"sha256" json:"sha256"`
+	Key              string             `bson:"key" json:"key"` // 存储中的对象键
+	URL              string             `bson:"url" json:"url"`
+	ThumbnailKey     string             `bson:"thumbnail_key,omitempty" json:"thumbnail_key,omitempty"`
+	ThumbnailURL     string             `bson:"thumbnail_url,omitempty" json:"thumbnail_url,omitempty"`
+	Width            int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height           int                `bson:"height,omitempty" json:"height,omitempty"`
+	OriginalName     string             `bson:"original_name,omitempty" json:"original_name,omitempty"`
+	UploadedByOpenID string             `bson:"uploaded_by_openid" json:"uploaded_by_openid"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	Duplicate        bool               `bson:"-" json:"duplicate,omitempty"` // 上传内容与已有资源相同，直接返回已有资源
+}
+
+// MediaReference 内容中引用的媒体地址
+type MediaReference struct {
+	Source     string             `json:"source"` // word、book 或 product
+	SourceID   primitive.ObjectID `json:"source_id"`
+	SourceName string             `json:"source_name"`
+	Field      string             `json:"field"` // 引用字段，如 pronunciation_url、senses.examples.audio_url
+	URL        string             `json:"url"`
+	Reason     string             `json:"reason,omitempty"` // 失效原因
+}
+
+// MediaAuditResult 媒体检查结果
+type MediaAuditResult struct {
+	CheckedAt           time.Time        `json:"checked_at"`
+	TotalReferences     int              `json:"total_references"`
+	CheckedReferences   int              `json:"checked_references"`
+	UncheckedReferences int              `json:"unchecked_references"` // 外部地址且未开启远程检查
+	Broken              []MediaReference `json:"broken"`
+	Orphaned            []MediaAsset     `json:"orphaned"` // 未被任何内容引用的资源（上传超过24小时）
+}
+
+// UserFileMigrationResult 用户头像和二维码迁移到对象存储的结果
+type UserFileMigrationResult struct {
+	Scanned         int      `json:"scanned"`
+	QRCodesMigrated int      `json:"qr_codes_migrated"`
+	QRCodesDropped  int      `json:"qr_codes_dropped"` // base64 数据损坏，已清除（访问时重新生成）
+	AvatarsMigrated int      `json:"avatars_migrated"`
+	AvatarsMissing  int      `json:"avatars_missing"` // 本地文件已不存在，已清除头像路径
+	Failed          int      `json:"failed"`
+	FailedUserIDs   []string `json:"failed_user_ids,omitempty"`
+	Remaining       int64    `json:"remaining"`             // 仍待迁移的用户数（含迁移失败的用户）
+	NextCursor      string   `json:"next_cursor,omitempty"` // 下一批的 after 参数，为空表示已处理到最后
 }
 
 // ===== 仪表盘相关结构体 =====
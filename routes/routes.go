@@ -1,9 +1,12 @@
 package routes
 
 import (
+	"log"
 	"miniprogram/config"
 	"miniprogram/controllers"
 	"miniprogram/middlewares"
+	"miniprogram/utils"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,8 +14,16 @@ import (
 // SetupRoutes 设置所有API路由
 func SetupRoutes(r *gin.Engine) {
 
+	// 本地存储的媒体文件通过静态路由提供（使用 S3、OBS 时由存储服务直接提供）
+	// 头像、小程序码等私有文件只能通过带签名的 /files 地址访问
+	if cfg := config.GetConfig(); cfg.StorageBackend == utils.StorageBackendLocal || cfg.StorageBackend == "" {
+		r.Static("/media", filepath.Join(cfg.StorageLocalDir, "media"))
+		r.GET("/files/*key", controllers.ServeSignedFileHandler())
+	}
+
 	// API v1 组
 	v1 := r.Group("/api")
+	v1.Use(middlewares.ResolveUserIDMiddleware())
 	{
 		// 公开路由（不需要认证）
 		public := v1.Group("/")
@@ -20,11 +31,7 @@ func SetupRoutes(r *gin.Engine) {
 			// 用户认证相关路由
 			public.POST("/auth", controllers.WechatAuthHandler())
 
-			// 开发环境专用登录接口（可配置启用/禁用）
-			cfg := config.GetConfig()
-			if cfg.EnableDevLogin {
-				public.POST("/dev-login", controllers.DevLoginHandler())
-			}
+			// 测试身份登录接口只注册在内部监听地址上（见 SetupTestIdentityRoutes）
 			public.POST("/users/profile", controllers.UpdateUserProfileHandler())
 
 			// 商城相关公开路由
@@ -38,6 +45,9 @@ func SetupRoutes(r *gin.Engine) {
 			public.POST("/search", controllers.SearchHandler())
 			public.GET("/search/words", controllers.SearchWordsHandler())
 			public.GET("/search/books", controllers.SearchBooksHandler())
+			public.GET("/search/suggest", controllers.SearchSuggestHandler())
+			public.GET("/search/hot", controllers.GetHotSearchQueriesHandler())
+			public.GET("/search/trending", controllers.GetTrendingSearchQueriesHandler())
 
 			// 推荐相关公开路由
 			public.POST("/referrals/validate", controllers.ValidateReferralCodeHandler())
@@ -49,12 +59,21 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			// Token相关路由
 			protected.POST("/auth/refresh", middlewares.RefreshTokenMiddleware())
+			protected.GET("/auth/session", controllers.CheckWechatSessionHandler())
+			protected.POST("/auth/decrypt", controllers.DecryptWechatDataHandler())
 
 			// 用户管理路由
 			protected.GET("/users/:user_id", controllers.GetUserHandler())
 			protected.POST("/users/:user_id/avatar", controllers.UploadAvatarHandler())
 			protected.GET("/users/:user_id/avatar", controllers.GetAvatarHandler())
 			protected.GET("/users/:user_id/qrcode", controllers.GetUserQRCodeHandler())
+			protected.POST("/users/:user_id/phone", controllers.BindPhoneNumberHandler())
+
+			// 个人数据导出与账号注销路由（仅本人）
+			protected.GET("/users/:user_id/export", controllers.ExportUserDataHandler())
+			protected.POST("/users/:user_id/deletion", controllers.RequestAccountDeletionHandler())
+			protected.GET("/users/:user_id/deletion", controllers.GetAccountDeletionHandler())
+			protected.DELETE("/users/:user_id/deletion", controllers.CancelAccountDeletionHandler())
 
 			// 地址管理路由
 			protected.POST("/users/:user_id/address", controllers.CreateAddressHandler())
@@ -82,11 +101,33 @@ func SetupRoutes(r *gin.Engine) {
 				admin.GET("/users", controllers.GetAllUsersHandler())
 				admin.GET("/users/:user_id", controllers.GetUserDetailHandler())
 				admin.PUT("/users/:user_id/admin", controllers.UpdateUserAdminStatusHandler())
+				admin.PUT("/users/:user_id/permissions", controllers.UpdateUserPermissionsHandler())
+				admin.GET("/users/:user_id/status", controllers.GetUserStatusHandler())
+				admin.PUT("/users/:user_id/status", controllers.UpdateUserStatusHandler())
 				admin.GET("/users/:user_id/orders", controllers.GetUserOrdersHandler())
+				admin.POST("/users/:user_id/book-permissions", controllers.GrantBookPermissionHandler())
+				admin.DELETE("/users/:user_id/book-permissions/:book_id", controllers.RevokeBookPermissionHandler())
 
 				// 订单管理
 				admin.GET("/orders", controllers.GetAllOrdersHandler())
 
+				// 敏感数据管理
+				admin.POST("/pii/reencrypt", controllers.ReencryptPIIHandler())
+
+				// 账号注销管理
+				admin.POST("/account-deletions/process", controllers.ProcessAccountDeletionsHandler())
+
+				// 排行榜管理
+				admin.POST("/leaderboards/refresh", controllers.RefreshLeaderboardsHandler())
+
+				// 激活码管理
+				admin.POST("/activation-batches", controllers.CreateActivationBatchHandler())
+				admin.GET("/activation-batches", controllers.GetActivationBatchesHandler())
+				admin.GET("/activation-batches/:batch_id", controllers.GetActivationBatchHandler())
+				admin.GET("/activation-batches/:batch_id/codes", controllers.ExportActivationCodesHandler())
+				admin.POST("/activation-batches/:batch_id/revoke", controllers.RevokeActivationBatchHandler())
+				admin.POST("/activation-codes/:code/revoke", controllers.RevokeActivationCodeHandler())
+
 				// 代理管理
 				admin.PUT("/users/:user_id/agent-level", controllers.UpdateAgentLevelHandler())
 				admin.PUT("/agents/:user_id/schools", controllers.UpdateAgentSchoolsHandler())
@@ -98,13 +139,81 @@ func SetupRoutes(r *gin.Engine) {
 				admin.PUT("/products/:product_id", controllers.UpdateProductHandler())
 				admin.DELETE("/products/:product_id", controllers.DeleteProductHandler())
 				admin.PUT("/products/:product_id/status", controllers.UpdateProductStatusHandler())
+
+				// 内容管理（书籍、单元、单词）
+				admin.POST("/books", controllers.CreateBookHandler())
+				admin.GET("/books/:book_id", controllers.GetAdminBookHandler())
+				admin.PUT("/books/:book_id", controllers.UpdateBookHandler())
+				admin.DELETE("/books/:book_id", controllers.DeleteBookHandler())
+				admin.POST("/books/:book_id/units", controllers.CreateUnitHandler())
+				admin.PUT("/books/:book_id/units/order", controllers.ReorderUnitsHandler())
+				admin.PUT("/units/:unit_id", controllers.UpdateUnitHandler())
+				admin.DELETE("/units/:unit_id", controllers.DeleteUnitHandler())
+				admin.GET("/units/:unit_id/words", controllers.GetUnitWordsAdminHandler())
+				admin.POST("/units/:unit_id/words", controllers.CreateWordHandler())
+				admin.PUT("/words/:word_id", controllers.UpdateWordHandler())
+				admin.DELETE("/words/:word_id", controllers.DeleteWordHandler())
+				admin.POST("/content/import", controllers.ImportContentHandler())
+				admin.GET("/books/:book_id/export", controllers.ExportBookContentHandler())
+
+				// 媒体资源（音频、图片）
+				admin.POST("/media", controllers.UploadMediaHandler())
+				admin.GET("/media", controllers.GetMediaAssetsHandler())
+				admin.GET("/media/audit", controllers.AuditMediaHandler())
+				admin.DELETE("/media/:asset_id", controllers.DeleteMediaAssetHandler())
+
+				// 旧版头像、base64 小程序码迁移到对象存储
+				admin.POST("/storage/migrate-user-files", controllers.MigrateUserFilesHandler())
+
+				// 重建单词和课本搜索键
+				admin.POST("/search/reindex", controllers.ReindexSearchHandler())
+
+				// 搜索屏蔽词与置顶建议
+				admin.GET("/search/rules", controllers.GetSearchRulesHandler())
+				admin.POST("/search/rules", controllers.CreateSearchRuleHandler())
+				admin.DELETE("/search/rules/:rule_id", controllers.DeleteSearchRuleHandler())
 			}
 
 			// 学习进度相关路由
 			protected.GET("/users/:user_id/progress", controllers.GetProgressHandler())
 			protected.PUT("/users/:user_id/progress", controllers.UpdateProgressHandler())
+			protected.POST("/users/:user_id/progress/sync", controllers.SyncProgressHandler())
+			protected.GET("/users/:user_id/progress/books", controllers.GetBookProgressListHandler())
+			protected.GET("/users/:user_id/progress/books/:book_id", controllers.GetBookUnitProgressHandler())
+			protected.GET("/users/:user_id/reviews/due", controllers.GetDueReviewsHandler())
+			protected.POST("/users/:user_id/reviews/:word_id", controllers.ReviewWordHandler())
+			protected.POST("/users/:user_id/quizzes", controllers.CreateQuizHandler())
+			protected.GET("/users/:user_id/quizzes", controllers.ListQuizzesHandler())
+			protected.GET("/users/:user_id/quizzes/:quiz_id", controllers.GetQuizHandler())
+			protected.POST("/users/:user_id/quizzes/:quiz_id/submit", controllers.SubmitQuizHandler())
+			// 听音题发音（只能获取本人测验题目中的发音）
+			protected.GET("/quiz-audio/:audio_key", controllers.GetQuizAudioHandler())
+			protected.GET("/users/:user_id/mistakes", controllers.GetMistakesHandler())
+			protected.GET("/users/:user_id/mistakes/practice", controllers.GetMistakePracticeHandler())
+			protected.POST("/users/:user_id/mistakes/:word_id/practice", controllers.SubmitMistakePracticeHandler())
+			protected.DELETE("/users/:user_id/mistakes/:word_id", controllers.RemoveMistakeHandler())
+			protected.GET("/users/:user_id/study-packs", controllers.GetStudyPackIndexHandler())
+			protected.GET("/users/:user_id/study-packs/books/:book_id", controllers.GetBookStudyPackHandler())
+			protected.GET("/users/:user_id/study-packs/units/:unit_id", controllers.GetUnitStudyPackHandler())
+			protected.POST("/users/:user_id/study-packs/answers", controllers.UploadOfflineAnswersHandler())
+			protected.POST("/users/:user_id/study-sessions", controllers.StartStudySessionHandler())
+			protected.GET("/users/:user_id/study-sessions", controllers.ListStudySessionsHandler())
+			protected.POST("/users/:user_id/study-sessions/:session_id/end", controllers.EndStudySessionHandler())
+			protected.GET("/users/:user_id/study-goal", controllers.GetStudyGoalHandler())
+			protected.PUT("/users/:user_id/study-goal", controllers.UpdateStudyGoalHandler())
+			protected.GET("/users/:user_id/study-stats", controllers.GetStudyStatsHandler())
+			protected.GET("/users/:user_id/leaderboards", controllers.GetLeaderboardHandler())
+			protected.PUT("/users/:user_id/leaderboard-settings", controllers.UpdateLeaderboardSettingsHandler())
 			protected.GET("/books/:book_id/words", controllers.GetBookWordsHandler())
 
+			// 书籍访问权限与家庭共享路由
+			protected.GET("/users/:user_id/books/:book_id/access", controllers.GetBookAccessHandler())
+			protected.GET("/users/:user_id/family", controllers.GetFamilyHandler())
+			protected.POST("/users/:user_id/family/members", controllers.AddFamilyMemberHandler())
+			protected.DELETE("/users/:user_id/family/members/:member_id", controllers.RemoveFamilyMemberHandler())
+			protected.DELETE("/users/:user_id/family", controllers.LeaveFamilyHandler())
+			protected.POST("/users/:user_id/activation-codes/redeem", controllers.RedeemActivationCodeHandler())
+
 			// 单词卡片相关路由
 			protected.GET("/units/:unit_id/words", controllers.GetUnitWordsHandler())
 			protected.GET("/words/:word_id/card", controllers.GetWordCardHandler())
@@ -124,6 +233,19 @@ func SetupRoutes(r *gin.Engine) {
 			protected.GET("/agents/:user_id/commission/details", controllers.GetAgentCommissionDetailsHandler())
 			protected.POST("/agents/:user_id/withdraw", controllers.WithdrawCommissionHandler())
 
+			// 班级相关路由（教师需被授予 teacher 权限，只能访问自己创建的班级）
+			protected.POST("/teachers/:user_id/classrooms", controllers.CreateClassroomHandler())
+			protected.GET("/teachers/:user_id/classrooms", controllers.GetTeacherClassroomsHandler())
+			protected.GET("/teachers/:user_id/classrooms/:classroom_id", controllers.GetTeacherClassroomHandler())
+			protected.DELETE("/teachers/:user_id/classrooms/:classroom_id", controllers.DeleteClassroomHandler())
+			protected.DELETE("/teachers/:user_id/classrooms/:classroom_id/students/:student_id", controllers.RemoveClassroomStudentHandler())
+			protected.POST("/teachers/:user_id/classrooms/:classroom_id/assignments", controllers.CreateClassAssignmentHandler())
+			protected.DELETE("/teachers/:user_id/classrooms/:classroom_id/assignments/:assignment_id", controllers.DeleteClassAssignmentHandler())
+			protected.GET("/teachers/:user_id/classrooms/:classroom_id/report", controllers.GetClassroomReportHandler())
+			protected.POST("/users/:user_id/classrooms/join", controllers.JoinClassroomHandler())
+			protected.GET("/users/:user_id/classrooms", controllers.GetStudentClassroomsHandler())
+			protected.DELETE("/users/:user_id/classrooms/:classroom_id", controllers.LeaveClassroomHandler())
+
 			// 商城相关路由
 			// 购物车路由
 			protected.GET("/users/:user_id/cart", controllers.GetCartHandler())
@@ -154,6 +276,11 @@ func SetupRoutes(r *gin.Engine) {
 
 			// 受保护的搜索路由（需要用户身份）
 			protected.GET("/search/orders", controllers.SearchOrdersHandler())
+
+			// 搜索历史路由（仅本人）
+			protected.GET("/users/:user_id/search-history", controllers.GetSearchHistoryHandler())
+			protected.DELETE("/users/:user_id/search-history", controllers.ClearSearchHistoryHandler())
+			protected.DELETE("/users/:user_id/search-history/:history_id", controllers.DeleteSearchHistoryHandler())
 		}
 
 		// 微信支付回调路由（不需要JWT认证）
@@ -166,3 +293,20 @@ func SetupRoutes(r *gin.Engine) {
 	// 健康检查
 	r.GET("/health", controllers.HealthCheckHandler())
 }
+
+// SetupTestIdentityRoutes 设置测试身份登录路由
+// 该路由只注册在单独的内部监听地址（TEST_IDENTITY_LISTEN，只能是本机回环地址）上，
+// 对外端口和经过反向代理的请求无法访问；生产环境或未配置签名密钥时返回 false，不启动内部监听
+func SetupTestIdentityRoutes(r *gin.Engine) bool {
+	cfg := config.GetConfig()
+	if !cfg.DevLoginAllowed() {
+		if cfg.EnableDevLogin {
+			log.Println("警告: ENABLE_DEV_LOGIN 已设置，但当前为生产环境、未配置 TEST_IDENTITY_SECRET 或 TEST_IDENTITY_LISTEN 不是本机回环地址，测试身份登录接口未启用")
+		}
+		return false
+	}
+
+	log.Printf("警告: 测试身份登录接口已启用，监听地址: %s，白名单openID数量: %d", cfg.TestIdentityListen, len(cfg.TestIdentityOpenIDs))
+	r.POST("/api/dev-login", controllers.DevLoginHandler())
+	return true
+}
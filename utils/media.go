@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+/**
+ * 媒体文件工具函数
+ * 根据文件内容识别音频、图片类型，生成图片缩略图（仅使用标准库支持的 JPEG、PNG、GIF 解码）
+ */
+
+// 媒体类型
+const (
+	MediaTypeAudio = "audio"
+	MediaTypeImage = "image"
+)
+
+// ErrUnsupportedMedia 不支持的媒体文件类型
+var ErrUnsupportedMedia = errors.New("不支持的文件类型，音频只支持 mp3、m4a、wav、ogg，图片只支持 jpg、png、gif、webp")
+
+// maxThumbnailSourcePixels 生成缩略图时允许解码的最大像素数，防止解压炸弹
+const maxThumbnailSourcePixels = 40_000_000
+
+// MediaFormat 识别出的媒体格式
+type MediaFormat struct {
+	MediaType   string // audio 或 image
+	ContentType string
+	Extension   string
+}
+
+// mediaFormats 支持的内容类型
+var mediaFormats = map[string]MediaFormat{
+	"audio/mpeg": {MediaTypeAudio, "audio/mpeg", ".mp3"},
+	"audio/mp4":  {MediaTypeAudio, "audio/mp4", ".m4a"},
+	"audio/wav":  {MediaTypeAudio, "audio/wav", ".wav"},
+	"audio/ogg":  {MediaTypeAudio, "audio/ogg", ".ogg"},
+	"image/jpeg": {MediaTypeImage, "image/jpeg", ".jpg"},
+	"image/png":  {MediaTypeImage, "image/png", ".png"},
+	"image/gif":  {MediaTypeImage, "image/gif", ".gif"},
+	"image/webp": {MediaTypeImage, "image/webp", ".webp"},
+}
+
+// DetectMediaFormat 根据文件内容识别媒体格式，不信任客户端提供的 Content-Type
+func DetectMediaFormat(data []byte, filename string) (MediaFormat, error) {
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	switch contentType {
+	case "audio/wave":
+		contentType = "audio/wav"
+	case "application/ogg":
+		contentType = "audio/ogg"
+	case "video/mp4":
+		// MP4 容器按文件头中的品牌区分音频
+		if isMP4Audio(data) || strings.EqualFold(filepath.Ext(filename), ".m4a") {
+			contentType = "audio/mp4"
+		}
+	case "application/octet-stream":
+		// 没有 ID3 标签的 MP3 以帧同步字开头
+		if len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 {
+			contentType = "audio/mpeg"
+		} else if isMP4Audio(data) {
+			contentType = "audio/mp4"
+		}
+	}
+
+	format, ok := mediaFormats[contentType]
+	if !ok {
+		return MediaFormat{}, ErrUnsupportedMedia
+	}
+	return format, nil
+}
+
+// isMP4Audio 检查 MP4 文件品牌是否为音频（M4A、M4B）
+func isMP4Audio(data []byte) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	brand := string(data[8:12])
+	return brand == "M4A " || brand == "M4B "
+}
+
+// ImageDimensions 读取图片宽高（WebP 无法识别时返回 0）
+func ImageDimensions(data []byte) (int, int) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
+
+// MakeThumbnail 生成长边不超过 maxSize 的缩略图，PNG 和 GIF 输出 PNG 以保留透明度，其余输出 JPEG
+// 图片格式不支持解码（如 WebP）时返回 ErrUnsupportedMedia
+func MakeThumbnail(data []byte, maxSize int) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedMedia
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxThumbnailSourcePixels {
+		return nil, "", ErrUnsupportedMedia
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	thumb := scaleImage(src, maxSize)
+
+	var buf bytes.Buffer
+	if format == "png" || format == "gif" {
+		if err := png.Encode(&buf, thumb); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// scaleImage 按区域平均（预乘透明度）缩小图片，长边不超过 maxSize（不放大）
+func scaleImage(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > maxSize || srcH > maxSize {
+		if srcW >= srcH {
+			dstW, dstH = maxSize, max(1, srcH*maxSize/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*maxSize/srcH), maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"miniprogram/config"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/**
+ * 敏感字段（手机号、收货人、详细地址等）静态加密工具
+ *
+ * 密钥层级：
+ * 1. 主密钥（PII_ENCRYPTION_KEYS，按版本配置，如 "v1:xxx,v2:yyy"）只用于派生，不直接加密数据
+ * 2. 数据密钥 = HMAC-SHA256(主密钥, "pii-data-key|版本")，用于 AES-256-GCM 加密
+ * 3. 盲索引密钥 = HMAC-SHA256(PII_BLIND_INDEX_KEY, "pii-blind-index")，用于等值查询
+ *
+ * 密文格式：enc:{版本}:{Base64URL(nonce + 密文)}
+ * 轮换时新增主密钥版本并切换 PII_ACTIVE_KEY_VERSION，旧版本密文仍可解密，重新保存后使用新版本加密
+ */
+
+// piiCiphertextPrefix 敏感字段密文前缀
+const piiCiphertextPrefix = "enc:"
+
+// piiKeyring 敏感字段密钥环
+type piiKeyring struct {
+	activeVersion string
+	ciphers       map[string]cipher.AEAD
+	blindIndexKey []byte
+}
+
+var (
+	globalPIIKeyring *piiKeyring
+	piiKeyringErr    error
+	piiKeyringOnce   sync.Once
+)
+
+// getPIIKeyring 获取全局密钥环（首次调用时根据配置初始化）
+func getPIIKeyring() (*piiKeyring, error) {
+	piiKeyringOnce.Do(func() {
+		globalPIIKeyring, piiKeyringErr = newPIIKeyring(config.GetConfig())
+	})
+	return globalPIIKeyring, piiKeyringErr
+}
+
+// newPIIKeyring 根据配置创建密钥环
+func newPIIKeyring(cfg *config.Config) (*piiKeyring, error) {
+	masterKeys := map[string]string{}
+	for _, item := range cfg.PIIEncryptionKeys {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("PII_ENCRYPTION_KEYS 格式错误，应为 版本:密钥")
+		}
+		masterKeys[parts[0]] = parts[1]
+	}
+
+	if len(masterKeys) == 0 {
+		return nil, fmt.Errorf("未配置 PII_ENCRYPTION_KEYS")
+	}
+	if cfg.PIIBlindIndexKey == "" {
+		return nil, fmt.Errorf("未配置 PII_BLIND_INDEX_KEY")
+	}
+
+	if _, ok := masterKeys[cfg.PIIActiveKeyVersion]; !ok {
+		return nil, fmt.Errorf("未找到当前加密密钥版本: %s", cfg.PIIActiveKeyVersion)
+	}
+
+	keyring := &piiKeyring{
+		activeVersion: cfg.PIIActiveKeyVersion,
+		ciphers:       map[string]cipher.AEAD{},
+		blindIndexKey: deriveKey(cfg.PIIBlindIndexKey, "pii-blind-index"),
+	}
+
+	for version, masterKey := range masterKeys {
+		block, err := aes.NewCipher(deriveKey(masterKey, "pii-data-key|"+version))
+		if err != nil {
+			return nil, fmt.Errorf("创建AES加密器失败: %v", err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("创建GCM模式失败: %v", err)
+		}
+		keyring.ciphers[version] = gcm
+	}
+
+	return keyring, nil
+}
+
+// deriveKey 从主密钥派生32字节子密钥
+func deriveKey(masterKey, label string) []byte {
+	mac := hmac.New(sha256.New, []byte(masterKey))
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// EncryptPII 使用当前版本密钥加密敏感字段，空字符串不加密
+func EncryptPII(plaintext string) (string, error) {
+	if plaintext == "" {
+		return plaintext, nil
+	}
+
+	keyring, err := getPIIKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	gcm := keyring.ciphers[keyring.activeVersion]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("生成nonce失败: %v", err)
+	}
+
+	// 以版本号作为附加数据，防止密文被挪用到其他版本
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(keyring.activeVersion))
+	return piiCiphertextPrefix + keyring.activeVersion + ":" + base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptPII 解密敏感字段，非密文格式的历史明文数据按原样返回
+func DecryptPII(value string) (string, error) {
+	if !IsEncryptedPII(value) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, piiCiphertextPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("敏感字段密文格式错误")
+	}
+	version, encoded := parts[0], parts[1]
+
+	keyring, err := getPIIKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	gcm, ok := keyring.ciphers[version]
+	if !ok {
+		return "", fmt.Errorf("未找到敏感字段密钥版本: %s", version)
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("Base64解码失败: %v", err)
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", errors.New("密文长度不足")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(version))
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %v", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// IsEncryptedPII 判断值是否为敏感字段密文
+func IsEncryptedPII(value string) bool {
+	return strings.HasPrefix(value, piiCiphertextPrefix)
+}
+
+// PIIKeyVersion 获取密文使用的密钥版本，明文返回空字符串
+func PIIKeyVersion(value string) string {
+	if !IsEncryptedPII(value) {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(value, piiCiphertextPrefix), ":", 2)[0]
+}
+
+// ActivePIIKeyVersion 获取当前用于加密的密钥版本
+func ActivePIIKeyVersion() (string, error) {
+	keyring, err := getPIIKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.activeVersion, nil
+}
+
+// NormalizePhone 规范化手机号（去除空格、短横线等非数字字符，保留开头的+号）
+func NormalizePhone(phone string) string {
+	var builder strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if unicode.IsDigit(r) || (i == 0 && r == '+') {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// PhoneBlindIndex 计算手机号盲索引，空手机号返回空字符串
+func PhoneBlindIndex(phone string) string {
+	normalized := NormalizePhone(phone)
+	if normalized == "" {
+		return ""
+	}
+
+	keyring, err := getPIIKeyring()
+	if err != nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, keyring.blindIndexKey)
+	mac.Write([]byte("phone|" + normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MaskPhone 手机号脱敏，如 138****8000
+func MaskPhone(phone string) string {
+	runes := []rune(phone)
+	if len(runes) == 0 {
+		return ""
+	}
+	if len(runes) <= 7 {
+		return string(runes[:1]) + strings.Repeat("*", len(runes)-1)
+	}
+	return string(runes[:3]) + "****" + string(runes[len(runes)-4:])
+}
+
+// MaskName 姓名脱敏，只保留第一个字，如 张*
+func MaskName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return ""
+	}
+	return string(runes[:1]) + strings.Repeat("*", len(runes)-1)
+}
+
+// MaskStreet 详细地址脱敏，只保留前4个字，如 中关村大****
+func MaskStreet(street string) string {
+	runes := []rune(street)
+	if len(runes) == 0 {
+		return ""
+	}
+	if len(runes) <= 4 {
+		return string(runes[:1]) + "****"
+	}
+	return string(runes[:4]) + "****"
+}
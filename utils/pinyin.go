@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+)
+
+/**
+ * 汉字拼音工具函数
+ * 拼音表（pinyin_table.go）覆盖 GB2312 一、二级汉字，每个字只有一个读音，常用多音字的其他读音在 pinyinAlternates 中补充
+ * 拼音不带声调，ü 写作 v；首字母取拼音的第一个字母（zh、ch、sh 分别取 z、c、s）
+ */
+
+const (
+	// maxPinyinRunLength 从每个汉字开始最多连续转写的字数
+	maxPinyinRunLength = 10
+	// maxPinyinVariants 多音字组合时每个起始位置最多生成的拼音串数量
+	maxPinyinVariants = 8
+)
+
+// pinyinAlternates 常用多音字的其他读音
+var pinyinAlternates = map[rune][]string{
+	'长': {"chang"}, '重': {"chong"}, '行': {"hang"}, '乐': {"yue"}, '还': {"huan"}, '都': {"du"},
+	'地': {"di"}, '的': {"di"}, '得': {"dei"}, '觉': {"jiao"}, '便': {"pian"}, '藏': {"zang"},
+	'朝': {"zhao"}, '传': {"zhuan"}, '单': {"shan"}, '会': {"kuai"}, '说': {"shui"},
+	'曾': {"zeng"}, '解': {"xie"}, '血': {"xie"}, '校': {"jiao"}, '区': {"ou"}, '色': {"shai"},
+	'着': {"zhao", "zhuo"}, '了': {"liao"}, '和': {"huo", "hu"}, '调': {"tiao"},
+	'差': {"chai", "ci"}, '参': {"shen", "cen"}, '降': {"xiang"}, '角': {"jue"}, '露': {"lou"},
+	'率': {"shuai"}, '模': {"mu"}, '强': {"jiang"}, '省': {"xing"}, '数': {"shuo"}, '似': {"si"},
+	'弹': {"tan"}, '提': {"di"}, '卡': {"qia"}, '厦': {"xia"}, '薄': {"bo"}, '系': {"ji"},
+	'壳': {"qiao"}, '给': {"ji"}, '奇': {"ji"}, '大': {"dai"}, '称': {"chen"}, '仇': {"qiu"},
+	'恶': {"wu"}, '折': {"she"},
+}
+
+var (
+	pinyinReadings map[rune][]string
+	pinyinOnce     sync.Once
+)
+
+// loadPinyin 解析拼音表并合并多音字读音
+func loadPinyin() {
+	pinyinReadings = make(map[rune][]string, 7000)
+	for _, line := range strings.Split(strings.TrimSpace(pinyinTable), "\n") {
+		syllable, chars, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		for _, r := range chars {
+			pinyinReadings[r] = []string{syllable}
+		}
+	}
+	for r, alternates := range pinyinAlternates {
+		for _, alternate := range alternates {
+			if !containsString(pinyinReadings[r], alternate) {
+				pinyinReadings[r] = append(pinyinReadings[r], alternate)
+			}
+		}
+	}
+}
+
+// containsString 切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// HanziPinyin 返回汉字的拼音（第一个为最常用读音），不在拼音表中的字符返回 nil
+func HanziPinyin(r rune) []string {
+	pinyinOnce.Do(loadPinyin)
+	return pinyinReadings[r]
+}
+
+// isPinyinQuery 查询词是否可能是拼音或拼音首字母（只包含小写字母）
+func isPinyinQuery(word string) bool {
+	if word == "" {
+		return false
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// pinyinRunAt 返回从 runes[start] 开始连续汉字的读音（最多 maxPinyinRunLength 个字）
+func pinyinRunAt(runes []rune, start int) [][]string {
+	var readings [][]string
+	for i := start; i < len(runes) && len(readings) < maxPinyinRunLength; i++ {
+		pinyin := HanziPinyin(runes[i])
+		if pinyin == nil {
+			break
+		}
+		readings = append(readings, pinyin)
+	}
+	return readings
+}
+
+// pinyinInitials 读音的首字母（去重）
+func pinyinInitials(readings []string) []string {
+	initials := make([]string, 0, len(readings))
+	for _, reading := range readings {
+		if initial := reading[:1]; !containsString(initials, initial) {
+			initials = append(initials, initial)
+		}
+	}
+	return initials
+}
+
+// pinyinCombinations 按读音组合生成拼音串，每个字的读音通过 pick 选取，总数不超过 maxPinyinVariants
+func pinyinCombinations(readings [][]string, pick func([]string) []string) []string {
+	combinations := []string{""}
+	for _, reading := range readings {
+		var next []string
+		for _, prefix := range combinations {
+			for _, syllable := range pick(reading) {
+				if len(next) < maxPinyinVariants {
+					next = append(next, prefix+syllable)
+				}
+			}
+		}
+		combinations = next
+		if len(combinations[0]) >= maxSearchPrefixLength {
+			break
+		}
+	}
+	return combinations
+}
+
+// AddPinyin 添加中文文本的拼音搜索键：从每个汉字开始的全拼和首字母串的全部前缀
+func (b *SearchKeyBuilder) AddPinyin(text string) {
+	for _, run := range TokenizeSearchText(text).CJK {
+		runes := []rune(run)
+		for start := range runes {
+			readings := pinyinRunAt(runes, start)
+			if len(readings) == 0 {
+				continue
+			}
+			for _, full := range pinyinCombinations(readings, func(r []string) []string { return r }) {
+				b.addPrefixes(full, 1)
+			}
+			for _, initials := range pinyinCombinations(readings, pinyinInitials) {
+				b.addPrefixes(initials, 1)
+			}
+		}
+	}
+}
+
+// matchPinyinPrefix 判断 query 是否为连续汉字的全拼前缀（initials 为 true 时按首字母），返回覆盖的字数，不匹配时返回 0
+// 全拼的最后一个音节可以只输入开头部分，如 pinggu 匹配“苹果”
+func matchPinyinPrefix(readings [][]string, query string, initials bool) int {
+	var match func(index int, rest string) int
+	match = func(index int, rest string) int {
+		if rest == "" {
+			return index
+		}
+		if index == len(readings) {
+			return 0
+		}
+		for _, reading := range readings[index] {
+			if initials {
+				reading = reading[:1]
+			}
+			if strings.HasPrefix(rest, reading) {
+				if covered := match(index+1, rest[len(reading):]); covered > 0 {
+					return covered
+				}
+			} else if strings.HasPrefix(reading, rest) {
+				return index + 1
+			}
+		}
+		return 0
+	}
+	return match(0, query)
+}
@@ -0,0 +1,67 @@
+package utils
+
+import "testing"
+
+func TestMatchSearchTextPinyin(t *testing.T) {
+	tests := []struct {
+		text, query string
+		matched     bool
+		prefix      bool
+	}{
+		{"苹果", "pg", true, true},
+		{"苹果", "pingguo", true, true},
+		{"苹果", "pinggu", true, true},
+		{"苹果", "pin", true, true},
+		{"红苹果", "pg", true, false},
+		{"银行", "yinhang", true, true}, // 多音字的其他读音
+		{"苹果", "xg", false, false},
+		{"苹果", "pg1", false, false},
+	}
+	for _, tt := range tests {
+		match := MatchSearchText(tt.text, TokenizeSearchText(tt.query), NormalizeSearchText(tt.query), true, true)
+		if matched := len(match.Terms) > 0; matched != tt.matched || match.Pinyin != tt.matched || match.Prefix != tt.prefix {
+			t.Errorf("MatchSearchText(%q, %q) = terms %v pinyin %v prefix %v, want matched %v prefix %v",
+				tt.text, tt.query, match.Terms, match.Pinyin, match.Prefix, tt.matched, tt.prefix)
+		}
+	}
+
+	if match := MatchSearchText("苹果", TokenizeSearchText("pg"), "pg", true, false); len(match.Terms) != 0 {
+		t.Errorf("MatchSearchText without pinyin matched %v", match.Terms)
+	}
+}
+
+func TestMatchPinyinPrefix(t *testing.T) {
+	readings := [][]string{{"ping"}, {"guo"}}
+	tests := []struct {
+		query    string
+		initials bool
+		covered  int
+	}{
+		{"pingguo", false, 2},
+		{"pingg", false, 2},
+		{"pi", false, 1},
+		{"pingguoa", false, 0},
+		{"pg", true, 2},
+		{"p", true, 1},
+		{"gp", true, 0},
+	}
+	for _, tt := range tests {
+		if covered := matchPinyinPrefix(readings, tt.query, tt.initials); covered != tt.covered {
+			t.Errorf("matchPinyinPrefix(%q, %v) = %d, want %d", tt.query, tt.initials, covered, tt.covered)
+		}
+	}
+}
+
+func TestAddPinyin(t *testing.T) {
+	builder := NewSearchKeyBuilder()
+	builder.AddPinyin("苹果")
+	keys := make(map[string]bool)
+	for _, key := range builder.Keys() {
+		keys[key] = true
+	}
+	for key, want := range map[string]bool{"p": true, "pg": true, "pin": true, "pingguo": true, "guo": true, "gp": false, "guoping": false} {
+		if keys[key] != want {
+			t.Errorf("AddPinyin(苹果) key %q present = %v, want %v", key, keys[key], want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+/**
+ * 搜索文本工具函数
+ * 文本统一规范化（小写、全角转半角、去掉音标重音符号）后切分为英文词和中日韩字符串：
+ * 名称类字段为每个词生成全部前缀，正文类字段生成完整词和3个字符以上的前缀，中日韩文本生成单字和相邻双字
+ * 生成的搜索键保存在文档的 search_keys 字段并建立索引，查询时不使用正则表达式
+ */
+
+const (
+	// maxSearchPrefixLength 生成前缀的最大长度
+	maxSearchPrefixLength = 20
+	// minTextPrefixLength 正文类字段生成前缀的最小长度
+	minTextPrefixLength = 3
+	// MaxSearchQueryKeys 一次查询最多使用的搜索键数量
+	MaxSearchQueryKeys = 8
+)
+
+// normalizeSearchRune 规范化单个字符，返回 false 表示该字符应被忽略（如音标重音符号）
+func normalizeSearchRune(r rune) (rune, bool) {
+	switch {
+	case r == 'ˈ' || r == 'ˌ':
+		return 0, false
+	case r == '　':
+		return ' ', true
+	case r >= '！' && r <= '～':
+		// 全角 ASCII 转半角
+		r -= 0xFEE0
+	}
+	return unicode.ToLower(r), true
+}
+
+// isCJK 是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// isSearchWordRune 是否为英文词（含音标字符、数字）的组成字符
+func isSearchWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)) && !isCJK(r)
+}
+
+// NormalizeSearchText 规范化搜索文本
+func NormalizeSearchText(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		if n, ok := normalizeSearchRune(r); ok {
+			builder.WriteRune(n)
+		}
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// SearchTerms 文本切分结果
+type SearchTerms struct {
+	Words []string // 英文词（含数字、音标）
+	CJK   []string // 连续的中日韩文字
+}
+
+// Empty 是否没有可搜索的内容
+func (t SearchTerms) Empty() bool {
+	return len(t.Words) == 0 && len(t.CJK) == 0
+}
+
+// TokenizeSearchText 规范化并切分文本
+func TokenizeSearchText(text string) SearchTerms {
+	var terms SearchTerms
+	var current []rune
+	currentCJK := false
+	flush := func() {
+		if len(current) > 0 {
+			if currentCJK {
+				terms.CJK = append(terms.CJK, string(current))
+			} else {
+				terms.Words = append(terms.Words, string(current))
+			}
+		}
+		current = current[:0]
+	}
+
+	for _, r := range text {
+		n, ok := normalizeSearchRune(r)
+		if !ok {
+			continue
+		}
+		switch {
+		case isCJK(n):
+			if !currentCJK {
+				flush()
+				currentCJK = true
+			}
+			current = append(current, n)
+		case isSearchWordRune(n):
+			if currentCJK {
+				flush()
+				currentCJK = false
+			}
+			current = append(current, n)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// SearchKeyBuilder 搜索键生成器（自动去重）
+type SearchKeyBuilder struct {
+	keys map[string]struct{}
+}
+
+// NewSearchKeyBuilder 创建搜索键生成器
+func NewSearchKeyBuilder() *SearchKeyBuilder {
+	return &SearchKeyBuilder{keys: make(map[string]struct{})}
+}
+
+// AddName 添加名称类字段（单词、书名等），生成每个词的全部前缀
+func (b *SearchKeyBuilder) AddName(text string) {
+	terms := TokenizeSearchText(text)
+	for _, word := range terms.Words {
+		b.addPrefixes(word, 1)
+	}
+	for _, run := range terms.CJK {
+		b.addNGrams(run)
+	}
+}
+
+// AddText 添加正文类字段（释义、例句等），生成完整词、较长前缀和中文单字、双字
+func (b *SearchKeyBuilder) AddText(text string) {
+	terms := TokenizeSearchText(text)
+	for _, word := range terms.Words {
+		b.addPrefixes(word, minTextPrefixLength)
+		b.keys[word] = struct{}{}
+	}
+	for _, run := range terms.CJK {
+		b.addNGrams(run)
+	}
+}
+
+// addPrefixes 添加长度不小于 minLength 的前缀
+func (b *SearchKeyBuilder) addPrefixes(word string, minLength int) {
+	runes := []rune(word)
+	for i := minLength; i <= len(runes) && i <= maxSearchPrefixLength; i++ {
+		b.keys[string(runes[:i])] = struct{}{}
+	}
+}
+
+// addNGrams 添加单字和相邻双字
+func (b *SearchKeyBuilder) addNGrams(run string) {
+	runes := []rune(run)
+	for i := range runes {
+		b.keys[string(runes[i])] = struct{}{}
+		if i+1 < len(runes) {
+			b.keys[string(runes[i:i+2])] = struct{}{}
+		}
+	}
+}
+
+// Keys 返回全部搜索键
+func (b *SearchKeyBuilder) Keys() []string {
+	keys := make([]string, 0, len(b.keys))
+	for key := range b.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// SearchQueryKeys 生成查询使用的搜索键：英文词取前20个字符，中文取相邻双字（单字时取单字）
+func SearchQueryKeys(terms SearchTerms) []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	add := func(key string) {
+		if key != "" && !seen[key] && len(keys) < MaxSearchQueryKeys {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, word := range terms.Words {
+		runes := []rune(word)
+		if len(runes) > maxSearchPrefixLength {
+			runes = runes[:maxSearchPrefixLength]
+		}
+		add(string(runes))
+	}
+	for _, run := range terms.CJK {
+		runes := []rune(run)
+		if len(runes) == 1 {
+			add(run)
+			continue
+		}
+		for i := 0; i+1 < len(runes); i++ {
+			add(string(runes[i : i+2]))
+		}
+	}
+	return keys
+}
+
+// SearchMatch 字段匹配结果
+type SearchMatch struct {
+	Terms  []int    // 命中的查询词序号（英文词在前，中日韩文字在后）
+	Exact  bool     // 整个字段与查询完全相同
+	Prefix bool     // 字段以查询开头
+	Pinyin bool     // 有查询词只通过拼音命中
+	spans  [][2]int // 命中位置（原文字符下标）
+}
+
+// MatchSearchText 在字段文本中匹配查询词
+// prefixOnly 为 true 时英文词只需匹配文本中某个词的开头（名称类字段），否则查询词少于3个字符时要求完整匹配
+// pinyin 为 true 时英文词还可以按全拼或首字母匹配连续的汉字（如 pg、pingguo 匹配“苹果”）
+func MatchSearchText(text string, terms SearchTerms, query string, prefixOnly, pinyin bool) SearchMatch {
+	var match SearchMatch
+	if text == "" || terms.Empty() {
+		return match
+	}
+
+	// 规范化后的字符与原文字符下标的对应关系
+	original := []rune(text)
+	normalized := make([]rune, 0, len(original))
+	positions := make([]int, 0, len(original))
+	for i, r := range original {
+		if n, ok := normalizeSearchRune(r); ok {
+			normalized = append(normalized, n)
+			positions = append(positions, i)
+		}
+	}
+	normalizedText := strings.TrimSpace(string(normalized))
+	if normalizedText == query {
+		match.Exact = true
+	}
+	if strings.HasPrefix(normalizedText, query) {
+		match.Prefix = true
+	}
+
+	addSpan := func(start, length int) {
+		match.spans = append(match.spans, [2]int{positions[start], positions[start+length-1] + 1})
+	}
+
+	for index, word := range terms.Words {
+		needle := []rune(word)
+		found := false
+		for i := 0; i+len(needle) <= len(normalized); i++ {
+			// 只匹配词的开头
+			if i > 0 && isSearchWordRune(normalized[i-1]) {
+				continue
+			}
+			if !runesEqual(normalized[i:i+len(needle)], needle) {
+				continue
+			}
+			end := i + len(needle)
+			wholeWord := end == len(normalized) || !isSearchWordRune(normalized[end])
+			if !prefixOnly && !wholeWord && len(needle) < minTextPrefixLength {
+				continue
+			}
+			addSpan(i, len(needle))
+			found = true
+		}
+		if !found && pinyin && isPinyinQuery(word) {
+			for i := range normalized {
+				readings := pinyinRunAt(normalized, i)
+				if len(readings) == 0 {
+					continue
+				}
+				covered := matchPinyinPrefix(readings, word, false)
+				if covered == 0 {
+					covered = matchPinyinPrefix(readings, word, true)
+				}
+				if covered > 0 {
+					addSpan(i, covered)
+					found = true
+					match.Pinyin = true
+					if i == 0 && len(terms.Words)+len(terms.CJK) == 1 {
+						match.Prefix = true
+					}
+				}
+			}
+		}
+		if found {
+			match.Terms = append(match.Terms, index)
+		}
+	}
+
+	for index, run := range terms.CJK {
+		needle := []rune(run)
+		found := false
+		for i := 0; i+len(needle) <= len(normalized); i++ {
+			if runesEqual(normalized[i:i+len(needle)], needle) {
+				addSpan(i, len(needle))
+				found = true
+			}
+		}
+		if found {
+			match.Terms = append(match.Terms, len(terms.Words)+index)
+		}
+	}
+	return match
+}
+
+// runesEqual 比较两个字符切片
+func runesEqual(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Highlight 返回转义后的 HTML 文本，命中部分用 <em></em> 包裹
+func (m SearchMatch) Highlight(text string) string {
+	if len(m.spans) == 0 {
+		return html.EscapeString(text)
+	}
+	runes := []rune(text)
+	marked := make([]bool, len(runes))
+	for _, span := range m.spans {
+		for i := span[0]; i < span[1] && i < len(runes); i++ {
+			marked[i] = true
+		}
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			builder.WriteString("<em>" + segment + "</em>")
+		} else {
+			builder.WriteString(segment)
+		}
+		i = j
+	}
+	return builder.String()
+}
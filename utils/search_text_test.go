@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeSearchText(t *testing.T) {
+	tests := []struct {
+		text  string
+		words []string
+		cjk   []string
+	}{
+		{"Hello World", []string{"hello", "world"}, nil},
+		{"ＡＢＣ　ｄｅｆ", []string{"abc", "def"}, nil},
+		{"ˈæpl", []string{"æpl"}, nil},
+		{"apple苹果pie", []string{"apple", "pie"}, []string{"苹果"}},
+		// 正则元字符只作为分隔符
+		{"a.*b+(c)[d]|e^$", []string{"a", "b", "c", "d", "e"}, nil},
+		{".*+?()[]{}|^$\\", nil, nil},
+	}
+	for _, tt := range tests {
+		terms := TokenizeSearchText(tt.text)
+		if !reflect.DeepEqual(terms.Words, tt.words) || !reflect.DeepEqual(terms.CJK, tt.cjk) {
+			t.Errorf("TokenizeSearchText(%q) = %+v, want words %v cjk %v", tt.text, terms, tt.words, tt.cjk)
+		}
+	}
+}
+
+func TestSearchQueryKeys(t *testing.T) {
+	tests := []struct {
+		query string
+		keys  []string
+	}{
+		{"apple pie", []string{"apple", "pie"}},
+		{"红苹果", []string{"红苹", "苹果"}},
+		{"果", []string{"果"}},
+		{"go go", []string{"go"}},
+		{".*", []string{}},
+	}
+	for _, tt := range tests {
+		if keys := SearchQueryKeys(TokenizeSearchText(tt.query)); !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("SearchQueryKeys(%q) = %v, want %v", tt.query, keys, tt.keys)
+		}
+	}
+}
+
+func TestMatchSearchText(t *testing.T) {
+	tests := []struct {
+		text, query   string
+		prefixOnly    bool
+		matched       bool
+		exact, prefix bool
+	}{
+		{"Apple", "apple", true, true, true, true},
+		{"pineapple", "pine", true, true, false, true},
+		{"pineapple", "apple", true, false, false, false},
+		// 正文类字段中少于3个字符的查询词要求完整匹配
+		{"it appends", "ap", false, false, false, false},
+		{"an apple", "an", false, true, false, true},
+		// 正则元字符不匹配任意字符
+		{"axc", "a.c", false, false, false, false},
+		{"apple", ".*", true, false, false, false},
+		{"红苹果", "苹果", false, true, false, false},
+	}
+	for _, tt := range tests {
+		match := MatchSearchText(tt.text, TokenizeSearchText(tt.query), NormalizeSearchText(tt.query), tt.prefixOnly, false)
+		if matched := len(match.Terms) > 0; matched != tt.matched || match.Exact != tt.exact || match.Prefix != tt.prefix {
+			t.Errorf("MatchSearchText(%q, %q) = terms %v exact %v prefix %v, want matched %v exact %v prefix %v",
+				tt.text, tt.query, match.Terms, match.Exact, match.Prefix, tt.matched, tt.exact, tt.prefix)
+		}
+	}
+}
+
+func TestSearchMatchHighlight(t *testing.T) {
+	tests := []struct{ text, query, want string }{
+		{"Apple pie", "app", "<em>App</em>le pie"},
+		{"<b>apple</b>", "apple", "&lt;b&gt;<em>apple</em>&lt;/b&gt;"},
+		{"a<b", "zzz", "a&lt;b"},
+	}
+	for _, tt := range tests {
+		match := MatchSearchText(tt.text, TokenizeSearchText(tt.query), NormalizeSearchText(tt.query), true, false)
+		if got := match.Highlight(tt.text); got != tt.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", tt.text, tt.query, got, tt.want)
+		}
+	}
+}
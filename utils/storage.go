@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"miniprogram/config"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * 对象存储工具函数
+ * 上传的音频、图片、头像和小程序码等文件通过统一接口保存，可切换本地磁盘、S3 兼容存储或华为云 OBS
+ * 公开文件（媒体资源）直接使用 URL 访问；私有文件（private/ 前缀，如头像、小程序码）只通过有时效的签名地址访问
+ */
+
+var (
+	// ErrObjectNotFound 对象不存在
+	ErrObjectNotFound = errors.New("对象不存在")
+	// ErrInvalidObjectKey 对象键格式错误
+	ErrInvalidObjectKey = errors.New("无效的对象键")
+	// ErrInvalidSignedURL 签名地址无效或已过期
+	ErrInvalidSignedURL = errors.New("签名地址无效或已过期")
+)
+
+// 存储后端类型
+const (
+	StorageBackendLocal = "local"
+	StorageBackendS3    = "s3"
+	StorageBackendOBS   = "obs"
+)
+
+// PrivateObjectPrefix 私有对象键前缀，这些对象不通过静态路由公开
+const PrivateObjectPrefix = "private/"
+
+// uploadACL 上传对象时设置的 ACL：私有对象显式设为 private，存储桶开放公共读时也不能匿名访问
+func uploadACL(key string) string {
+	if strings.HasPrefix(key, PrivateObjectPrefix) {
+		return "private"
+	}
+	return ""
+}
+
+// ObjectStorage 对象存储接口
+type ObjectStorage interface {
+	// Put 保存对象，已存在时覆盖
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 读取对象，不存在时返回 ErrObjectNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Exists 检查对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 对象的公开访问地址
+	URL(key string) string
+	// SignedURL 对象的临时访问地址，ttl 后失效
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+var (
+	globalStorage     ObjectStorage
+	globalStorageErr  error
+	globalStorageOnce sync.Once
+)
+
+// GetObjectStorage 获取全局对象存储（首次调用时根据配置初始化）
+func GetObjectStorage() (ObjectStorage, error) {
+	globalStorageOnce.Do(func() {
+		globalStorage, globalStorageErr = NewObjectStorage(config.GetConfig())
+	})
+	return globalStorage, globalStorageErr
+}
+
+// NewObjectStorage 根据配置创建对象存储
+func NewObjectStorage(cfg *config.Config) (ObjectStorage, error) {
+	publicURL := strings.TrimRight(cfg.StoragePublicURL, "/")
+	switch cfg.StorageBackend {
+	case StorageBackendLocal, "":
+		if publicURL == "" {
+			publicURL = strings.TrimRight(cfg.BaseAPIURL, "/")
+		}
+		if cfg.StorageSigningSecret == "" {
+			return nil, fmt.Errorf("本地存储未配置 STORAGE_SIGNING_SECRET")
+		}
+		return &LocalStorage{
+			Dir:           cfg.StorageLocalDir,
+			PublicURL:     publicURL,
+			SignedBaseURL: strings.TrimRight(cfg.BaseAPIURL, "/") + "/files",
+			SigningSecret: cfg.StorageSigningSecret,
+		}, nil
+	case StorageBackendS3:
+		return NewS3Storage(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+			PublicURL: publicURL,
+		})
+	case StorageBackendOBS:
+		if publicURL == "" {
+			publicURL = strings.TrimRight(cfg.OBSURL, "/")
+		}
+		return NewOBSStorage(OBSConfig{
+			Endpoint:  cfg.OBSEndpoint,
+			Bucket:    cfg.OBSBucket,
+			AccessKey: cfg.OBSAccessKey,
+			SecretKey: cfg.OBSSecretKey,
+			PublicURL: publicURL,
+		})
+	default:
+		return nil, fmt.Errorf("不支持的存储后端: %s", cfg.StorageBackend)
+	}
+}
+
+// ValidateObjectKey 校验对象键：只允许字母、数字和 /._-，不能包含 .. 或以 / 开头
+func ValidateObjectKey(key string) error {
+	if key == "" || len(key) > 512 || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return ErrInvalidObjectKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidObjectKey
+		}
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '/' || r == '.' || r == '_' || r == '-':
+		default:
+			return ErrInvalidObjectKey
+		}
+	}
+	return nil
+}
+
+// ObjectKeyFromURL 从公开访问地址解析对象键，不属于该存储时返回 false
+func ObjectKeyFromURL(storage ObjectStorage, rawURL string) (string, bool) {
+	prefix := storage.URL("")
+	if prefix == "" || !strings.HasPrefix(rawURL, prefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(rawURL, prefix)
+	if i := strings.IndexAny(key, "?#"); i >= 0 {
+		key = key[:i]
+	}
+	if ValidateObjectKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// LocalStorage 本地磁盘存储，公开对象通过静态文件路由提供，私有对象通过签名文件路由提供
+type LocalStorage struct {
+	Dir           string // 存储根目录
+	PublicURL     string // 公开访问地址前缀
+	SignedBaseURL string // 签名文件路由地址前缀
+	SigningSecret string // 签名密钥
+}
+
+// FilePath 对象键对应的磁盘路径
+func (s *LocalStorage) FilePath(key string) (string, error) {
+	if err := ValidateObjectKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put 写入临时文件后重命名，避免读取到写了一半的文件
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	fullPath, err := s.FilePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("设置文件权限失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return fmt.Errorf("保存文件失败: %w", err)
+	}
+	return nil
+}
+
+// Get 读取对象
+func (s *LocalStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	fullPath, err := s.FilePath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fullPath)
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+// Exists 检查对象是否存在
+func (s *LocalStorage) Exists(ctx context.Context, key string) (bool, error) {
+	fullPath, err := s.FilePath(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// Delete 删除对象
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	fullPath, err := s.FilePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// URL 对象的公开访问地址
+func (s *LocalStorage) URL(key string) string {
+	return s.PublicURL + "/" + key
+}
+
+// SignedURL 生成签名文件路由地址：/files/<key>?expires=<unix>&signature=<hmac>
+func (s *LocalStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	if err := ValidateObjectKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.signature(key, expires))
+	return s.SignedBaseURL + "/" + key + "?" + query.Encode(), nil
+}
+
+// VerifySignedURL 校验签名文件路由的参数
+func (s *LocalStorage) VerifySignedURL(key, expires, signature string) error {
+	if ValidateObjectKey(key) != nil {
+		return ErrInvalidSignedURL
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidSignedURL
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, expires))) {
+		return ErrInvalidSignedURL
+	}
+	return nil
+}
+
+// signature 计算签名
+func (s *LocalStorage) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.SigningSecret))
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OBSConfig 华为云 OBS 存储配置
+type OBSConfig struct {
+	Endpoint  string // 服务地址，如 https://obs.cn-south-1.myhuaweicloud.com
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicURL string // 公开访问地址前缀，为空时使用存储桶域名
+}
+
+// OBSStorage 华为云 OBS 对象存储（OBS 原生签名）
+type OBSStorage struct {
+	cfg      OBSConfig
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewOBSStorage 创建华为云 OBS 存储
+func NewOBSStorage(cfg OBSConfig) (*OBSStorage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("OBS 存储配置不完整，需要 endpoint、bucket、access key 和 secret key")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("OBS endpoint 格式错误: %s", cfg.Endpoint)
+	}
+	storage := &OBSStorage{cfg: cfg, endpoint: endpoint, client: &http.Client{Timeout: 60 * time.Second}}
+	if storage.cfg.PublicURL == "" {
+		storage.cfg.PublicURL = fmt.Sprintf("%s://%s.%s", endpoint.Scheme, cfg.Bucket, endpoint.Host)
+	}
+	return storage, nil
+}
+
+// objectURL 对象的请求地址（存储桶域名访问）
+func (s *OBSStorage) objectURL(key string) string {
+	return fmt.Sprintf("%s://%s.%s/%s", s.endpoint.Scheme, s.cfg.Bucket, s.endpoint.Host, escapeS3Path(key))
+}
+
+// signature 计算 OBS 签名：Base64(HMAC-SHA1(SK, StringToSign))
+// StringToSign = 方法\nContent-MD5\nContent-Type\n日期或过期时间\n[x-obs-acl:ACL\n]/存储桶/对象键
+func (s *OBSStorage) signature(method, contentType, dateOrExpires, acl, key string) string {
+	canonicalizedHeaders := ""
+	if acl != "" {
+		canonicalizedHeaders = "x-obs-acl:" + acl + "\n"
+	}
+	stringToSign := method + "\n\n" + contentType + "\n" + dateOrExpires + "\n" + canonicalizedHeaders + "/" + s.cfg.Bucket + "/" + escapeS3Path(key)
+	mac := hmac.New(sha1.New, []byte(s.cfg.SecretKey))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// do 发送签名请求
+func (s *OBSStorage) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	if err := ValidateObjectKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", date)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	acl := ""
+	if method == http.MethodPut {
+		if acl = uploadACL(key); acl != "" {
+			req.Header.Set("x-obs-acl", acl)
+		}
+	}
+	req.Header.Set("Authorization", "OBS "+s.cfg.AccessKey+":"+s.signature(method, contentType, date, acl, key))
+	return s.client.Do(req)
+}
+
+// Put 上传对象
+func (s *OBSStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp, "上传对象")
+	}
+	return nil
+}
+
+// Get 下载对象
+func (s *OBSStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, s3Error(resp, "下载对象")
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Exists 检查对象是否存在
+func (s *OBSStorage) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, "")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode/100 == 2:
+		return true, nil
+	default:
+		return false, s3Error(resp, "查询对象")
+	}
+}
+
+// Delete 删除对象
+func (s *OBSStorage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp, "删除对象")
+	}
+	return nil
+}
+
+// URL 对象的公开访问地址
+func (s *OBSStorage) URL(key string) string {
+	return s.cfg.PublicURL + "/" + escapeS3Path(key)
+}
+
+// SignedURL 生成临时授权访问地址（URL 中携带 AccessKeyId、Expires 和 Signature）
+func (s *OBSStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	if err := ValidateObjectKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{}
+	query.Set("AccessKeyId", s.cfg.AccessKey)
+	query.Set("Expires", expires)
+	query.Set("Signature", s.signature(http.MethodGet, "", expires, "", key))
+	return s.objectURL(key) + "?" + query.Encode(), nil
+}
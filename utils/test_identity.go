@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+)
+
+/**
+ * 测试身份签名工具
+ * 测试身份以签名夹具的形式下发：openID + 过期时间戳，使用 TEST_IDENTITY_SECRET 做 HMAC-SHA256 签名
+ */
+
+// SignTestIdentity 为测试身份生成签名
+func SignTestIdentity(secret, openID string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(openID + "|" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyTestIdentity 校验测试身份签名及有效期
+func VerifyTestIdentity(secret, openID string, expiresAt int64, signature string) error {
+	if secret == "" {
+		return errors.New("未配置测试身份签名密钥")
+	}
+
+	if GetCurrentUTCTime().Unix() > expiresAt {
+		return errors.New("测试身份已过期")
+	}
+
+	expected := SignTestIdentity(secret, openID, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("测试身份签名无效")
+	}
+
+	return nil
+}
@@ -81,3 +81,16 @@ func GetCurrentMonthEndUTC() time.Time {
 func ParseDateString(dateStr string) (time.Time, error) {
 	return time.Parse("2006-01-02", dateStr)
 }
+
+// FormatAppDate 按应用时区格式化日期（YYYY-MM-DD），用于按自然日统计
+func FormatAppDate(t time.Time) string {
+	return ConvertToAppTimeZone(t).Format("2006-01-02")
+}
+
+// ParseAppDate 按应用时区解析日期字符串（YYYY-MM-DD），返回当天零点
+func ParseAppDate(dateStr string) (time.Time, error) {
+	if AppTimeZone == nil {
+		InitTimeZone()
+	}
+	return time.ParseInLocation("2006-01-02", dateStr, AppTimeZone)
+}
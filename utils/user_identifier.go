@@ -3,18 +3,23 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"miniprogram/config"
+	"strings"
 )
 
+// UserIDPrefix 安全用户标识符前缀
+const UserIDPrefix = "uid_"
+
 // UserIdentifierManager 用户标识符管理器
 type UserIdentifierManager struct {
-	gcm cipher.AEAD
+	gcm      cipher.AEAD
+	nonceKey []byte // 用于派生确定性nonce的HMAC密钥
 }
 
 // NewUserIdentifierManager 创建用户标识符管理器实例
@@ -39,29 +44,32 @@ func NewUserIdentifierManager() (*UserIdentifierManager, error) {
 		return nil, fmt.Errorf("创建GCM模式失败: %v", err)
 	}
 
-	return &UserIdentifierManager{gcm: gcm}, nil
+	// 派生独立的nonce密钥，使同一openID总是得到相同的标识符
+	nonceHash := sha256.Sum256([]byte("uid-nonce|" + secretKey))
+
+	return &UserIdentifierManager{gcm: gcm, nonceKey: nonceHash[:]}, nil
 }
 
 // EncodeOpenID 将openID编码为安全的用户标识符
+// nonce 由 HMAC(openID) 派生，同一openID的标识符稳定不变，可用于URL和客户端缓存
 func (m *UserIdentifierManager) EncodeOpenID(openID string) (string, error) {
 	if openID == "" {
 		return "", errors.New("openID不能为空")
 	}
 
-	// 生成随机nonce
-	nonce := make([]byte, m.gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("生成nonce失败: %v", err)
-	}
+	// 派生确定性nonce
+	mac := hmac.New(sha256.New, m.nonceKey)
+	mac.Write([]byte(openID))
+	nonce := mac.Sum(nil)[:m.gcm.NonceSize()]
 
 	// 加密openID
 	ciphertext := m.gcm.Seal(nonce, nonce, []byte(openID), nil)
 
-	// Base64编码
-	encoded := base64.StdEncoding.EncodeToString(ciphertext)
+	// URL安全的Base64编码（不含 / 和 +，可直接放入路径参数）
+	encoded := base64.RawURLEncoding.EncodeToString(ciphertext)
 
 	// 添加前缀标识
-	return "uid_" + encoded, nil
+	return UserIDPrefix + encoded, nil
 }
 
 // DecodeUserID 将用户标识符解码为openID
@@ -71,17 +79,20 @@ func (m *UserIdentifierManager) DecodeUserID(userID string) (string, error) {
 	}
 
 	// 检查前缀
-	if len(userID) < 4 || userID[:4] != "uid_" {
+	if !IsSafeUserID(userID) {
 		return "", errors.New("无效的用户标识符格式")
 	}
 
 	// 去掉前缀
-	encoded := userID[4:]
+	encoded := userID[len(UserIDPrefix):]
 
-	// Base64解码
-	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	// Base64解码（兼容早期使用标准Base64编码的标识符）
+	ciphertext, err := base64.RawURLEncoding.DecodeString(encoded)
 	if err != nil {
-		return "", fmt.Errorf("Base64解码失败: %v", err)
+		ciphertext, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("Base64解码失败: %v", err)
+		}
 	}
 
 	// 检查密文长度
@@ -112,7 +123,7 @@ func (m *UserIdentifierManager) GetSafeUserID(openID string) string {
 	if err != nil {
 		// 如果编码失败，返回一个基于openID的hash值作为fallback
 		hash := sha256.Sum256([]byte(openID))
-		return "uid_" + base64.StdEncoding.EncodeToString(hash[:16])
+		return UserIDPrefix + base64.RawURLEncoding.EncodeToString(hash[:16])
 	}
 
 	return encoded
@@ -124,7 +135,7 @@ func (m *UserIdentifierManager) ValidateUserID(userID string) bool {
 		return false
 	}
 
-	if len(userID) < 4 || userID[:4] != "uid_" {
+	if !IsSafeUserID(userID) {
 		return false
 	}
 
@@ -165,3 +176,24 @@ func DecodeSafeIDToOpenID(safeID string) (string, error) {
 func ValidateSafeUserID(safeID string) bool {
 	return GetUserIDManager().ValidateUserID(safeID)
 }
+
+// IsSafeUserID 判断是否为安全用户标识符格式（仅检查前缀）
+func IsSafeUserID(userID string) bool {
+	return strings.HasPrefix(userID, UserIDPrefix)
+}
+
+// ResolveUserID 将路径或请求体中的用户标识解析为openID
+// 安全标识符会被解码；其他值视为原始openID，按原样返回（兼容旧客户端）
+func ResolveUserID(userID string) (string, error) {
+	if !IsSafeUserID(userID) {
+		return userID, nil
+	}
+	return DecodeSafeIDToOpenID(userID)
+}
+
+// AnonymousUserID 生成注销用户的匿名标识（不可逆，用于保留财务记录时替换openID）
+func AnonymousUserID(openID string) string {
+	mac := hmac.New(sha256.New, []byte(config.GetConfig().UserIDSecretKey))
+	mac.Write([]byte("deleted-user|" + openID))
+	return "deleted_" + hex.EncodeToString(mac.Sum(nil))[:24]
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"miniprogram/config"
+)
+
+/**
+ * 微信加密数据工具
+ * 1. session_key 在服务端使用 AES-256-GCM 加密存储
+ * 2. 小程序 encryptedData 使用 session_key 做 AES-128-CBC 解密（PKCS#7 填充）
+ */
+
+// newSessionKeyGCM 根据配置的会话密钥创建 GCM 加密器
+func newSessionKeyGCM() (cipher.AEAD, error) {
+	secret := config.GetConfig().WechatSessionSecret
+	if secret == "" {
+		return nil, fmt.Errorf("未配置 WECHAT_SESSION_SECRET")
+	}
+	hash := sha256.Sum256([]byte(secret))
+
+	block, err := aes.NewCipher(hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("创建AES加密器失败: %v", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建GCM模式失败: %v", err)
+	}
+	return gcm, nil
+}
+
+// EncryptSessionKey 加密 session_key 用于存储
+func EncryptSessionKey(sessionKey string) (string, error) {
+	if sessionKey == "" {
+		return "", errors.New("session_key不能为空")
+	}
+
+	gcm, err := newSessionKeyGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("生成nonce失败: %v", err)
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, []byte(sessionKey), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptSessionKey 解密存储的 session_key
+func DecryptSessionKey(encrypted string) (string, error) {
+	gcm, err := newSessionKeyGCM()
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("Base64解码失败: %v", err)
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", errors.New("密文长度不足")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %v", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// DecryptWechatData 使用 session_key 解密小程序 encryptedData（AES-128-CBC）
+func DecryptWechatData(sessionKey, encryptedData, iv string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil || len(key) != aes.BlockSize {
+		return nil, errors.New("session_key格式无效")
+	}
+
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil || len(ivBytes) != aes.BlockSize {
+		return nil, errors.New("iv格式无效")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("encryptedData格式无效")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES加密器失败: %v", err)
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plaintext, ciphertext)
+
+	return pkcs7Unpad(plaintext)
+}
+
+// pkcs7Unpad 去除 PKCS#7 填充
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	length := len(data)
+	if length == 0 {
+		return nil, errors.New("解密数据为空")
+	}
+
+	padding := int(data[length-1])
+	if padding == 0 || padding > aes.BlockSize || padding > length {
+		return nil, errors.New("解密数据填充无效")
+	}
+	if !bytes.Equal(data[length-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("解密数据填充无效")
+	}
+
+	return data[:length-padding], nil
+}